@@ -56,6 +56,9 @@ func (h *AIHandler) OptimizePrompt(c *gin.Context) {
 
 	prompt, err := h.aiService.OptimizeImagePrompt(req.Prompt, req.Protected)
 	if err != nil {
+		if respondBudgetExceeded(c, err) {
+			return
+		}
 		h.log.Errorw("Failed to optimize prompt", "error", err)
 		response.InternalError(c, "Failed to optimize prompt: "+err.Error())
 		return
@@ -63,3 +66,15 @@ func (h *AIHandler) OptimizePrompt(c *gin.Context) {
 
 	response.Success(c, gin.H{"prompt": prompt})
 }
+
+// ClearCache 清空文本响应缓存
+func (h *AIHandler) ClearCache(c *gin.Context) {
+	deleted, err := h.aiService.GetLLMCache().Clear()
+	if err != nil {
+		h.log.Errorw("Failed to clear LLM cache", "error", err)
+		response.InternalError(c, "清空缓存失败")
+		return
+	}
+
+	response.Success(c, gin.H{"deleted": deleted})
+}
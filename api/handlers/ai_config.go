@@ -32,6 +32,10 @@ func (h *AIConfigHandler) CreateConfig(c *gin.Context) {
 
 	config, err := h.aiService.CreateConfig(&req)
 	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
 		response.InternalError(c, "创建失败")
 		return
 	}
@@ -106,6 +110,10 @@ func (h *AIConfigHandler) UpdateConfig(c *gin.Context) {
 			response.NotFound(c, "配置不存在")
 			return
 		}
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
 		response.InternalError(c, "更新失败")
 		return
 	}
@@ -147,3 +155,24 @@ func (h *AIConfigHandler) TestConnection(c *gin.Context) {
 
 	response.Success(c, gin.H{"message": "连接测试成功"})
 }
+
+// ListModels 获取服务端可用的模型列表
+func (h *AIConfigHandler) ListModels(c *gin.Context) {
+	var req services.ListModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	modelNames, err := h.aiService.ListModels(&req)
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		response.BadRequest(c, "获取模型列表失败: "+err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"models": modelNames})
+}
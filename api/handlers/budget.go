@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/drama-generator/backend/application/services"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BudgetHandler struct {
+	budgetService *services.BudgetService
+	log           *logger.Logger
+}
+
+func NewBudgetHandler(db *gorm.DB, log *logger.Logger) *BudgetHandler {
+	return &BudgetHandler{
+		budgetService: services.NewBudgetService(db, log),
+		log:           log,
+	}
+}
+
+// respondBudgetExceeded 预算用尽时返回 402 BUDGET_EXCEEDED，返回 true 表示已写入响应
+func respondBudgetExceeded(c *gin.Context, err error) bool {
+	budgetErr, ok := services.IsBudgetExceededError(err)
+	if !ok {
+		return false
+	}
+	response.ErrorWithDetails(c, http.StatusPaymentRequired, "BUDGET_EXCEEDED", "预算已用尽，已阻止新的生成任务", budgetErr)
+	return true
+}
+
+// ListBudgets 列出预算及当前消耗，可按 drama_id 过滤
+func (h *BudgetHandler) ListBudgets(c *gin.Context) {
+	var dramaID *uint
+	if raw := c.Query("drama_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			response.BadRequest(c, "无效的剧本ID")
+			return
+		}
+		value := uint(id)
+		dramaID = &value
+	}
+
+	statuses, err := h.budgetService.ListStatuses(dramaID)
+	if err != nil {
+		h.log.Errorw("Failed to list budgets", "error", err)
+		response.InternalError(c, "获取预算失败")
+		return
+	}
+
+	response.Success(c, statuses)
+}
+
+func (h *BudgetHandler) CreateBudget(c *gin.Context) {
+	var req services.BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	budget, err := h.budgetService.CreateBudget(&req)
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		h.log.Errorw("Failed to create budget", "error", err)
+		response.InternalError(c, "创建失败")
+		return
+	}
+
+	response.Created(c, budget)
+}
+
+func (h *BudgetHandler) UpdateBudget(c *gin.Context) {
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的预算ID")
+		return
+	}
+
+	var req services.BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	budget, err := h.budgetService.UpdateBudget(uint(budgetID), &req)
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		if err.Error() == "budget not found" {
+			response.NotFound(c, "预算不存在")
+			return
+		}
+		response.InternalError(c, "更新失败")
+		return
+	}
+
+	response.Success(c, budget)
+}
+
+func (h *BudgetHandler) DeleteBudget(c *gin.Context) {
+	budgetID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的预算ID")
+		return
+	}
+
+	if err := h.budgetService.DeleteBudget(uint(budgetID)); err != nil {
+		if err.Error() == "budget not found" {
+			response.NotFound(c, "预算不存在")
+			return
+		}
+		response.InternalError(c, "删除失败")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/drama-generator/backend/application/services"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CharacterConsistencyHandler struct {
+	consistencyService *services.CharacterConsistencyService
+	log                *logger.Logger
+}
+
+func NewCharacterConsistencyHandler(db *gorm.DB, log *logger.Logger, aiService *services.AIService, imageService *services.ImageGenerationService) *CharacterConsistencyHandler {
+	return &CharacterConsistencyHandler{
+		consistencyService: services.NewCharacterConsistencyService(db, aiService, services.NewTaskService(db, log), imageService, log),
+		log:                log,
+	}
+}
+
+// StartAudit 审查一集分镜图片中的角色一致性（异步）
+func (h *CharacterConsistencyHandler) StartAudit(c *gin.Context) {
+	episodeID, err := strconv.ParseUint(c.Param("episode_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid episode_id")
+		return
+	}
+
+	taskID, err := h.consistencyService.StartAudit(uint(episodeID))
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		if err.Error() == "episode not found" {
+			response.NotFound(c, err.Error())
+			return
+		}
+		h.log.Errorw("Failed to start consistency audit", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"task_id": taskID, "message": "角色一致性审查任务已提交"})
+}
+
+// GetAuditResults 获取最近一次审查结果，inconsistent_only=true 时只返回不一致的记录
+func (h *CharacterConsistencyHandler) GetAuditResults(c *gin.Context) {
+	episodeID, err := strconv.ParseUint(c.Param("episode_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid episode_id")
+		return
+	}
+
+	inconsistentOnly, _ := strconv.ParseBool(c.Query("inconsistent_only"))
+	checks, err := h.consistencyService.GetAuditResults(uint(episodeID), inconsistentOnly)
+	if err != nil {
+		h.log.Errorw("Failed to get consistency audit results", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, checks)
+}
+
+// RegenerateStoryboard 以角色设定图为参考重新生成分镜图片
+func (h *CharacterConsistencyHandler) RegenerateStoryboard(c *gin.Context) {
+	storyboardID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	var req struct {
+		CharacterIDs []uint `json:"character_ids"`
+	}
+	// 请求体可选，未指定角色时使用审查结果
+	_ = c.ShouldBindJSON(&req)
+
+	imageGen, err := h.consistencyService.RegenerateStoryboard(uint(storyboardID), req.CharacterIDs)
+	if err != nil {
+		if respondBudgetExceeded(c, err) {
+			return
+		}
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		if err.Error() == "storyboard not found" {
+			response.NotFound(c, err.Error())
+			return
+		}
+		h.log.Errorw("Failed to regenerate storyboard image", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, imageGen)
+}
@@ -277,7 +277,8 @@ func (h *CharacterLibraryHandler) ExtractCharacters(c *gin.Context) {
 		return
 	}
 
-	taskID, err := h.libraryService.ExtractCharactersFromScript(uint(episodeID))
+	bypassCache, _ := strconv.ParseBool(c.Query("bypass_cache"))
+	taskID, err := h.libraryService.ExtractCharactersFromScript(uint(episodeID), bypassCache)
 	if err != nil {
 		h.log.Errorw("Failed to extract characters", "error", err)
 		response.InternalError(c, err.Error())
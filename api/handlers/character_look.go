@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/drama-generator/backend/application/services"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CharacterLookHandler struct {
+	lookService *services.CharacterLookService
+	log         *logger.Logger
+}
+
+func NewCharacterLookHandler(db *gorm.DB, log *logger.Logger) *CharacterLookHandler {
+	return &CharacterLookHandler{
+		lookService: services.NewCharacterLookService(db, log),
+		log:         log,
+	}
+}
+
+// ListLooks 获取角色的造型列表
+func (h *CharacterLookHandler) ListLooks(c *gin.Context) {
+	characterID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	looks, err := h.lookService.ListLooks(uint(characterID))
+	h.respond(c, looks, err)
+}
+
+// CreateLook 为角色新增造型
+func (h *CharacterLookHandler) CreateLook(c *gin.Context) {
+	characterID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	var req services.CharacterLookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	look, err := h.lookService.CreateLook(uint(characterID), &req)
+	h.respond(c, look, err)
+}
+
+// UpdateLook 更新造型
+func (h *CharacterLookHandler) UpdateLook(c *gin.Context) {
+	lookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	var req services.CharacterLookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	look, err := h.lookService.UpdateLook(uint(lookID), &req)
+	h.respond(c, look, err)
+}
+
+// DeleteLook 删除造型
+func (h *CharacterLookHandler) DeleteLook(c *gin.Context) {
+	lookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	err = h.lookService.DeleteLook(uint(lookID))
+	h.respond(c, gin.H{"message": "删除成功"}, err)
+}
+
+// AssignLook 指定造型在章节或分镜上生效
+func (h *CharacterLookHandler) AssignLook(c *gin.Context) {
+	lookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	var req services.AssignCharacterLookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	assignment, err := h.lookService.AssignLook(uint(lookID), &req)
+	h.respond(c, assignment, err)
+}
+
+// RemoveAssignment 取消造型的生效范围
+func (h *CharacterLookHandler) RemoveAssignment(c *gin.Context) {
+	assignmentID, err := strconv.ParseUint(c.Param("assignment_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	err = h.lookService.RemoveAssignment(uint(assignmentID))
+	h.respond(c, gin.H{"message": "已取消"}, err)
+}
+
+// ListEpisodeAssignments 获取章节及其分镜上的造型指定
+func (h *CharacterLookHandler) ListEpisodeAssignments(c *gin.Context) {
+	episodeID, err := strconv.ParseUint(c.Param("episode_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	assignments, err := h.lookService.ListEpisodeAssignments(uint(episodeID))
+	h.respond(c, assignments, err)
+}
+
+func (h *CharacterLookHandler) respond(c *gin.Context, data interface{}, err error) {
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		switch err.Error() {
+		case "character not found", "look not found", "assignment not found", "episode not found", "storyboard not found":
+			response.NotFound(c, err.Error())
+			return
+		}
+		h.log.Errorw("Character look operation failed", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, data)
+}
@@ -104,6 +104,10 @@ func (h *DramaHandler) UpdateDrama(c *gin.Context) {
 
 	drama, err := h.dramaService.UpdateDrama(dramaID, &req)
 	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
 		if err.Error() == "drama not found" {
 			response.NotFound(c, "剧本不存在")
 			return
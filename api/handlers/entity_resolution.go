@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/drama-generator/backend/application/services"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type EntityResolutionHandler struct {
+	resolutionService *services.EntityResolutionService
+	log               *logger.Logger
+}
+
+func NewEntityResolutionHandler(db *gorm.DB, log *logger.Logger, aiService *services.AIService) *EntityResolutionHandler {
+	return &EntityResolutionHandler{
+		resolutionService: services.NewEntityResolutionService(db, aiService, services.NewTaskService(db, log), log),
+		log:               log,
+	}
+}
+
+// ListAliases 获取剧本中角色、道具、场景的别名，可按 entity_type 过滤
+func (h *EntityResolutionHandler) ListAliases(c *gin.Context) {
+	dramaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	aliases, err := h.resolutionService.ListAliases(uint(dramaID), c.Query("entity_type"))
+	h.respond(c, aliases, err)
+}
+
+// AddAlias 为实体添加别名
+func (h *EntityResolutionHandler) AddAlias(c *gin.Context) {
+	var req services.AddEntityAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	alias, err := h.resolutionService.AddAlias(&req)
+	h.respond(c, alias, err)
+}
+
+// DeleteAlias 删除别名
+func (h *EntityResolutionHandler) DeleteAlias(c *gin.Context) {
+	aliasID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	err = h.resolutionService.DeleteAlias(uint(aliasID))
+	h.respond(c, gin.H{"message": "删除成功"}, err)
+}
+
+// ProposeMerges 让大模型找出跨章节重复提取的实体（异步），entity_type 为空时检查全部类型
+func (h *EntityResolutionHandler) ProposeMerges(c *gin.Context) {
+	dramaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	var req struct {
+		EntityType string `json:"entity_type"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	taskID, err := h.resolutionService.ProposeMerges(uint(dramaID), req.EntityType)
+	h.respond(c, gin.H{"task_id": taskID, "message": "合并建议任务已提交"}, err)
+}
+
+// MergeEntities 将重复实体合并到保留的实体
+func (h *EntityResolutionHandler) MergeEntities(c *gin.Context) {
+	var req services.MergeEntitiesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := h.resolutionService.MergeEntities(&req)
+	h.respond(c, result, err)
+}
+
+func (h *EntityResolutionHandler) respond(c *gin.Context, data interface{}, err error) {
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		switch err.Error() {
+		case "drama not found", "entity not found", "alias not found":
+			response.NotFound(c, err.Error())
+			return
+		}
+		h.log.Errorw("Entity resolution operation failed", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, data)
+}
@@ -27,9 +27,10 @@ func (h *FramePromptHandler) GenerateFramePrompt(c *gin.Context) {
 	storyboardID := c.Param("id")
 
 	var req struct {
-		FrameType  string `json:"frame_type"`
-		PanelCount int    `json:"panel_count"`
-		Model      string `json:"model"`
+		FrameType   string `json:"frame_type"`
+		PanelCount  int    `json:"panel_count"`
+		Model       string `json:"model"`
+		BypassCache bool   `json:"bypass_cache"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.BadRequest(c, err.Error())
@@ -40,6 +41,7 @@ func (h *FramePromptHandler) GenerateFramePrompt(c *gin.Context) {
 		StoryboardID: storyboardID,
 		FrameType:    services.FrameType(req.FrameType),
 		PanelCount:   req.PanelCount,
+		BypassCache:  req.BypassCache,
 	}
 
 	// 直接调用服务层的异步方法，该方法会创建任务并返回任务ID
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"io"
+	"net/http"
 	"strconv"
 
 	"github.com/drama-generator/backend/application/services"
@@ -36,8 +38,30 @@ func (h *ImageGenerationHandler) GenerateImage(c *gin.Context) {
 		return
 	}
 
+	// 候选模式：一次生成多张候选图，由用户选定其一
+	if req.Candidates > 1 {
+		candidates, err := h.imageService.GenerateImageCandidates(&req)
+		if err != nil {
+			if respondBudgetExceeded(c, err) {
+				return
+			}
+			if validationErr, ok := services.IsValidationError(err); ok {
+				response.BadRequest(c, validationErr.Message)
+				return
+			}
+			h.log.Errorw("Failed to generate image candidates", "error", err)
+			response.InternalError(c, err.Error())
+			return
+		}
+		response.Success(c, candidates)
+		return
+	}
+
 	imageGen, err := h.imageService.GenerateImage(&req)
 	if err != nil {
+		if respondBudgetExceeded(c, err) {
+			return
+		}
 		h.log.Errorw("Failed to generate image", "error", err)
 		response.InternalError(c, err.Error())
 		return
@@ -46,12 +70,148 @@ func (h *ImageGenerationHandler) GenerateImage(c *gin.Context) {
 	response.Success(c, imageGen)
 }
 
+// SelectStoryboardImage 从候选图中选定分镜图片，同组其余候选图移入回收区
+func (h *ImageGenerationHandler) SelectStoryboardImage(c *gin.Context) {
+	storyboardID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	var req struct {
+		ImageGenID uint `json:"image_gen_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	storyboard, err := h.imageService.SelectStoryboardImage(uint(storyboardID), req.ImageGenID)
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		if err.Error() == "storyboard not found" || err.Error() == "image not found" {
+			response.NotFound(c, err.Error())
+			return
+		}
+		h.log.Errorw("Failed to select storyboard image", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, storyboard)
+}
+
+// ScoreImage 使用视觉模型为图片评分
+func (h *ImageGenerationHandler) ScoreImage(c *gin.Context) {
+	imageGenID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	score, err := h.imageService.ScoreImage(uint(imageGenID))
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		if err.Error() == "image not found" {
+			response.NotFound(c, err.Error())
+			return
+		}
+		h.log.Errorw("Failed to score image", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, score)
+}
+
+// GetCandidateGroup 获取候选组的进度与全部候选图
+func (h *ImageGenerationHandler) GetCandidateGroup(c *gin.Context) {
+	group, images, err := h.imageService.GetCandidateGroup(c.Param("group"))
+	if err != nil {
+		if err.Error() == "candidate group not found" {
+			response.NotFound(c, err.Error())
+			return
+		}
+		h.log.Errorw("Failed to get candidate group", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{
+		"group":  group,
+		"images": images,
+	})
+}
+
+// AutoSelectCandidates 为候选组评分并自动选出最佳候选（异步）
+func (h *ImageGenerationHandler) AutoSelectCandidates(c *gin.Context) {
+	var req struct {
+		MinScore *float64 `json:"min_score"`
+	}
+	// 请求体可选
+	_ = c.ShouldBindJSON(&req)
+
+	group, err := h.imageService.AutoSelectCandidates(c.Param("group"), req.MinScore)
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		if err.Error() == "candidate group not found" {
+			response.NotFound(c, err.Error())
+			return
+		}
+		h.log.Errorw("Failed to auto-select candidates", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, group)
+}
+
+// ListDiscardedImages 查看回收区中的落选候选图
+func (h *ImageGenerationHandler) ListDiscardedImages(c *gin.Context) {
+	var dramaID *uint
+	if dramaIDStr := c.Query("drama_id"); dramaIDStr != "" {
+		if id, err := strconv.ParseUint(dramaIDStr, 10, 32); err == nil {
+			uid := uint(id)
+			dramaID = &uid
+		}
+	}
+
+	var storyboardID *uint
+	if storyboardIDStr := c.Query("storyboard_id"); storyboardIDStr != "" {
+		if id, err := strconv.ParseUint(storyboardIDStr, 10, 32); err == nil {
+			uid := uint(id)
+			storyboardID = &uid
+		}
+	}
+
+	images, err := h.imageService.ListDiscardedImages(dramaID, storyboardID)
+	if err != nil {
+		h.log.Errorw("Failed to list discarded images", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, images)
+}
+
 func (h *ImageGenerationHandler) GenerateImagesForScene(c *gin.Context) {
 
 	sceneID := c.Param("scene_id")
 
 	images, err := h.imageService.GenerateImagesForScene(sceneID)
 	if err != nil {
+		if respondBudgetExceeded(c, err) {
+			return
+		}
 		h.log.Errorw("Failed to generate images for scene", "error", err)
 		response.InternalError(c, err.Error())
 		return
@@ -79,8 +239,9 @@ func (h *ImageGenerationHandler) ExtractBackgroundsForEpisode(c *gin.Context) {
 
 	// 接收可选的 model 和 style 参数
 	var req struct {
-		Model string `json:"model"`
-		Style string `json:"style"`
+		Model       string `json:"model"`
+		Style       string `json:"style"`
+		BypassCache bool   `json:"bypass_cache"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		// 如果没有提供body或者解析失败，使用空字符串（使用默认模型和风格）
@@ -93,7 +254,7 @@ func (h *ImageGenerationHandler) ExtractBackgroundsForEpisode(c *gin.Context) {
 	}
 
 	// 直接调用服务层的异步方法，该方法会创建任务并返回任务ID
-	taskID, err := h.imageService.ExtractBackgroundsForEpisode(episodeID, req.Model, req.Style)
+	taskID, err := h.imageService.ExtractBackgroundsForEpisode(episodeID, req.Model, req.Style, req.BypassCache)
 	if err != nil {
 		h.log.Errorw("Failed to extract backgrounds", "error", err, "episode_id", episodeID)
 		response.InternalError(c, err.Error())
@@ -114,6 +275,9 @@ func (h *ImageGenerationHandler) BatchGenerateForEpisode(c *gin.Context) {
 
 	images, err := h.imageService.BatchGenerateImagesForEpisode(episodeID)
 	if err != nil {
+		if respondBudgetExceeded(c, err) {
+			return
+		}
 		h.log.Errorw("Failed to batch generate images", "error", err)
 		response.InternalError(c, err.Error())
 		return
@@ -236,3 +400,29 @@ func (h *ImageGenerationHandler) UploadImage(c *gin.Context) {
 
 	response.Success(c, imageGen)
 }
+
+// ProviderCallback 图片服务商任务回调，响应体直接返回（不使用统一响应格式）
+func (h *ImageGenerationHandler) ProviderCallback(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		response.BadRequest(c, "读取回调内容失败")
+		return
+	}
+
+	result, err := h.imageService.HandleProviderCallback(c.Param("provider"), c.Param("token"), body)
+	if err != nil {
+		if err.Error() == "callback not found" {
+			response.NotFound(c, "回调不存在")
+			return
+		}
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		h.log.Errorw("Failed to handle provider callback", "error", err, "provider", c.Param("provider"))
+		response.InternalError(c, "处理回调失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
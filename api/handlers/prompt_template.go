@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/drama-generator/backend/application/services"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PromptTemplateHandler struct {
+	templateService *services.PromptTemplateService
+	log             *logger.Logger
+}
+
+func NewPromptTemplateHandler(db *gorm.DB, log *logger.Logger) *PromptTemplateHandler {
+	return &PromptTemplateHandler{
+		templateService: services.NewPromptTemplateService(db, log),
+		log:             log,
+	}
+}
+
+// ListKeys 列出可配置的模板键及可用变量
+func (h *PromptTemplateHandler) ListKeys(c *gin.Context) {
+	response.Success(c, h.templateService.ListKeys())
+}
+
+// ListTemplates 列出模板版本，可按 key、language、drama_id 过滤
+func (h *PromptTemplateHandler) ListTemplates(c *gin.Context) {
+	filter := services.PromptTemplateFilter{
+		Key:      c.Query("key"),
+		Language: c.Query("language"),
+	}
+	if raw := c.Query("drama_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			response.BadRequest(c, "无效的剧本ID")
+			return
+		}
+		value := uint(id)
+		filter.DramaID = &value
+	}
+
+	templates, err := h.templateService.ListTemplates(filter)
+	if err != nil {
+		h.log.Errorw("Failed to list prompt templates", "error", err)
+		response.InternalError(c, "获取提示词模板失败")
+		return
+	}
+
+	response.Success(c, templates)
+}
+
+func (h *PromptTemplateHandler) GetTemplate(c *gin.Context) {
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的模板ID")
+		return
+	}
+
+	template, err := h.templateService.GetTemplate(uint(templateID))
+	if err != nil {
+		if err.Error() == "template not found" {
+			response.NotFound(c, "模板不存在")
+			return
+		}
+		response.InternalError(c, "获取失败")
+		return
+	}
+
+	response.Success(c, template)
+}
+
+func (h *PromptTemplateHandler) CreateTemplate(c *gin.Context) {
+	var req services.CreatePromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	template, err := h.templateService.CreateTemplate(&req)
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		h.log.Errorw("Failed to create prompt template", "error", err)
+		response.InternalError(c, "创建失败")
+		return
+	}
+
+	response.Created(c, template)
+}
+
+func (h *PromptTemplateHandler) UpdateTemplate(c *gin.Context) {
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的模板ID")
+		return
+	}
+
+	var req services.UpdatePromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	template, err := h.templateService.UpdateTemplate(uint(templateID), &req)
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		if err.Error() == "template not found" {
+			response.NotFound(c, "模板不存在")
+			return
+		}
+		h.log.Errorw("Failed to update prompt template", "error", err)
+		response.InternalError(c, "更新失败")
+		return
+	}
+
+	response.Success(c, template)
+}
+
+func (h *PromptTemplateHandler) DeleteTemplate(c *gin.Context) {
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的模板ID")
+		return
+	}
+
+	if err := h.templateService.DeleteTemplate(uint(templateID)); err != nil {
+		if err.Error() == "template not found" {
+			response.NotFound(c, "模板不存在")
+			return
+		}
+		response.InternalError(c, "删除失败")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
+
+// ActivateTemplate 启用指定版本
+func (h *PromptTemplateHandler) ActivateTemplate(c *gin.Context) {
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的模板ID")
+		return
+	}
+
+	template, err := h.templateService.ActivateTemplate(uint(templateID))
+	if err != nil {
+		if err.Error() == "template not found" {
+			response.NotFound(c, "模板不存在")
+			return
+		}
+		h.log.Errorw("Failed to activate prompt template", "error", err)
+		response.InternalError(c, "启用失败")
+		return
+	}
+
+	response.Success(c, template)
+}
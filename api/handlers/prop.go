@@ -147,7 +147,8 @@ func (h *PropHandler) ExtractProps(c *gin.Context) {
 		return
 	}
 
-	taskID, err := h.propService.ExtractPropsFromScript(uint(episodeID))
+	bypassCache, _ := strconv.ParseBool(c.Query("bypass_cache"))
+	taskID, err := h.propService.ExtractPropsFromScript(uint(episodeID), bypassCache)
 	if err != nil {
 		response.InternalError(c, err.Error())
 		return
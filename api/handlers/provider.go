@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/drama-generator/backend/application/services"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ProviderHandler struct {
+	capabilityService *services.ProviderCapabilityService
+	log               *logger.Logger
+}
+
+func NewProviderHandler(db *gorm.DB, log *logger.Logger) *ProviderHandler {
+	return &ProviderHandler{
+		capabilityService: services.NewProviderCapabilityService(db, log),
+		log:               log,
+	}
+}
+
+// ListProviders 列出服务商及其能力配置，供前端渲染可选参数
+func (h *ProviderHandler) ListProviders(c *gin.Context) {
+	serviceType := c.Query("service_type")
+	if serviceType != "" && serviceType != "text" && serviceType != "image" && serviceType != "video" {
+		response.BadRequest(c, "service_type must be one of text, image, video")
+		return
+	}
+
+	providers, err := h.capabilityService.ListProviders(serviceType)
+	if err != nil {
+		h.log.Errorw("Failed to list providers", "error", err)
+		response.InternalError(c, "获取服务商列表失败")
+		return
+	}
+
+	response.Success(c, providers)
+}
+
+// GetCapabilities 获取指定服务商（及模型）合并后的能力
+func (h *ProviderHandler) GetCapabilities(c *gin.Context) {
+	serviceType := c.Query("service_type")
+	provider := c.Query("provider")
+	if serviceType == "" || provider == "" {
+		response.BadRequest(c, "service_type 和 provider 不能为空")
+		return
+	}
+
+	caps, err := h.capabilityService.GetCapabilities(serviceType, provider, c.Query("model"))
+	if err != nil {
+		h.log.Errorw("Failed to get provider capabilities", "error", err, "provider", provider)
+		response.InternalError(c, "获取服务商能力失败")
+		return
+	}
+	if caps == nil {
+		response.NotFound(c, "未登记该服务商的能力配置")
+		return
+	}
+
+	response.Success(c, caps)
+}
+
+func (h *ProviderHandler) UpdateProvider(c *gin.Context) {
+	providerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的服务商ID")
+		return
+	}
+
+	var req services.UpdateProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	provider, err := h.capabilityService.UpdateProvider(uint(providerID), &req)
+	if err != nil {
+		if err.Error() == "provider not found" {
+			response.NotFound(c, "服务商不存在")
+			return
+		}
+		h.log.Errorw("Failed to update provider", "error", err)
+		response.InternalError(c, "更新失败")
+		return
+	}
+
+	response.Success(c, provider)
+}
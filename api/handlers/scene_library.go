@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	services2 "github.com/drama-generator/backend/application/services"
+	"github.com/drama-generator/backend/infrastructure/storage"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SceneLibraryHandler struct {
+	libraryService *services2.SceneLibraryService
+	log            *logger.Logger
+}
+
+func NewSceneLibraryHandler(db *gorm.DB, cfg *config.Config, log *logger.Logger, localStorage *storage.LocalStorage) *SceneLibraryHandler {
+	return &SceneLibraryHandler{
+		libraryService: services2.NewSceneLibraryService(db, cfg, localStorage, log),
+		log:            log,
+	}
+}
+
+// ListLibraryItems 获取场景库列表，支持按分类、标签、来源与关键词筛选
+func (h *SceneLibraryHandler) ListLibraryItems(c *gin.Context) {
+	var query services2.SceneLibraryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	items, total, err := h.libraryService.ListLibraryItems(&query)
+	if err != nil {
+		h.log.Errorw("Failed to list scene library items", "error", err)
+		response.InternalError(c, "获取场景库失败")
+		return
+	}
+
+	response.SuccessWithPagination(c, items, total, query.Page, query.PageSize)
+}
+
+// ListCategories 获取场景库分类
+func (h *SceneLibraryHandler) ListCategories(c *gin.Context) {
+	categories, err := h.libraryService.ListCategories()
+	if err != nil {
+		h.log.Errorw("Failed to list scene library categories", "error", err)
+		response.InternalError(c, "获取分类失败")
+		return
+	}
+
+	response.Success(c, categories)
+}
+
+// CreateLibraryItem 添加到场景库
+func (h *SceneLibraryHandler) CreateLibraryItem(c *gin.Context) {
+	var req services2.CreateSceneLibraryItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	item, err := h.libraryService.CreateLibraryItem(&req)
+	if err != nil {
+		if validationErr, ok := services2.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		h.log.Errorw("Failed to create scene library item", "error", err)
+		response.InternalError(c, "添加到场景库失败")
+		return
+	}
+
+	response.Created(c, item)
+}
+
+// GetLibraryItem 获取场景库项详情
+func (h *SceneLibraryHandler) GetLibraryItem(c *gin.Context) {
+	item, err := h.libraryService.GetLibraryItem(c.Param("id"))
+	if err != nil {
+		if err.Error() == "library item not found" {
+			response.NotFound(c, "场景库项不存在")
+			return
+		}
+		h.log.Errorw("Failed to get scene library item", "error", err)
+		response.InternalError(c, "获取失败")
+		return
+	}
+
+	response.Success(c, item)
+}
+
+// UpdateLibraryItem 更新场景库项的分类与标签
+func (h *SceneLibraryHandler) UpdateLibraryItem(c *gin.Context) {
+	var req services2.UpdateSceneLibraryItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	item, err := h.libraryService.UpdateLibraryItem(c.Param("id"), &req)
+	if err != nil {
+		if err.Error() == "library item not found" {
+			response.NotFound(c, "场景库项不存在")
+			return
+		}
+		h.log.Errorw("Failed to update scene library item", "error", err)
+		response.InternalError(c, "更新失败")
+		return
+	}
+
+	response.Success(c, item)
+}
+
+// DeleteLibraryItem 删除场景库项
+func (h *SceneLibraryHandler) DeleteLibraryItem(c *gin.Context) {
+	if err := h.libraryService.DeleteLibraryItem(c.Param("id")); err != nil {
+		if err.Error() == "library item not found" {
+			response.NotFound(c, "场景库项不存在")
+			return
+		}
+		h.log.Errorw("Failed to delete scene library item", "error", err)
+		response.InternalError(c, "删除失败")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
+
+// ApplyLibraryItemToScene 从场景库应用背景图
+func (h *SceneLibraryHandler) ApplyLibraryItemToScene(c *gin.Context) {
+	sceneID := c.Param("scene_id")
+
+	var req struct {
+		LibraryItemID string `json:"library_item_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.libraryService.ApplyLibraryItemToScene(sceneID, req.LibraryItemID); err != nil {
+		if err.Error() == "library item not found" {
+			response.NotFound(c, "场景库项不存在")
+			return
+		}
+		if err.Error() == "scene not found" {
+			response.NotFound(c, "场景不存在")
+			return
+		}
+		h.log.Errorw("Failed to apply scene library item", "error", err)
+		response.InternalError(c, "应用失败")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "应用成功"})
+}
+
+// AddSceneToLibrary 将场景添加到场景库
+func (h *SceneLibraryHandler) AddSceneToLibrary(c *gin.Context) {
+	sceneID := c.Param("scene_id")
+
+	var req struct {
+		Category *string `json:"category"`
+		Tags     *string `json:"tags"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		// 允许空body
+		req.Category = nil
+		req.Tags = nil
+	}
+
+	item, err := h.libraryService.AddSceneToLibrary(sceneID, req.Category, req.Tags)
+	if err != nil {
+		if err.Error() == "scene not found" {
+			response.NotFound(c, "场景不存在")
+			return
+		}
+		if err.Error() == "scene has no image" {
+			response.BadRequest(c, "场景还没有背景图片")
+			return
+		}
+		h.log.Errorw("Failed to add scene to library", "error", err)
+		response.InternalError(c, "添加失败")
+		return
+	}
+
+	response.Created(c, item)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"github.com/drama-generator/backend/application/services"
 	"github.com/drama-generator/backend/pkg/config"
 	"github.com/drama-generator/backend/pkg/logger"
 	"github.com/drama-generator/backend/pkg/response"
@@ -20,7 +21,7 @@ func NewSettingsHandler(cfg *config.Config, log *logger.Logger) *SettingsHandler
 	}
 }
 
-// GetLanguage 获取当前系统语言
+// GetLanguage 获取系统默认语言，未单独设置语言的剧本使用该语言
 func (h *SettingsHandler) GetLanguage(c *gin.Context) {
 	language := h.config.App.Language
 	if language == "" {
@@ -28,18 +29,19 @@ func (h *SettingsHandler) GetLanguage(c *gin.Context) {
 	}
 
 	response.Success(c, gin.H{
-		"language": language,
+		"language":  language,
+		"languages": services.SupportedPromptLanguages(),
 	})
 }
 
-// UpdateLanguage 更新系统语言
+// UpdateLanguage 更新系统默认语言，已设置语言的剧本不受影响
 func (h *SettingsHandler) UpdateLanguage(c *gin.Context) {
 	var req struct {
-		Language string `json:"language" binding:"required,oneof=zh en"`
+		Language string `json:"language" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		response.BadRequest(c, "语言参数错误，只支持 zh 或 en")
+	if err := c.ShouldBindJSON(&req); err != nil || !services.IsSupportedPromptLanguage(req.Language) {
+		response.BadRequest(c, "语言参数错误，不支持该语言")
 		return
 	}
 
@@ -55,10 +57,7 @@ func (h *SettingsHandler) UpdateLanguage(c *gin.Context) {
 
 	h.log.Infow("System language updated", "language", req.Language)
 
-	message := "语言已切换为中文"
-	if req.Language == "en" {
-		message = "Language switched to English"
-	}
+	message := services.NewPromptI18n(h.config, nil, h.log).FormatUserPrompt("language_switched")
 
 	response.Success(c, gin.H{
 		"message":  message,
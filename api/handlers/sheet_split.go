@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/drama-generator/backend/application/services"
+	"github.com/drama-generator/backend/infrastructure/storage"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SheetSplitHandler struct {
+	splitService *services.SheetSplitService
+	log          *logger.Logger
+}
+
+func NewSheetSplitHandler(db *gorm.DB, cfg *config.Config, log *logger.Logger, localStorage *storage.LocalStorage) *SheetSplitHandler {
+	return &SheetSplitHandler{
+		splitService: services.NewSheetSplitService(db, cfg, localStorage, log),
+		log:          log,
+	}
+}
+
+// SplitCharacterSheet 将角色三视图设定图裁切为单独的视图
+func (h *SheetSplitHandler) SplitCharacterSheet(c *gin.Context) {
+	characterID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	views, err := h.splitService.SplitCharacterSheet(uint(characterID))
+	h.respond(c, views, err, "character not found")
+}
+
+// SplitPropSheet 将道具三视图设定图裁切为单独的视图
+func (h *SheetSplitHandler) SplitPropSheet(c *gin.Context) {
+	propID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	views, err := h.splitService.SplitPropSheet(uint(propID))
+	h.respond(c, views, err, "prop not found")
+}
+
+// SplitLookSheet 将角色造型设定图裁切为单独的视图
+func (h *SheetSplitHandler) SplitLookSheet(c *gin.Context) {
+	lookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+
+	views, err := h.splitService.SplitLookSheet(uint(lookID))
+	h.respond(c, views, err, "look not found")
+}
+
+func (h *SheetSplitHandler) respond(c *gin.Context, data interface{}, err error, notFoundMsg string) {
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		if err.Error() == notFoundMsg {
+			response.NotFound(c, err.Error())
+			return
+		}
+		h.log.Errorw("Failed to split sheet", "error", err)
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, data)
+}
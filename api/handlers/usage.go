@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/drama-generator/backend/application/services"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/response"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type UsageHandler struct {
+	usageService *services.UsageService
+	log          *logger.Logger
+}
+
+func NewUsageHandler(db *gorm.DB, log *logger.Logger) *UsageHandler {
+	return &UsageHandler{
+		usageService: services.NewUsageService(db, log),
+		log:          log,
+	}
+}
+
+// GetUsageSummary 按剧本/章节/厂商/模型/天汇总用量与花费
+func (h *UsageHandler) GetUsageSummary(c *gin.Context) {
+	var query services.UsageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	summary, err := h.usageService.Summarize(&query)
+	if err != nil {
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		h.log.Errorw("Failed to summarize usage", "error", err)
+		response.InternalError(c, "获取用量统计失败")
+		return
+	}
+
+	response.Success(c, summary)
+}
+
+// ListUsageRecords 分页查询用量流水
+func (h *UsageHandler) ListUsageRecords(c *gin.Context) {
+	var query services.UsageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	records, total, err := h.usageService.ListRecords(&query, page, pageSize)
+	if err != nil {
+		h.log.Errorw("Failed to list usage records", "error", err)
+		response.InternalError(c, "获取用量记录失败")
+		return
+	}
+
+	response.SuccessWithPagination(c, records, total, page, pageSize)
+}
+
+func (h *UsageHandler) ListPrices(c *gin.Context) {
+	prices, err := h.usageService.ListPrices(c.Query("service_type"))
+	if err != nil {
+		response.InternalError(c, "获取价格表失败")
+		return
+	}
+
+	response.Success(c, prices)
+}
+
+func (h *UsageHandler) CreatePrice(c *gin.Context) {
+	var req services.ModelPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	price, err := h.usageService.CreatePrice(&req)
+	if err != nil {
+		h.log.Errorw("Failed to create model price", "error", err)
+		response.InternalError(c, "创建失败")
+		return
+	}
+
+	response.Created(c, price)
+}
+
+func (h *UsageHandler) UpdatePrice(c *gin.Context) {
+	priceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的价格ID")
+		return
+	}
+
+	var req services.ModelPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	price, err := h.usageService.UpdatePrice(uint(priceID), &req)
+	if err != nil {
+		if err.Error() == "price not found" {
+			response.NotFound(c, "价格配置不存在")
+			return
+		}
+		response.InternalError(c, "更新失败")
+		return
+	}
+
+	response.Success(c, price)
+}
+
+func (h *UsageHandler) DeletePrice(c *gin.Context) {
+	priceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的价格ID")
+		return
+	}
+
+	if err := h.usageService.DeletePrice(uint(priceID)); err != nil {
+		if err.Error() == "price not found" {
+			response.NotFound(c, "价格配置不存在")
+			return
+		}
+		response.InternalError(c, "删除失败")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
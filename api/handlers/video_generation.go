@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"io"
+	"net/http"
 	"strconv"
 
 	"github.com/drama-generator/backend/application/services"
@@ -33,6 +35,13 @@ func (h *VideoGenerationHandler) GenerateVideo(c *gin.Context) {
 
 	videoGen, err := h.videoService.GenerateVideo(&req)
 	if err != nil {
+		if respondBudgetExceeded(c, err) {
+			return
+		}
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
 		h.log.Errorw("Failed to generate video", "error", err)
 		response.InternalError(c, err.Error())
 		return
@@ -51,6 +60,9 @@ func (h *VideoGenerationHandler) GenerateVideoFromImage(c *gin.Context) {
 
 	videoGen, err := h.videoService.GenerateVideoFromImage(uint(imageGenID))
 	if err != nil {
+		if respondBudgetExceeded(c, err) {
+			return
+		}
 		h.log.Errorw("Failed to generate video from image", "error", err)
 		response.InternalError(c, err.Error())
 		return
@@ -65,6 +77,9 @@ func (h *VideoGenerationHandler) BatchGenerateForEpisode(c *gin.Context) {
 
 	videos, err := h.videoService.BatchGenerateVideosForEpisode(episodeID)
 	if err != nil {
+		if respondBudgetExceeded(c, err) {
+			return
+		}
 		h.log.Errorw("Failed to batch generate videos", "error", err)
 		response.InternalError(c, err.Error())
 		return
@@ -147,3 +162,29 @@ func (h *VideoGenerationHandler) DeleteVideoGeneration(c *gin.Context) {
 
 	response.Success(c, nil)
 }
+
+// ProviderCallback 服务商任务回调，响应体按服务商要求直接返回（不使用统一响应格式）
+func (h *VideoGenerationHandler) ProviderCallback(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		response.BadRequest(c, "读取回调内容失败")
+		return
+	}
+
+	result, err := h.videoService.HandleProviderCallback(c.Param("provider"), c.Param("token"), body)
+	if err != nil {
+		if err.Error() == "callback not found" {
+			response.NotFound(c, "回调不存在")
+			return
+		}
+		if validationErr, ok := services.IsValidationError(err); ok {
+			response.BadRequest(c, validationErr.Message)
+			return
+		}
+		h.log.Errorw("Failed to handle provider callback", "error", err, "provider", c.Param("provider"))
+		response.InternalError(c, "处理回调失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
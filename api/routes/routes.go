@@ -43,6 +43,7 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 	assetHandler := handlers2.NewAssetHandler(db, cfg, log)
 	characterLibraryService := services2.NewCharacterLibraryService(db, log, cfg)
 	characterLibraryHandler := handlers2.NewCharacterLibraryHandler(db, cfg, log, transferService, localStoragePtr)
+	sceneLibraryHandler := handlers2.NewSceneLibraryHandler(db, cfg, log, localStoragePtr)
 	uploadHandler, err := handlers2.NewUploadHandler(cfg, log, characterLibraryService)
 	if err != nil {
 		log.Fatalw("Failed to create upload handler", "error", err)
@@ -55,6 +56,14 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 	audioExtractionHandler := handlers2.NewAudioExtractionHandler(log, cfg.Storage.LocalPath)
 	settingsHandler := handlers2.NewSettingsHandler(cfg, log)
 	propHandler := handlers2.NewPropHandler(db, cfg, log, aiService, imageGenService)
+	consistencyHandler := handlers2.NewCharacterConsistencyHandler(db, log, aiService, imageGenService)
+	sheetSplitHandler := handlers2.NewSheetSplitHandler(db, cfg, log, localStoragePtr)
+	characterLookHandler := handlers2.NewCharacterLookHandler(db, log)
+	entityResolutionHandler := handlers2.NewEntityResolutionHandler(db, log, aiService)
+	usageHandler := handlers2.NewUsageHandler(db, log)
+	budgetHandler := handlers2.NewBudgetHandler(db, log)
+	providerHandler := handlers2.NewProviderHandler(db, log)
+	promptTemplateHandler := handlers2.NewPromptTemplateHandler(db, log)
 
 	api := r.Group("/api/v1")
 	{
@@ -75,6 +84,16 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			dramas.PUT("/:id/episodes", dramaHandler.SaveEpisodes)
 			dramas.PUT("/:id/progress", dramaHandler.SaveProgress)
 			dramas.GET("/:id/props", propHandler.ListProps) // Added prop list route
+			dramas.GET("/:id/entity-aliases", entityResolutionHandler.ListAliases)
+			dramas.POST("/:id/entity-merges/propose", entityResolutionHandler.ProposeMerges)
+		}
+
+		// 角色、道具、场景的别名与合并
+		entities := api.Group("/entities")
+		{
+			entities.POST("/aliases", entityResolutionHandler.AddAlias)
+			entities.DELETE("/aliases/:id", entityResolutionHandler.DeleteAlias)
+			entities.POST("/merge", entityResolutionHandler.MergeEntities)
 		}
 
 		aiConfigs := api.Group("/ai-configs")
@@ -82,15 +101,37 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			aiConfigs.GET("", aiConfigHandler.ListConfigs)
 			aiConfigs.POST("", aiConfigHandler.CreateConfig)
 			aiConfigs.POST("/test", aiConfigHandler.TestConnection)
+			aiConfigs.POST("/models", aiConfigHandler.ListModels)
 			aiConfigs.GET("/:id", aiConfigHandler.GetConfig)
 			aiConfigs.PUT("/:id", aiConfigHandler.UpdateConfig)
 			aiConfigs.DELETE("/:id", aiConfigHandler.DeleteConfig)
 		}
 
+		// 服务商能力路由
+		providers := api.Group("/providers")
+		{
+			providers.GET("", providerHandler.ListProviders)
+			providers.GET("/capabilities", providerHandler.GetCapabilities)
+			providers.PUT("/:id", providerHandler.UpdateProvider)
+		}
+
+		// 提示词模板路由
+		promptTemplates := api.Group("/prompt-templates")
+		{
+			promptTemplates.GET("", promptTemplateHandler.ListTemplates)
+			promptTemplates.POST("", promptTemplateHandler.CreateTemplate)
+			promptTemplates.GET("/keys", promptTemplateHandler.ListKeys)
+			promptTemplates.GET("/:id", promptTemplateHandler.GetTemplate)
+			promptTemplates.PUT("/:id", promptTemplateHandler.UpdateTemplate)
+			promptTemplates.DELETE("/:id", promptTemplateHandler.DeleteTemplate)
+			promptTemplates.POST("/:id/activate", promptTemplateHandler.ActivateTemplate)
+		}
+
 		ai := api.Group("/ai")
 		{
 			ai.POST("/reverse-prompt", aiHandler.GeneratePromptFromImage)
 			ai.POST("/optimize-prompt", aiHandler.OptimizePrompt)
+			ai.DELETE("/cache", aiHandler.ClearCache)
 		}
 
 		generation := api.Group("/generation")
@@ -107,6 +148,17 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			characterLibrary.DELETE("/:id", characterLibraryHandler.DeleteLibraryItem)
 		}
 
+		// 场景库路由
+		sceneLibrary := api.Group("/scene-library")
+		{
+			sceneLibrary.GET("", sceneLibraryHandler.ListLibraryItems)
+			sceneLibrary.POST("", sceneLibraryHandler.CreateLibraryItem)
+			sceneLibrary.GET("/categories", sceneLibraryHandler.ListCategories)
+			sceneLibrary.GET("/:id", sceneLibraryHandler.GetLibraryItem)
+			sceneLibrary.PUT("/:id", sceneLibraryHandler.UpdateLibraryItem)
+			sceneLibrary.DELETE("/:id", sceneLibraryHandler.DeleteLibraryItem)
+		}
+
 		// 角色图片相关路由
 		characters := api.Group("/characters")
 		{
@@ -118,6 +170,19 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			characters.PUT("/:id/image", characterLibraryHandler.UploadCharacterImage)
 			characters.PUT("/:id/image-from-library", characterLibraryHandler.ApplyLibraryItemToCharacter)
 			characters.POST("/:id/add-to-library", characterLibraryHandler.AddCharacterToLibrary)
+			characters.POST("/:id/split-views", sheetSplitHandler.SplitCharacterSheet)
+			characters.GET("/:id/looks", characterLookHandler.ListLooks)
+			characters.POST("/:id/looks", characterLookHandler.CreateLook)
+		}
+
+		// 角色造型
+		characterLooks := api.Group("/character-looks")
+		{
+			characterLooks.PUT("/:id", characterLookHandler.UpdateLook)
+			characterLooks.DELETE("/:id", characterLookHandler.DeleteLook)
+			characterLooks.POST("/:id/assignments", characterLookHandler.AssignLook)
+			characterLooks.POST("/:id/split-views", sheetSplitHandler.SplitLookSheet)
+			characterLooks.DELETE("/assignments/:assignment_id", characterLookHandler.RemoveAssignment)
 		}
 
 		props := api.Group("/props")
@@ -125,6 +190,7 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			props.POST("", propHandler.CreateProp)
 			props.PUT("/:id", propHandler.UpdateProp)
 			props.DELETE("/:id", propHandler.DeleteProp)
+			props.POST("/:id/split-views", sheetSplitHandler.SplitPropSheet)
 			props.POST("/:id/generate", propHandler.GenerateImage)
 			props.GET("/library", propHandler.ListPropLibrary)
 			props.POST("/library", propHandler.AddPropToLibrary)
@@ -145,7 +211,10 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			episodes.POST("/:episode_id/storyboards", storyboardHandler.GenerateStoryboard)
 			episodes.POST("/:episode_id/props/extract", propHandler.ExtractProps)
 			episodes.POST("/:episode_id/characters/extract", characterLibraryHandler.ExtractCharacters)
+			episodes.POST("/:episode_id/consistency-audit", consistencyHandler.StartAudit)
+			episodes.GET("/:episode_id/consistency-audit", consistencyHandler.GetAuditResults)
 			episodes.GET("/:episode_id/props", propHandler.ListEpisodeProps)
+			episodes.GET("/:episode_id/character-looks", characterLookHandler.ListEpisodeAssignments)
 			episodes.GET("/:episode_id/storyboards", sceneHandler.GetStoryboardsForEpisode)
 			episodes.POST("/:episode_id/finalize", dramaHandler.FinalizeEpisode)
 			episodes.GET("/:episode_id/download", dramaHandler.DownloadEpisodeVideo)
@@ -164,6 +233,8 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			scenes.PUT("/:scene_id", sceneHandler.UpdateScene)
 			scenes.PUT("/:scene_id/prompt", sceneHandler.UpdateScenePrompt)
 			scenes.DELETE("/:scene_id", sceneHandler.DeleteScene)
+			scenes.PUT("/:scene_id/image-from-library", sceneLibraryHandler.ApplyLibraryItemToScene)
+			scenes.POST("/:scene_id/add-to-library", sceneLibraryHandler.AddSceneToLibrary)
 
 			scenes.POST("/generate-image", sceneHandler.GenerateSceneImage)
 			scenes.POST("", sceneHandler.CreateScene)
@@ -172,9 +243,13 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 		images := api.Group("/images")
 		{
 			images.GET("", imageGenHandler.ListImageGenerations)
+			images.GET("/discarded", imageGenHandler.ListDiscardedImages)
+			images.GET("/candidates/:group", imageGenHandler.GetCandidateGroup)
+			images.POST("/candidates/:group/auto-select", imageGenHandler.AutoSelectCandidates)
 			images.POST("", imageGenHandler.GenerateImage)
 			images.GET("/:id", imageGenHandler.GetImageGeneration)
 			images.DELETE("/:id", imageGenHandler.DeleteImageGeneration)
+			images.POST("/:id/score", imageGenHandler.ScoreImage)
 			images.POST("/scene/:scene_id", imageGenHandler.GenerateImagesForScene)
 			images.POST("/upload", imageGenHandler.UploadImage)
 			images.GET("/episode/:episode_id/backgrounds", imageGenHandler.GetBackgroundsForEpisode)
@@ -192,6 +267,10 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			videos.POST("/episode/:episode_id/batch", videoGenHandler.BatchGenerateForEpisode)
 		}
 
+		// 服务商任务回调，按回调地址中的令牌校验来源
+		api.POST("/callbacks/:provider/:token", videoGenHandler.ProviderCallback)
+		api.POST("/callbacks/images/:provider/:token", imageGenHandler.ProviderCallback)
+
 		videoMerges := api.Group("/video-merges")
 		{
 			videoMerges.GET("", videoMergeHandler.ListMerges)
@@ -216,6 +295,8 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			storyboards.GET("/episode/:episode_id/generate", storyboardHandler.GenerateStoryboard)
 			storyboards.POST("", storyboardHandler.CreateStoryboard)
 			storyboards.PUT("/:id", storyboardHandler.UpdateStoryboard)
+			storyboards.PUT("/:id/selected-image", imageGenHandler.SelectStoryboardImage)
+			storyboards.POST("/:id/consistency-regenerate", consistencyHandler.RegenerateStoryboard)
 			storyboards.DELETE("/:id", storyboardHandler.DeleteStoryboard)
 			storyboards.POST("/:id/props", propHandler.AssociateProps)
 			storyboards.POST("/:id/frame-prompt", framePromptHandler.GenerateFramePrompt)
@@ -233,6 +314,26 @@ func SetupRouter(cfg *config.Config, db *gorm.DB, log *logger.Logger, localStora
 			settings.GET("/language", settingsHandler.GetLanguage)
 			settings.PUT("/language", settingsHandler.UpdateLanguage)
 		}
+
+		// 用量与计费路由
+		usage := api.Group("/usage")
+		{
+			usage.GET("/summary", usageHandler.GetUsageSummary)
+			usage.GET("/records", usageHandler.ListUsageRecords)
+			usage.GET("/prices", usageHandler.ListPrices)
+			usage.POST("/prices", usageHandler.CreatePrice)
+			usage.PUT("/prices/:id", usageHandler.UpdatePrice)
+			usage.DELETE("/prices/:id", usageHandler.DeletePrice)
+		}
+
+		// 预算与配额路由
+		budgets := api.Group("/budgets")
+		{
+			budgets.GET("", budgetHandler.ListBudgets)
+			budgets.POST("", budgetHandler.CreateBudget)
+			budgets.PUT("/:id", budgetHandler.UpdateBudget)
+			budgets.DELETE("/:id", budgetHandler.DeleteBudget)
+		}
 	}
 
 	// 前端静态文件服务（放在API路由之后，避免冲突）
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 	"github.com/drama-generator/backend/domain/models"
 	"github.com/drama-generator/backend/pkg/ai"
 	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/httpadapter"
 	"github.com/drama-generator/backend/pkg/logger"
 	"gorm.io/gorm"
 )
@@ -21,19 +23,59 @@ import (
 type AIService struct {
 	db               *gorm.DB
 	log              *logger.Logger
+	usage            *UsageService
+	budget           *BudgetService
+	cache            *LLMCacheService
+	promptI18n       *PromptI18n
 	localStoragePath string
 	baseURL          string
+	publicURL        string
 }
 
 func NewAIService(db *gorm.DB, log *logger.Logger, cfg *config.Config) *AIService {
 	return &AIService{
 		db:               db,
 		log:              log,
+		usage:            NewUsageService(db, log),
+		budget:           NewBudgetService(db, log),
+		cache:            NewLLMCacheService(db, log, cfg),
+		promptI18n:       NewPromptI18n(cfg, db, log),
 		localStoragePath: cfg.Storage.LocalPath,
 		baseURL:          cfg.Storage.BaseURL,
+		publicURL:        strings.TrimRight(cfg.Server.PublicURL, "/"),
 	}
 }
 
+// CallbacksEnabled 是否配置了 server.public_url，未配置时服务商无法回调
+func (s *AIService) CallbacksEnabled() bool {
+	return s.publicURL != ""
+}
+
+// CallbackURL 返回服务商回调地址
+func (s *AIService) CallbackURL(provider, token string) string {
+	return fmt.Sprintf("%s/api/v1/callbacks/%s/%s", s.publicURL, provider, token)
+}
+
+// ImageCallbackURL 返回图片服务商回调地址
+func (s *AIService) ImageCallbackURL(provider, token string) string {
+	return fmt.Sprintf("%s/api/v1/callbacks/images/%s/%s", s.publicURL, provider, token)
+}
+
+// GetUsageService 获取用量记录服务
+func (s *AIService) GetUsageService() *UsageService {
+	return s.usage
+}
+
+// GetBudgetService 获取预算检查服务
+func (s *AIService) GetBudgetService() *BudgetService {
+	return s.budget
+}
+
+// GetLLMCache 获取文本响应缓存
+func (s *AIService) GetLLMCache() *LLMCacheService {
+	return s.cache
+}
+
 func (s *AIService) GetDB() *gorm.DB {
 	return s.db
 }
@@ -43,7 +85,7 @@ type CreateAIConfigRequest struct {
 	Name          string            `json:"name" binding:"required,min=1,max=100"`
 	Provider      string            `json:"provider" binding:"required"`
 	BaseURL       string            `json:"base_url" binding:"required,url"`
-	APIKey        string            `json:"api_key" binding:"required"`
+	APIKey        string            `json:"api_key"`
 	Model         models.ModelField `json:"model" binding:"required"`
 	Endpoint      string            `json:"endpoint"`
 	QueryEndpoint string            `json:"query_endpoint"`
@@ -66,15 +108,78 @@ type UpdateAIConfigRequest struct {
 	Settings      string             `json:"settings"`
 }
 
+type ListModelsRequest struct {
+	Provider string `json:"provider" binding:"required"`
+	BaseURL  string `json:"base_url" binding:"omitempty,url"`
+	APIKey   string `json:"api_key"`
+}
+
 type TestConnectionRequest struct {
 	BaseURL  string            `json:"base_url" binding:"required,url"`
-	APIKey   string            `json:"api_key" binding:"required"`
+	APIKey   string            `json:"api_key"`
 	Model    models.ModelField `json:"model" binding:"required"`
 	Provider string            `json:"provider"`
 	Endpoint string            `json:"endpoint"`
 }
 
+// keylessProviders 本地部署或离线模拟的服务商，不需要 API Key
+var keylessProviders = map[string]bool{
+	"ollama":   true,
+	"llamacpp": true, // llama.cpp server 走 OpenAI 兼容接口
+	"mock":     true,
+	// 自部署的 Stable Diffusion WebUI / ComfyUI，API Key 可选
+	string(models.ProviderStableDiffusion): true,
+	"sdwebui":                              true,
+	"automatic1111":                        true,
+	string(models.ProviderComfyUI):         true,
+}
+
+// validateAPIKey 除本地服务商外 API Key 必填
+func validateAPIKey(provider, apiKey string) error {
+	if strings.TrimSpace(apiKey) == "" && !keylessProviders[provider] {
+		return &ValidationError{Message: "API Key 不能为空"}
+	}
+	return nil
+}
+
+// httpAdapterProviders 由 Settings 中 http_adapter 声明请求/响应格式的通用服务商
+var httpAdapterProviders = map[string]bool{
+	"http_adapter": true,
+	"custom":       true,
+}
+
+// validateProviderSettings 通用 HTTP 适配器的 Settings 在保存时即校验，避免到生成时才报错
+func validateProviderSettings(provider, settings string) error {
+	if !httpAdapterProviders[provider] {
+		return nil
+	}
+	if _, err := httpadapter.ParseSpec(settings); err != nil {
+		return &ValidationError{Message: fmt.Sprintf("适配器配置无效: %v", err)}
+	}
+	return nil
+}
+
+// textProviderSettings 从 AIServiceConfig.Settings 中读取的文本模型参数，例如 Ollama：{"num_ctx": 16384}
+type textProviderSettings struct {
+	NumCtx int `json:"num_ctx"`
+}
+
+func parseTextProviderSettings(config *models.AIServiceConfig) textProviderSettings {
+	var settings textProviderSettings
+	if strings.TrimSpace(config.Settings) != "" {
+		json.Unmarshal([]byte(config.Settings), &settings)
+	}
+	return settings
+}
+
 func (s *AIService) CreateConfig(req *CreateAIConfigRequest) (*models.AIServiceConfig, error) {
+	if err := validateAPIKey(req.Provider, req.APIKey); err != nil {
+		return nil, err
+	}
+	if err := validateProviderSettings(req.Provider, req.Settings); err != nil {
+		return nil, err
+	}
+
 	// 根据 provider 和 service_type 自动设置 endpoint
 	endpoint := req.Endpoint
 	queryEndpoint := req.QueryEndpoint
@@ -116,6 +221,14 @@ func (s *AIService) CreateConfig(req *CreateAIConfigRequest) (*models.AIServiceC
 					queryEndpoint = "/api/v3/contents/generations/tasks/{taskId}"
 				}
 			}
+		case "ollama":
+			if req.ServiceType == "text" {
+				endpoint = "/api/chat"
+			}
+		case "anthropic":
+			if req.ServiceType == "text" {
+				endpoint = "/v1/messages"
+			}
 		default:
 			// 默认使用 OpenAI 格式
 			if req.ServiceType == "text" {
@@ -191,6 +304,17 @@ func (s *AIService) UpdateConfig(configID uint, req *UpdateAIConfigRequest) (*mo
 		return nil, err
 	}
 
+	provider, settings := config.Provider, config.Settings
+	if req.Provider != "" {
+		provider = req.Provider
+	}
+	if req.Settings != "" {
+		settings = req.Settings
+	}
+	if err := validateProviderSettings(provider, settings); err != nil {
+		return nil, err
+	}
+
 	tx := s.db.Begin()
 
 	// 不再需要is_default独占逻辑
@@ -248,6 +372,14 @@ func (s *AIService) UpdateConfig(configID uint, req *UpdateAIConfigRequest) (*mo
 				updates["endpoint"] = "/api/v3/contents/generations/tasks"
 				updates["query_endpoint"] = "/api/v3/contents/generations/tasks/{taskId}"
 			}
+		case "ollama":
+			if serviceType == "text" {
+				updates["endpoint"] = "/api/chat"
+			}
+		case "anthropic":
+			if serviceType == "text" {
+				updates["endpoint"] = "/v1/messages"
+			}
 		}
 	} else if req.Endpoint != "" {
 		updates["endpoint"] = req.Endpoint
@@ -292,6 +424,9 @@ func (s *AIService) DeleteConfig(configID uint) error {
 }
 
 func (s *AIService) TestConnection(req *TestConnectionRequest) error {
+	if err := validateAPIKey(req.Provider, req.APIKey); err != nil {
+		return err
+	}
 	s.log.Infow("TestConnection called", "baseURL", req.BaseURL, "provider", req.Provider, "endpoint", req.Endpoint, "modelCount", len(req.Model))
 
 	// 使用第一个模型进行测试
@@ -311,6 +446,19 @@ func (s *AIService) TestConnection(req *TestConnectionRequest) error {
 		s.log.Infow("Using Gemini client", "baseURL", req.BaseURL)
 		endpoint = "/v1beta/models/{model}:generateContent"
 		client = ai.NewGeminiClient(req.BaseURL, req.APIKey, model, endpoint)
+	case "mock":
+		// 离线模拟，不访问网络
+		client = ai.NewMockClient(model)
+	case "ollama":
+		s.log.Infow("Using Ollama client", "baseURL", req.BaseURL)
+		client = ai.NewOllamaClient(req.BaseURL, req.APIKey, model)
+	case "anthropic":
+		s.log.Infow("Using Anthropic client", "baseURL", req.BaseURL)
+		endpoint = req.Endpoint
+		if endpoint == "" {
+			endpoint = "/v1/messages"
+		}
+		client = ai.NewAnthropicClient(req.BaseURL, req.APIKey, model, endpoint)
 	case "openai", "chatfire":
 		// OpenAI 格式（包括 chatfire 等）
 		s.log.Infow("Using OpenAI-compatible client", "baseURL", req.BaseURL, "provider", req.Provider)
@@ -339,6 +487,27 @@ func (s *AIService) TestConnection(req *TestConnectionRequest) error {
 	return err
 }
 
+// ListModels 查询服务端已有的模型，用于配置时选择；目前支持 Ollama
+func (s *AIService) ListModels(req *ListModelsRequest) ([]string, error) {
+	var client ai.AIClient
+	switch req.Provider {
+	case "ollama":
+		client = ai.NewOllamaClient(req.BaseURL, req.APIKey, "")
+	}
+
+	lister, ok := client.(ai.ModelLister)
+	if !ok {
+		return nil, &ValidationError{Message: "该服务商不支持获取模型列表"}
+	}
+
+	modelNames, err := lister.ListModels()
+	if err != nil {
+		s.log.Errorw("Failed to list models", "provider", req.Provider, "base_url", req.BaseURL, "error", err)
+		return nil, err
+	}
+	return modelNames, nil
+}
+
 func (s *AIService) GetDefaultConfig(serviceType string) (*models.AIServiceConfig, error) {
 	var config models.AIServiceConfig
 	// 按优先级降序获取第一个激活的配置
@@ -404,17 +573,48 @@ func (s *AIService) buildTextClientFromConfig(config *models.AIServiceConfig, mo
 		switch config.Provider {
 		case "gemini", "google":
 			endpoint = "/v1beta/models/{model}:generateContent"
+		case "anthropic":
+			endpoint = "/v1/messages"
 		default:
 			endpoint = "/chat/completions"
 		}
 	}
 
+	var client ai.AIClient
 	switch config.Provider {
 	case "gemini", "google":
-		return ai.NewGeminiClient(config.BaseURL, config.APIKey, model, endpoint)
+		client = ai.NewGeminiClient(config.BaseURL, config.APIKey, model, endpoint)
+	case "mock":
+		client = ai.NewMockClient(model)
+	case "ollama":
+		ollama := ai.NewOllamaClient(config.BaseURL, config.APIKey, model)
+		ollama.NumCtx = parseTextProviderSettings(config).NumCtx
+		client = ollama
+	case "anthropic":
+		client = ai.NewAnthropicClient(config.BaseURL, config.APIKey, model, endpoint)
 	default:
-		return ai.NewOpenAIClient(config.BaseURL, config.APIKey, model, endpoint)
+		client = ai.NewOpenAIClient(config.BaseURL, config.APIKey, model, endpoint)
+	}
+
+	// 包装限流、用量记录与预算检查
+	client = &meteredTextClient{
+		inner:  newRateLimitedTextClient(client, config),
+		usage:  s.usage,
+		budget: s.budget,
+		config: config,
+		model:  model,
+	}
+
+	// 响应缓存放在最外层，命中时不占用限流与预算
+	if s.cache.Enabled() {
+		client = &cachedTextClient{
+			inner:  client,
+			cache:  s.cache,
+			config: config,
+			model:  model,
+		}
 	}
+	return client
 }
 
 func isRetryableAIError(err error) bool {
@@ -444,31 +644,8 @@ func (s *AIService) GetAIClient(serviceType string) (ai.AIClient, error) {
 		return nil, err
 	}
 
-	// 使用第一个模型
-	model := ""
-	if len(config.Model) > 0 {
-		model = config.Model[0]
-	}
-
-	// 使用数据库配置中的 endpoint，如果为空则根据 provider 设置默认值
-	endpoint := config.Endpoint
-	if endpoint == "" {
-		switch config.Provider {
-		case "gemini", "google":
-			endpoint = "/v1beta/models/{model}:generateContent"
-		default:
-			endpoint = "/chat/completions"
-		}
-	}
-
-	// 根据 provider 创建对应的客户端
-	switch config.Provider {
-	case "gemini", "google":
-		return ai.NewGeminiClient(config.BaseURL, config.APIKey, model, endpoint), nil
-	default:
-		// openai, chatfire 等其他厂商都使用 OpenAI 格式
-		return ai.NewOpenAIClient(config.BaseURL, config.APIKey, model, endpoint), nil
-	}
+	// 使用第一个模型；openai, chatfire 等其他厂商都使用 OpenAI 格式
+	return s.buildTextClientFromConfig(config, ""), nil
 }
 
 // GetAIClientForModel 根据服务类型和模型名称获取对应的AI客户端
@@ -478,25 +655,7 @@ func (s *AIService) GetAIClientForModel(serviceType string, modelName string) (a
 		return nil, err
 	}
 
-	// 使用数据库配置中的 endpoint，如果为空则根据 provider 设置默认值
-	endpoint := config.Endpoint
-	if endpoint == "" {
-		switch config.Provider {
-		case "gemini", "google":
-			endpoint = "/v1beta/models/{model}:generateContent"
-		default:
-			endpoint = "/chat/completions"
-		}
-	}
-
-	// 根据 provider 创建对应的客户端
-	switch config.Provider {
-	case "gemini", "google":
-		return ai.NewGeminiClient(config.BaseURL, config.APIKey, modelName, endpoint), nil
-	default:
-		// openai, chatfire 等其他厂商都使用 OpenAI 格式
-		return ai.NewOpenAIClient(config.BaseURL, config.APIKey, modelName, endpoint), nil
-	}
+	return s.buildTextClientFromConfig(config, modelName), nil
 }
 
 func (s *AIService) GenerateText(prompt string, systemPrompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
@@ -504,17 +663,23 @@ func (s *AIService) GenerateText(prompt string, systemPrompt string, options ...
 	if err != nil {
 		return "", fmt.Errorf("failed to get AI client: %w", err)
 	}
+	configs = candidateConfigsForModel(configs, "")
 
 	var lastErr error
 	for index := range configs {
 		config := configs[index]
 		client := s.buildTextClientFromConfig(&config, "")
 		text, callErr := client.GenerateText(prompt, systemPrompt, options...)
+		recordConfigResult(config.ID, callErr)
 		if callErr == nil {
 			return text, nil
 		}
 		lastErr = callErr
-		if !isRetryableAIError(callErr) {
+		// 单个配置的预算用尽时切换到下一个配置，剧本/工作区预算用尽则直接返回
+		if budgetErr, ok := IsBudgetExceededError(callErr); ok && budgetErr.Scope == BudgetScopeConfig {
+			continue
+		}
+		if !isFailoverError(callErr) {
 			return "", callErr
 		}
 		if index < len(configs)-1 {
@@ -525,6 +690,47 @@ func (s *AIService) GenerateText(prompt string, systemPrompt string, options ...
 	return "", lastErr
 }
 
+// GenerateTextStream 流式生成文本；只有在尚未输出任何内容时才切换到下一个配置，避免调用方收到重复片段
+func (s *AIService) GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	configs, err := s.getActiveConfigs("text")
+	if err != nil {
+		return "", fmt.Errorf("failed to get AI client: %w", err)
+	}
+	configs = candidateConfigsForModel(configs, "")
+
+	var lastErr error
+	for index := range configs {
+		config := configs[index]
+		client := s.buildTextClientFromConfig(&config, "")
+		emitted := false
+		text, callErr := client.GenerateTextStream(prompt, systemPrompt, func(chunk string) {
+			emitted = true
+			if onChunk != nil {
+				onChunk(chunk)
+			}
+		}, options...)
+		recordConfigResult(config.ID, callErr)
+		if callErr == nil {
+			return text, nil
+		}
+		lastErr = callErr
+		if emitted {
+			return "", callErr
+		}
+		if budgetErr, ok := IsBudgetExceededError(callErr); ok && budgetErr.Scope == BudgetScopeConfig {
+			continue
+		}
+		if !isFailoverError(callErr) {
+			return "", callErr
+		}
+		if index < len(configs)-1 {
+			s.log.Warnw("AI stream failed, trying next config", "error", callErr, "config_id", config.ID, "provider", config.Provider)
+		}
+	}
+
+	return "", lastErr
+}
+
 func (s *AIService) OptimizeImagePrompt(prompt string, protected []string) (string, error) {
 	if strings.TrimSpace(prompt) == "" {
 		return "", errors.New("prompt is empty")
@@ -536,7 +742,7 @@ func (s *AIService) OptimizeImagePrompt(prompt string, protected []string) (stri
 	}
 
 	userPrompt := fmt.Sprintf("原始提示词：%s", prompt)
-	text, err := s.GenerateText(userPrompt, systemPrompt, ai.WithTemperature(0.7), ai.WithMaxTokens(800))
+	text, err := s.GenerateText(userPrompt, systemPrompt, ai.WithTemperature(0.7), ai.WithMaxTokens(800), WithUsageScope(UsageScope{Operation: "prompt_optimization"}))
 	if err != nil {
 		return "", err
 	}
@@ -544,31 +750,60 @@ func (s *AIService) OptimizeImagePrompt(prompt string, protected []string) (stri
 	return strings.TrimSpace(text), nil
 }
 
-func (s *AIService) GenerateImage(prompt string, size string, n int) ([]string, error) {
+func (s *AIService) GenerateImage(prompt string, size string, n int, options ...func(*ai.ChatCompletionRequest)) ([]string, error) {
 	client, err := s.GetAIClient("image")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get AI client for image: %w", err)
 	}
 
-	return client.GenerateImage(prompt, size, n)
+	return client.GenerateImage(prompt, size, n, options...)
 }
 
 func (s *AIService) GeneratePromptFromImage(imageURL string) (string, error) {
-	// 1. Get Text Client (Vision models are usually text-generation models with vision capabilities)
-	// We prefer "text" service which usually points to LLMs like GPT-4 or Gemini
+	return s.DescribeImage(imageURL, "")
+}
+
+// DescribeImage 使用默认文本模型的视觉能力按 prompt 分析图片，prompt 为空时生成图片描述。
+// 本地静态文件与远程图片都会先转换为 data URI；options 可附带用量归属，用于预算检查与计费
+func (s *AIService) DescribeImage(imageURL string, prompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	return s.DescribeImages([]string{imageURL}, prompt, options...)
+}
+
+// DescribeImages 把多张图片放在同一次请求中分析，图片按顺序发送，便于模型直接对比
+func (s *AIService) DescribeImages(imageURLs []string, prompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	if len(imageURLs) == 0 {
+		return "", fmt.Errorf("no image to describe")
+	}
+
+	// Vision models are usually text-generation models with vision capabilities,
+	// so the "text" service (GPT-4, Gemini, ...) is used here
 	client, err := s.GetAIClient("text")
 	if err != nil {
 		return "", fmt.Errorf("failed to get AI client: %w", err)
 	}
 
-	// If it is already a data URI, call directly
+	dataURIs := make([]string, 0, len(imageURLs))
+	for _, imageURL := range imageURLs {
+		dataURI, err := s.imageDataURI(imageURL)
+		if err != nil {
+			return "", err
+		}
+		dataURIs = append(dataURIs, dataURI)
+	}
+	if len(dataURIs) > 1 {
+		options = append(options[:len(options):len(options)], ai.WithImages(dataURIs[1:]...))
+	}
+	return client.GenerateImageDescription(dataURIs[0], prompt, options...)
+}
+
+// imageDataURI 读取本地静态文件或远程图片并转换为 data URI，已是 data URI 时原样返回
+func (s *AIService) imageDataURI(imageURL string) (string, error) {
 	if strings.HasPrefix(imageURL, "data:") {
-		return client.GenerateImageDescription(imageURL, "")
+		return imageURL, nil
 	}
 
 	var data []byte
 
-	// 2. Fetch image data
 	// Check if it is a local file (relative URL)
 	if strings.HasPrefix(imageURL, "/") && !strings.HasPrefix(imageURL, "//") {
 		// Map /static/ to local storage path
@@ -612,11 +847,6 @@ func (s *AIService) GeneratePromptFromImage(imageURL string) (string, error) {
 		}
 	}
 
-	// 3. Convert to Base64 Data URI
 	mimeType := http.DetectContentType(data)
-	base64Data := base64.StdEncoding.EncodeToString(data)
-	dataURI := fmt.Sprintf("data:%s;base64,%s", mimeType, base64Data)
-
-	// 4. Call Client
-	return client.GenerateImageDescription(dataURI, "")
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)), nil
 }
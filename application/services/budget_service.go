@@ -0,0 +1,449 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	BudgetScopeWorkspace = "workspace"
+	BudgetScopeDrama     = "drama"
+	BudgetScopeConfig    = "config"
+
+	BudgetStateOK       = "ok"
+	BudgetStateWarning  = "warning"
+	BudgetStateExceeded = "exceeded"
+)
+
+// BudgetExceededError 硬限额已达到，拒绝启动新的生成任务
+type BudgetExceededError struct {
+	BudgetID uint    `json:"budget_id"`
+	Name     string  `json:"name"`
+	Scope    string  `json:"scope"`
+	ScopeID  *uint   `json:"scope_id,omitempty"`
+	Metric   string  `json:"metric"`
+	Period   string  `json:"period"`
+	Spent    float64 `json:"spent"`
+	Limit    float64 `json:"limit"`
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("budget exceeded: %s (%s %s, %.4f/%.4f)", e.Name, e.Scope, e.Metric, e.Spent, e.Limit)
+}
+
+func IsBudgetExceededError(err error) (*BudgetExceededError, bool) {
+	var target *BudgetExceededError
+	if errors.As(err, &target) {
+		return target, true
+	}
+	return nil, false
+}
+
+// BudgetCheck 描述一次即将发起的生成调用
+type BudgetCheck struct {
+	DramaID     uint
+	ConfigID    uint
+	ServiceType string // text, image, video
+}
+
+// BudgetStatus 预算当前消耗情况
+type BudgetStatus struct {
+	models.Budget
+	Spent   float64 `json:"spent"`
+	Percent float64 `json:"percent"` // 相对硬限额（无硬限额时相对软限额）的百分比
+	State   string  `json:"state"`   // ok, warning, exceeded
+}
+
+type BudgetService struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewBudgetService(db *gorm.DB, log *logger.Logger) *BudgetService {
+	return &BudgetService{
+		db:  db,
+		log: log,
+	}
+}
+
+// Check 在发起生成前检查所有适用预算：超过软限额记录告警，超过硬限额返回 BudgetExceededError
+func (s *BudgetService) Check(check BudgetCheck) error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+
+	budgets, err := s.applicableBudgets(check)
+	if err != nil {
+		// 预算查询失败不应阻塞生成
+		s.log.Warnw("Failed to load budgets", "error", err)
+		return nil
+	}
+	return s.checkBudgets(budgets)
+}
+
+// CheckConfig 只检查指定服务配置的预算，用于在多个候选配置之间切换
+func (s *BudgetService) CheckConfig(configID uint, serviceType string) error {
+	if s == nil || s.db == nil || configID == 0 {
+		return nil
+	}
+
+	var budgets []models.Budget
+	query := s.db.Where("is_active = ? AND scope = ? AND scope_id = ?", true, BudgetScopeConfig, configID)
+	if serviceType != "" {
+		query = query.Where("service_type = '' OR service_type IS NULL OR service_type = ?", serviceType)
+	}
+	if err := query.Order("id ASC").Find(&budgets).Error; err != nil {
+		s.log.Warnw("Failed to load config budgets", "error", err, "config_id", configID)
+		return nil
+	}
+	return s.checkBudgets(budgets)
+}
+
+func (s *BudgetService) checkBudgets(budgets []models.Budget) error {
+	for i := range budgets {
+		status, err := s.evaluate(&budgets[i])
+		if err != nil {
+			s.log.Warnw("Failed to evaluate budget", "error", err, "budget_id", budgets[i].ID)
+			continue
+		}
+		s.alert(status)
+		if status.State == BudgetStateExceeded {
+			budget := status.Budget
+			return &BudgetExceededError{
+				BudgetID: budget.ID,
+				Name:     budget.Name,
+				Scope:    budget.Scope,
+				ScopeID:  budget.ScopeID,
+				Metric:   budget.Metric,
+				Period:   budget.Period,
+				Spent:    status.Spent,
+				Limit:    budget.HardLimit,
+			}
+		}
+	}
+	return nil
+}
+
+// resolveDramaID 从用量归属中解析剧本ID（只有章节ID时查询章节）
+func (s *BudgetService) resolveDramaID(scope UsageScope) uint {
+	if scope.DramaID != 0 || scope.EpisodeID == 0 {
+		return scope.DramaID
+	}
+	var episode models.Episode
+	if err := s.db.Select("id", "drama_id").First(&episode, scope.EpisodeID).Error; err != nil {
+		return 0
+	}
+	return episode.DramaID
+}
+
+func (s *BudgetService) applicableBudgets(check BudgetCheck) ([]models.Budget, error) {
+	query := s.db.Where("is_active = ?", true)
+	if check.ServiceType != "" {
+		query = query.Where("service_type = '' OR service_type IS NULL OR service_type = ?", check.ServiceType)
+	}
+
+	scopeCond := s.db.Where("scope = ?", BudgetScopeWorkspace)
+	if check.DramaID != 0 {
+		scopeCond = scopeCond.Or("scope = ? AND scope_id = ?", BudgetScopeDrama, check.DramaID)
+	}
+	if check.ConfigID != 0 {
+		scopeCond = scopeCond.Or("scope = ? AND scope_id = ?", BudgetScopeConfig, check.ConfigID)
+	}
+
+	var budgets []models.Budget
+	if err := query.Where(scopeCond).Order("id ASC").Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	return budgets, nil
+}
+
+// evaluate 计算预算在当前周期内的消耗（含进行中的图片/视频任务）
+func (s *BudgetService) evaluate(budget *models.Budget) (*BudgetStatus, error) {
+	spent, err := s.recordedUsage(budget)
+	if err != nil {
+		return nil, err
+	}
+	spent += s.inflightUsage(budget)
+
+	status := &BudgetStatus{Budget: *budget, Spent: spent, State: BudgetStateOK}
+	if budget.HardLimit > 0 {
+		status.Percent = spent / budget.HardLimit * 100
+	} else if budget.SoftLimit > 0 {
+		status.Percent = spent / budget.SoftLimit * 100
+	}
+
+	if budget.HardLimit > 0 && spent >= budget.HardLimit {
+		status.State = BudgetStateExceeded
+	} else if budget.SoftLimit > 0 && spent >= budget.SoftLimit {
+		status.State = BudgetStateWarning
+	}
+	return status, nil
+}
+
+func budgetMetricExpr(metric string) (string, error) {
+	switch metric {
+	case "", "cost":
+		return "sum(cost)", nil
+	case "tokens":
+		return "sum(total_tokens)", nil
+	case "images":
+		return "sum(image_count)", nil
+	case "video_seconds":
+		return "sum(video_seconds)", nil
+	case "calls":
+		return "count(*)", nil
+	}
+	return "", fmt.Errorf("unsupported budget metric: %s", metric)
+}
+
+// periodStart 返回当前周期起始日期（YYYY-MM-DD），total 返回空串
+func periodStart(period string, now time.Time) string {
+	switch period {
+	case "daily":
+		return now.Format("2006-01-02")
+	case "monthly":
+		return now.Format("2006-01") + "-01"
+	}
+	return ""
+}
+
+func (s *BudgetService) recordedUsage(budget *models.Budget) (float64, error) {
+	expr, err := budgetMetricExpr(budget.Metric)
+	if err != nil {
+		return 0, err
+	}
+
+	db := s.db.Model(&models.UsageRecord{})
+	switch budget.Scope {
+	case BudgetScopeDrama:
+		db = db.Where("drama_id = ?", budget.ScopeID)
+	case BudgetScopeConfig:
+		db = db.Where("config_id = ?", budget.ScopeID)
+	}
+	if budget.ServiceType != "" {
+		db = db.Where("service_type = ?", budget.ServiceType)
+	}
+	if budget.Metric == "" || budget.Metric == "cost" {
+		db = db.Where("currency = ?", budget.Currency)
+	}
+	if start := periodStart(budget.Period, time.Now()); start != "" {
+		db = db.Where("usage_date >= ?", start)
+	}
+
+	var total *float64
+	if err := db.Select(expr).Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	if total == nil {
+		return 0, nil
+	}
+	return *total, nil
+}
+
+// inflightUsage 统计已提交但尚未完成的图片/视频任务，避免批量任务在用量落账前突破配额
+func (s *BudgetService) inflightUsage(budget *models.Budget) float64 {
+	if budget.Scope == BudgetScopeConfig {
+		return 0
+	}
+
+	var since time.Time
+	if start := periodStart(budget.Period, time.Now()); start != "" {
+		since, _ = time.ParseInLocation("2006-01-02", start, time.Local)
+	}
+
+	var total float64
+	if (budget.ServiceType == "" || budget.ServiceType == "image") &&
+		(budget.Metric == "images" || budget.Metric == "calls") {
+		db := s.db.Model(&models.ImageGeneration{}).
+			Where("status IN ?", []models.ImageGenerationStatus{models.ImageStatusPending, models.ImageStatusProcessing}).
+			Where("created_at >= ?", since)
+		if budget.Scope == BudgetScopeDrama {
+			db = db.Where("drama_id = ?", budget.ScopeID)
+		}
+		var count int64
+		if err := db.Count(&count).Error; err == nil {
+			total += float64(count)
+		}
+	}
+
+	if (budget.ServiceType == "" || budget.ServiceType == "video") &&
+		(budget.Metric == "video_seconds" || budget.Metric == "calls") {
+		db := s.db.Model(&models.VideoGeneration{}).
+			Where("status IN ?", []models.VideoStatus{models.VideoStatusPending, models.VideoStatusProcessing}).
+			Where("created_at >= ?", since)
+		if budget.Scope == BudgetScopeDrama {
+			db = db.Where("drama_id = ?", budget.ScopeID)
+		}
+		if budget.Metric == "calls" {
+			var count int64
+			if err := db.Count(&count).Error; err == nil {
+				total += float64(count)
+			}
+		} else {
+			var seconds *float64
+			if err := db.Select("sum(coalesce(duration, 0))").Scan(&seconds).Error; err == nil && seconds != nil {
+				total += *seconds
+			}
+		}
+	}
+
+	return total
+}
+
+// alert 在同一周期内每个告警级别只触发一次
+func (s *BudgetService) alert(status *BudgetStatus) {
+	if status.State == BudgetStateOK {
+		return
+	}
+
+	budget := status.Budget
+	currentPeriod := periodStart(budget.Period, time.Now())
+	if budget.LastAlertLevel == status.State && budget.LastAlertPeriod == currentPeriod {
+		return
+	}
+
+	if status.State == BudgetStateExceeded {
+		s.log.Errorw("Budget hard limit reached, blocking new generation",
+			"budget_id", budget.ID, "name", budget.Name, "scope", budget.Scope, "scope_id", budget.ScopeID,
+			"metric", budget.Metric, "spent", status.Spent, "hard_limit", budget.HardLimit)
+	} else {
+		s.log.Warnw("Budget soft limit reached",
+			"budget_id", budget.ID, "name", budget.Name, "scope", budget.Scope, "scope_id", budget.ScopeID,
+			"metric", budget.Metric, "spent", status.Spent, "soft_limit", budget.SoftLimit)
+	}
+
+	now := time.Now()
+	s.db.Model(&models.Budget{}).Where("id = ?", budget.ID).Updates(map[string]interface{}{
+		"last_alert_level":  status.State,
+		"last_alert_period": currentPeriod,
+		"last_alert_at":     now,
+	})
+}
+
+// ListStatuses 返回预算及其当前消耗，dramaID 不为空时只返回工作区和该剧本的预算
+func (s *BudgetService) ListStatuses(dramaID *uint) ([]BudgetStatus, error) {
+	db := s.db.Order("id ASC")
+	if dramaID != nil {
+		db = db.Where("scope = ? OR (scope = ? AND scope_id = ?)", BudgetScopeWorkspace, BudgetScopeDrama, *dramaID)
+	}
+
+	var budgets []models.Budget
+	if err := db.Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for i := range budgets {
+		status, err := s.evaluate(&budgets[i])
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+type BudgetRequest struct {
+	Name        string  `json:"name"`
+	Scope       string  `json:"scope" binding:"required,oneof=workspace drama config"`
+	ScopeID     *uint   `json:"scope_id"`
+	ServiceType string  `json:"service_type" binding:"omitempty,oneof=text image video"`
+	Metric      string  `json:"metric" binding:"omitempty,oneof=cost tokens images video_seconds calls"`
+	Period      string  `json:"period" binding:"omitempty,oneof=total daily monthly"`
+	Currency    string  `json:"currency"`
+	SoftLimit   float64 `json:"soft_limit" binding:"min=0"`
+	HardLimit   float64 `json:"hard_limit" binding:"min=0"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+func (r *BudgetRequest) validate() error {
+	if r.Scope != BudgetScopeWorkspace && (r.ScopeID == nil || *r.ScopeID == 0) {
+		return &ValidationError{Message: "drama/config 预算必须指定 scope_id"}
+	}
+	if r.SoftLimit == 0 && r.HardLimit == 0 {
+		return &ValidationError{Message: "soft_limit 和 hard_limit 至少设置一个"}
+	}
+	if r.HardLimit > 0 && r.SoftLimit > r.HardLimit {
+		return &ValidationError{Message: "soft_limit 不能大于 hard_limit"}
+	}
+	return nil
+}
+
+func (r *BudgetRequest) apply(budget *models.Budget) {
+	budget.Name = r.Name
+	budget.Scope = r.Scope
+	budget.ScopeID = r.ScopeID
+	if r.Scope == BudgetScopeWorkspace {
+		budget.ScopeID = nil
+	}
+	budget.ServiceType = r.ServiceType
+	budget.Metric = r.Metric
+	if budget.Metric == "" {
+		budget.Metric = "cost"
+	}
+	budget.Period = r.Period
+	if budget.Period == "" {
+		budget.Period = "total"
+	}
+	budget.Currency = r.Currency
+	if budget.Currency == "" {
+		budget.Currency = "USD"
+	}
+	budget.SoftLimit = r.SoftLimit
+	budget.HardLimit = r.HardLimit
+	if r.IsActive != nil {
+		budget.IsActive = *r.IsActive
+	}
+	// 限额调整后重新允许告警
+	budget.LastAlertLevel = ""
+	budget.LastAlertPeriod = ""
+}
+
+func (s *BudgetService) CreateBudget(req *BudgetRequest) (*models.Budget, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	budget := &models.Budget{IsActive: true}
+	req.apply(budget)
+	if err := s.db.Create(budget).Error; err != nil {
+		return nil, err
+	}
+	return budget, nil
+}
+
+func (s *BudgetService) UpdateBudget(id uint, req *BudgetRequest) (*models.Budget, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	var budget models.Budget
+	if err := s.db.First(&budget, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("budget not found")
+		}
+		return nil, err
+	}
+
+	req.apply(&budget)
+	if err := s.db.Save(&budget).Error; err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func (s *BudgetService) DeleteBudget(id uint) error {
+	result := s.db.Delete(&models.Budget{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("budget not found")
+	}
+	return nil
+}
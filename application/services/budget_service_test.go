@@ -0,0 +1,83 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+func TestBudgetServiceCheckBlocksAtHardLimit(t *testing.T) {
+	usage, db := setupUsageService(t)
+	if err := db.AutoMigrate(&models.Budget{}, &models.ImageGeneration{}, &models.VideoGeneration{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	budgets := NewBudgetService(db, logger.NewLogger(true))
+	dramaID := uint(5)
+	if _, err := budgets.CreateBudget(&BudgetRequest{
+		Scope:     BudgetScopeDrama,
+		ScopeID:   &dramaID,
+		Metric:    "images",
+		SoftLimit: 1,
+		HardLimit: 2,
+	}); err != nil {
+		t.Fatalf("failed to create budget: %v", err)
+	}
+
+	config := &models.AIServiceConfig{ID: 1, Provider: "openai"}
+	usage.RecordImage(UsageScope{DramaID: dramaID}, config, "img-1", 1, "", nil)
+	if err := budgets.Check(BudgetCheck{DramaID: dramaID, ServiceType: "image"}); err != nil {
+		t.Fatalf("expected soft limit to only warn, got %v", err)
+	}
+
+	usage.RecordImage(UsageScope{DramaID: dramaID}, config, "img-1", 1, "", nil)
+	err := budgets.Check(BudgetCheck{DramaID: dramaID, ServiceType: "image"})
+	budgetErr, ok := IsBudgetExceededError(err)
+	if !ok {
+		t.Fatalf("expected budget exceeded error, got %v", err)
+	}
+	if budgetErr.Spent != 2 || budgetErr.Limit != 2 {
+		t.Fatalf("unexpected budget error: %+v", budgetErr)
+	}
+
+	// 其他剧本不受影响
+	if err := budgets.Check(BudgetCheck{DramaID: 6, ServiceType: "image"}); err != nil {
+		t.Fatalf("expected other drama to pass, got %v", err)
+	}
+}
+
+func TestBudgetServiceCreateInactiveBudget(t *testing.T) {
+	usage, db := setupUsageService(t)
+	if err := db.AutoMigrate(&models.Budget{}, &models.ImageGeneration{}, &models.VideoGeneration{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	budgets := NewBudgetService(db, logger.NewLogger(true))
+	dramaID := uint(5)
+	inactive := false
+	budget, err := budgets.CreateBudget(&BudgetRequest{
+		Scope:     BudgetScopeDrama,
+		ScopeID:   &dramaID,
+		Metric:    "images",
+		HardLimit: 1,
+		IsActive:  &inactive,
+	})
+	if err != nil {
+		t.Fatalf("failed to create budget: %v", err)
+	}
+
+	var stored models.Budget
+	if err := db.First(&stored, budget.ID).Error; err != nil {
+		t.Fatalf("failed to load budget: %v", err)
+	}
+	if stored.IsActive {
+		t.Fatalf("expected budget to be stored inactive")
+	}
+
+	// 停用的预算不参与检查
+	usage.RecordImage(UsageScope{DramaID: dramaID}, &models.AIServiceConfig{ID: 1, Provider: "openai"}, "img-1", 3, "", nil)
+	if err := budgets.Check(BudgetCheck{DramaID: dramaID, ServiceType: "image"}); err != nil {
+		t.Fatalf("expected inactive budget to be ignored, got %v", err)
+	}
+}
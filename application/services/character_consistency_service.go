@@ -0,0 +1,347 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	models "github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// consistencyMinScore 一致性评分低于该值的角色视为不一致
+const consistencyMinScore = 6.0
+
+// consistencyReferenceHint 按设定图重新生成时追加的提示词
+const consistencyReferenceHint = "keep the character appearance consistent with the reference character sheet"
+
+// CharacterConsistencyService 审查一集分镜图片中的角色是否与角色设定图一致，并按设定图重新生成不一致的分镜
+type CharacterConsistencyService struct {
+	db           *gorm.DB
+	aiService    *AIService
+	taskService  *TaskService
+	imageService *ImageGenerationService
+	log          *logger.Logger
+}
+
+func NewCharacterConsistencyService(db *gorm.DB, aiService *AIService, taskService *TaskService, imageService *ImageGenerationService, log *logger.Logger) *CharacterConsistencyService {
+	return &CharacterConsistencyService{
+		db:           db,
+		aiService:    aiService,
+		taskService:  taskService,
+		imageService: imageService,
+		log:          log,
+	}
+}
+
+// ConsistencyIssue 单个角色在某个分镜中的不一致情况
+type ConsistencyIssue struct {
+	CharacterID uint     `json:"character_id"`
+	Name        string   `json:"name"`
+	Score       float64  `json:"score"`
+	Issues      []string `json:"issues"`
+}
+
+// InconsistentShot 存在角色不一致的分镜
+type InconsistentShot struct {
+	StoryboardID     uint               `json:"storyboard_id"`
+	StoryboardNumber int                `json:"storyboard_number"`
+	ImageURL         string             `json:"image_url"`
+	Characters       []ConsistencyIssue `json:"characters"`
+}
+
+// ConsistencyAuditResult 审查任务结果
+type ConsistencyAuditResult struct {
+	Checked      int                `json:"checked"`      // 审查的分镜数
+	Inconsistent int                `json:"inconsistent"` // 存在不一致角色的分镜数
+	Failed       int                `json:"failed"`       // 视觉模型调用或解析失败的分镜数
+	Shots        []InconsistentShot `json:"shots"`
+}
+
+// consistencyVerdict 视觉模型对单个角色的判断，按提示词中给出的角色ID对应，避免重名或改写名称导致错配
+type consistencyVerdict struct {
+	CharacterID uint     `json:"character_id"`
+	Consistent  bool     `json:"consistent"`
+	Score       float64  `json:"score"`
+	Issues      []string `json:"issues"`
+}
+
+// StartAudit 创建角色一致性审查任务
+func (s *CharacterConsistencyService) StartAudit(episodeID uint) (string, error) {
+	var episode models.Episode
+	if err := s.db.First(&episode, episodeID).Error; err != nil {
+		return "", fmt.Errorf("episode not found")
+	}
+
+	var count int64
+	s.db.Model(&models.Storyboard{}).
+		Where("episode_id = ? AND composed_image IS NOT NULL AND composed_image <> ''", episodeID).
+		Where("id IN (?)", s.db.Table("storyboard_characters").Select("storyboard_id")).
+		Count(&count)
+	if count == 0 {
+		return "", &ValidationError{Message: "本集没有包含角色的分镜图片"}
+	}
+
+	task, err := s.taskService.CreateTaskWithParams("character_consistency_audit", fmt.Sprintf("%d", episode.ID), episodeTaskParams{EpisodeID: episode.ID})
+	if err != nil {
+		return "", fmt.Errorf("创建任务失败: %w", err)
+	}
+
+	go s.processAudit(task.ID, episode)
+
+	return task.ID, nil
+}
+
+func (s *CharacterConsistencyService) processAudit(taskID string, episode models.Episode) {
+	s.taskService.UpdateTaskStatus(taskID, "processing", 0, "正在读取分镜...")
+
+	var storyboards []models.Storyboard
+	if err := s.db.Preload("Characters").
+		Where("episode_id = ? AND composed_image IS NOT NULL AND composed_image <> ''", episode.ID).
+		Order("storyboard_number ASC").Find(&storyboards).Error; err != nil {
+		s.taskService.UpdateTaskError(taskID, err)
+		return
+	}
+
+	// 同一角色（同一造型）的设定图特征只提取一次
+	featureCache := map[[2]uint]string{}
+	scope := UsageScope{DramaID: episode.DramaID, EpisodeID: episode.ID, Operation: "consistency_audit"}
+	result := &ConsistencyAuditResult{Shots: []InconsistentShot{}}
+	var checks []models.CharacterConsistencyCheck
+	for i, storyboard := range storyboards {
+		looks := resolveActiveLooks(s.db, &storyboard)
+		features := map[uint]string{}
+		var characters []models.Character
+		for _, base := range storyboard.Characters {
+			key := [2]uint{base.ID, 0}
+			if look := looks[base.ID]; look != nil {
+				key[1] = look.ID
+			}
+			character := withLook(base, looks[base.ID])
+			if _, ok := featureCache[key]; !ok {
+				featureCache[key] = describeCharacterFeatures(s.aiService, s.log, &character, scope)
+			}
+			if featureCache[key] != "" {
+				features[character.ID] = featureCache[key]
+				characters = append(characters, character)
+			}
+		}
+		if len(characters) == 0 {
+			continue
+		}
+
+		s.taskService.UpdateTaskProgress(taskID, i*100/len(storyboards), fmt.Sprintf("正在审查第 %d 个分镜...", storyboard.StoryboardNumber), nil)
+		verdicts, err := s.auditStoryboard(*storyboard.ComposedImage, characters, features, scope)
+		if err != nil {
+			s.log.Warnw("Failed to audit storyboard", "storyboard_id", storyboard.ID, "error", err)
+			result.Failed++
+			continue
+		}
+		result.Checked++
+
+		shot := InconsistentShot{StoryboardID: storyboard.ID, StoryboardNumber: storyboard.StoryboardNumber, ImageURL: *storyboard.ComposedImage}
+		for _, character := range characters {
+			verdict, ok := verdicts[character.ID]
+			if !ok {
+				continue
+			}
+			consistent := verdict.Consistent && verdict.Score >= consistencyMinScore
+			issues, _ := json.Marshal(verdict.Issues)
+			checks = append(checks, models.CharacterConsistencyCheck{
+				EpisodeID:    episode.ID,
+				StoryboardID: storyboard.ID,
+				CharacterID:  character.ID,
+				TaskID:       taskID,
+				ImageURL:     *storyboard.ComposedImage,
+				Consistent:   consistent,
+				Score:        verdict.Score,
+				Issues:       issues,
+			})
+			if !consistent {
+				shot.Characters = append(shot.Characters, ConsistencyIssue{CharacterID: character.ID, Name: character.Name, Score: verdict.Score, Issues: verdict.Issues})
+			}
+		}
+		if len(shot.Characters) > 0 {
+			result.Inconsistent++
+			result.Shots = append(result.Shots, shot)
+		}
+	}
+
+	if result.Checked == 0 {
+		s.taskService.UpdateTaskError(taskID, errors.New("没有可审查的分镜：角色缺少设定图与外貌描述，或视觉模型调用全部失败"))
+		return
+	}
+
+	// 每次审查替换整集的结果
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("episode_id = ?", episode.ID).Delete(&models.CharacterConsistencyCheck{}).Error; err != nil {
+			return err
+		}
+		if len(checks) == 0 {
+			return nil
+		}
+		return tx.Create(&checks).Error
+	})
+	if err != nil {
+		s.taskService.UpdateTaskError(taskID, err)
+		return
+	}
+
+	s.log.Infow("Character consistency audit completed", "episode_id", episode.ID, "checked", result.Checked, "inconsistent", result.Inconsistent, "failed", result.Failed)
+	s.taskService.UpdateTaskResult(taskID, result)
+}
+
+// auditStoryboard 一次视觉调用审查分镜中的全部角色，返回按角色ID索引的判断；不在本次角色列表中的ID被忽略
+func (s *CharacterConsistencyService) auditStoryboard(imageURL string, characters []models.Character, features map[uint]string, scope UsageScope) (map[uint]consistencyVerdict, error) {
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return s.aiService.DescribeImage(imageURL, p, options...)
+	}
+	var verdicts []consistencyVerdict
+	if _, err := s.aiService.generateStructured(generate, buildConsistencyPrompt(characters, features), "verdicts", &verdicts, WithUsageScope(scope)); err != nil {
+		return nil, fmt.Errorf("failed to parse consistency result: %w", err)
+	}
+
+	wanted := make(map[uint]bool, len(characters))
+	for _, character := range characters {
+		wanted[character.ID] = true
+	}
+	byID := make(map[uint]consistencyVerdict, len(verdicts))
+	for _, verdict := range verdicts {
+		if !wanted[verdict.CharacterID] {
+			continue
+		}
+		verdict.Score = clampScore(verdict.Score)
+		byID[verdict.CharacterID] = verdict
+	}
+	return byID, nil
+}
+
+func buildConsistencyPrompt(characters []models.Character, features map[uint]string) string {
+	var sb strings.Builder
+	sb.WriteString("你是一名分镜连续性审查员。请判断这张分镜图片中以下每个角色的外貌是否与其设定一致，重点检查发型发色、服装配饰和面部特征。\n")
+	sb.WriteString("角色设定（方括号内为角色ID）：\n")
+	for _, character := range characters {
+		sb.WriteString(fmt.Sprintf("- [%d] %s：%s\n", character.ID, character.Name, features[character.ID]))
+	}
+	sb.WriteString("\n对每个角色输出一项，character_id 填写上面方括号中的角色ID，score 为 0-10 的一致性分数，issues 列出具体差异，一致时为空数组。")
+	return sb.String()
+}
+
+// GetAuditResults 获取一集最近一次审查的结果
+func (s *CharacterConsistencyService) GetAuditResults(episodeID uint, inconsistentOnly bool) ([]models.CharacterConsistencyCheck, error) {
+	query := s.db.Where("episode_id = ?", episodeID)
+	if inconsistentOnly {
+		query = query.Where("consistent = ?", false)
+	}
+	var checks []models.CharacterConsistencyCheck
+	if err := query.Order("storyboard_id ASC, character_id ASC").Find(&checks).Error; err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+// RegenerateStoryboard 以角色设定图作为参考图重新生成分镜图片。
+// characterIDs 为空时使用最近一次审查中不一致的角色
+func (s *CharacterConsistencyService) RegenerateStoryboard(storyboardID uint, characterIDs []uint) (*models.ImageGeneration, error) {
+	var storyboard models.Storyboard
+	if err := s.db.Preload("Characters").Preload("Episode").First(&storyboard, storyboardID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("storyboard not found")
+		}
+		return nil, err
+	}
+
+	if len(characterIDs) == 0 {
+		if err := s.db.Model(&models.CharacterConsistencyCheck{}).
+			Where("storyboard_id = ? AND consistent = ?", storyboardID, false).
+			Pluck("character_id", &characterIDs).Error; err != nil {
+			return nil, err
+		}
+		if len(characterIDs) == 0 {
+			return nil, &ValidationError{Message: "该分镜没有需要修正的角色"}
+		}
+	}
+	wanted := make(map[uint]bool, len(characterIDs))
+	for _, id := range characterIDs {
+		wanted[id] = true
+	}
+	looks := resolveActiveLooks(s.db, &storyboard)
+	var sheets []string
+	for _, base := range storyboard.Characters {
+		character := withLook(base, looks[base.ID])
+		if wanted[character.ID] && character.ImageURL != nil && *character.ImageURL != "" {
+			sheets = append(sheets, *character.ImageURL)
+		}
+	}
+	if len(sheets) == 0 {
+		return nil, &ValidationError{Message: "所选角色尚未生成设定图"}
+	}
+
+	// 沿用当前分镜图片的生成参数
+	var source models.ImageGeneration
+	query := s.db.Where("storyboard_id = ? AND status = ?", storyboardID, models.ImageStatusCompleted)
+	if storyboard.SelectedImageID != nil {
+		query = query.Where("id = ?", *storyboard.SelectedImageID)
+	} else if storyboard.ComposedImage != nil {
+		query = query.Where("image_url = ?", *storyboard.ComposedImage)
+	}
+	hasSource := query.Order("id DESC").First(&source).Error == nil
+
+	prompt := ""
+	if hasSource {
+		prompt = source.Prompt
+	} else if storyboard.ImagePrompt != nil {
+		prompt = *storyboard.ImagePrompt
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return nil, &ValidationError{Message: "分镜缺少图片提示词"}
+	}
+	if !strings.Contains(prompt, consistencyReferenceHint) {
+		prompt += ", " + consistencyReferenceHint
+	}
+
+	request := &GenerateImageRequest{
+		StoryboardID: &storyboard.ID,
+		DramaID:      fmt.Sprintf("%d", storyboard.Episode.DramaID),
+		ImageType:    string(models.ImageTypeStoryboard),
+		Prompt:       prompt,
+	}
+	var references []string
+	if hasSource {
+		request.FrameType = source.FrameType
+		request.NegativePrompt = source.NegPrompt
+		request.Provider = source.Provider
+		request.Model = source.Model
+		request.Quality = source.Quality
+		request.Style = source.Style
+		if len(source.ReferenceImages) > 0 {
+			json.Unmarshal(source.ReferenceImages, &references)
+		}
+	}
+	// 设定图放在最前，参考图数量受限的服务商优先使用设定图
+	for _, ref := range references {
+		if !containsString(sheets, ref) {
+			sheets = append(sheets, ref)
+		}
+	}
+	request.ReferenceImages = sheets
+
+	imageGen, err := s.imageService.GenerateImage(request)
+	if err != nil {
+		return nil, err
+	}
+	s.log.Infow("Storyboard regenerated with character sheets", "storyboard_id", storyboardID, "image_gen_id", imageGen.ID, "characters", characterIDs)
+	return imageGen, nil
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
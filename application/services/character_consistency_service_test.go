@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+func TestCharacterConsistencyRequiresAuditedShots(t *testing.T) {
+	db := newTestDB(t, &models.Drama{}, &models.Episode{}, &models.Character{}, &models.Storyboard{}, &models.ImageGeneration{}, &models.CharacterConsistencyCheck{}, &models.AsyncTask{})
+
+	log := logger.NewLogger(true)
+	cfg := &config.Config{}
+	imageService := NewImageGenerationService(db, cfg, nil, nil, log)
+	service := NewCharacterConsistencyService(db, NewAIService(db, log, cfg), NewTaskService(db, log), imageService, log)
+
+	drama := models.Drama{Title: "d"}
+	db.Create(&drama)
+	episode := models.Episode{DramaID: drama.ID, EpisodeNum: 1, Title: "e"}
+	db.Create(&episode)
+
+	// 分镜图片没有关联角色时无需审查
+	image := "/static/images/shot.png"
+	storyboard := models.Storyboard{EpisodeID: episode.ID, StoryboardNumber: 1, ComposedImage: &image}
+	db.Create(&storyboard)
+	if _, err := service.StartAudit(episode.ID); err == nil {
+		t.Fatalf("expected audit without characters to be rejected")
+	} else if _, ok := IsValidationError(err); !ok {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	noSheet := models.Character{DramaID: drama.ID, Name: "阿青"}
+	db.Create(&noSheet)
+	db.Model(&storyboard).Association("Characters").Append(&noSheet)
+
+	if _, err := service.RegenerateStoryboard(storyboard.ID, nil); err == nil || err.Error() != "该分镜没有需要修正的角色" {
+		t.Fatalf("expected missing audit result error, got %v", err)
+	}
+
+	db.Create(&models.CharacterConsistencyCheck{EpisodeID: episode.ID, StoryboardID: storyboard.ID, CharacterID: noSheet.ID, Consistent: false, Score: 3})
+	db.Create(&models.CharacterConsistencyCheck{EpisodeID: episode.ID, StoryboardID: storyboard.ID, CharacterID: noSheet.ID + 100, Consistent: true, Score: 9})
+
+	if _, err := service.RegenerateStoryboard(storyboard.ID, nil); err == nil || err.Error() != "所选角色尚未生成设定图" {
+		t.Fatalf("expected missing character sheet error, got %v", err)
+	}
+
+	checks, err := service.GetAuditResults(episode.ID, true)
+	if err != nil || len(checks) != 1 || checks[0].CharacterID != noSheet.ID {
+		t.Fatalf("expected only the inconsistent check, got %+v (err %v)", checks, err)
+	}
+}
+
+func TestCharacterConsistencyAuditMatchesVerdictsByCharacterID(t *testing.T) {
+	db := newTestDB(t, &models.Drama{}, &models.Episode{}, &models.Character{}, &models.Storyboard{}, &models.ImageGeneration{},
+		&models.CharacterConsistencyCheck{}, &models.CharacterLookAssignment{}, &models.AsyncTask{},
+		&models.AIServiceConfig{}, &models.UsageRecord{}, &models.ModelPrice{}, &models.Budget{}, &models.LLMCacheEntry{})
+
+	log := logger.NewLogger(true)
+	cfg := &config.Config{}
+	taskService := NewTaskService(db, log)
+	service := NewCharacterConsistencyService(db, NewAIService(db, log, cfg), taskService, NewImageGenerationService(db, cfg, nil, nil, log), log)
+	db.Create(&models.AIServiceConfig{ServiceType: "text", Provider: "mock", Name: "mock", BaseURL: "mock", APIKey: "mock", Model: models.ModelField{"mock-vision"}, IsActive: true})
+
+	drama := models.Drama{Title: "d"}
+	db.Create(&drama)
+	episode := models.Episode{DramaID: drama.ID, EpisodeNum: 1, Title: "e"}
+	db.Create(&episode)
+
+	// 两个同名角色：按名称匹配时会互相覆盖；模拟模型依次为ID 1、2、3 给出 1、2、3 分，ID 3 不在分镜中
+	appearance := "短发，青色长衫"
+	first := models.Character{DramaID: drama.ID, Name: "阿青", Appearance: &appearance}
+	second := models.Character{DramaID: drama.ID, Name: "阿青", Appearance: &appearance}
+	db.Create(&first)
+	db.Create(&second)
+	if first.ID != 1 || second.ID != 2 {
+		t.Fatalf("unexpected character ids %d, %d", first.ID, second.ID)
+	}
+	image := "data:image/png;base64,aGVsbG8="
+	storyboard := models.Storyboard{EpisodeID: episode.ID, StoryboardNumber: 1, ComposedImage: &image}
+	db.Create(&storyboard)
+	db.Model(&storyboard).Association("Characters").Append(&first, &second)
+
+	taskID, err := service.StartAudit(episode.ID)
+	if err != nil {
+		t.Fatalf("start audit failed: %v", err)
+	}
+	var task *models.AsyncTask
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if task, err = taskService.GetTask(taskID); err == nil && (task.Status == "completed" || task.Status == "failed") {
+			break
+		}
+	}
+	if task == nil || task.Status != "completed" {
+		t.Fatalf("expected audit to complete, got %+v", task)
+	}
+	if task.ResourceID != fmt.Sprintf("%d", episode.ID) {
+		t.Fatalf("expected task resource to be the episode, got %q", task.ResourceID)
+	}
+
+	var result ConsistencyAuditResult
+	if err := json.Unmarshal([]byte(task.Result), &result); err != nil {
+		t.Fatalf("invalid task result: %v", err)
+	}
+	if result.Checked != 1 || result.Inconsistent != 1 || len(result.Shots) != 1 || len(result.Shots[0].Characters) != 2 {
+		t.Fatalf("unexpected audit result %+v", result)
+	}
+
+	checks, err := service.GetAuditResults(episode.ID, false)
+	if err != nil || len(checks) != 2 {
+		t.Fatalf("expected one check per character, got %+v (err %v)", checks, err)
+	}
+	for _, check := range checks {
+		if check.Score != float64(check.CharacterID) || check.Consistent {
+			t.Fatalf("verdict matched to the wrong character: %+v", check)
+		}
+	}
+}
@@ -31,7 +31,7 @@ func NewCharacterLibraryService(db *gorm.DB, log *logger.Logger, cfg *config.Con
 		config:      cfg,
 		aiService:   NewAIService(db, log, cfg),
 		taskService: NewTaskService(db, log),
-		promptI18n:  NewPromptI18n(cfg),
+		promptI18n:  NewPromptI18n(cfg, db, log),
 		styleConsistencyService: NewStyleConsistencyService(cfg, log),
 	}
 }
@@ -578,8 +578,8 @@ func (s *CharacterLibraryService) BatchGenerateCharacterImages(characterIDs []st
 		"total", len(characterIDs))
 }
 
-// ExtractCharactersFromScript 从分集剧本中提取角色
-func (s *CharacterLibraryService) ExtractCharactersFromScript(episodeID uint) (string, error) {
+// ExtractCharactersFromScript 从分集剧本中提取角色，bypassCache 为 true 时不使用缓存的模型响应
+func (s *CharacterLibraryService) ExtractCharactersFromScript(episodeID uint, bypassCache bool) (string, error) {
 	var episode models.Episode
 	if err := s.db.First(&episode, episodeID).Error; err != nil {
 		return "", fmt.Errorf("episode not found")
@@ -589,17 +589,17 @@ func (s *CharacterLibraryService) ExtractCharactersFromScript(episodeID uint) (s
 		return "", fmt.Errorf("剧本内容为空")
 	}
 
-	task, err := s.taskService.CreateTask("character_extraction", fmt.Sprintf("%d", episode.DramaID))
+	task, err := s.taskService.CreateTaskWithParams("character_extraction", fmt.Sprintf("%d", episode.DramaID), episodeTaskParams{EpisodeID: episode.ID})
 	if err != nil {
 		return "", fmt.Errorf("创建任务失败: %w", err)
 	}
 
-	go s.processCharacterExtraction(task.ID, episode)
+	go s.processCharacterExtraction(task.ID, episode, NewLLMCacheStatus(bypassCache))
 
 	return task.ID, nil
 }
 
-func (s *CharacterLibraryService) processCharacterExtraction(taskID string, episode models.Episode) {
+func (s *CharacterLibraryService) processCharacterExtraction(taskID string, episode models.Episode, cacheStatus *LLMCacheStatus) {
 	s.taskService.UpdateTaskStatus(taskID, "processing", 0, "正在分析剧本...")
 
 	script := ""
@@ -607,17 +607,9 @@ func (s *CharacterLibraryService) processCharacterExtraction(taskID string, epis
 		script = *episode.ScriptContent
 	}
 
-	prompt := s.promptI18n.GetCharacterExtractionPrompt()
+	prompt := s.promptI18n.ForDrama(episode.DramaID).GetCharacterExtractionPrompt()
 	userPrompt := fmt.Sprintf("【剧本内容】\n%s", script)
 
-	response, err := s.aiService.GenerateText(userPrompt, prompt, ai.WithMaxTokens(3000))
-	if err != nil {
-		s.taskService.UpdateTaskError(taskID, err)
-		return
-	}
-
-	s.taskService.UpdateTaskStatus(taskID, "processing", 50, "正在整理角色数据...")
-
 	var extractedCharacters []struct {
 		Name        string `json:"name"`
 		Role        string `json:"role"`
@@ -626,17 +618,34 @@ func (s *CharacterLibraryService) processCharacterExtraction(taskID string, epis
 		Description string `json:"description"`
 	}
 
-	if err := utils.SafeParseAIJSON(response, &extractedCharacters); err != nil {
-		s.log.Errorw("Failed to parse AI response for characters", "error", err, "response", response)
-		s.taskService.UpdateTaskError(taskID, fmt.Errorf("解析AI响应失败"))
+	// 流式接收时每识别出一个角色就更新一次进度（角色总数未知，进度在 50% 前逐步逼近）
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		stream := utils.NewJSONArrayStream()
+		return s.aiService.GenerateTextStream(p, prompt, func(chunk string) {
+			if len(stream.Write(chunk)) == 0 {
+				return
+			}
+			count := stream.Count()
+			progress := 50 - 40/(count+1)
+			s.taskService.UpdateTaskProgress(taskID, progress, fmt.Sprintf("已识别 %d 个角色...", count), nil)
+		}, options...)
+	}
+
+	response, err := s.aiService.generateStructured(generate, userPrompt, "characters", &extractedCharacters, ai.WithMaxTokens(3000),
+		WithUsageScope(UsageScope{DramaID: episode.DramaID, EpisodeID: episode.ID, Operation: "character_extraction"}), cacheStatus.Option())
+	if err != nil {
+		s.log.Errorw("Failed to extract characters", "error", err, "response", response)
+		s.taskService.UpdateTaskError(taskID, err)
 		return
 	}
 
+	s.taskService.UpdateTaskStatus(taskID, "processing", 50, "正在整理角色数据...")
+
 	var savedCharacters []models.Character
 	for _, charData := range extractedCharacters {
-		// 检查是否已存在同名角色
+		// 检查是否已存在同名（或别名相同的）角色
 		var existingCharacter models.Character
-		err := s.db.Where("drama_id = ? AND name = ?", episode.DramaID, charData.Name).First(&existingCharacter).Error
+		err := findEntityByName(s.db, episode.DramaID, models.EntityTypeCharacter, charData.Name, &existingCharacter)
 
 		if err == nil {
 			// 如果存在，只关联，不更新（或者可以选更新，这里暂不更新）
@@ -670,5 +679,6 @@ func (s *CharacterLibraryService) processCharacterExtraction(taskID string, epis
 	s.taskService.UpdateTaskResult(taskID, map[string]interface{}{
 		"characters": savedCharacters,
 		"count":      len(savedCharacters),
+		"cache_hit":  cacheStatus.Hit(),
 	})
 }
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	models "github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// CharacterLookService 管理角色造型变体及其在章节、分镜上的生效范围
+type CharacterLookService struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewCharacterLookService(db *gorm.DB, log *logger.Logger) *CharacterLookService {
+	return &CharacterLookService{db: db, log: log}
+}
+
+type CharacterLookRequest struct {
+	Name            string    `json:"name"`
+	Appearance      *string   `json:"appearance"`
+	ImageURL        *string   `json:"image_url"`
+	ReferenceImages *[]string `json:"reference_images"`
+	SeedValue       *string   `json:"seed_value"`
+}
+
+type AssignCharacterLookRequest struct {
+	EpisodeID    *uint `json:"episode_id"`
+	StoryboardID *uint `json:"storyboard_id"`
+}
+
+// ListLooks 获取角色的全部造型
+func (s *CharacterLookService) ListLooks(characterID uint) ([]models.CharacterLook, error) {
+	var looks []models.CharacterLook
+	if err := s.db.Where("character_id = ?", characterID).Order("id ASC").Find(&looks).Error; err != nil {
+		return nil, err
+	}
+	return looks, nil
+}
+
+// CreateLook 为角色新增造型
+func (s *CharacterLookService) CreateLook(characterID uint, req *CharacterLookRequest) (*models.CharacterLook, error) {
+	var character models.Character
+	if err := s.db.First(&character, characterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("character not found")
+		}
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, &ValidationError{Message: "造型名称不能为空"}
+	}
+	if err := validateLookSeed(req.SeedValue); err != nil {
+		return nil, err
+	}
+
+	look := &models.CharacterLook{
+		CharacterID: character.ID,
+		Name:        name,
+		Appearance:  req.Appearance,
+		ImageURL:    req.ImageURL,
+		SeedValue:   req.SeedValue,
+	}
+	if req.ReferenceImages != nil {
+		data, err := json.Marshal(*req.ReferenceImages)
+		if err != nil {
+			return nil, err
+		}
+		look.ReferenceImages = datatypes.JSON(data)
+	}
+	if err := s.db.Create(look).Error; err != nil {
+		return nil, err
+	}
+
+	s.log.Infow("Character look created", "character_id", character.ID, "look_id", look.ID, "name", name)
+	return look, nil
+}
+
+// UpdateLook 更新造型，未提供的字段保持不变
+func (s *CharacterLookService) UpdateLook(lookID uint, req *CharacterLookRequest) (*models.CharacterLook, error) {
+	look, err := s.getLook(lookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateLookSeed(req.SeedValue); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	if req.Appearance != nil {
+		updates["appearance"] = req.Appearance
+	}
+	if req.ImageURL != nil {
+		updates["image_url"] = req.ImageURL
+	}
+	if req.ReferenceImages != nil {
+		data, err := json.Marshal(*req.ReferenceImages)
+		if err != nil {
+			return nil, err
+		}
+		updates["reference_images"] = datatypes.JSON(data)
+	}
+	if req.SeedValue != nil {
+		updates["seed_value"] = req.SeedValue
+	}
+	if len(updates) > 0 {
+		if err := s.db.Model(look).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return s.getLook(lookID)
+}
+
+// DeleteLook 删除造型及其全部生效范围，相关分镜恢复使用角色的基础设定
+func (s *CharacterLookService) DeleteLook(lookID uint) error {
+	if _, err := s.getLook(lookID); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("look_id = ?", lookID).Delete(&models.CharacterLookAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.CharacterLook{}, lookID).Error
+	})
+}
+
+// AssignLook 指定造型在某一章节或某个分镜生效。同一角色在同一范围内只能有一个造型，重复指定时替换
+func (s *CharacterLookService) AssignLook(lookID uint, req *AssignCharacterLookRequest) (*models.CharacterLookAssignment, error) {
+	if (req.EpisodeID == nil) == (req.StoryboardID == nil) {
+		return nil, &ValidationError{Message: "需要且只能指定 episode_id 或 storyboard_id 其中之一"}
+	}
+	look, err := s.getLook(lookID)
+	if err != nil {
+		return nil, err
+	}
+	var character models.Character
+	if err := s.db.First(&character, look.CharacterID).Error; err != nil {
+		return nil, errors.New("character not found")
+	}
+
+	// 校验章节/分镜与角色属于同一剧本
+	var episode models.Episode
+	if req.StoryboardID != nil {
+		var storyboard models.Storyboard
+		if err := s.db.First(&storyboard, *req.StoryboardID).Error; err != nil {
+			return nil, errors.New("storyboard not found")
+		}
+		if err := s.db.First(&episode, storyboard.EpisodeID).Error; err != nil {
+			return nil, errors.New("episode not found")
+		}
+	} else if err := s.db.First(&episode, *req.EpisodeID).Error; err != nil {
+		return nil, errors.New("episode not found")
+	}
+	if episode.DramaID != character.DramaID {
+		return nil, &ValidationError{Message: "章节或分镜不属于该角色所在的剧本"}
+	}
+
+	assignment := &models.CharacterLookAssignment{
+		CharacterID:  character.ID,
+		LookID:       look.ID,
+		EpisodeID:    req.EpisodeID,
+		StoryboardID: req.StoryboardID,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		scope := tx.Where("character_id = ?", character.ID)
+		if req.StoryboardID != nil {
+			scope = scope.Where("storyboard_id = ?", *req.StoryboardID)
+		} else {
+			scope = scope.Where("episode_id = ?", *req.EpisodeID)
+		}
+		if err := scope.Delete(&models.CharacterLookAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(assignment).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infow("Character look assigned", "character_id", character.ID, "look_id", look.ID, "episode_id", req.EpisodeID, "storyboard_id", req.StoryboardID)
+	return assignment, nil
+}
+
+// RemoveAssignment 取消造型的某个生效范围
+func (s *CharacterLookService) RemoveAssignment(assignmentID uint) error {
+	result := s.db.Delete(&models.CharacterLookAssignment{}, assignmentID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("assignment not found")
+	}
+	return nil
+}
+
+// ListEpisodeAssignments 获取章节及其分镜上的造型指定
+func (s *CharacterLookService) ListEpisodeAssignments(episodeID uint) ([]models.CharacterLookAssignment, error) {
+	var assignments []models.CharacterLookAssignment
+	err := s.db.Where("episode_id = ? OR storyboard_id IN (?)", episodeID,
+		s.db.Model(&models.Storyboard{}).Select("id").Where("episode_id = ?", episodeID)).
+		Order("id ASC").Find(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+func (s *CharacterLookService) getLook(lookID uint) (*models.CharacterLook, error) {
+	var look models.CharacterLook
+	if err := s.db.First(&look, lookID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("look not found")
+		}
+		return nil, err
+	}
+	return &look, nil
+}
+
+// resolveActiveLooks 返回分镜中各角色当前生效的造型：分镜级指定优先，其次章节级，未指定的角色不在结果中
+func resolveActiveLooks(db *gorm.DB, storyboard *models.Storyboard) map[uint]*models.CharacterLook {
+	if len(storyboard.Characters) == 0 {
+		return nil
+	}
+	characterIDs := make([]uint, 0, len(storyboard.Characters))
+	for _, character := range storyboard.Characters {
+		characterIDs = append(characterIDs, character.ID)
+	}
+
+	var assignments []models.CharacterLookAssignment
+	if err := db.Where("character_id IN ? AND (storyboard_id = ? OR episode_id = ?)", characterIDs, storyboard.ID, storyboard.EpisodeID).
+		Find(&assignments).Error; err != nil || len(assignments) == 0 {
+		return nil
+	}
+	lookIDs := map[uint]uint{}
+	for _, assignment := range assignments {
+		if _, ok := lookIDs[assignment.CharacterID]; ok && assignment.StoryboardID == nil {
+			continue
+		}
+		lookIDs[assignment.CharacterID] = assignment.LookID
+	}
+
+	ids := make([]uint, 0, len(lookIDs))
+	for _, id := range lookIDs {
+		ids = append(ids, id)
+	}
+	var looks []models.CharacterLook
+	if err := db.Where("id IN ?", ids).Find(&looks).Error; err != nil {
+		return nil
+	}
+	byID := make(map[uint]*models.CharacterLook, len(looks))
+	for i := range looks {
+		byID[looks[i].ID] = &looks[i]
+	}
+	active := make(map[uint]*models.CharacterLook, len(lookIDs))
+	for characterID, lookID := range lookIDs {
+		if look, ok := byID[lookID]; ok {
+			active[characterID] = look
+		}
+	}
+	return active
+}
+
+// validateLookSeed 造型种子用于出图，必须是整数；空字符串表示清除
+func validateLookSeed(seed *string) error {
+	if seed == nil || strings.TrimSpace(*seed) == "" {
+		return nil
+	}
+	if _, err := strconv.ParseInt(strings.TrimSpace(*seed), 10, 64); err != nil {
+		return &ValidationError{Message: "造型种子必须是整数"}
+	}
+	return nil
+}
+
+// resolveLookSeed 返回分镜中生效造型的出图种子，按分镜角色顺序取第一个设置了种子的造型，没有时返回 nil
+func resolveLookSeed(db *gorm.DB, storyboardID uint) *int64 {
+	var storyboard models.Storyboard
+	if err := db.Preload("Characters").First(&storyboard, storyboardID).Error; err != nil {
+		return nil
+	}
+	looks := resolveActiveLooks(db, &storyboard)
+	for _, character := range storyboard.Characters {
+		look := looks[character.ID]
+		if look == nil || look.SeedValue == nil {
+			continue
+		}
+		if seed, err := strconv.ParseInt(strings.TrimSpace(*look.SeedValue), 10, 64); err == nil {
+			return &seed
+		}
+	}
+	return nil
+}
+
+// withLook 返回应用造型后的角色副本：外貌、设定图与种子以造型为准，造型未填写的字段沿用基础设定
+func withLook(character models.Character, look *models.CharacterLook) models.Character {
+	if look == nil {
+		return character
+	}
+	if look.Appearance != nil && *look.Appearance != "" {
+		character.Appearance = look.Appearance
+	}
+	if look.ImageURL != nil && *look.ImageURL != "" {
+		character.ImageURL = look.ImageURL
+		character.ReferenceImages = look.ReferenceImages
+	}
+	if look.SeedValue != nil && *look.SeedValue != "" {
+		character.SeedValue = look.SeedValue
+	}
+	return character
+}
+
+// characterPromptLabel 提示词中的角色描述，有生效造型时附带造型名称与外貌
+func characterPromptLabel(character models.Character, look *models.CharacterLook) string {
+	if look == nil {
+		return character.Name
+	}
+	parts := []string{look.Name}
+	if look.Appearance != nil && strings.TrimSpace(*look.Appearance) != "" {
+		parts = append(parts, strings.TrimSpace(*look.Appearance))
+	}
+	return fmt.Sprintf("%s (%s)", character.Name, strings.Join(parts, ", "))
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+func TestCharacterLookResolution(t *testing.T) {
+	db := newTestDB(t, &models.Episode{}, &models.Character{}, &models.Prop{}, &models.Storyboard{},
+		&models.CharacterLook{}, &models.CharacterLookAssignment{})
+
+	episode := models.Episode{DramaID: 1, EpisodeNum: 1, Title: "第一集"}
+	otherEpisode := models.Episode{DramaID: 2, EpisodeNum: 1, Title: "其他剧本"}
+	db.Create(&episode)
+	db.Create(&otherEpisode)
+	baseSheet := "http://example.com/base.png"
+	character := models.Character{DramaID: 1, Name: "阿青", ImageURL: &baseSheet}
+	db.Create(&character)
+	sb1 := models.Storyboard{EpisodeID: episode.ID, StoryboardNumber: 1}
+	sb2 := models.Storyboard{EpisodeID: episode.ID, StoryboardNumber: 2}
+	db.Create(&sb1)
+	db.Create(&sb2)
+	db.Model(&sb1).Association("Characters").Append(&character)
+	db.Model(&sb2).Association("Characters").Append(&character)
+
+	service := NewCharacterLookService(db, logger.NewLogger(true))
+	armorSheet := "http://example.com/armor.png"
+	armorAppearance := "银色铠甲"
+	armor, err := service.CreateLook(character.ID, &CharacterLookRequest{Name: "战甲", Appearance: &armorAppearance, ImageURL: &armorSheet})
+	if err != nil {
+		t.Fatalf("CreateLook failed: %v", err)
+	}
+	injured, err := service.CreateLook(character.ID, &CharacterLookRequest{Name: "受伤"})
+	if err != nil {
+		t.Fatalf("CreateLook failed: %v", err)
+	}
+
+	if _, err := service.AssignLook(armor.ID, &AssignCharacterLookRequest{}); err == nil {
+		t.Fatal("expected validation error without scope")
+	}
+	if _, err := service.AssignLook(armor.ID, &AssignCharacterLookRequest{EpisodeID: &otherEpisode.ID}); err == nil {
+		t.Fatal("expected validation error for episode of another drama")
+	}
+	if _, err := service.AssignLook(injured.ID, &AssignCharacterLookRequest{EpisodeID: &episode.ID}); err != nil {
+		t.Fatalf("AssignLook failed: %v", err)
+	}
+	// 同一范围重复指定时替换
+	if _, err := service.AssignLook(armor.ID, &AssignCharacterLookRequest{EpisodeID: &episode.ID}); err != nil {
+		t.Fatalf("AssignLook failed: %v", err)
+	}
+	if _, err := service.AssignLook(injured.ID, &AssignCharacterLookRequest{StoryboardID: &sb2.ID}); err != nil {
+		t.Fatalf("AssignLook failed: %v", err)
+	}
+	assignments, _ := service.ListEpisodeAssignments(episode.ID)
+	if len(assignments) != 2 {
+		t.Fatalf("expected 2 assignments, got %d", len(assignments))
+	}
+
+	db.Preload("Characters").First(&sb1, sb1.ID)
+	db.Preload("Characters").First(&sb2, sb2.ID)
+	if look := resolveActiveLooks(db, &sb1)[character.ID]; look == nil || look.ID != armor.ID {
+		t.Fatalf("expected episode look on storyboard 1, got %+v", look)
+	}
+	if look := resolveActiveLooks(db, &sb2)[character.ID]; look == nil || look.ID != injured.ID {
+		t.Fatalf("expected storyboard look to override episode look, got %+v", look)
+	}
+
+	if label := characterPromptLabel(character, resolveActiveLooks(db, &sb1)[character.ID]); label != "阿青 (战甲, 银色铠甲)" {
+		t.Fatalf("unexpected prompt label: %s", label)
+	}
+
+	// 分镜 1 使用造型设定图，分镜 2 的造型没有设定图，沿用基础设定图
+	if refs := resolveSheetViews(db, sb1.ID, []string{baseSheet}); refs[0] != armorSheet {
+		t.Fatalf("expected look sheet, got %v", refs)
+	}
+	if refs := resolveSheetViews(db, sb2.ID, []string{baseSheet}); refs[0] != baseSheet {
+		t.Fatalf("expected base sheet, got %v", refs)
+	}
+
+	if err := service.DeleteLook(armor.ID); err != nil {
+		t.Fatalf("DeleteLook failed: %v", err)
+	}
+	if look := resolveActiveLooks(db, &sb1)[character.ID]; look != nil {
+		t.Fatalf("expected base character after deleting look, got %+v", look)
+	}
+}
+
+func TestLookSeedIsDefaultImageSeed(t *testing.T) {
+	db := newTestDB(t, &models.Drama{}, &models.Episode{}, &models.Character{}, &models.Prop{}, &models.Storyboard{},
+		&models.CharacterLook{}, &models.CharacterLookAssignment{}, &models.Budget{})
+
+	drama := models.Drama{Title: "d"}
+	db.Create(&drama)
+	episode := models.Episode{DramaID: drama.ID, EpisodeNum: 1, Title: "第一集"}
+	db.Create(&episode)
+	plain := models.Character{DramaID: drama.ID, Name: "路人"}
+	hero := models.Character{DramaID: drama.ID, Name: "阿青"}
+	db.Create(&plain)
+	db.Create(&hero)
+	storyboard := models.Storyboard{EpisodeID: episode.ID, StoryboardNumber: 1}
+	db.Create(&storyboard)
+	db.Model(&storyboard).Association("Characters").Append(&plain, &hero)
+
+	service := NewCharacterLookService(db, logger.NewLogger(true))
+	invalid := "abc"
+	if _, err := service.CreateLook(hero.ID, &CharacterLookRequest{Name: "战甲", SeedValue: &invalid}); err == nil {
+		t.Fatal("expected validation error for non-integer seed")
+	}
+	seed := "424242"
+	look, err := service.CreateLook(hero.ID, &CharacterLookRequest{Name: "战甲", SeedValue: &seed})
+	if err != nil {
+		t.Fatalf("CreateLook failed: %v", err)
+	}
+	if _, err := service.AssignLook(look.ID, &AssignCharacterLookRequest{EpisodeID: &episode.ID}); err != nil {
+		t.Fatalf("AssignLook failed: %v", err)
+	}
+
+	imageService := NewImageGenerationService(db, &config.Config{}, nil, nil, logger.NewLogger(true))
+	request := &GenerateImageRequest{DramaID: fmt.Sprint(drama.ID), StoryboardID: &storyboard.ID, Prompt: "p"}
+	imageGen, err := imageService.prepareImageGeneration(request)
+	if err != nil {
+		t.Fatalf("prepareImageGeneration failed: %v", err)
+	}
+	if imageGen.Seed == nil || *imageGen.Seed != 424242 {
+		t.Fatalf("expected look seed on the image request, got %v", imageGen.Seed)
+	}
+
+	// 调用方显式指定的种子优先
+	explicit := int64(7)
+	request = &GenerateImageRequest{DramaID: fmt.Sprint(drama.ID), StoryboardID: &storyboard.ID, Prompt: "p", Seed: &explicit}
+	if imageGen, err = imageService.prepareImageGeneration(request); err != nil || imageGen.Seed == nil || *imageGen.Seed != explicit {
+		t.Fatalf("expected explicit seed to win, got %v (%v)", imageGen.Seed, err)
+	}
+}
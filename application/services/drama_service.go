@@ -35,6 +35,7 @@ type CreateDramaRequest struct {
 	ReferenceWork  string `json:"reference_work"`
 	AspectRatio    string `json:"aspect_ratio"`
 	ReferenceImage string `json:"reference_image"`
+	Language       string `json:"language"`
 	Status         string `json:"status"`
 }
 
@@ -65,6 +66,7 @@ type UpdateDramaRequest struct {
 	ReferenceWork  string `json:"reference_work"`
 	AspectRatio    string `json:"aspect_ratio"`
 	ReferenceImage string `json:"reference_image"`
+	Language       string `json:"language"`
 }
 
 type DramaListQuery struct {
@@ -115,6 +117,12 @@ func (s *DramaService) CreateDrama(req *CreateDramaRequest) (*models.Drama, erro
 	if req.ReferenceImage != "" {
 		drama.ReferenceImage = &req.ReferenceImage
 	}
+	if req.Language != "" {
+		if !IsSupportedPromptLanguage(req.Language) {
+			return nil, &ValidationError{Message: "不支持的语言"}
+		}
+		drama.Language = req.Language
+	}
 	if req.Status != "" {
 		if !isValidDramaStatus(req.Status) {
 			return nil, &ValidationError{Message: "状态值不合法"}
@@ -364,6 +372,12 @@ func (s *DramaService) UpdateDrama(dramaID string, req *UpdateDramaRequest) (*mo
 	if req.ReferenceImage != "" {
 		updates["reference_image"] = req.ReferenceImage
 	}
+	if req.Language != "" {
+		if !IsSupportedPromptLanguage(req.Language) {
+			return nil, &ValidationError{Message: "不支持的语言"}
+		}
+		updates["language"] = req.Language
+	}
 	if req.Tags != "" {
 		updates["tags"] = req.Tags
 	}
@@ -416,9 +430,23 @@ func (s *DramaService) GetDramaStats() (map[string]interface{}, error) {
 		return nil, err
 	}
 
+	// 花费统计：按服务类型与币种汇总全部调用
+	spend, err := NewUsageService(s.db, s.log).Summarize(&UsageQuery{})
+	if err != nil {
+		return nil, err
+	}
+	totalCost := make(map[string]float64)
+	for _, row := range spend {
+		totalCost[row.Currency] += row.Cost
+	}
+
 	stats := map[string]interface{}{
 		"total":     total,
 		"by_status": byStatus,
+		"spend": map[string]interface{}{
+			"total_cost":      totalCost,
+			"by_service_type": spend,
+		},
 	}
 
 	return stats, nil
@@ -0,0 +1,695 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	models "github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// entityResolutionPrompt 合并建议的系统提示词
+const entityResolutionPrompt = `你是短剧剧本的设定整理助手。下面是从不同章节中分别提取出的%s列表，同一个%s可能因为称呼不同（全名、昵称、职称、中英文名、描述性说法）被重复提取。
+请找出指向同一个%s的条目并分组：
+1. 只有确定是同一个%s时才分组，名字相似但身份不同的不要合并
+2. 每组选一个信息最完整的条目作为 survivor_id，其余放入 duplicate_ids
+3. reason 用一句话说明判断依据
+没有重复时返回空数组。`
+
+var entityTypeLabels = map[string]string{
+	models.EntityTypeCharacter: "角色",
+	models.EntityTypeProp:      "道具",
+	models.EntityTypeScene:     "场景",
+}
+
+// EntityResolutionService 管理角色、道具、场景的别名，并借助大模型发现跨章节重复提取的实体后合并
+type EntityResolutionService struct {
+	db          *gorm.DB
+	aiService   *AIService
+	taskService *TaskService
+	log         *logger.Logger
+}
+
+func NewEntityResolutionService(db *gorm.DB, aiService *AIService, taskService *TaskService, log *logger.Logger) *EntityResolutionService {
+	return &EntityResolutionService{
+		db:          db,
+		aiService:   aiService,
+		taskService: taskService,
+		log:         log,
+	}
+}
+
+type AddEntityAliasRequest struct {
+	EntityType string `json:"entity_type" binding:"required"`
+	EntityID   uint   `json:"entity_id" binding:"required"`
+	Alias      string `json:"alias" binding:"required"`
+}
+
+type MergeEntitiesRequest struct {
+	EntityType   string `json:"entity_type" binding:"required"`
+	SurvivorID   uint   `json:"survivor_id" binding:"required"`
+	DuplicateIDs []uint `json:"duplicate_ids" binding:"required"`
+}
+
+// EntityMergeProposal 一组疑似重复的实体
+type EntityMergeProposal struct {
+	EntityType     string   `json:"entity_type"`
+	SurvivorID     uint     `json:"survivor_id"`
+	SurvivorName   string   `json:"survivor_name"`
+	DuplicateIDs   []uint   `json:"duplicate_ids"`
+	DuplicateNames []string `json:"duplicate_names"`
+	Reason         string   `json:"reason"`
+}
+
+// EntityMergeResult 合并结果
+type EntityMergeResult struct {
+	EntityType   string   `json:"entity_type"`
+	SurvivorID   uint     `json:"survivor_id"`
+	MergedIDs    []uint   `json:"merged_ids"`
+	LinkedIDs    []uint   `json:"linked_ids,omitempty"` // 其他章节的重复场景：引用已改指向 survivor，场景本身保留在其章节中
+	AddedAliases []string `json:"added_aliases"`
+}
+
+// entityCandidate 提交给大模型的实体摘要
+type entityCandidate struct {
+	ID       uint
+	DramaID  uint
+	Name     string
+	Detail   string
+	Episodes []int
+}
+
+// normalizeEntityName 别名匹配时忽略大小写与多余空白
+func normalizeEntityName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func entityNameColumn(entityType string) string {
+	if entityType == models.EntityTypeScene {
+		return "location"
+	}
+	return "name"
+}
+
+func entityModel(entityType string) (interface{}, error) {
+	switch entityType {
+	case models.EntityTypeCharacter:
+		return &models.Character{}, nil
+	case models.EntityTypeProp:
+		return &models.Prop{}, nil
+	case models.EntityTypeScene:
+		return &models.Scene{}, nil
+	}
+	return nil, &ValidationError{Message: "entity_type 只能是 character、prop 或 scene"}
+}
+
+// findEntityByName 先按名称（场景为地点）精确匹配，再按别名匹配，均未找到时返回 gorm.ErrRecordNotFound
+func findEntityByName(db *gorm.DB, dramaID uint, entityType, name string, dest interface{}) error {
+	err := db.Where("drama_id = ? AND "+entityNameColumn(entityType)+" = ?", dramaID, name).Order("id ASC").First(dest).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	var alias models.EntityAlias
+	if err := db.Where("drama_id = ? AND entity_type = ? AND normalized = ?", dramaID, entityType, normalizeEntityName(name)).
+		First(&alias).Error; err != nil {
+		return err
+	}
+	return db.First(dest, alias.EntityID).Error
+}
+
+// retargetSceneReferences 章节重新提取会删除旧场景，把指向这些场景的别名与分镜（包括合并后引用它的其他章节分镜）
+// 改指向同地点的现存场景；没有同地点场景时删除别名，分镜保持不变
+func retargetSceneReferences(tx *gorm.DB, dramaID uint, removed []models.Scene) error {
+	for _, old := range removed {
+		var replacement models.Scene
+		err := tx.Where("drama_id = ? AND location = ?", dramaID, old.Location).Order("id DESC").First(&replacement).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := tx.Where("drama_id = ? AND entity_type = ? AND entity_id = ?", dramaID, models.EntityTypeScene, old.ID).
+				Delete(&models.EntityAlias{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.EntityAlias{}).
+			Where("drama_id = ? AND entity_type = ? AND entity_id = ?", dramaID, models.EntityTypeScene, old.ID).
+			Update("entity_id", replacement.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Storyboard{}).Where("scene_id = ?", old.ID).Update("scene_id", replacement.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canonicalSceneLocation 将场景地点的别名还原为已合并场景的地点，便于复用已生成的背景图
+func canonicalSceneLocation(db *gorm.DB, dramaID uint, location string) string {
+	var scene models.Scene
+	if err := findEntityByName(db, dramaID, models.EntityTypeScene, location, &scene); err != nil {
+		return location
+	}
+	return scene.Location
+}
+
+// entityInfo 返回实体所属剧本与名称
+func entityInfo(db *gorm.DB, entityType string, entityID uint) (uint, string, error) {
+	var dramaID uint
+	var name string
+	var err error
+	switch entityType {
+	case models.EntityTypeCharacter:
+		var character models.Character
+		err = db.First(&character, entityID).Error
+		dramaID, name = character.DramaID, character.Name
+	case models.EntityTypeProp:
+		var prop models.Prop
+		err = db.First(&prop, entityID).Error
+		dramaID, name = prop.DramaID, prop.Name
+	case models.EntityTypeScene:
+		var scene models.Scene
+		err = db.First(&scene, entityID).Error
+		dramaID, name = scene.DramaID, scene.Location
+	default:
+		return 0, "", &ValidationError{Message: "entity_type 只能是 character、prop 或 scene"}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", errors.New("entity not found")
+	}
+	return dramaID, name, err
+}
+
+// AddAlias 为实体添加别名，同一剧本内一个别名只能指向一个同类实体
+func (s *EntityResolutionService) AddAlias(req *AddEntityAliasRequest) (*models.EntityAlias, error) {
+	dramaID, _, err := entityInfo(s.db, req.EntityType, req.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	alias, err := addEntityAlias(s.db, dramaID, req.EntityType, req.EntityID, req.Alias)
+	if err != nil {
+		return nil, err
+	}
+	if alias.EntityID != req.EntityID {
+		return nil, &ValidationError{Message: fmt.Sprintf("别名“%s”已被同剧本的其他%s使用", alias.Alias, entityTypeLabels[req.EntityType])}
+	}
+	return alias, nil
+}
+
+// addEntityAlias 创建别名；别名已存在时返回已有记录，由调用方判断是否指向同一实体
+func addEntityAlias(db *gorm.DB, dramaID uint, entityType string, entityID uint, alias string) (*models.EntityAlias, error) {
+	alias = strings.TrimSpace(alias)
+	normalized := normalizeEntityName(alias)
+	if normalized == "" {
+		return nil, &ValidationError{Message: "别名不能为空"}
+	}
+
+	var existing models.EntityAlias
+	err := db.Where("drama_id = ? AND entity_type = ? AND normalized = ?", dramaID, entityType, normalized).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	record := &models.EntityAlias{
+		DramaID:    dramaID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Alias:      alias,
+		Normalized: normalized,
+	}
+	if err := db.Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ListAliases 获取剧本的别名，entityType 为空时返回全部类型
+func (s *EntityResolutionService) ListAliases(dramaID uint, entityType string) ([]models.EntityAlias, error) {
+	query := s.db.Where("drama_id = ?", dramaID)
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	var aliases []models.EntityAlias
+	if err := query.Order("entity_type ASC, entity_id ASC, id ASC").Find(&aliases).Error; err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// DeleteAlias 删除别名
+func (s *EntityResolutionService) DeleteAlias(aliasID uint) error {
+	result := s.db.Delete(&models.EntityAlias{}, aliasID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("alias not found")
+	}
+	return nil
+}
+
+// ProposeMerges 创建合并建议任务，entityType 为空时依次检查角色、道具、场景
+func (s *EntityResolutionService) ProposeMerges(dramaID uint, entityType string) (string, error) {
+	var drama models.Drama
+	if err := s.db.First(&drama, dramaID).Error; err != nil {
+		return "", fmt.Errorf("drama not found")
+	}
+	if entityType != "" {
+		if _, err := entityModel(entityType); err != nil {
+			return "", err
+		}
+	}
+
+	task, err := s.taskService.CreateTaskWithParams("entity_merge_proposal", fmt.Sprintf("%d", dramaID), entityMergeTaskParams{DramaID: dramaID, EntityType: entityType})
+	if err != nil {
+		return "", fmt.Errorf("创建任务失败: %w", err)
+	}
+
+	go s.processProposals(task.ID, dramaID, entityType)
+
+	return task.ID, nil
+}
+
+func (s *EntityResolutionService) processProposals(taskID string, dramaID uint, entityType string) {
+	s.taskService.UpdateTaskStatus(taskID, "processing", 0, "正在整理实体列表...")
+
+	types := []string{models.EntityTypeCharacter, models.EntityTypeProp, models.EntityTypeScene}
+	if entityType != "" {
+		types = []string{entityType}
+	}
+
+	proposals := []EntityMergeProposal{}
+	for i, t := range types {
+		candidates, err := s.loadCandidates(dramaID, t)
+		if err != nil {
+			s.taskService.UpdateTaskError(taskID, err)
+			return
+		}
+		if len(candidates) < 2 {
+			continue
+		}
+
+		s.taskService.UpdateTaskProgress(taskID, i*100/len(types), fmt.Sprintf("正在分析重复的%s...", entityTypeLabels[t]), nil)
+		groups, err := s.proposeGroups(dramaID, t, candidates)
+		if err != nil {
+			s.log.Errorw("Failed to propose entity merges", "drama_id", dramaID, "entity_type", t, "error", err)
+			s.taskService.UpdateTaskError(taskID, fmt.Errorf("解析AI结果失败: %w", err))
+			return
+		}
+		proposals = append(proposals, groups...)
+	}
+
+	s.log.Infow("Entity merge proposals generated", "drama_id", dramaID, "entity_type", entityType, "proposals", len(proposals))
+	s.taskService.UpdateTaskResult(taskID, map[string]interface{}{
+		"proposals": proposals,
+		"count":     len(proposals),
+	})
+}
+
+// proposeGroups 调用大模型分组，并丢弃引用了不存在条目或重复使用条目的分组
+func (s *EntityResolutionService) proposeGroups(dramaID uint, entityType string, candidates []entityCandidate) ([]EntityMergeProposal, error) {
+	label := entityTypeLabels[entityType]
+	var sb strings.Builder
+	for _, c := range candidates {
+		sb.WriteString(fmt.Sprintf("[%d] %s", c.ID, c.Name))
+		if c.Detail != "" {
+			sb.WriteString(" | " + c.Detail)
+		}
+		if len(c.Episodes) > 0 {
+			episodes := make([]string, 0, len(c.Episodes))
+			for _, n := range c.Episodes {
+				episodes = append(episodes, fmt.Sprintf("%d", n))
+			}
+			sb.WriteString(" | 出现章节: " + strings.Join(episodes, ","))
+		}
+		sb.WriteString("\n")
+	}
+
+	var groups []struct {
+		SurvivorID   uint   `json:"survivor_id"`
+		DuplicateIDs []uint `json:"duplicate_ids"`
+		Reason       string `json:"reason"`
+	}
+	systemPrompt := fmt.Sprintf(entityResolutionPrompt, label, label, label, label)
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return s.aiService.GenerateText(p, systemPrompt, options...)
+	}
+	if _, err := s.aiService.generateStructured(generate, fmt.Sprintf("【%s列表】\n%s", label, sb.String()), "groups", &groups, ai.WithMaxTokens(2000),
+		WithUsageScope(UsageScope{DramaID: dramaID, Operation: "entity_resolution"})); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]entityCandidate, len(candidates))
+	for _, c := range candidates {
+		byID[c.ID] = c
+	}
+	used := map[uint]bool{}
+	var proposals []EntityMergeProposal
+	for _, group := range groups {
+		survivor, ok := byID[group.SurvivorID]
+		if !ok || used[survivor.ID] {
+			continue
+		}
+		proposal := EntityMergeProposal{EntityType: entityType, SurvivorID: survivor.ID, SurvivorName: survivor.Name, Reason: group.Reason}
+		for _, id := range group.DuplicateIDs {
+			duplicate, ok := byID[id]
+			if !ok || id == survivor.ID || used[id] {
+				continue
+			}
+			used[id] = true
+			proposal.DuplicateIDs = append(proposal.DuplicateIDs, id)
+			proposal.DuplicateNames = append(proposal.DuplicateNames, duplicate.Name)
+		}
+		if len(proposal.DuplicateIDs) == 0 {
+			continue
+		}
+		used[survivor.ID] = true
+		proposals = append(proposals, proposal)
+	}
+	return proposals, nil
+}
+
+// loadCandidates 读取剧本中某类实体的名称、简介与出现的章节
+func (s *EntityResolutionService) loadCandidates(dramaID uint, entityType string) ([]entityCandidate, error) {
+	var candidates []entityCandidate
+	switch entityType {
+	case models.EntityTypeCharacter:
+		var characters []models.Character
+		if err := s.db.Preload("Episodes").Where("drama_id = ?", dramaID).Order("id ASC").Find(&characters).Error; err != nil {
+			return nil, err
+		}
+		for _, c := range characters {
+			candidate := entityCandidate{ID: c.ID, DramaID: c.DramaID, Name: c.Name, Detail: joinNonEmpty(c.Role, c.Description)}
+			for _, e := range c.Episodes {
+				candidate.Episodes = append(candidate.Episodes, e.EpisodeNum)
+			}
+			candidates = append(candidates, candidate)
+		}
+	case models.EntityTypeProp:
+		var props []models.Prop
+		if err := s.db.Where("drama_id = ?", dramaID).Order("id ASC").Find(&props).Error; err != nil {
+			return nil, err
+		}
+		for _, p := range props {
+			candidate := entityCandidate{ID: p.ID, DramaID: p.DramaID, Name: p.Name, Detail: joinNonEmpty(p.Type, p.Description)}
+			s.db.Model(&models.Episode{}).
+				Where("id IN (?)", s.db.Table("episode_props").Select("episode_id").Where("prop_id = ?", p.ID)).
+				Order("episode_number ASC").Pluck("episode_number", &candidate.Episodes)
+			candidates = append(candidates, candidate)
+		}
+	case models.EntityTypeScene:
+		var scenes []models.Scene
+		if err := s.db.Where("drama_id = ?", dramaID).Order("id ASC").Find(&scenes).Error; err != nil {
+			return nil, err
+		}
+		episodeNums := map[uint]int{}
+		var episodes []models.Episode
+		s.db.Where("drama_id = ?", dramaID).Find(&episodes)
+		for _, e := range episodes {
+			episodeNums[e.ID] = e.EpisodeNum
+		}
+		for _, sc := range scenes {
+			candidate := entityCandidate{ID: sc.ID, DramaID: sc.DramaID, Name: sc.Location, Detail: sc.Time}
+			if sc.EpisodeID != nil {
+				if n, ok := episodeNums[*sc.EpisodeID]; ok {
+					candidate.Episodes = []int{n}
+				}
+			}
+			candidates = append(candidates, candidate)
+		}
+	default:
+		return nil, &ValidationError{Message: "entity_type 只能是 character、prop 或 scene"}
+	}
+	return candidates, nil
+}
+
+func joinNonEmpty(values ...*string) string {
+	var parts []string
+	for _, v := range values {
+		if v != nil && strings.TrimSpace(*v) != "" {
+			parts = append(parts, strings.TrimSpace(*v))
+		}
+	}
+	return strings.Join(parts, "；")
+}
+
+// MergeEntities 将重复实体合并到 survivor：分镜、章节与道具关联、生成记录等引用改指向 survivor，
+// survivor 缺失的信息从重复实体补全，重复实体的名称与别名转为 survivor 的别名，最后删除重复实体。
+// 场景属于各自章节，其他章节的重复场景不删除，见 mergeScenes
+func (s *EntityResolutionService) MergeEntities(req *MergeEntitiesRequest) (*EntityMergeResult, error) {
+	if _, err := entityModel(req.EntityType); err != nil {
+		return nil, err
+	}
+	dramaID, survivorName, err := entityInfo(s.db, req.EntityType, req.SurvivorID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[uint]bool{req.SurvivorID: true}
+	var duplicateIDs []uint
+	var duplicateNames []string
+	for _, id := range req.DuplicateIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		dupDramaID, name, err := entityInfo(s.db, req.EntityType, id)
+		if err != nil {
+			return nil, err
+		}
+		if dupDramaID != dramaID {
+			return nil, &ValidationError{Message: "只能合并同一剧本中的实体"}
+		}
+		duplicateIDs = append(duplicateIDs, id)
+		duplicateNames = append(duplicateNames, name)
+	}
+	if len(duplicateIDs) == 0 {
+		return nil, &ValidationError{Message: "没有需要合并的实体"}
+	}
+
+	result := &EntityMergeResult{EntityType: req.EntityType, SurvivorID: req.SurvivorID, MergedIDs: duplicateIDs, AddedAliases: []string{}}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		switch req.EntityType {
+		case models.EntityTypeCharacter:
+			err = mergeCharacters(tx, req.SurvivorID, duplicateIDs)
+		case models.EntityTypeProp:
+			err = mergeProps(tx, req.SurvivorID, duplicateIDs)
+		case models.EntityTypeScene:
+			result.MergedIDs, result.LinkedIDs, err = mergeScenes(tx, req.SurvivorID, duplicateIDs)
+		}
+		if err != nil {
+			return err
+		}
+
+		// 重复实体原有的别名转移到 survivor，名称作为新别名
+		if err := tx.Model(&models.EntityAlias{}).
+			Where("drama_id = ? AND entity_type = ? AND entity_id IN ?", dramaID, req.EntityType, duplicateIDs).
+			Update("entity_id", req.SurvivorID).Error; err != nil {
+			return err
+		}
+		for _, name := range duplicateNames {
+			if normalizeEntityName(name) == normalizeEntityName(survivorName) {
+				continue
+			}
+			alias, err := addEntityAlias(tx, dramaID, req.EntityType, req.SurvivorID, name)
+			if err != nil {
+				return err
+			}
+			if alias.EntityID != req.SurvivorID {
+				// 该名称已是其他实体的别名，保留原有指向
+				continue
+			}
+			result.AddedAliases = append(result.AddedAliases, alias.Alias)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infow("Entities merged", "entity_type", req.EntityType, "survivor_id", req.SurvivorID, "merged_ids", result.MergedIDs, "linked_ids", result.LinkedIDs)
+	return result, nil
+}
+
+// repointJoinTable 将多对多关联表中重复实体的行改指向 survivor，survivor 已有的关联直接删除避免主键冲突
+func repointJoinTable(tx *gorm.DB, table, column, otherColumn string, survivorID uint, duplicateIDs []uint) error {
+	for _, id := range duplicateIDs {
+		var linked []uint
+		if err := tx.Table(table).Where(column+" = ?", survivorID).Pluck(otherColumn, &linked).Error; err != nil {
+			return err
+		}
+		if len(linked) > 0 {
+			if err := tx.Table(table).Where(column+" = ? AND "+otherColumn+" IN ?", id, linked).Delete(map[string]interface{}{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Table(table).Where(column+" = ?", id).Update(column, survivorID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillMissing 为 survivor 中为空的字段补上重复实体的值
+func fillMissing(updates map[string]interface{}, column string, survivor, duplicate *string) {
+	if _, ok := updates[column]; ok {
+		return
+	}
+	if (survivor == nil || *survivor == "") && duplicate != nil && *duplicate != "" {
+		updates[column] = *duplicate
+	}
+}
+
+func mergeCharacters(tx *gorm.DB, survivorID uint, duplicateIDs []uint) error {
+	var survivor models.Character
+	if err := tx.First(&survivor, survivorID).Error; err != nil {
+		return err
+	}
+	var duplicates []models.Character
+	if err := tx.Where("id IN ?", duplicateIDs).Order("id ASC").Find(&duplicates).Error; err != nil {
+		return err
+	}
+	updates := map[string]interface{}{}
+	for _, d := range duplicates {
+		fillMissing(updates, "role", survivor.Role, d.Role)
+		fillMissing(updates, "description", survivor.Description, d.Description)
+		fillMissing(updates, "appearance", survivor.Appearance, d.Appearance)
+		fillMissing(updates, "personality", survivor.Personality, d.Personality)
+		fillMissing(updates, "voice_style", survivor.VoiceStyle, d.VoiceStyle)
+		if _, ok := updates["image_url"]; !ok && (survivor.ImageURL == nil || *survivor.ImageURL == "") && d.ImageURL != nil && *d.ImageURL != "" {
+			updates["image_url"] = *d.ImageURL
+			updates["reference_images"] = d.ReferenceImages
+		}
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&survivor).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, join := range [][2]string{{"storyboard_characters", "storyboard_id"}, {"episode_characters", "episode_id"}, {"character_props", "prop_id"}} {
+		if err := repointJoinTable(tx, join[0], "character_id", join[1], survivorID, duplicateIDs); err != nil {
+			return err
+		}
+	}
+	for _, model := range []interface{}{&models.ImageGeneration{}, &models.CharacterLook{}, &models.CharacterLookAssignment{}, &models.CharacterConsistencyCheck{}} {
+		if err := tx.Model(model).Where("character_id IN ?", duplicateIDs).Update("character_id", survivorID).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Where("id IN ?", duplicateIDs).Delete(&models.Character{}).Error
+}
+
+func mergeProps(tx *gorm.DB, survivorID uint, duplicateIDs []uint) error {
+	var survivor models.Prop
+	if err := tx.First(&survivor, survivorID).Error; err != nil {
+		return err
+	}
+	var duplicates []models.Prop
+	if err := tx.Where("id IN ?", duplicateIDs).Order("id ASC").Find(&duplicates).Error; err != nil {
+		return err
+	}
+	updates := map[string]interface{}{}
+	for _, d := range duplicates {
+		fillMissing(updates, "type", survivor.Type, d.Type)
+		fillMissing(updates, "description", survivor.Description, d.Description)
+		fillMissing(updates, "prompt", survivor.Prompt, d.Prompt)
+		if _, ok := updates["image_url"]; !ok && (survivor.ImageURL == nil || *survivor.ImageURL == "") && d.ImageURL != nil && *d.ImageURL != "" {
+			updates["image_url"] = *d.ImageURL
+			updates["reference_images"] = d.ReferenceImages
+		}
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&survivor).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, join := range [][2]string{{"storyboard_props", "storyboard_id"}, {"episode_props", "episode_id"}, {"character_props", "character_id"}, {"scene_props", "scene_id"}} {
+		if err := repointJoinTable(tx, join[0], "prop_id", join[1], survivorID, duplicateIDs); err != nil {
+			return err
+		}
+	}
+	for _, model := range []interface{}{&models.ImageGeneration{}, &models.PropLibrary{}} {
+		if err := tx.Model(model).Where("prop_id IN ?", duplicateIDs).Update("prop_id", survivorID).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Where("id IN ?", duplicateIDs).Delete(&models.Prop{}).Error
+}
+
+// mergeScenes 将所有重复场景的分镜、道具与生成记录改指向 survivor。场景按章节提取，重新提取时会整体替换该章节的场景，
+// 因此只删除与 survivor 同一章节的重复场景；其他章节的重复场景保留在其章节的场景列表中，缺少背景图时复用 survivor 的图片，
+// 其名称同样记为 survivor 的别名，该章节重新提取时据此找回背景图。返回被删除与被保留的场景ID
+func mergeScenes(tx *gorm.DB, survivorID uint, duplicateIDs []uint) ([]uint, []uint, error) {
+	var survivor models.Scene
+	if err := tx.First(&survivor, survivorID).Error; err != nil {
+		return nil, nil, err
+	}
+	var duplicates []models.Scene
+	if err := tx.Where("id IN ?", duplicateIDs).Order("updated_at DESC").Find(&duplicates).Error; err != nil {
+		return nil, nil, err
+	}
+
+	imageURL := ""
+	if survivor.ImageURL != nil {
+		imageURL = *survivor.ImageURL
+	}
+	if imageURL == "" {
+		for _, d := range duplicates {
+			if d.ImageURL != nil && *d.ImageURL != "" {
+				imageURL = *d.ImageURL
+				if err := tx.Model(&survivor).Updates(map[string]interface{}{"image_url": imageURL, "status": "generated"}).Error; err != nil {
+					return nil, nil, err
+				}
+				break
+			}
+		}
+	}
+
+	var mergedIDs, linkedIDs []uint
+	for _, d := range duplicates {
+		if sameEpisode(survivor.EpisodeID, d.EpisodeID) {
+			mergedIDs = append(mergedIDs, d.ID)
+			continue
+		}
+		linkedIDs = append(linkedIDs, d.ID)
+		if imageURL != "" && (d.ImageURL == nil || *d.ImageURL == "") {
+			if err := tx.Model(&d).Updates(map[string]interface{}{"image_url": imageURL, "status": "generated"}).Error; err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if err := tx.Model(&models.Storyboard{}).Where("scene_id IN ?", duplicateIDs).Update("scene_id", survivorID).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := repointJoinTable(tx, "scene_props", "scene_id", "prop_id", survivorID, duplicateIDs); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Model(&models.ImageGeneration{}).Where("scene_id IN ?", duplicateIDs).Update("scene_id", survivorID).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(mergedIDs) == 0 {
+		return mergedIDs, linkedIDs, nil
+	}
+	if err := tx.Where("id IN ?", mergedIDs).Delete(&models.Scene{}).Error; err != nil {
+		return nil, nil, err
+	}
+	return mergedIDs, linkedIDs, nil
+}
+
+func sameEpisode(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+func TestMergeEntitiesAndAliasLookup(t *testing.T) {
+	db := newTestDB(t, &models.Episode{}, &models.Character{}, &models.Prop{}, &models.Scene{}, &models.Storyboard{},
+		&models.PropLibrary{}, &models.ImageGeneration{}, &models.CharacterLook{}, &models.CharacterLookAssignment{},
+		&models.CharacterConsistencyCheck{}, &models.EntityAlias{})
+
+	ep1 := models.Episode{DramaID: 1, EpisodeNum: 1, Title: "第一集"}
+	ep2 := models.Episode{DramaID: 1, EpisodeNum: 2, Title: "第二集"}
+	db.Create(&ep1)
+	db.Create(&ep2)
+
+	sheet := "http://example.com/li.png"
+	appearance := "白大褂，戴眼镜"
+	liWei := models.Character{DramaID: 1, Name: "李伟"}
+	drLi := models.Character{DramaID: 1, Name: "Dr. Li", Appearance: &appearance, ImageURL: &sheet}
+	other := models.Character{DramaID: 2, Name: "李医生"}
+	db.Create(&liWei)
+	db.Create(&drLi)
+	db.Create(&other)
+	db.Model(&ep1).Association("Characters").Append(&liWei)
+	db.Model(&ep2).Association("Characters").Append(&drLi)
+
+	// 同一分镜同时关联了两个重复角色，合并后只保留一条关联
+	sb1 := models.Storyboard{EpisodeID: ep1.ID, StoryboardNumber: 1}
+	sb2 := models.Storyboard{EpisodeID: ep2.ID, StoryboardNumber: 1}
+	db.Create(&sb1)
+	db.Create(&sb2)
+	db.Model(&sb1).Association("Characters").Append(&liWei, &drLi)
+	db.Model(&sb2).Association("Characters").Append(&drLi)
+	db.Create(&models.ImageGeneration{CharacterID: &drLi.ID, DramaID: 1, Provider: "test", Prompt: "p", Status: models.ImageStatusCompleted})
+
+	service := NewEntityResolutionService(db, nil, nil, logger.NewLogger(true))
+	if _, err := service.AddAlias(&AddEntityAliasRequest{EntityType: models.EntityTypeCharacter, EntityID: drLi.ID, Alias: "李医生"}); err != nil {
+		t.Fatalf("AddAlias failed: %v", err)
+	}
+	if _, err := service.AddAlias(&AddEntityAliasRequest{EntityType: models.EntityTypeCharacter, EntityID: liWei.ID, Alias: " 李医生 "}); err == nil {
+		t.Fatal("expected alias conflict")
+	}
+	if _, err := service.MergeEntities(&MergeEntitiesRequest{EntityType: models.EntityTypeCharacter, SurvivorID: liWei.ID, DuplicateIDs: []uint{other.ID}}); err == nil {
+		t.Fatal("expected error when merging across dramas")
+	}
+
+	result, err := service.MergeEntities(&MergeEntitiesRequest{EntityType: models.EntityTypeCharacter, SurvivorID: liWei.ID, DuplicateIDs: []uint{drLi.ID}})
+	if err != nil {
+		t.Fatalf("MergeEntities failed: %v", err)
+	}
+	if len(result.AddedAliases) != 1 || result.AddedAliases[0] != "Dr. Li" {
+		t.Fatalf("unexpected aliases: %v", result.AddedAliases)
+	}
+
+	var survivor models.Character
+	db.Preload("Episodes").First(&survivor, liWei.ID)
+	if survivor.Appearance == nil || *survivor.Appearance != appearance || survivor.ImageURL == nil || *survivor.ImageURL != sheet {
+		t.Fatalf("expected survivor to inherit appearance and sheet, got %+v", survivor)
+	}
+	if len(survivor.Episodes) != 2 {
+		t.Fatalf("expected survivor in 2 episodes, got %d", len(survivor.Episodes))
+	}
+	var links int64
+	db.Table("storyboard_characters").Where("character_id = ?", liWei.ID).Count(&links)
+	if links != 2 {
+		t.Fatalf("expected 2 storyboard links, got %d", links)
+	}
+	db.Table("storyboard_characters").Where("character_id = ?", drLi.ID).Count(&links)
+	if links != 0 {
+		t.Fatalf("expected duplicate links removed, got %d", links)
+	}
+	var images int64
+	db.Model(&models.ImageGeneration{}).Where("character_id = ?", liWei.ID).Count(&images)
+	if images != 1 {
+		t.Fatalf("expected image re-pointed, got %d", images)
+	}
+	if err := db.First(&models.Character{}, drLi.ID).Error; err == nil {
+		t.Fatal("expected duplicate deleted")
+	}
+
+	// 原有别名与被合并的名称都指向 survivor
+	for _, name := range []string{"李医生", "dr.  li", "李伟"} {
+		var found models.Character
+		if err := findEntityByName(db, 1, models.EntityTypeCharacter, name, &found); err != nil || found.ID != liWei.ID {
+			t.Fatalf("expected %q to resolve to survivor, got %d (%v)", name, found.ID, err)
+		}
+	}
+
+	// 场景：其他章节的重复场景保留并共用背景图，其分镜改指向 survivor
+	warehouseImage := "http://example.com/warehouse.png"
+	oldWarehouse := models.Scene{DramaID: 1, EpisodeID: &ep1.ID, Location: "旧仓库", Time: "夜晚", Prompt: "p", ImageURL: &warehouseImage}
+	abandoned := models.Scene{DramaID: 1, EpisodeID: &ep2.ID, Location: "废弃的仓库", Time: "夜晚", Prompt: "p"}
+	db.Create(&oldWarehouse)
+	db.Create(&abandoned)
+	db.Model(&sb2).Update("scene_id", abandoned.ID)
+	sceneResult, err := service.MergeEntities(&MergeEntitiesRequest{EntityType: models.EntityTypeScene, SurvivorID: oldWarehouse.ID, DuplicateIDs: []uint{abandoned.ID}})
+	if err != nil {
+		t.Fatalf("MergeEntities failed: %v", err)
+	}
+	if len(sceneResult.MergedIDs) != 0 || len(sceneResult.LinkedIDs) != 1 || sceneResult.LinkedIDs[0] != abandoned.ID {
+		t.Fatalf("expected cross-episode scene to be linked, got %+v", sceneResult)
+	}
+	if err := db.First(&abandoned, abandoned.ID).Error; err != nil {
+		t.Fatalf("expected cross-episode scene kept: %v", err)
+	}
+	if abandoned.ImageURL == nil || *abandoned.ImageURL != warehouseImage || abandoned.Status != "generated" {
+		t.Fatalf("expected cross-episode scene to share the image, got %+v", abandoned)
+	}
+	db.First(&sb2, sb2.ID)
+	if sb2.SceneID == nil || *sb2.SceneID != oldWarehouse.ID {
+		t.Fatalf("expected storyboard re-pointed to survivor scene, got %v", sb2.SceneID)
+	}
+	if location := canonicalSceneLocation(db, 1, "废弃的仓库"); location != "废弃的仓库" {
+		t.Fatalf("expected existing scene to match by its own location, got %s", location)
+	}
+
+	// 同一章节的重复场景被合并删除
+	storage := models.Scene{DramaID: 1, EpisodeID: &ep1.ID, Location: "仓库储藏间", Time: "夜晚", Prompt: "p"}
+	db.Create(&storage)
+	db.Model(&sb1).Update("scene_id", storage.ID)
+	sceneResult, err = service.MergeEntities(&MergeEntitiesRequest{EntityType: models.EntityTypeScene, SurvivorID: oldWarehouse.ID, DuplicateIDs: []uint{storage.ID}})
+	if err != nil {
+		t.Fatalf("MergeEntities failed: %v", err)
+	}
+	if len(sceneResult.MergedIDs) != 1 || len(sceneResult.LinkedIDs) != 0 {
+		t.Fatalf("expected same-episode scene to be merged, got %+v", sceneResult)
+	}
+	db.First(&sb1, sb1.ID)
+	if sb1.SceneID == nil || *sb1.SceneID != oldWarehouse.ID {
+		t.Fatalf("expected storyboard re-pointed to survivor scene, got %v", sb1.SceneID)
+	}
+	if err := db.First(&models.Scene{}, storage.ID).Error; err == nil {
+		t.Fatal("expected same-episode duplicate deleted")
+	}
+	if location := canonicalSceneLocation(db, 1, "仓库储藏间"); location != "旧仓库" {
+		t.Fatalf("expected canonical location, got %s", location)
+	}
+}
+
+func TestMergeProps(t *testing.T) {
+	db := newTestDB(t, &models.Episode{}, &models.Character{}, &models.Prop{}, &models.Scene{}, &models.Storyboard{},
+		&models.PropLibrary{}, &models.ImageGeneration{}, &models.EntityAlias{})
+
+	episode := models.Episode{DramaID: 1, EpisodeNum: 1, Title: "第一集"}
+	db.Create(&episode)
+	description := "青铜古剑，剑身有裂纹"
+	image := "http://example.com/sword.png"
+	sword := models.Prop{DramaID: 1, Name: "古剑"}
+	bronze := models.Prop{DramaID: 1, Name: "青铜剑", Description: &description, ImageURL: &image}
+	db.Create(&sword)
+	db.Create(&bronze)
+
+	storyboard := models.Storyboard{EpisodeID: episode.ID, StoryboardNumber: 1}
+	db.Create(&storyboard)
+	db.Model(&storyboard).Association("Props").Append(&sword, &bronze)
+	db.Exec("INSERT INTO episode_props (episode_id, prop_id) VALUES (?, ?)", episode.ID, bronze.ID)
+	db.Create(&models.ImageGeneration{PropID: &bronze.ID, DramaID: 1, Provider: "test", Prompt: "p", Status: models.ImageStatusCompleted})
+	db.Create(&models.PropLibrary{PropID: bronze.ID, UserID: 1})
+
+	service := NewEntityResolutionService(db, nil, nil, logger.NewLogger(true))
+	result, err := service.MergeEntities(&MergeEntitiesRequest{EntityType: models.EntityTypeProp, SurvivorID: sword.ID, DuplicateIDs: []uint{bronze.ID}})
+	if err != nil {
+		t.Fatalf("MergeEntities failed: %v", err)
+	}
+	if len(result.MergedIDs) != 1 || len(result.AddedAliases) != 1 || result.AddedAliases[0] != "青铜剑" {
+		t.Fatalf("unexpected merge result %+v", result)
+	}
+
+	var survivor models.Prop
+	db.First(&survivor, sword.ID)
+	if survivor.Description == nil || *survivor.Description != description || survivor.ImageURL == nil || *survivor.ImageURL != image {
+		t.Fatalf("expected survivor to inherit description and image, got %+v", survivor)
+	}
+	for table, column := range map[string]string{"storyboard_props": "storyboard_id", "episode_props": "episode_id"} {
+		var links int64
+		db.Table(table).Where("prop_id = ?", sword.ID).Count(&links)
+		if links != 1 {
+			t.Fatalf("expected 1 %s link for survivor, got %d", table, links)
+		}
+		db.Table(table).Where("prop_id = ?", bronze.ID).Count(&links)
+		if links != 0 {
+			t.Fatalf("expected duplicate %s links removed (%s), got %d", table, column, links)
+		}
+	}
+	var count int64
+	db.Model(&models.ImageGeneration{}).Where("prop_id = ?", sword.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected image generation re-pointed, got %d", count)
+	}
+	db.Model(&models.PropLibrary{}).Where("prop_id = ?", sword.ID).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected library entry re-pointed, got %d", count)
+	}
+	if err := db.First(&models.Prop{}, bronze.ID).Error; err == nil {
+		t.Fatal("expected duplicate deleted")
+	}
+	var found models.Prop
+	if err := findEntityByName(db, 1, models.EntityTypeProp, "青铜剑", &found); err != nil || found.ID != sword.ID {
+		t.Fatalf("expected alias to resolve to survivor, got %d (%v)", found.ID, err)
+	}
+}
+
+// 合并后重新提取保留场景所在章节：旧场景被替换，别名与其他章节的分镜改指向新场景，背景图继续复用
+func TestBackgroundReExtractionAfterSceneMerge(t *testing.T) {
+	db := newTestDB(t, &models.Drama{}, &models.Episode{}, &models.Scene{}, &models.Storyboard{}, &models.Prop{},
+		&models.ImageGeneration{}, &models.EntityAlias{}, &models.AsyncTask{},
+		&models.AIServiceConfig{}, &models.UsageRecord{}, &models.ModelPrice{}, &models.Budget{}, &models.LLMCacheEntry{})
+
+	log := logger.NewLogger(true)
+	cfg := &config.Config{}
+	taskService := NewTaskService(db, log)
+	imageService := NewImageGenerationService(db, cfg, nil, nil, log)
+	db.Create(&models.AIServiceConfig{ServiceType: "text", Provider: "mock", Name: "mock", BaseURL: "mock", APIKey: "mock", Model: models.ModelField{"mock-vision"}, IsActive: true})
+
+	drama := models.Drama{Title: "d"}
+	db.Create(&drama)
+	script := "夜里，两人在仓库见面。"
+	episode := models.Episode{DramaID: drama.ID, EpisodeNum: 1, Title: "e", ScriptContent: &script}
+	db.Create(&episode)
+
+	// 模拟模型提取的第 2 个场景为 "Mock location 2"（夜晚），与保留场景同地点
+	image := "http://example.com/warehouse.png"
+	survivor := models.Scene{DramaID: drama.ID, EpisodeID: &episode.ID, Location: "Mock location 2", Time: "night", Prompt: "p", ImageURL: &image, Status: "generated"}
+	duplicate := models.Scene{DramaID: drama.ID, EpisodeID: &episode.ID, Location: "废弃的仓库", Time: "night", Prompt: "p"}
+	db.Create(&survivor)
+	db.Create(&duplicate)
+	nextEpisode := models.Episode{DramaID: drama.ID, EpisodeNum: 2, Title: "e2"}
+	db.Create(&nextEpisode)
+	linked := models.Scene{DramaID: drama.ID, EpisodeID: &nextEpisode.ID, Location: "仓库", Time: "night", Prompt: "p"}
+	db.Create(&linked)
+	nextStoryboard := models.Storyboard{EpisodeID: nextEpisode.ID, StoryboardNumber: 1, SceneID: &linked.ID}
+	db.Create(&nextStoryboard)
+	service := NewEntityResolutionService(db, nil, nil, log)
+	if _, err := service.MergeEntities(&MergeEntitiesRequest{EntityType: models.EntityTypeScene, SurvivorID: survivor.ID, DuplicateIDs: []uint{duplicate.ID, linked.ID}}); err != nil {
+		t.Fatalf("MergeEntities failed: %v", err)
+	}
+
+	task, err := taskService.CreateTask("background_extraction", fmt.Sprintf("%d", episode.ID))
+	if err != nil {
+		t.Fatalf("create task failed: %v", err)
+	}
+	imageService.processBackgroundExtraction(task.ID, fmt.Sprintf("%d", episode.ID), "", "", false)
+	if task, err = taskService.GetTask(task.ID); err != nil || task.Status != "completed" {
+		t.Fatalf("expected extraction to complete, got %+v (%v)", task, err)
+	}
+
+	var scenes []models.Scene
+	db.Where("episode_id = ?", episode.ID).Order("id ASC").Find(&scenes)
+	if len(scenes) != 3 {
+		t.Fatalf("expected 3 re-extracted scenes, got %d", len(scenes))
+	}
+	reextracted := scenes[1]
+	if reextracted.ID == survivor.ID || reextracted.Location != "Mock location 2" {
+		t.Fatalf("unexpected re-extracted scene %+v", reextracted)
+	}
+	if reextracted.ImageURL == nil || *reextracted.ImageURL != image || reextracted.Status != "generated" {
+		t.Fatalf("expected background image reused, got %+v", reextracted)
+	}
+
+	var alias models.EntityAlias
+	if err := db.Where("drama_id = ? AND entity_type = ?", drama.ID, models.EntityTypeScene).First(&alias).Error; err != nil {
+		t.Fatalf("expected merge alias to survive re-extraction: %v", err)
+	}
+	if alias.EntityID != reextracted.ID {
+		t.Fatalf("expected alias re-pointed to scene %d, got %d", reextracted.ID, alias.EntityID)
+	}
+	if location := canonicalSceneLocation(db, drama.ID, "废弃的仓库"); location != "Mock location 2" {
+		t.Fatalf("expected canonical location after re-extraction, got %s", location)
+	}
+	db.First(&nextStoryboard, nextStoryboard.ID)
+	if nextStoryboard.SceneID == nil || *nextStoryboard.SceneID != reextracted.ID {
+		t.Fatalf("expected other episode storyboard re-pointed to scene %d, got %v", reextracted.ID, nextStoryboard.SceneID)
+	}
+	if err := db.First(&models.Scene{}, linked.ID).Error; err != nil {
+		t.Fatalf("expected other episode scene kept: %v", err)
+	}
+}
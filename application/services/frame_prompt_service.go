@@ -28,7 +28,7 @@ func NewFramePromptService(db *gorm.DB, cfg *config.Config, log *logger.Logger)
 		aiService:  NewAIService(db, log, cfg),
 		log:        log,
 		config:     cfg,
-		promptI18n: NewPromptI18n(cfg),
+		promptI18n: NewPromptI18n(cfg, db, log),
 		taskService: NewTaskService(db, log),
 		styleConsistencyService: NewStyleConsistencyService(cfg, log),
 	}
@@ -50,7 +50,8 @@ type GenerateFramePromptRequest struct {
 	StoryboardID string    `json:"storyboard_id"`
 	FrameType    FrameType `json:"frame_type"`
 	// 可选参数
-	PanelCount int `json:"panel_count,omitempty"` // 分镜板格数，默认3
+	PanelCount  int  `json:"panel_count,omitempty"`  // 分镜板格数，默认3
+	BypassCache bool `json:"bypass_cache,omitempty"` // 不使用缓存的模型响应
 }
 
 // FramePromptResponse 帧提示词响应
@@ -81,7 +82,7 @@ func (s *FramePromptService) GenerateFramePrompt(req GenerateFramePromptRequest,
 	}
 
 	// 创建任务
-	task, err := s.taskService.CreateTask("frame_prompt_generation", req.StoryboardID)
+	task, err := s.taskService.CreateTaskWithParams("frame_prompt_generation", req.StoryboardID, framePromptTaskParams{Request: req, Model: model})
 	if err != nil {
 		s.log.Errorw("Failed to create frame prompt generation task", "error", err, "storyboard_id", req.StoryboardID)
 		return "", fmt.Errorf("创建任务失败: %w", err)
@@ -147,24 +148,25 @@ func (s *FramePromptService) processFramePromptGeneration(taskID string, req Gen
 	response := &FramePromptResponse{
 		FrameType: req.FrameType,
 	}
+	cacheStatus := NewLLMCacheStatus(req.BypassCache)
 
 	// 生成提示词
 	switch req.FrameType {
 	case FrameTypeFirst:
-		response.SingleFrame = s.generateFirstFrame(storyboard, scene, model, stylePrompt)
+		response.SingleFrame = s.generateFirstFrame(storyboard, scene, model, stylePrompt, cacheStatus)
 		if response.SingleFrame != nil {
 			response.SingleFrame.Prompt = s.normalizeFramePrompt(response.SingleFrame.Prompt, storyboard.ID, req.FrameType, targetStyle, referenceWork)
 		}
 		// 保存单帧提示词
 		s.saveFramePrompt(req.StoryboardID, string(req.FrameType), response.SingleFrame.Prompt, response.SingleFrame.Description, "")
 	case FrameTypeKey:
-		response.SingleFrame = s.generateKeyFrame(storyboard, scene, model, stylePrompt)
+		response.SingleFrame = s.generateKeyFrame(storyboard, scene, model, stylePrompt, cacheStatus)
 		if response.SingleFrame != nil {
 			response.SingleFrame.Prompt = s.normalizeFramePrompt(response.SingleFrame.Prompt, storyboard.ID, req.FrameType, targetStyle, referenceWork)
 		}
 		s.saveFramePrompt(req.StoryboardID, string(req.FrameType), response.SingleFrame.Prompt, response.SingleFrame.Description, "")
 	case FrameTypeLast:
-		response.SingleFrame = s.generateLastFrame(storyboard, scene, model, stylePrompt)
+		response.SingleFrame = s.generateLastFrame(storyboard, scene, model, stylePrompt, cacheStatus)
 		if response.SingleFrame != nil {
 			response.SingleFrame.Prompt = s.normalizeFramePrompt(response.SingleFrame.Prompt, storyboard.ID, req.FrameType, targetStyle, referenceWork)
 		}
@@ -174,7 +176,7 @@ func (s *FramePromptService) processFramePromptGeneration(taskID string, req Gen
 		if count == 0 {
 			count = 3
 		}
-		response.MultiFrame = s.generatePanelFrames(storyboard, scene, count, model, stylePrompt)
+		response.MultiFrame = s.generatePanelFrames(storyboard, scene, count, model, stylePrompt, cacheStatus)
 		if response.MultiFrame != nil {
 			for index, frame := range response.MultiFrame.Frames {
 				response.MultiFrame.Frames[index].Prompt = s.normalizeFramePrompt(frame.Prompt, storyboard.ID, req.FrameType, targetStyle, referenceWork)
@@ -188,7 +190,7 @@ func (s *FramePromptService) processFramePromptGeneration(taskID string, req Gen
 		combinedPrompt := strings.Join(prompts, "\n---\n")
 		s.saveFramePrompt(req.StoryboardID, string(req.FrameType), combinedPrompt, "分镜板组合提示词", response.MultiFrame.Layout)
 	case FrameTypeAction:
-		response.MultiFrame = s.generateActionSequence(storyboard, scene, model, stylePrompt)
+		response.MultiFrame = s.generateActionSequence(storyboard, scene, model, stylePrompt, cacheStatus)
 		if response.MultiFrame != nil {
 			for index, frame := range response.MultiFrame.Frames {
 				response.MultiFrame.Frames[index].Prompt = s.normalizeFramePrompt(frame.Prompt, storyboard.ID, req.FrameType, targetStyle, referenceWork)
@@ -211,6 +213,7 @@ func (s *FramePromptService) processFramePromptGeneration(taskID string, req Gen
 		"response":      response,
 		"storyboard_id": req.StoryboardID,
 		"frame_type":    string(req.FrameType),
+		"cache_hit":     cacheStatus.Hit(),
 	})
 
 	s.log.Infow("Frame prompt generation completed", "task_id", taskID, "storyboard_id", req.StoryboardID, "frame_type", req.FrameType)
@@ -259,13 +262,15 @@ func mustParseUint(s string) uint64 {
 }
 
 // generateFirstFrame 生成首帧提示词
-func (s *FramePromptService) generateFirstFrame(sb models.Storyboard, scene *models.Scene, model string, stylePrompt string) *SingleFramePrompt {
+func (s *FramePromptService) generateFirstFrame(sb models.Storyboard, scene *models.Scene, model string, stylePrompt string, cacheStatus *LLMCacheStatus) *SingleFramePrompt {
 	// 构建上下文信息
-	contextInfo := s.buildStoryboardContext(sb, scene, stylePrompt)
+	promptI18n := s.promptI18n.ForEpisode(sb.EpisodeID)
+	contextInfo := s.buildStoryboardContext(promptI18n, sb, scene, stylePrompt)
 
 	// 使用国际化提示词
-	systemPrompt := s.promptI18n.GetFirstFramePrompt(stylePrompt)
-	userPrompt := s.promptI18n.FormatUserPrompt("frame_info", contextInfo)
+	systemPrompt := promptI18n.GetFirstFramePrompt(stylePrompt)
+	userPrompt := promptI18n.FormatUserPrompt("frame_info", contextInfo)
+	usageScope := WithUsageScope(UsageScope{EpisodeID: sb.EpisodeID, Operation: "frame_prompt"})
 
 	// 调用AI生成（如果指定了模型则使用指定的模型）
 	var aiResponse string
@@ -274,12 +279,12 @@ func (s *FramePromptService) generateFirstFrame(sb models.Storyboard, scene *mod
 		client, getErr := s.aiService.GetAIClientForModel("text", model)
 		if getErr != nil {
 			s.log.Warnw("Failed to get client for specified model, using default", "model", model, "error", getErr)
-			aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt)
+			aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt, usageScope, cacheStatus.Option())
 		} else {
-			aiResponse, err = client.GenerateText(userPrompt, systemPrompt)
+			aiResponse, err = client.GenerateText(userPrompt, systemPrompt, usageScope, cacheStatus.Option())
 		}
 	} else {
-		aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt)
+		aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt, usageScope, cacheStatus.Option())
 	}
 	if err != nil {
 		s.log.Warnw("AI generation failed, using fallback", "error", err)
@@ -307,13 +312,15 @@ func (s *FramePromptService) generateFirstFrame(sb models.Storyboard, scene *mod
 }
 
 // generateKeyFrame 生成关键帧提示词
-func (s *FramePromptService) generateKeyFrame(sb models.Storyboard, scene *models.Scene, model string, stylePrompt string) *SingleFramePrompt {
+func (s *FramePromptService) generateKeyFrame(sb models.Storyboard, scene *models.Scene, model string, stylePrompt string, cacheStatus *LLMCacheStatus) *SingleFramePrompt {
 	// 构建上下文信息
-	contextInfo := s.buildStoryboardContext(sb, scene, stylePrompt)
+	promptI18n := s.promptI18n.ForEpisode(sb.EpisodeID)
+	contextInfo := s.buildStoryboardContext(promptI18n, sb, scene, stylePrompt)
 
 	// 使用国际化提示词
-	systemPrompt := s.promptI18n.GetKeyFramePrompt(stylePrompt)
-	userPrompt := s.promptI18n.FormatUserPrompt("key_frame_info", contextInfo)
+	systemPrompt := promptI18n.GetKeyFramePrompt(stylePrompt)
+	userPrompt := promptI18n.FormatUserPrompt("key_frame_info", contextInfo)
+	usageScope := WithUsageScope(UsageScope{EpisodeID: sb.EpisodeID, Operation: "frame_prompt"})
 
 	// 调用AI生成（如果指定了模型则使用指定的模型）
 	var aiResponse string
@@ -322,12 +329,12 @@ func (s *FramePromptService) generateKeyFrame(sb models.Storyboard, scene *model
 		client, getErr := s.aiService.GetAIClientForModel("text", model)
 		if getErr != nil {
 			s.log.Warnw("Failed to get client for specified model, using default", "model", model, "error", getErr)
-			aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt)
+			aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt, usageScope, cacheStatus.Option())
 		} else {
-			aiResponse, err = client.GenerateText(userPrompt, systemPrompt)
+			aiResponse, err = client.GenerateText(userPrompt, systemPrompt, usageScope, cacheStatus.Option())
 		}
 	} else {
-		aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt)
+		aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt, usageScope, cacheStatus.Option())
 	}
 	if err != nil {
 		s.log.Warnw("AI generation failed, using fallback", "error", err)
@@ -354,13 +361,15 @@ func (s *FramePromptService) generateKeyFrame(sb models.Storyboard, scene *model
 }
 
 // generateLastFrame 生成尾帧提示词
-func (s *FramePromptService) generateLastFrame(sb models.Storyboard, scene *models.Scene, model string, stylePrompt string) *SingleFramePrompt {
+func (s *FramePromptService) generateLastFrame(sb models.Storyboard, scene *models.Scene, model string, stylePrompt string, cacheStatus *LLMCacheStatus) *SingleFramePrompt {
 	// 构建上下文信息
-	contextInfo := s.buildStoryboardContext(sb, scene, stylePrompt)
+	promptI18n := s.promptI18n.ForEpisode(sb.EpisodeID)
+	contextInfo := s.buildStoryboardContext(promptI18n, sb, scene, stylePrompt)
 
 	// 使用国际化提示词
-	systemPrompt := s.promptI18n.GetLastFramePrompt(stylePrompt)
-	userPrompt := s.promptI18n.FormatUserPrompt("last_frame_info", contextInfo)
+	systemPrompt := promptI18n.GetLastFramePrompt(stylePrompt)
+	userPrompt := promptI18n.FormatUserPrompt("last_frame_info", contextInfo)
+	usageScope := WithUsageScope(UsageScope{EpisodeID: sb.EpisodeID, Operation: "frame_prompt"})
 
 	// 调用AI生成（如果指定了模型则使用指定的模型）
 	var aiResponse string
@@ -369,12 +378,12 @@ func (s *FramePromptService) generateLastFrame(sb models.Storyboard, scene *mode
 		client, getErr := s.aiService.GetAIClientForModel("text", model)
 		if getErr != nil {
 			s.log.Warnw("Failed to get client for specified model, using default", "model", model, "error", getErr)
-			aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt)
+			aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt, usageScope, cacheStatus.Option())
 		} else {
-			aiResponse, err = client.GenerateText(userPrompt, systemPrompt)
+			aiResponse, err = client.GenerateText(userPrompt, systemPrompt, usageScope, cacheStatus.Option())
 		}
 	} else {
-		aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt)
+		aiResponse, err = s.aiService.GenerateText(userPrompt, systemPrompt, usageScope, cacheStatus.Option())
 	}
 	if err != nil {
 		s.log.Warnw("AI generation failed, using fallback", "error", err)
@@ -401,27 +410,27 @@ func (s *FramePromptService) generateLastFrame(sb models.Storyboard, scene *mode
 }
 
 // generatePanelFrames 生成分镜板（多格组合）
-func (s *FramePromptService) generatePanelFrames(sb models.Storyboard, scene *models.Scene, count int, model string, stylePrompt string) *MultiFramePrompt {
+func (s *FramePromptService) generatePanelFrames(sb models.Storyboard, scene *models.Scene, count int, model string, stylePrompt string, cacheStatus *LLMCacheStatus) *MultiFramePrompt {
 	layout := fmt.Sprintf("horizontal_%d", count)
 
 	frames := make([]SingleFramePrompt, count)
 
 	// 固定生成：首帧 -> 关键帧 -> 尾帧
 	if count == 3 {
-		frames[0] = *s.generateFirstFrame(sb, scene, model, stylePrompt)
+		frames[0] = *s.generateFirstFrame(sb, scene, model, stylePrompt, cacheStatus)
 		frames[0].Description = "第1格：初始状态"
 
-		frames[1] = *s.generateKeyFrame(sb, scene, model, stylePrompt)
+		frames[1] = *s.generateKeyFrame(sb, scene, model, stylePrompt, cacheStatus)
 		frames[1].Description = "第2格：动作高潮"
 
-		frames[2] = *s.generateLastFrame(sb, scene, model, stylePrompt)
+		frames[2] = *s.generateLastFrame(sb, scene, model, stylePrompt, cacheStatus)
 		frames[2].Description = "第3格：最终状态"
 	} else if count == 4 {
 		// 4格：首帧 -> 中间帧1 -> 中间帧2 -> 尾帧
-		frames[0] = *s.generateFirstFrame(sb, scene, model, stylePrompt)
-		frames[1] = *s.generateKeyFrame(sb, scene, model, stylePrompt)
-		frames[2] = *s.generateKeyFrame(sb, scene, model, stylePrompt)
-		frames[3] = *s.generateLastFrame(sb, scene, model, stylePrompt)
+		frames[0] = *s.generateFirstFrame(sb, scene, model, stylePrompt, cacheStatus)
+		frames[1] = *s.generateKeyFrame(sb, scene, model, stylePrompt, cacheStatus)
+		frames[2] = *s.generateKeyFrame(sb, scene, model, stylePrompt, cacheStatus)
+		frames[3] = *s.generateLastFrame(sb, scene, model, stylePrompt, cacheStatus)
 	}
 
 	return &MultiFramePrompt{
@@ -431,16 +440,16 @@ func (s *FramePromptService) generatePanelFrames(sb models.Storyboard, scene *mo
 }
 
 // generateActionSequence 生成动作序列（5-8格）
-func (s *FramePromptService) generateActionSequence(sb models.Storyboard, scene *models.Scene, model string, stylePrompt string) *MultiFramePrompt {
+func (s *FramePromptService) generateActionSequence(sb models.Storyboard, scene *models.Scene, model string, stylePrompt string, cacheStatus *LLMCacheStatus) *MultiFramePrompt {
 	// 将动作分解为5个步骤
 	frames := make([]SingleFramePrompt, 5)
 
 	// 简化实现：均匀分布从首帧到尾帧
-	frames[0] = *s.generateFirstFrame(sb, scene, model, stylePrompt)
-	frames[1] = *s.generateKeyFrame(sb, scene, model, stylePrompt)
-	frames[2] = *s.generateKeyFrame(sb, scene, model, stylePrompt)
-	frames[3] = *s.generateKeyFrame(sb, scene, model, stylePrompt)
-	frames[4] = *s.generateLastFrame(sb, scene, model, stylePrompt)
+	frames[0] = *s.generateFirstFrame(sb, scene, model, stylePrompt, cacheStatus)
+	frames[1] = *s.generateKeyFrame(sb, scene, model, stylePrompt, cacheStatus)
+	frames[2] = *s.generateKeyFrame(sb, scene, model, stylePrompt, cacheStatus)
+	frames[3] = *s.generateKeyFrame(sb, scene, model, stylePrompt, cacheStatus)
+	frames[4] = *s.generateLastFrame(sb, scene, model, stylePrompt, cacheStatus)
 
 	return &MultiFramePrompt{
 		Layout: "horizontal_5",
@@ -449,7 +458,7 @@ func (s *FramePromptService) generateActionSequence(sb models.Storyboard, scene
 }
 
 // buildStoryboardContext 构建镜头上下文信息
-func (s *FramePromptService) buildStoryboardContext(sb models.Storyboard, scene *models.Scene, stylePrompt string) string {
+func (s *FramePromptService) buildStoryboardContext(promptI18n *PromptI18n, sb models.Storyboard, scene *models.Scene, stylePrompt string) string {
 	var parts []string
 
 	// 风格提示词 (Project Style)
@@ -459,54 +468,55 @@ func (s *FramePromptService) buildStoryboardContext(sb models.Storyboard, scene
 
 	// 镜头描述（最重要）
 	if sb.Description != nil && *sb.Description != "" {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("shot_description_label", *sb.Description))
+		parts = append(parts, promptI18n.FormatUserPrompt("shot_description_label", *sb.Description))
 	}
 
 	// 场景信息
 	if scene != nil {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("scene_label", scene.Location, scene.Time))
+		parts = append(parts, promptI18n.FormatUserPrompt("scene_label", scene.Location, scene.Time))
 	} else if sb.Location != nil && sb.Time != nil {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("scene_label", *sb.Location, *sb.Time))
+		parts = append(parts, promptI18n.FormatUserPrompt("scene_label", *sb.Location, *sb.Time))
 	}
 
-	// 角色
+	// 角色（有生效造型时使用造型外貌）
 	if len(sb.Characters) > 0 {
+		looks := resolveActiveLooks(s.db, &sb)
 		var charNames []string
 		for _, char := range sb.Characters {
-			charNames = append(charNames, char.Name)
+			charNames = append(charNames, characterPromptLabel(char, looks[char.ID]))
 		}
-		parts = append(parts, s.promptI18n.FormatUserPrompt("characters_label", strings.Join(charNames, ", ")))
+		parts = append(parts, promptI18n.FormatUserPrompt("characters_label", strings.Join(charNames, ", ")))
 	}
 
 	// 动作
 	if sb.Action != nil && *sb.Action != "" {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("action_label", *sb.Action))
+		parts = append(parts, promptI18n.FormatUserPrompt("action_label", *sb.Action))
 	}
 
 	// 结果
 	if sb.Result != nil && *sb.Result != "" {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("result_label", *sb.Result))
+		parts = append(parts, promptI18n.FormatUserPrompt("result_label", *sb.Result))
 	}
 
 	// 对白
 	if sb.Dialogue != nil && *sb.Dialogue != "" {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("dialogue_label", *sb.Dialogue))
+		parts = append(parts, promptI18n.FormatUserPrompt("dialogue_label", *sb.Dialogue))
 	}
 
 	// 氛围
 	if sb.Atmosphere != nil && *sb.Atmosphere != "" {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("atmosphere_label", *sb.Atmosphere))
+		parts = append(parts, promptI18n.FormatUserPrompt("atmosphere_label", *sb.Atmosphere))
 	}
 
 	// 镜头参数
 	if sb.ShotType != nil {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("shot_type_label", *sb.ShotType))
+		parts = append(parts, promptI18n.FormatUserPrompt("shot_type_label", *sb.ShotType))
 	}
 	if sb.Angle != nil {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("angle_label", *sb.Angle))
+		parts = append(parts, promptI18n.FormatUserPrompt("angle_label", *sb.Angle))
 	}
 	if sb.Movement != nil {
-		parts = append(parts, s.promptI18n.FormatUserPrompt("movement_label", *sb.Movement))
+		parts = append(parts, promptI18n.FormatUserPrompt("movement_label", *sb.Movement))
 	}
 
 	return strings.Join(parts, "\n")
@@ -528,8 +538,9 @@ func (s *FramePromptService) buildFallbackPrompt(sb models.Storyboard, scene *mo
 
 	// 角色
 	if len(sb.Characters) > 0 {
+		looks := resolveActiveLooks(s.db, &sb)
 		for _, char := range sb.Characters {
-			parts = append(parts, char.Name)
+			parts = append(parts, characterPromptLabel(char, looks[char.ID]))
 		}
 	}
 
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	models "github.com/drama-generator/backend/domain/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxImageCandidates 单次候选生成的图片数量上限
+const maxImageCandidates = 4
+
+// maxCandidateRegenerations 自动选图时重新生成的轮数上限
+const maxCandidateRegenerations = 2
+
+// DiscardedImageRetention 落选候选图在回收区保留的时长，超过后由清理任务删除
+const DiscardedImageRetention = 7 * 24 * time.Hour
+
+// GenerateImageCandidates 为分镜帧生成一组候选图。每张候选图是独立的生成记录，
+// 共享同一个 candidate_group，完成后不会覆盖分镜图片，需通过 SelectStoryboardImage 选定
+func (s *ImageGenerationService) GenerateImageCandidates(request *GenerateImageRequest) ([]*models.ImageGeneration, error) {
+	if request.StoryboardID == nil {
+		return nil, &ValidationError{Message: "候选模式需要指定分镜"}
+	}
+	if request.Candidates < 2 || request.Candidates > maxImageCandidates {
+		return nil, &ValidationError{Message: fmt.Sprintf("候选图数量需在 2 到 %d 之间", maxImageCandidates)}
+	}
+	if request.ImageType != "" && request.ImageType != string(models.ImageTypeStoryboard) {
+		return nil, &ValidationError{Message: "候选模式仅支持分镜图片"}
+	}
+	if request.MinScore < 0 || request.MinScore > 10 {
+		return nil, &ValidationError{Message: "最低分数需在 0 到 10 之间"}
+	}
+	if request.Regenerations < 0 || request.Regenerations > maxCandidateRegenerations {
+		return nil, &ValidationError{Message: fmt.Sprintf("重新生成轮数需在 0 到 %d 之间", maxCandidateRegenerations)}
+	}
+
+	var storyboard models.Storyboard
+	if err := s.db.First(&storyboard, *request.StoryboardID).Error; err != nil {
+		return nil, fmt.Errorf("storyboard not found")
+	}
+
+	template, err := s.prepareImageGeneration(request)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &models.ImageCandidateGroup{
+		ID:               uuid.New().String(),
+		StoryboardID:     storyboard.ID,
+		DramaID:          template.DramaID,
+		Candidates:       request.Candidates,
+		AutoSelect:       request.AutoSelect,
+		MinScore:         request.MinScore,
+		MaxRegenerations: request.Regenerations,
+		Status:           models.CandidateGroupGenerating,
+	}
+	var candidates []*models.ImageGeneration
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		candidates, err = createCandidateRecords(tx, template, group.ID, request.Candidates, 0)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create record: %w", err)
+	}
+
+	s.log.Infow("Image candidates created", "storyboard_id", *request.StoryboardID, "group", group.ID, "count", len(candidates), "auto_select", group.AutoSelect)
+	for _, candidate := range candidates {
+		go s.ProcessImageGeneration(candidate.ID)
+	}
+
+	return candidates, nil
+}
+
+// createCandidateRecords 按模板创建一轮候选记录，seedOffset 用于让重新生成的候选使用新的种子
+func createCandidateRecords(tx *gorm.DB, template *models.ImageGeneration, group string, count int, seedOffset int64) ([]*models.ImageGeneration, error) {
+	candidates := make([]*models.ImageGeneration, 0, count)
+	for i := 0; i < count; i++ {
+		candidate := *template
+		candidate.CandidateGroup = &group
+		// 指定种子时逐张递增，保证候选图之间有差异
+		if template.Seed != nil {
+			seed := *template.Seed + seedOffset + int64(i)
+			candidate.Seed = &seed
+		}
+		if err := tx.Create(&candidate).Error; err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, &candidate)
+	}
+	return candidates, nil
+}
+
+// SelectStoryboardImage 选定分镜图片：同步到分镜的 composed_image，
+// 同组其余候选图移入回收区。选中回收区中的图片会将其恢复
+func (s *ImageGenerationService) SelectStoryboardImage(storyboardID, imageGenID uint) (*models.Storyboard, error) {
+	var storyboard models.Storyboard
+	if err := s.db.First(&storyboard, storyboardID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("storyboard not found")
+		}
+		return nil, err
+	}
+
+	var imageGen models.ImageGeneration
+	if err := s.db.Where("id = ? AND storyboard_id = ?", imageGenID, storyboardID).First(&imageGen).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("image not found")
+		}
+		return nil, err
+	}
+	if imageGen.Status != models.ImageStatusCompleted || imageGen.ImageURL == nil || *imageGen.ImageURL == "" {
+		return nil, &ValidationError{Message: "图片尚未生成完成"}
+	}
+
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Storyboard{}).Where("id = ?", storyboardID).Updates(map[string]interface{}{
+			"composed_image":    *imageGen.ImageURL,
+			"selected_image_id": imageGen.ID,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ImageGeneration{}).Where("id = ?", imageGen.ID).Update("discarded_at", nil).Error; err != nil {
+			return err
+		}
+		if imageGen.CandidateGroup == nil {
+			return nil
+		}
+		if err := tx.Model(&models.ImageGeneration{}).
+			Where("candidate_group = ? AND id <> ? AND discarded_at IS NULL", *imageGen.CandidateGroup, imageGen.ID).
+			Update("discarded_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ImageCandidateGroup{}).Where("id = ?", *imageGen.CandidateGroup).Updates(map[string]interface{}{
+			"selected_image_id": imageGen.ID,
+			"status":            models.CandidateGroupSelected,
+			"completed_at":      now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infow("Storyboard image selected", "storyboard_id", storyboardID, "image_gen_id", imageGen.ID)
+	if err := s.db.First(&storyboard, storyboardID).Error; err != nil {
+		return nil, err
+	}
+	return &storyboard, nil
+}
+
+// ListDiscardedImages 列出回收区中的图片
+func (s *ImageGenerationService) ListDiscardedImages(dramaID *uint, storyboardID *uint) ([]models.ImageGeneration, error) {
+	query := s.db.Where("discarded_at IS NOT NULL")
+	if dramaID != nil {
+		query = query.Where("drama_id = ?", *dramaID)
+	}
+	if storyboardID != nil {
+		query = query.Where("storyboard_id = ?", *storyboardID)
+	}
+
+	var images []models.ImageGeneration
+	if err := query.Order("discarded_at DESC").Find(&images).Error; err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// PurgeDiscardedImages 删除在回收区停留超过 olderThan 的图片记录及其本地文件
+func (s *ImageGenerationService) PurgeDiscardedImages(olderThan time.Duration) (int64, error) {
+	var images []models.ImageGeneration
+	if err := s.db.Select("id", "image_url").
+		Where("discarded_at IS NOT NULL AND discarded_at <= ?", time.Now().Add(-olderThan)).
+		Find(&images).Error; err != nil {
+		return 0, err
+	}
+	if len(images) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uint, 0, len(images))
+	for _, img := range images {
+		ids = append(ids, img.ID)
+		if s.localStorage != nil && img.ImageURL != nil {
+			if err := s.localStorage.Delete(*img.ImageURL); err != nil {
+				s.log.Warnw("Failed to delete discarded image file", "id", img.ID, "error", err)
+			}
+		}
+	}
+
+	result := s.db.Where("id IN ?", ids).Delete(&models.ImageGeneration{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+func TestSelectStoryboardImageDiscardsSiblings(t *testing.T) {
+	db := newTestDB(t, &models.Storyboard{}, &models.ImageGeneration{}, &models.ImageCandidateGroup{})
+
+	storyboard := models.Storyboard{EpisodeID: 1, StoryboardNumber: 1}
+	db.Create(&storyboard)
+
+	group := "group-1"
+	var candidates []models.ImageGeneration
+	for _, url := range []string{"a.png", "b.png", ""} {
+		imageURL := url
+		candidate := models.ImageGeneration{StoryboardID: &storyboard.ID, DramaID: 1, Provider: "openai", Prompt: "p", CandidateGroup: &group, Status: models.ImageStatusCompleted, ImageURL: &imageURL}
+		if url == "" {
+			candidate.Status = models.ImageStatusProcessing
+			candidate.ImageURL = nil
+		}
+		db.Create(&candidate)
+		candidates = append(candidates, candidate)
+	}
+
+	service := NewImageGenerationService(db, &config.Config{}, nil, nil, logger.NewLogger(true))
+
+	if _, err := service.SelectStoryboardImage(storyboard.ID, candidates[2].ID); err == nil {
+		t.Fatalf("expected unfinished candidate to be rejected")
+	} else if _, ok := IsValidationError(err); !ok {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	updated, err := service.SelectStoryboardImage(storyboard.ID, candidates[1].ID)
+	if err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if updated.ComposedImage == nil || *updated.ComposedImage != "b.png" || updated.SelectedImageID == nil || *updated.SelectedImageID != candidates[1].ID {
+		t.Fatalf("expected storyboard to use selected candidate, got %+v", updated)
+	}
+
+	sbID := storyboard.ID
+	images, _, err := service.ListImageGenerations(nil, nil, &sbID, "", "", 1, 20)
+	if err != nil || len(images) != 1 || images[0].ID != candidates[1].ID {
+		t.Fatalf("expected only the selected candidate to be listed, got %d images (err %v)", len(images), err)
+	}
+	discarded, err := service.ListDiscardedImages(nil, &sbID)
+	if err != nil || len(discarded) != 2 {
+		t.Fatalf("expected two discarded candidates, got %d (err %v)", len(discarded), err)
+	}
+
+	// 重新选择回收区中的候选图会将其恢复，并让之前选中的图片落选
+	if _, err := service.SelectStoryboardImage(storyboard.ID, candidates[0].ID); err != nil {
+		t.Fatalf("reselect failed: %v", err)
+	}
+	var previous models.ImageGeneration
+	db.First(&previous, candidates[1].ID)
+	if previous.DiscardedAt == nil {
+		t.Fatalf("expected previously selected candidate to be discarded")
+	}
+
+	if purged, err := service.PurgeDiscardedImages(time.Hour); err != nil || purged != 0 {
+		t.Fatalf("expected recent discards to be kept, purged %d (err %v)", purged, err)
+	}
+	db.Model(&models.ImageGeneration{}).Where("discarded_at IS NOT NULL").Update("discarded_at", time.Now().Add(-2*time.Hour))
+	if purged, err := service.PurgeDiscardedImages(time.Hour); err != nil || purged != 2 {
+		t.Fatalf("expected two purged candidates, purged %d (err %v)", purged, err)
+	}
+}
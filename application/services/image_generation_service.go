@@ -11,9 +11,9 @@ import (
 	"github.com/drama-generator/backend/infrastructure/storage"
 	"github.com/drama-generator/backend/pkg/ai"
 	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/httpadapter"
 	"github.com/drama-generator/backend/pkg/image"
 	"github.com/drama-generator/backend/pkg/logger"
-	"github.com/drama-generator/backend/pkg/utils"
 	"gorm.io/gorm"
 )
 
@@ -26,6 +26,7 @@ type ImageGenerationService struct {
 	config          *config.Config
 	promptI18n      *PromptI18n
 	taskService     *TaskService
+	capabilities    *ProviderCapabilityService
 }
 
 // truncateImageURL 截断图片 URL，避免 base64 格式的 URL 占满日志
@@ -53,9 +54,10 @@ func NewImageGenerationService(db *gorm.DB, cfg *config.Config, transferService
 		transferService: transferService,
 		localStorage:    localStorage,
 		config:          cfg,
-		promptI18n:      NewPromptI18n(cfg),
+		promptI18n:      NewPromptI18n(cfg, db, log),
 		log:             log,
 		taskService:     NewTaskService(db, log),
+		capabilities:    NewProviderCapabilityService(db, log),
 	}
 }
 
@@ -85,14 +87,39 @@ type GenerateImageRequest struct {
 	Width           *int     `json:"width"`
 	Height          *int     `json:"height"`
 	ReferenceImages []string `json:"reference_images"` // 参考图片URL列表
+	Candidates      int      `json:"candidates"`       // 候选图数量，大于1时为分镜帧生成一组候选图
+	AutoSelect      bool     `json:"auto_select"`      // 候选图全部完成后由视觉模型评分并自动选出最佳
+	MinScore        float64  `json:"min_score"`        // 自动选图的最低综合分（0-10）
+	Regenerations   int      `json:"regenerations"`    // 最佳候选低于 min_score 时最多重新生成的轮数
 }
 
 func (s *ImageGenerationService) GenerateImage(request *GenerateImageRequest) (*models.ImageGeneration, error) {
+	imageGen, err := s.prepareImageGeneration(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(imageGen).Error; err != nil {
+		return nil, fmt.Errorf("failed to create record: %w", err)
+	}
+
+	go s.ProcessImageGeneration(imageGen.ID)
+
+	return imageGen, nil
+}
+
+// prepareImageGeneration 校验请求并应用剧本级规范，返回尚未保存的生成记录
+func (s *ImageGenerationService) prepareImageGeneration(request *GenerateImageRequest) (*models.ImageGeneration, error) {
 	var drama models.Drama
 	if err := s.db.Where("id = ? ", request.DramaID).First(&drama).Error; err != nil {
 		return nil, fmt.Errorf("drama not found")
 	}
 
+	// 剧本或工作区预算用尽时拒绝新任务
+	if err := s.aiService.GetBudgetService().Check(BudgetCheck{DramaID: drama.ID, ServiceType: "image"}); err != nil {
+		return nil, err
+	}
+
 	// Apply StylePrompt globally (if not already present)
 	if drama.StylePrompt != nil && *drama.StylePrompt != "" {
 		if !strings.Contains(request.Prompt, *drama.StylePrompt) {
@@ -155,6 +182,16 @@ func (s *ImageGenerationService) GenerateImage(request *GenerateImageRequest) (*
 		request.Size = "2048x2048"
 	}
 
+	// 分镜图片使用角色/道具设定图中最适合镜头角度的单个视图
+	if request.StoryboardID != nil && len(request.ReferenceImages) > 0 {
+		request.ReferenceImages = resolveSheetViews(s.db, *request.StoryboardID, request.ReferenceImages)
+	}
+
+	// 未显式指定种子时，沿用分镜中生效造型的种子，使同一造型多次出图保持一致
+	if request.Seed == nil && request.StoryboardID != nil {
+		request.Seed = resolveLookSeed(s.db, *request.StoryboardID)
+	}
+
 	// 注意：SceneID可能指向Scene或Storyboard表，调用方已经做过权限验证，这里不再重复验证
 
 	provider := request.Provider
@@ -204,12 +241,6 @@ func (s *ImageGenerationService) GenerateImage(request *GenerateImageRequest) (*
 		Status:          models.ImageStatusPending,
 	}
 
-	if err := s.db.Create(imageGen).Error; err != nil {
-		return nil, fmt.Errorf("failed to create record: %w", err)
-	}
-
-	go s.ProcessImageGeneration(imageGen.ID)
-
 	return imageGen, nil
 }
 
@@ -287,26 +318,22 @@ func (s *ImageGenerationService) ProcessImageGeneration(imageGenID uint) {
 		return
 	}
 
-	candidateConfigs := configs
-	if imageGen.Model != "" {
-		var matched []models.AIServiceConfig
-		for _, cfg := range configs {
-			for _, model := range cfg.Model {
-				if model == imageGen.Model {
-					matched = append(matched, cfg)
-					break
-				}
-			}
-		}
-		if len(matched) > 0 {
-			candidateConfigs = matched
-		}
-	}
+	candidateConfigs := candidateConfigsForModel(configs, imageGen.Model)
 
 	var lastErr error
+	var callbackToken string
 	for index := range candidateConfigs {
 		config := candidateConfigs[index]
-		client, actualProvider, actualModel, buildErr := s.buildImageClientFromConfig(&config, imageGen.Provider, imageGen.Model)
+		if budgetErr := s.aiService.GetBudgetService().CheckConfig(config.ID, "image"); budgetErr != nil {
+			lastErr = budgetErr
+			s.log.Warnw("Image config budget exhausted, skipping", "id", imageGenID, "config_id", config.ID)
+			continue
+		}
+		requestModel := imageGen.Model
+		if !configHasModel(&config, requestModel) {
+			requestModel = ""
+		}
+		client, actualProvider, actualModel, buildErr := s.buildImageClientFromConfig(&config, imageGen.Provider, requestModel)
 		if buildErr != nil {
 			lastErr = buildErr
 			continue
@@ -315,30 +342,42 @@ func (s *ImageGenerationService) ProcessImageGeneration(imageGenID uint) {
 			s.log.Warnw("Image generation using fallback config", "image_id", imageGenID, "config_id", config.ID, "provider", actualProvider, "model", actualModel)
 		}
 
-		result, callErr := client.GenerateImage(prompt, opts...)
+		callOpts := s.adjustImageOptions(opts, &config, &imageGen, actualModel, referenceImages)
+		callbackOpt, withCallback := s.callbackOption(imageGenID, &config, actualProvider, &callbackToken)
+		if withCallback {
+			callOpts = append(callOpts, callbackOpt)
+		}
+		result, callErr := client.GenerateImage(prompt, callOpts...)
+		recordConfigResult(config.ID, callErr)
 		if callErr == nil {
-			if actualProvider != imageGen.Provider || actualModel != imageGen.Model {
-				s.db.Model(&imageGen).Updates(map[string]interface{}{
-					"provider": actualProvider,
-					"model":    actualModel,
-				})
-			}
+			// 记录实际完成生成的配置
+			s.db.Model(&imageGen).Updates(map[string]interface{}{
+				"provider":  actualProvider,
+				"model":     actualModel,
+				"config_id": config.ID,
+			})
 			s.log.Infow("Image generation API call completed", "id", imageGenID, "completed", result.Completed, "has_url", result.ImageURL != "")
 			if !result.Completed {
-				s.db.Model(&imageGen).Updates(map[string]interface{}{
+				updates := map[string]interface{}{
 					"status":  models.ImageStatusProcessing,
 					"task_id": result.TaskID,
-				})
-				go s.pollTaskStatus(imageGenID, client, result.TaskID)
+				}
+				if !withCallback {
+					// 最终使用的配置未注册回调，清除令牌以恢复正常轮询频率
+					updates["callback_token"] = nil
+				}
+				s.db.Model(&imageGen).Updates(updates)
+				go s.pollTaskStatus(imageGenID, client, result.TaskID, &config, actualModel)
 				return
 			}
 			s.completeImageGeneration(imageGenID, result)
+			s.recordImageUsage(imageGenID, &config, actualModel)
 			return
 		}
 
 		lastErr = callErr
 		s.log.Errorw("Image generation API call failed", "error", callErr, "id", imageGenID, "prompt", imageGen.Prompt, "config_id", config.ID, "provider", actualProvider, "model", actualModel)
-		if !isRetryableAIError(callErr) {
+		if !isFailoverError(callErr) {
 			break
 		}
 		if index < len(candidateConfigs)-1 {
@@ -352,31 +391,106 @@ func (s *ImageGenerationService) ProcessImageGeneration(imageGenID uint) {
 	s.updateImageGenError(imageGenID, lastErr.Error())
 }
 
-func (s *ImageGenerationService) pollTaskStatus(imageGenID uint, client image.ImageClient, taskID string) {
+// adjustImageOptions 按候选配置的服务商能力追加覆盖参数：模型、尺寸与参考图数量
+func (s *ImageGenerationService) adjustImageOptions(opts []image.ImageOption, config *models.AIServiceConfig, imageGen *models.ImageGeneration, model string, referenceImages []string) []image.ImageOption {
+	callOpts := opts[:len(opts):len(opts)]
+	if imageGen.Model != "" && model != imageGen.Model {
+		callOpts = append(callOpts, image.WithModel(model))
+	}
+
+	caps, err := s.capabilities.GetCapabilities("image", config.Provider, model)
+	if err != nil {
+		s.log.Warnw("Failed to load provider capabilities", "provider", config.Provider, "error", err)
+		return callOpts
+	}
+	if caps == nil {
+		return callOpts
+	}
+
+	if size, adjustment := AdjustImageSize(caps, imageGen.Size); adjustment != nil {
+		s.log.Infow("Image size adjusted to provider capabilities", "id", imageGen.ID, "config_id", config.ID, "from", adjustment.From, "to", adjustment.To)
+		callOpts = append(callOpts, image.WithSize(size))
+	}
+	if caps.MaxReferenceImages > 0 && len(referenceImages) > caps.MaxReferenceImages {
+		s.log.Infow("Reference images truncated to provider limit", "id", imageGen.ID, "config_id", config.ID, "limit", caps.MaxReferenceImages)
+		callOpts = append(callOpts, image.WithReferenceImages(referenceImages[:caps.MaxReferenceImages]))
+	}
+	return callOpts
+}
+
+func (s *ImageGenerationService) pollTaskStatus(imageGenID uint, client image.ImageClient, taskID string, config *models.AIServiceConfig, model string) {
 	maxAttempts := 60
 	pollInterval := 5 * time.Second
+	var current models.ImageGeneration
+	if err := s.db.Select("id", "callback_token").First(&current, imageGenID).Error; err == nil && current.CallbackToken != nil {
+		// 已注册回调，轮询仅作兜底
+		maxAttempts = imageCallbackPollMaxAttempts
+		pollInterval = imageCallbackPollInterval
+	}
 
 	for i := 0; i < maxAttempts; i++ {
 		time.Sleep(pollInterval)
 
+		var imageGen models.ImageGeneration
+		if err := s.db.Select("id", "status").First(&imageGen, imageGenID).Error; err != nil {
+			s.log.Errorw("Failed to load image generation", "error", err, "id", imageGenID)
+			return
+		}
+		if imageGen.Status != models.ImageStatusProcessing {
+			s.log.Infow("Image generation status changed, stopping poll", "id", imageGenID, "status", imageGen.Status)
+			return
+		}
+
 		result, err := client.GetTaskStatus(taskID)
 		if err != nil {
 			s.log.Errorw("Failed to get task status", "error", err, "task_id", taskID)
 			continue
 		}
 
-		if result.Completed {
-			s.completeImageGeneration(imageGenID, result)
+		if s.applyImageTaskResult(imageGenID, result, config, model) {
 			return
 		}
+	}
 
-		if result.Error != "" {
-			s.updateImageGenError(imageGenID, result.Error)
-			return
+	s.updateImageGenError(imageGenID, "timeout: image generation took too long")
+}
+
+// getImageClientForGeneration 优先使用实际提交任务的配置，没有记录时使用默认图片配置
+func (s *ImageGenerationService) getImageClientForGeneration(imageGen *models.ImageGeneration) (image.ImageClient, *models.AIServiceConfig, string, error) {
+	var config *models.AIServiceConfig
+	var err error
+	if imageGen.ConfigID != nil {
+		config, err = s.aiService.GetConfig(*imageGen.ConfigID)
+	} else {
+		config, err = s.aiService.GetDefaultConfig("image")
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+	client, _, model, err := s.buildImageClientFromConfig(config, imageGen.Provider, imageGen.Model)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return client, config, model, nil
+}
+
+// recordImageUsage 记录图片生成用量，归属到剧本和分镜所在章节
+func (s *ImageGenerationService) recordImageUsage(imageGenID uint, config *models.AIServiceConfig, model string) {
+	var imageGen models.ImageGeneration
+	if err := s.db.First(&imageGen, imageGenID).Error; err != nil {
+		s.log.Warnw("Failed to load image generation for usage", "error", err, "id", imageGenID)
+		return
+	}
+
+	scope := UsageScope{DramaID: imageGen.DramaID, Operation: "image_generation"}
+	if imageGen.StoryboardID != nil {
+		var storyboard models.Storyboard
+		if err := s.db.Select("id", "episode_id").First(&storyboard, *imageGen.StoryboardID).Error; err == nil {
+			scope.EpisodeID = storyboard.EpisodeID
 		}
 	}
 
-	s.updateImageGenError(imageGenID, "timeout: image generation took too long")
+	s.aiService.GetUsageService().RecordImage(scope, config, model, 1, imageGen.Size, &imageGen.ID)
 }
 
 func (s *ImageGenerationService) completeImageGeneration(imageGenID uint, result *image.ImageResult) {
@@ -429,9 +543,10 @@ func (s *ImageGenerationService) completeImageGeneration(imageGenID uint, result
 
 	s.db.Model(&models.ImageGeneration{}).Where("id = ?", imageGenID).Updates(updates)
 	s.log.Infow("Image generation completed", "id", imageGenID)
+	s.onCandidateFinished(&imageGen)
 
-	// 如果关联了storyboard，同步更新storyboard的composed_image
-	if imageGen.StoryboardID != nil {
+	// 如果关联了storyboard，同步更新storyboard的composed_image；候选图需等待选中后再同步
+	if imageGen.StoryboardID != nil && imageGen.CandidateGroup == nil {
 		if err := s.db.Model(&models.Storyboard{}).Where("id = ?", *imageGen.StoryboardID).Update("composed_image", finalImageURL).Error; err != nil {
 			s.log.Errorw("Failed to update storyboard composed_image", "error", err, "storyboard_id", *imageGen.StoryboardID)
 		} else {
@@ -477,6 +592,8 @@ func (s *ImageGenerationService) completeImageGeneration(imageGenID uint, result
 				"image_url", truncateImageURL(finalImageURL))
 		}
 	}
+
+	s.splitSheetAsync(&imageGen, finalImageURL)
 }
 
 func (s *ImageGenerationService) updateImageGenError(imageGenID uint, errorMsg string) {
@@ -493,6 +610,7 @@ func (s *ImageGenerationService) updateImageGenError(imageGenID uint, errorMsg s
 		"error_msg": errorMsg,
 	})
 	s.log.Errorw("Image generation failed", "id", imageGenID, "error", errorMsg)
+	s.onCandidateFinished(&imageGen)
 
 	// 如果关联了scene，同步更新scene为失败状态
 	if imageGen.SceneID != nil {
@@ -501,6 +619,24 @@ func (s *ImageGenerationService) updateImageGenError(imageGenID uint, errorMsg s
 	}
 }
 
+// imageProviderSettings 从 AIServiceConfig.Settings 中读取的自部署图片服务参数，例如：
+// {"sampler": "DPM++ 2M Karras", "loras": [{"name": "hero_v2", "weight": 0.8}], "denoising_strength": 0.55}
+// ComfyUI 可通过 "workflow" 提供 API 格式的自定义工作流
+type imageProviderSettings struct {
+	Sampler           string          `json:"sampler"`
+	LoRAs             []image.SDLora  `json:"loras"`
+	DenoisingStrength float64         `json:"denoising_strength"`
+	Workflow          json.RawMessage `json:"workflow"`
+}
+
+func parseImageProviderSettings(config *models.AIServiceConfig) imageProviderSettings {
+	var settings imageProviderSettings
+	if strings.TrimSpace(config.Settings) != "" {
+		json.Unmarshal([]byte(config.Settings), &settings)
+	}
+	return settings
+}
+
 func (s *ImageGenerationService) buildImageClientFromConfig(config *models.AIServiceConfig, fallbackProvider string, modelName string) (image.ImageClient, string, string, error) {
 	model := modelName
 	if model == "" && len(config.Model) > 0 {
@@ -530,18 +666,37 @@ func (s *ImageGenerationService) buildImageClientFromConfig(config *models.AISer
 		}
 	}
 
+	var client image.ImageClient
 	switch actualProvider {
-	case "openai", "dalle":
-		return image.NewOpenAIImageClient(config.BaseURL, config.APIKey, model, endpoint), actualProvider, model, nil
-	case "chatfire":
-		return image.NewOpenAIImageClient(config.BaseURL, config.APIKey, model, endpoint), actualProvider, model, nil
 	case "volcengine", "volces", "doubao":
-		return image.NewVolcEngineImageClient(config.BaseURL, config.APIKey, model, endpoint, queryEndpoint), actualProvider, model, nil
+		client = image.NewVolcEngineImageClient(config.BaseURL, config.APIKey, model, endpoint, queryEndpoint)
 	case "gemini", "google":
-		return image.NewGeminiImageClient(config.BaseURL, config.APIKey, model, endpoint), actualProvider, model, nil
+		client = image.NewGeminiImageClient(config.BaseURL, config.APIKey, model, endpoint)
+	case "mock":
+		client = image.NewMockImageClient(model)
+	case string(models.ProviderStableDiffusion), "sdwebui", "automatic1111":
+		settings := parseImageProviderSettings(config)
+		sdClient := image.NewSDWebUIImageClient(config.BaseURL, config.APIKey, model)
+		sdClient.Sampler = settings.Sampler
+		sdClient.LoRAs = settings.LoRAs
+		if settings.DenoisingStrength > 0 {
+			sdClient.DenoisingStrength = settings.DenoisingStrength
+		}
+		client = sdClient
+	case "http_adapter", "custom":
+		spec, err := httpadapter.ParseSpec(config.Settings)
+		if err != nil {
+			return nil, actualProvider, model, fmt.Errorf("invalid http adapter config: %w", err)
+		}
+		client = image.NewHTTPAdapterImageClient(config.BaseURL, config.APIKey, model, config.Endpoint, config.QueryEndpoint, spec)
+	case string(models.ProviderComfyUI):
+		settings := parseImageProviderSettings(config)
+		client = image.NewComfyUIImageClient(config.BaseURL, config.APIKey, model, string(settings.Workflow))
 	default:
-		return image.NewOpenAIImageClient(config.BaseURL, config.APIKey, model, endpoint), actualProvider, model, nil
+		// openai, dalle, chatfire 及其他 OpenAI 兼容服务
+		client = image.NewOpenAIImageClient(config.BaseURL, config.APIKey, model, endpoint)
 	}
+	return newRateLimitedImageClient(client, config), actualProvider, model, nil
 }
 
 func (s *ImageGenerationService) getImageClient(provider string) (image.ImageClient, error) {
@@ -557,28 +712,18 @@ func (s *ImageGenerationService) getImageClient(provider string) (image.ImageCli
 	return client, nil
 }
 
-// getImageClientWithModel 根据模型名称获取图片客户端
+// getImageClientWithModel 根据模型名称获取图片客户端，按优先级选择第一个未熔断的配置
 func (s *ImageGenerationService) getImageClientWithModel(provider string, modelName string) (image.ImageClient, error) {
-	var config *models.AIServiceConfig
-	var err error
+	configs, err := s.aiService.getActiveConfigs("image")
+	if err != nil {
+		return nil, fmt.Errorf("no image AI config found: %w", err)
+	}
 
-	// 如果指定了模型，尝试获取对应的配置
-	if modelName != "" {
-		config, err = s.aiService.GetConfigForModel("image", modelName)
-		if err != nil {
-			s.log.Warnw("Failed to get config for model, using default", "model", modelName, "error", err)
-			config, err = s.aiService.GetDefaultConfig("image")
-			if err != nil {
-				return nil, fmt.Errorf("no image AI config found: %w", err)
-			}
-		}
-	} else {
-		config, err = s.aiService.GetDefaultConfig("image")
-		if err != nil {
-			return nil, fmt.Errorf("no image AI config found: %w", err)
-		}
+	config := candidateConfigsForModel(configs, modelName)[0]
+	if !configHasModel(&config, modelName) {
+		modelName = ""
 	}
-	client, _, _, err := s.buildImageClientFromConfig(config, provider, modelName)
+	client, _, _, err := s.buildImageClientFromConfig(&config, provider, modelName)
 	if err != nil {
 		return nil, err
 	}
@@ -594,7 +739,8 @@ func (s *ImageGenerationService) GetImageGeneration(imageGenID uint) (*models.Im
 }
 
 func (s *ImageGenerationService) ListImageGenerations(dramaID *uint, sceneID *uint, storyboardID *uint, frameType string, status string, page, pageSize int) ([]models.ImageGeneration, int64, error) {
-	query := s.db.Model(&models.ImageGeneration{})
+	// 回收区中的落选候选图不出现在常规列表中
+	query := s.db.Model(&models.ImageGeneration{}).Where("discarded_at IS NULL")
 
 	if dramaID != nil {
 		query = query.Where("drama_id = ?", *dramaID)
@@ -736,9 +882,9 @@ type BackgroundInfo struct {
 	Time              string `json:"time"`
 	Atmosphere        string `json:"atmosphere"`
 	Prompt            string `json:"prompt"`
-	StoryboardNumbers []int  `json:"storyboard_numbers"`
-	SceneIDs          []uint `json:"scene_ids"`
-	StoryboardCount   int    `json:"scene_count"`
+	StoryboardNumbers []int  `json:"storyboard_numbers" schema:"-"`
+	SceneIDs          []uint `json:"scene_ids" schema:"-"`
+	StoryboardCount   int    `json:"scene_count" schema:"-"`
 }
 
 func (s *ImageGenerationService) BatchGenerateImagesForEpisode(episodeID string) ([]*models.ImageGeneration, error) {
@@ -781,6 +927,13 @@ func (s *ImageGenerationService) BatchGenerateImagesForEpisode(episodeID string)
 				"location", bg.Location,
 				"error", err)
 			s.db.Model(bg).Update("status", "failed")
+			// 预算用尽时停止整批任务，避免逐个失败
+			if _, ok := IsBudgetExceededError(err); ok {
+				if len(results) == 0 {
+					return nil, err
+				}
+				break
+			}
 			continue
 		}
 
@@ -813,7 +966,7 @@ func (s *ImageGenerationService) GetScencesForEpisode(episodeID string) ([]*mode
 }
 
 // ExtractBackgroundsForEpisode 从剧本内容中提取场景并保存到项目级别数据库
-func (s *ImageGenerationService) ExtractBackgroundsForEpisode(episodeID string, model string, style string) (string, error) {
+func (s *ImageGenerationService) ExtractBackgroundsForEpisode(episodeID string, model string, style string, bypassCache bool) (string, error) {
 	var episode models.Episode
 	if err := s.db.Preload("Storyboards").First(&episode, episodeID).Error; err != nil {
 		return "", fmt.Errorf("episode not found")
@@ -825,21 +978,21 @@ func (s *ImageGenerationService) ExtractBackgroundsForEpisode(episodeID string,
 	}
 
 	// 创建任务
-	task, err := s.taskService.CreateTask("background_extraction", episodeID)
+	task, err := s.taskService.CreateTaskWithParams("background_extraction", episodeID, backgroundTaskParams{EpisodeID: episodeID, Model: model, Style: style})
 	if err != nil {
 		s.log.Errorw("Failed to create background extraction task", "error", err, "episode_id", episodeID)
 		return "", fmt.Errorf("创建任务失败: %w", err)
 	}
 
 	// 异步处理场景提取
-	go s.processBackgroundExtraction(task.ID, episodeID, model, style)
+	go s.processBackgroundExtraction(task.ID, episodeID, model, style, bypassCache)
 
 	s.log.Infow("Background extraction task created", "task_id", task.ID, "episode_id", episodeID)
 	return task.ID, nil
 }
 
 // processBackgroundExtraction 异步处理场景提取
-func (s *ImageGenerationService) processBackgroundExtraction(taskID string, episodeID string, model string, style string) {
+func (s *ImageGenerationService) processBackgroundExtraction(taskID string, episodeID string, model string, style string, bypassCache bool) {
 	// 更新任务状态为处理中
 	s.taskService.UpdateTaskStatus(taskID, "processing", 0, "正在提取场景信息...")
 
@@ -860,7 +1013,8 @@ func (s *ImageGenerationService) processBackgroundExtraction(taskID string, epis
 	dramaID := episode.DramaID
 
 	// 使用AI从剧本内容中提取场景
-	backgroundsInfo, err := s.extractBackgroundsFromScript(*episode.ScriptContent, dramaID, model, style)
+	cacheStatus := NewLLMCacheStatus(bypassCache)
+	backgroundsInfo, err := s.extractBackgroundsFromScript(*episode.ScriptContent, dramaID, model, style, cacheStatus)
 	if err != nil {
 		s.log.Errorw("Failed to extract backgrounds from script", "error", err, "task_id", taskID)
 		s.taskService.UpdateTaskError(taskID, fmt.Errorf("AI提取场景失败: %w", err))
@@ -870,7 +1024,24 @@ func (s *ImageGenerationService) processBackgroundExtraction(taskID string, epis
 	// 保存到数据库（不涉及Storyboard关联，因为此时还没有生成分镜）
 	var scenes []*models.Scene
 	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 地点是已合并场景的别名时沿用其地点，并复用已生成的背景图；别名可能指向本章节的旧场景，须在删除前解析
+		reusedImages := make([]*string, len(backgroundsInfo))
+		for i := range backgroundsInfo {
+			backgroundsInfo[i].Location = canonicalSceneLocation(tx, dramaID, backgroundsInfo[i].Location)
+			var existingScene models.Scene
+			err := tx.Where("drama_id = ? AND location = ? AND time = ? AND image_url IS NOT NULL AND image_url != ''", dramaID, backgroundsInfo[i].Location, backgroundsInfo[i].Time).
+				Order("updated_at DESC").
+				First(&existingScene).Error
+			if err == nil && existingScene.ImageURL != nil && *existingScene.ImageURL != "" {
+				reusedImages[i] = existingScene.ImageURL
+			}
+		}
+
 		// 先删除该章节的所有场景（实现重新提取覆盖功能）
+		var oldScenes []models.Scene
+		if err := tx.Where("episode_id = ?", episode.ID).Find(&oldScenes).Error; err != nil {
+			return err
+		}
 		if err := tx.Where("episode_id = ?", episode.ID).Delete(&models.Scene{}).Error; err != nil {
 			s.log.Errorw("Failed to delete old scenes", "error", err, "task_id", taskID)
 			return err
@@ -878,14 +1049,9 @@ func (s *ImageGenerationService) processBackgroundExtraction(taskID string, epis
 		s.log.Infow("Deleted old scenes for re-extraction", "episode_id", episode.ID, "task_id", taskID)
 
 		// 创建新提取的场景
-		for _, bgInfo := range backgroundsInfo {
+		for i, bgInfo := range backgroundsInfo {
 			// 保存新场景到数据库（章节级）
 			episodeIDVal := episode.ID
-			var existingScene models.Scene
-			err := tx.Where("drama_id = ? AND location = ? AND time = ? AND image_url IS NOT NULL AND image_url != ''", dramaID, bgInfo.Location, bgInfo.Time).
-				Order("updated_at DESC").
-				First(&existingScene).Error
-
 			scene := &models.Scene{
 				DramaID:         dramaID,
 				EpisodeID:       &episodeIDVal,
@@ -895,8 +1061,8 @@ func (s *ImageGenerationService) processBackgroundExtraction(taskID string, epis
 				StoryboardCount: 1, // 默认为1
 				Status:          "pending",
 			}
-			if err == nil && existingScene.ImageURL != nil && *existingScene.ImageURL != "" {
-				scene.ImageURL = existingScene.ImageURL
+			if reusedImages[i] != nil {
+				scene.ImageURL = reusedImages[i]
 				scene.Status = "generated"
 			}
 			if err := tx.Create(scene).Error; err != nil {
@@ -911,7 +1077,8 @@ func (s *ImageGenerationService) processBackgroundExtraction(taskID string, epis
 				"task_id", taskID)
 		}
 
-		return nil
+		// 合并时记录的别名及合并后引用旧场景的分镜，改指向新提取的同地点场景
+		return retargetSceneReferences(tx, dramaID, oldScenes)
 	})
 
 	if err != nil {
@@ -926,6 +1093,7 @@ func (s *ImageGenerationService) processBackgroundExtraction(taskID string, epis
 		"count":      len(scenes),
 		"episode_id": episodeID,
 		"drama_id":   dramaID,
+		"cache_hit":  cacheStatus.Hit(),
 	}
 	s.taskService.UpdateTaskResult(taskID, resultData)
 
@@ -937,7 +1105,7 @@ func (s *ImageGenerationService) processBackgroundExtraction(taskID string, epis
 }
 
 // extractBackgroundsFromScript 从剧本内容中使用AI提取场景信息
-func (s *ImageGenerationService) extractBackgroundsFromScript(scriptContent string, dramaID uint, model string, style string) ([]BackgroundInfo, error) {
+func (s *ImageGenerationService) extractBackgroundsFromScript(scriptContent string, dramaID uint, model string, style string, cacheStatus *LLMCacheStatus) ([]BackgroundInfo, error) {
 	if scriptContent == "" {
 		return []BackgroundInfo{}, nil
 	}
@@ -960,88 +1128,12 @@ func (s *ImageGenerationService) extractBackgroundsFromScript(scriptContent stri
 	}
 
 	// 使用国际化提示词
-	systemPrompt := s.promptI18n.GetSceneExtractionPrompt(style)
-	contentLabel := s.promptI18n.FormatUserPrompt("script_content_label")
-
-	// 根据语言构建不同的格式说明
-	var formatInstructions string
-	if s.promptI18n.IsEnglish() {
-		formatInstructions = `[Output JSON Format]
-{
-  "backgrounds": [
-    {
-      "location": "Location name (English)",
-      "time": "Time description (English)",
-      "atmosphere": "Atmosphere description (English)",
-      "prompt": "A cinematic anime-style pure background scene depicting [location description] at [time]. The scene shows [environment details, architecture, objects, lighting, no characters]. Style: rich details, high quality, atmospheric lighting. Mood: [environment mood description]."
-    }
-  ]
-}
+	promptI18n := s.promptI18n.ForDrama(dramaID)
+	systemPrompt := promptI18n.GetSceneExtractionPrompt(style)
+	contentLabel := promptI18n.FormatUserPrompt("script_content_label")
 
-[Example]
-Correct example (note: no characters):
-{
-  "backgrounds": [
-    {
-      "location": "Repair Shop Interior",
-      "time": "Late Night",
-      "atmosphere": "Dim, lonely, industrial",
-      "prompt": "A cinematic anime-style pure background scene depicting a messy repair shop interior at late night. Under dim fluorescent lights, the workbench is scattered with various wrenches, screwdrivers and mechanical parts, oil-stained tool boards and faded posters hang on walls, oil stains on the floor, used tires piled in corners. Style: rich details, high quality, dim atmosphere. Mood: lonely, industrial."
-    },
-    {
-      "location": "City Street",
-      "time": "Dusk",
-      "atmosphere": "Warm, busy, lively",
-      "prompt": "A cinematic anime-style pure background scene depicting a bustling city street at dusk. Sunset afterglow shines on the asphalt road, neon lights of shops on both sides begin to light up, bicycle racks and bus stops on the street, high-rise buildings in the distance, sky showing orange-red gradient. Style: rich details, high quality, warm atmosphere. Mood: lively, busy."
-    }
-  ]
-}
-
-[Wrong Examples (containing characters, forbidden)]:
-❌ "Depicting protagonist standing on the street" - contains character
-❌ "People hurrying by" - contains characters
-❌ "Character moving in the room" - contains character
-
-Please strictly follow the JSON format and ensure all fields use English.`
-	} else {
-		formatInstructions = `【输出JSON格式】
-{
-  "backgrounds": [
-    {
-      "location": "地点名称（中文）",
-      "time": "时间描述（中文）",
-      "atmosphere": "氛围描述（中文）",
-      "prompt": "一个电影感的动漫风格纯背景场景，展现[地点描述]在[时间]的环境。画面呈现[环境细节、建筑、物品、光线等，不包含人物]。风格：细节丰富，高质量，氛围光照。情绪：[环境情绪描述]。"
-    }
-  ]
-}
-
-【示例】
-正确示例（注意：不包含人物）：
-{
-  "backgrounds": [
-    {
-      "location": "维修店内部",
-      "time": "深夜",
-      "atmosphere": "昏暗、孤独、工业感",
-      "prompt": "一个电影感的动漫风格纯背景场景，展现凌乱的维修店内部在深夜的环境。昏暗的日光灯照射下，工作台上散落着各种扳手、螺丝刀和机械零件，墙上挂着油污斑斑的工具挂板和褪色海报，地面有油渍痕迹，角落堆放着废旧轮胎。风格：细节丰富，高质量，昏暗氛围。情绪：孤独、工业感。"
-    },
-    {
-      "location": "城市街道",
-      "time": "黄昏",
-      "atmosphere": "温暖、繁忙、生活气息",
-      "prompt": "一个电影感的动漫风格纯背景场景，展现繁华的城市街道在黄昏时分的环境。夕阳的余晖洒在街道的沥青路面上，两旁的商铺霓虹灯开始点亮，街边有自行车停靠架和公交站牌，远处高楼林立，天空呈现橙红色渐变。风格：细节丰富，高质量，温暖氛围。情绪：生活气息、繁忙。"
-    }
-  ]
-}
-
-【错误示例（包含人物，禁止）】：
-❌ "展现主角站在街道上的场景" - 包含人物
-❌ "人们匆匆而过" - 包含人物
-❌ "角色在房间里活动" - 包含人物
-
-请严格按照JSON格式输出，确保所有字段都使用中文。`
-	}
+	// 输出格式说明随剧本语言变化
+	formatInstructions := promptI18n.FormatUserPrompt("background_format_script")
 
 	prompt := fmt.Sprintf(`%s
 
@@ -1052,38 +1144,27 @@ Please strictly follow the JSON format and ensure all fields use English.`
 
 	// 打印完整提示词用于调试
 	s.log.Infow("=== AI Prompt for Background Extraction (extractBackgroundsFromScript) ===",
-		"language", s.promptI18n.GetLanguage(),
+		"language", promptI18n.GetLanguage(),
 		"prompt_length", len(prompt),
 		"full_prompt", prompt)
 
-	response, err := client.GenerateText(prompt, "", ai.WithTemperature(0.7))
-	if err != nil {
-		s.log.Errorw("Failed to extract backgrounds with AI", "error", err)
-		return nil, fmt.Errorf("AI提取场景失败: %w", err)
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return client.GenerateText(p, "", options...)
 	}
 
+	// 解析AI返回的JSON（兼容数组与 {"backgrounds": [...]} 两种格式）
+	var backgrounds []BackgroundInfo
+	response, err := s.aiService.generateStructured(generate, prompt, "backgrounds", &backgrounds, ai.WithTemperature(0.7),
+		WithUsageScope(UsageScope{DramaID: dramaID, Operation: "background_extraction"}), cacheStatus.Option())
+
 	// 打印AI返回的原始响应
 	s.log.Infow("=== AI Response for Background Extraction (extractBackgroundsFromScript) ===",
 		"response_length", len(response),
 		"raw_response", response)
 
-	// 解析AI返回的JSON
-	var backgrounds []BackgroundInfo
-
-	// 先尝试解析为数组格式
-	if err := utils.SafeParseAIJSON(response, &backgrounds); err == nil {
-		s.log.Infow("Parsed backgrounds as array format", "count", len(backgrounds))
-	} else {
-		// 尝试解析为对象格式
-		var result struct {
-			Backgrounds []BackgroundInfo `json:"backgrounds"`
-		}
-		if err := utils.SafeParseAIJSON(response, &result); err != nil {
-			s.log.Errorw("Failed to parse AI response in both formats", "error", err, "response", response[:min(len(response), 500)])
-			return nil, fmt.Errorf("解析AI响应失败: %w", err)
-		}
-		backgrounds = result.Backgrounds
-		s.log.Infow("Parsed backgrounds as object format", "count", len(backgrounds))
+	if err != nil {
+		s.log.Errorw("Failed to extract backgrounds with AI", "error", err)
+		return nil, fmt.Errorf("AI提取场景失败: %w", err)
 	}
 
 	s.log.Infow("Extracted backgrounds from script",
@@ -1124,84 +1205,12 @@ func (s *ImageGenerationService) extractBackgroundsWithAI(storyboards []models.S
 	}
 
 	// 使用国际化提示词
-	systemPrompt := s.promptI18n.GetSceneExtractionPrompt(style)
-	storyboardLabel := s.promptI18n.FormatUserPrompt("storyboard_list_label")
-
-	// 根据语言构建不同的提示词
-	var formatInstructions string
-	if s.promptI18n.IsEnglish() {
-		formatInstructions = `[Output JSON Format]
-{
-  "backgrounds": [
-    {
-      "location": "Location name (English)",
-      "time": "Time description (English)",
-      "prompt": "A cinematic anime-style background depicting [location description] at [time]. The scene shows [detail description]. Style: rich details, high quality, atmospheric lighting. Mood: [mood description].",
-      "scene_numbers": [1, 2, 3]
-    }
-  ]
-}
+	promptI18n := s.promptI18n.ForEpisode(storyboards[0].EpisodeID)
+	systemPrompt := promptI18n.GetSceneExtractionPrompt(style)
+	storyboardLabel := promptI18n.FormatUserPrompt("storyboard_list_label")
 
-[Example]
-Correct example:
-{
-  "backgrounds": [
-    {
-      "location": "Repair Shop",
-      "time": "Late Night",
-      "prompt": "A cinematic anime-style background depicting a messy repair shop interior at late night. Under dim lighting, the workbench is scattered with various tools and parts, with greasy posters hanging on the walls. Style: rich details, high quality, dim atmosphere. Mood: lonely, industrial.",
-      "scene_numbers": [1, 5, 6, 10, 15]
-    },
-    {
-      "location": "City Panorama",
-      "time": "Late Night with Acid Rain",
-      "prompt": "A cinematic anime-style background depicting a coastal city panorama in late night acid rain. Neon lights blur in the rain, skyscrapers shrouded in gray-green rain curtain, streets reflecting colorful lights. Style: rich details, high quality, cyberpunk atmosphere. Mood: oppressive, sci-fi, apocalyptic.",
-      "scene_numbers": [2, 7]
-    }
-  ]
-}
-
-Please strictly follow the JSON format and ensure:
-1. prompt field uses English
-2. scene_numbers includes all scene numbers using this background
-3. All scenes are assigned to a background`
-	} else {
-		formatInstructions = `【输出JSON格式】
-{
-  "backgrounds": [
-    {
-      "location": "地点名称（中文）",
-      "time": "时间描述（中文）",
-      "prompt": "一个电影感的动漫风格背景，展现[地点描述]在[时间]的场景。画面呈现[细节描述]。风格：细节丰富，高质量，氛围光照。情绪：[情绪描述]。",
-      "scene_numbers": [1, 2, 3]
-    }
-  ]
-}
-
-【示例】
-正确示例：
-{
-  "backgrounds": [
-    {
-      "location": "维修店",
-      "time": "深夜",
-      "prompt": "一个电影感的动漫风格背景，展现凌乱的维修店内部在深夜的场景。昏暗的灯光下，工作台上散落着各种工具和零件，墙上挂着油污的海报。风格：细节丰富，高质量，昏暗氛围。情绪：孤独、工业感。",
-      "scene_numbers": [1, 5, 6, 10, 15]
-    },
-    {
-      "location": "城市全景",
-      "time": "深夜·酸雨",
-      "prompt": "一个电影感的动漫风格背景，展现沿海城市全景在深夜酸雨中的场景。霓虹灯在雨中模糊，高楼大厦笼罩在灰绿色的雨幕中，街道反射着五颜六色的光。风格：细节丰富，高质量，赛博朋克氛围。情绪：压抑、科幻、末世感。",
-      "scene_numbers": [2, 7]
-    }
-  ]
-}
-
-请严格按照JSON格式输出，确保：
-1. prompt字段使用中文
-2. scene_numbers包含所有使用该背景的场景编号
-3. 所有场景都被分配到某个背景`
-	}
+	// 输出格式说明随剧本语言变化
+	formatInstructions := promptI18n.FormatUserPrompt("background_format_storyboards")
 
 	prompt := fmt.Sprintf(`%s
 
@@ -1212,21 +1221,10 @@ Please strictly follow the JSON format and ensure:
 
 	// 打印完整提示词用于调试
 	s.log.Infow("=== AI Prompt for Background Extraction (extractBackgroundsWithAI) ===",
-		"language", s.promptI18n.GetLanguage(),
+		"language", promptI18n.GetLanguage(),
 		"prompt_length", len(prompt),
 		"full_prompt", prompt)
 
-	// 调用AI服务
-	text, err := s.aiService.GenerateText(prompt, "", ai.WithMaxTokens(4000))
-	if err != nil {
-		return nil, fmt.Errorf("AI analysis failed: %w", err)
-	}
-
-	// 打印AI返回的原始响应
-	s.log.Infow("=== AI Response for Background Extraction ===",
-		"response_length", len(text),
-		"raw_response", text)
-
 	// 解析AI返回的JSON
 	var result struct {
 		Scenes []struct {
@@ -1237,8 +1235,19 @@ Please strictly follow the JSON format and ensure:
 		} `json:"backgrounds"`
 	}
 
-	if err := utils.SafeParseAIJSON(text, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+	// 调用AI服务
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return s.aiService.GenerateText(p, "", options...)
+	}
+	text, err := s.aiService.generateStructured(generate, prompt, "backgrounds", &result, ai.WithMaxTokens(4000))
+
+	// 打印AI返回的原始响应
+	s.log.Infow("=== AI Response for Background Extraction ===",
+		"response_length", len(text),
+		"raw_response", text)
+
+	if err != nil {
+		return nil, fmt.Errorf("AI analysis failed: %w", err)
 	}
 
 	// 构建场景编号到场景ID的映射
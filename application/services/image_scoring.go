@@ -0,0 +1,447 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	models "github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// ImageQualityScore 视觉模型对单张图片的评分，各维度 0-10 分
+type ImageQualityScore struct {
+	PromptAdherence      float64  `json:"prompt_adherence"`
+	CharacterConsistency *float64 `json:"character_consistency,omitempty"` // 分镜没有关联角色时为空
+	Anatomy              float64  `json:"anatomy"`
+	StyleMatch           *float64 `json:"style_match,omitempty"` // 剧本未设置风格时为空
+	Overall              float64  `json:"overall" schema:"-"`    // 按权重计算，不由模型输出
+	Defects              []string `json:"defects,omitempty"`
+	Comment              string   `json:"comment,omitempty"`
+}
+
+// 综合分各维度权重，缺失的维度不参与加权
+const (
+	scoreWeightPrompt    = 0.35
+	scoreWeightCharacter = 0.25
+	scoreWeightAnatomy   = 0.25
+	scoreWeightStyle     = 0.15
+)
+
+// characterSheetPrompt 提取角色设定图外貌特征的提示词，结果经文本缓存在同一角色的多次审查间复用
+const characterSheetPrompt = "请用简洁的中文描述这张角色设定图中角色的外貌特征：发型发色、脸型五官、服装配饰、体型。只输出描述，不要输出解释。"
+
+// computeOverall 各维度限制在 0-10 后按权重计算综合分
+func (score *ImageQualityScore) computeOverall() {
+	score.PromptAdherence = clampScore(score.PromptAdherence)
+	score.Anatomy = clampScore(score.Anatomy)
+	total := score.PromptAdherence*scoreWeightPrompt + score.Anatomy*scoreWeightAnatomy
+	weight := scoreWeightPrompt + scoreWeightAnatomy
+	if score.CharacterConsistency != nil {
+		v := clampScore(*score.CharacterConsistency)
+		score.CharacterConsistency = &v
+		total += v * scoreWeightCharacter
+		weight += scoreWeightCharacter
+	}
+	if score.StyleMatch != nil {
+		v := clampScore(*score.StyleMatch)
+		score.StyleMatch = &v
+		total += v * scoreWeightStyle
+		weight += scoreWeightStyle
+	}
+	score.Overall = math.Round(total/weight*100) / 100
+}
+
+func clampScore(v float64) float64 {
+	return math.Max(0, math.Min(10, v))
+}
+
+// scoringContext 同一分镜的候选图共享的评分依据
+type scoringContext struct {
+	Prompt     string
+	Characters []scoringCharacter
+	Style      string
+	Scope      UsageScope // 评分调用的用量归属
+}
+
+// scoringCharacter 分镜角色的设定：设定图与待评分画面放在同一次请求中供模型直接对比
+type scoringCharacter struct {
+	Name       string
+	Appearance string
+	SheetURL   string
+}
+
+// sheetURLs 有设定图的角色的设定图地址，顺序与提示词中的编号一致
+func (ctx *scoringContext) sheetURLs() []string {
+	var urls []string
+	for _, character := range ctx.Characters {
+		if character.SheetURL != "" {
+			urls = append(urls, character.SheetURL)
+		}
+	}
+	return urls
+}
+
+// buildScoringContext 收集生成提示词、分镜角色的设定图特征与剧本风格
+func (s *ImageGenerationService) buildScoringContext(imageGen *models.ImageGeneration) *scoringContext {
+	ctx := &scoringContext{Prompt: imageGen.Prompt, Scope: UsageScope{DramaID: imageGen.DramaID, Operation: "image_scoring"}}
+
+	var drama models.Drama
+	if err := s.db.Select("id", "style", "style_prompt").First(&drama, imageGen.DramaID).Error; err == nil {
+		if drama.StylePrompt != nil && *drama.StylePrompt != "" {
+			ctx.Style = *drama.StylePrompt
+		} else {
+			ctx.Style = drama.Style
+		}
+	}
+
+	if imageGen.StoryboardID == nil {
+		return ctx
+	}
+	var storyboard models.Storyboard
+	if err := s.db.Preload("Characters").First(&storyboard, *imageGen.StoryboardID).Error; err != nil {
+		s.log.Warnw("Failed to load storyboard characters for scoring", "storyboard_id", *imageGen.StoryboardID, "error", err)
+		return ctx
+	}
+	ctx.Scope.EpisodeID = storyboard.EpisodeID
+	looks := resolveActiveLooks(s.db, &storyboard)
+	for _, base := range storyboard.Characters {
+		character := withLook(base, looks[base.ID])
+		entry := scoringCharacter{Name: character.Name}
+		if character.ImageURL != nil {
+			entry.SheetURL = *character.ImageURL
+		}
+		if character.Appearance != nil {
+			entry.Appearance = strings.TrimSpace(*character.Appearance)
+		}
+		if entry.SheetURL != "" || entry.Appearance != "" {
+			ctx.Characters = append(ctx.Characters, entry)
+		}
+	}
+	return ctx
+}
+
+// describeCharacterFeatures 合并视觉模型从角色设定图提取的外貌特征与 Appearance 文本
+func describeCharacterFeatures(aiService *AIService, log *logger.Logger, character *models.Character, scope UsageScope) string {
+	var features []string
+	if character.ImageURL != nil && *character.ImageURL != "" {
+		description, err := aiService.DescribeImage(*character.ImageURL, characterSheetPrompt, WithUsageScope(scope))
+		if err != nil {
+			log.Warnw("Failed to describe character sheet", "character_id", character.ID, "error", err)
+		} else if description = strings.TrimSpace(description); description != "" {
+			features = append(features, "设定图："+description)
+		}
+	}
+	if character.Appearance != nil && strings.TrimSpace(*character.Appearance) != "" {
+		features = append(features, "设定描述："+strings.TrimSpace(*character.Appearance))
+	}
+	return strings.Join(features, "；")
+}
+
+func buildScoringPrompt(ctx *scoringContext) string {
+	var sb strings.Builder
+	sb.WriteString("你是一名严格的分镜画面质检员。")
+	if len(ctx.sheetURLs()) > 0 {
+		sb.WriteString("第 1 张图片是待评分的画面，之后的图片是角色设定图，只对第 1 张图片打分。")
+	}
+	sb.WriteString("请按以下维度为画面打分，每项 0-10 分：\n")
+	sb.WriteString("1. prompt_adherence：画面内容、构图与生成提示词的符合程度\n")
+	if len(ctx.Characters) > 0 {
+		sb.WriteString("2. character_consistency：画面中角色的外貌是否与角色设定图及设定描述一致\n")
+	}
+	sb.WriteString("3. anatomy：人体结构是否正确，没有多余或缺失的手指、肢体扭曲、面部崩坏等缺陷，没有缺陷为 10 分\n")
+	if ctx.Style != "" {
+		sb.WriteString("4. style_match：画风是否符合项目风格\n")
+	}
+	sb.WriteString("\n生成提示词：")
+	sb.WriteString(ctx.Prompt)
+	if len(ctx.Characters) > 0 {
+		sb.WriteString("\n角色设定：")
+		sheetIndex := 1
+		for _, character := range ctx.Characters {
+			sb.WriteString("\n- ")
+			sb.WriteString(character.Name)
+			if character.SheetURL != "" {
+				sheetIndex++
+				fmt.Fprintf(&sb, "：设定图为第 %d 张图片", sheetIndex)
+			}
+			if character.Appearance != "" {
+				sb.WriteString("；设定描述：")
+				sb.WriteString(character.Appearance)
+			}
+		}
+	}
+	if ctx.Style != "" {
+		sb.WriteString("\n项目风格：")
+		sb.WriteString(ctx.Style)
+	}
+	sb.WriteString("\n\n以 JSON 输出各维度分数，未要求的维度不要输出，defects 列出发现的具体缺陷，comment 为简短评语")
+	return sb.String()
+}
+
+// normalize 去掉本次未要求评估的维度并计算综合分
+func (score *ImageQualityScore) normalize(ctx *scoringContext) {
+	if len(ctx.Characters) == 0 {
+		score.CharacterConsistency = nil
+	}
+	if ctx.Style == "" {
+		score.StyleMatch = nil
+	}
+	score.computeOverall()
+}
+
+// ScoreImage 使用视觉模型为已完成的图片评分并保存结果
+func (s *ImageGenerationService) ScoreImage(imageGenID uint) (*ImageQualityScore, error) {
+	var imageGen models.ImageGeneration
+	if err := s.db.First(&imageGen, imageGenID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("image not found")
+		}
+		return nil, err
+	}
+	if imageGen.Status != models.ImageStatusCompleted || imageGen.ImageURL == nil || *imageGen.ImageURL == "" {
+		return nil, &ValidationError{Message: "图片尚未生成完成"}
+	}
+	return s.scoreImage(&imageGen, s.buildScoringContext(&imageGen))
+}
+
+func (s *ImageGenerationService) scoreImage(imageGen *models.ImageGeneration, ctx *scoringContext) (*ImageQualityScore, error) {
+	images := append([]string{*imageGen.ImageURL}, ctx.sheetURLs()...)
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return s.aiService.DescribeImages(images, p, options...)
+	}
+	score := &ImageQualityScore{}
+	if _, err := s.aiService.generateStructured(generate, buildScoringPrompt(ctx), "score", score, WithUsageScope(ctx.Scope)); err != nil {
+		return nil, fmt.Errorf("failed to parse quality score: %w", err)
+	}
+	score.normalize(ctx)
+
+	detail, _ := json.Marshal(score)
+	if err := s.db.Model(&models.ImageGeneration{}).Where("id = ?", imageGen.ID).Updates(map[string]interface{}{
+		"quality_score":  score.Overall,
+		"quality_detail": detail,
+	}).Error; err != nil {
+		return nil, err
+	}
+	imageGen.QualityScore = &score.Overall
+	imageGen.QualityDetail = detail
+	s.log.Infow("Image scored", "id", imageGen.ID, "overall", score.Overall, "defects", len(score.Defects))
+	return score, nil
+}
+
+// onCandidateFinished 候选图完成或失败后，检查同组是否全部结束并进入自动选图或等待手动选择
+func (s *ImageGenerationService) onCandidateFinished(imageGen *models.ImageGeneration) {
+	if imageGen.CandidateGroup == nil {
+		return
+	}
+	groupID := *imageGen.CandidateGroup
+
+	var unfinished int64
+	s.db.Model(&models.ImageGeneration{}).
+		Where("candidate_group = ? AND discarded_at IS NULL AND status IN ?", groupID, []models.ImageGenerationStatus{models.ImageStatusPending, models.ImageStatusProcessing}).
+		Count(&unfinished)
+	if unfinished > 0 {
+		return
+	}
+
+	var group models.ImageCandidateGroup
+	if err := s.db.First(&group, "id = ?", groupID).Error; err != nil {
+		s.log.Warnw("Failed to load candidate group", "group", groupID, "error", err)
+		return
+	}
+	next := models.CandidateGroupReady
+	if group.AutoSelect {
+		next = models.CandidateGroupScoring
+	} else {
+		var completed int64
+		s.db.Model(&models.ImageGeneration{}).
+			Where("candidate_group = ? AND discarded_at IS NULL AND status = ?", groupID, models.ImageStatusCompleted).
+			Count(&completed)
+		if completed == 0 {
+			next = models.CandidateGroupFailed
+		}
+	}
+	// 条件更新保证同组多张候选同时结束时只触发一次
+	result := s.db.Model(&models.ImageCandidateGroup{}).
+		Where("id = ? AND status = ?", groupID, models.CandidateGroupGenerating).
+		Update("status", next)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	if next == models.CandidateGroupScoring {
+		go s.autoSelectCandidates(groupID)
+	}
+}
+
+// GetCandidateGroup 获取候选组及其全部候选图（包括回收区中的）
+func (s *ImageGenerationService) GetCandidateGroup(groupID string) (*models.ImageCandidateGroup, []models.ImageGeneration, error) {
+	var group models.ImageCandidateGroup
+	if err := s.db.First(&group, "id = ?", groupID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("candidate group not found")
+		}
+		return nil, nil, err
+	}
+	var images []models.ImageGeneration
+	if err := s.db.Where("candidate_group = ?", groupID).Order("id").Find(&images).Error; err != nil {
+		return nil, nil, err
+	}
+	return &group, images, nil
+}
+
+// AutoSelectCandidates 对已结束生成的候选组触发评分与自动选图，minScore 为空时沿用原设置
+func (s *ImageGenerationService) AutoSelectCandidates(groupID string, minScore *float64) (*models.ImageCandidateGroup, error) {
+	var group models.ImageCandidateGroup
+	if err := s.db.First(&group, "id = ?", groupID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("candidate group not found")
+		}
+		return nil, err
+	}
+	if group.Status == models.CandidateGroupGenerating || group.Status == models.CandidateGroupScoring {
+		return nil, &ValidationError{Message: "候选图仍在生成或评分中"}
+	}
+	if minScore != nil && (*minScore < 0 || *minScore > 10) {
+		return nil, &ValidationError{Message: "最低分数需在 0 到 10 之间"}
+	}
+
+	updates := map[string]interface{}{
+		"auto_select": true,
+		"status":      models.CandidateGroupScoring,
+		"error_msg":   nil,
+	}
+	if minScore != nil {
+		updates["min_score"] = *minScore
+	}
+	result := s.db.Model(&models.ImageCandidateGroup{}).Where("id = ? AND status = ?", groupID, group.Status).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, &ValidationError{Message: "候选组状态已变化，请刷新后重试"}
+	}
+
+	go s.autoSelectCandidates(groupID)
+
+	if err := s.db.First(&group, "id = ?", groupID).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// autoSelectCandidates 为组内已完成的候选图评分并选出最高分；最高分低于阈值且还有重新生成次数时生成新一轮候选，
+// 新旧候选一起参与下一次比较
+func (s *ImageGenerationService) autoSelectCandidates(groupID string) {
+	var group models.ImageCandidateGroup
+	if err := s.db.First(&group, "id = ?", groupID).Error; err != nil {
+		s.log.Errorw("Failed to load candidate group", "group", groupID, "error", err)
+		return
+	}
+
+	var candidates []models.ImageGeneration
+	if err := s.db.Where("candidate_group = ? AND discarded_at IS NULL AND status = ?", groupID, models.ImageStatusCompleted).
+		Order("id").Find(&candidates).Error; err != nil {
+		s.failCandidateGroup(groupID, err.Error())
+		return
+	}
+	if len(candidates) == 0 {
+		s.failCandidateGroup(groupID, "没有生成成功的候选图")
+		return
+	}
+
+	ctx := s.buildScoringContext(&candidates[0])
+	var best *models.ImageGeneration
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.QualityScore == nil {
+			if _, err := s.scoreImage(candidate, ctx); err != nil {
+				s.log.Warnw("Failed to score image candidate", "id", candidate.ID, "group", groupID, "error", err)
+				continue
+			}
+		}
+		if best == nil || *candidate.QualityScore > *best.QualityScore {
+			best = candidate
+		}
+	}
+	if best == nil {
+		s.failCandidateGroup(groupID, "所有候选图评分失败")
+		return
+	}
+
+	belowThreshold := group.MinScore > 0 && *best.QualityScore < group.MinScore
+	if belowThreshold && group.Regenerations < group.MaxRegenerations {
+		if err := s.regenerateCandidates(&group, &candidates[0]); err != nil {
+			s.failCandidateGroup(groupID, err.Error())
+		}
+		return
+	}
+
+	if _, err := s.SelectStoryboardImage(group.StoryboardID, best.ID); err != nil {
+		s.failCandidateGroup(groupID, err.Error())
+		return
+	}
+	if belowThreshold {
+		s.db.Model(&models.ImageCandidateGroup{}).Where("id = ?", groupID).Update("status", models.CandidateGroupNeedsReview)
+	}
+	s.log.Infow("Image candidate auto-selected", "group", groupID, "image_gen_id", best.ID, "score", *best.QualityScore, "below_threshold", belowThreshold)
+}
+
+// regenerateCandidates 以组内首张候选为模板生成新一轮候选，种子顺延避免与之前的轮次重复
+func (s *ImageGenerationService) regenerateCandidates(group *models.ImageCandidateGroup, source *models.ImageGeneration) error {
+	template := models.ImageGeneration{
+		StoryboardID:    source.StoryboardID,
+		DramaID:         source.DramaID,
+		ImageType:       source.ImageType,
+		FrameType:       source.FrameType,
+		Provider:        source.Provider,
+		Prompt:          source.Prompt,
+		NegPrompt:       source.NegPrompt,
+		Model:           source.Model,
+		Size:            source.Size,
+		Quality:         source.Quality,
+		Style:           source.Style,
+		Steps:           source.Steps,
+		CfgScale:        source.CfgScale,
+		Seed:            source.Seed,
+		ReferenceImages: source.ReferenceImages,
+		Status:          models.ImageStatusPending,
+	}
+	round := group.Regenerations + 1
+
+	var candidates []*models.ImageGeneration
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ImageCandidateGroup{}).Where("id = ?", group.ID).Updates(map[string]interface{}{
+			"regenerations": round,
+			"status":        models.CandidateGroupGenerating,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		candidates, err = createCandidateRecords(tx, &template, group.ID, group.Candidates, int64(round*group.Candidates))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create record: %w", err)
+	}
+
+	s.log.Infow("Image candidates below threshold, regenerating", "group", group.ID, "round", round, "min_score", group.MinScore)
+	for _, candidate := range candidates {
+		go s.ProcessImageGeneration(candidate.ID)
+	}
+	return nil
+}
+
+func (s *ImageGenerationService) failCandidateGroup(groupID string, errorMsg string) {
+	now := time.Now()
+	s.db.Model(&models.ImageCandidateGroup{}).Where("id = ?", groupID).Updates(map[string]interface{}{
+		"status":       models.CandidateGroupFailed,
+		"error_msg":    errorMsg,
+		"completed_at": now,
+	})
+	s.log.Errorw("Image candidate auto-selection failed", "group", groupID, "error", errorMsg)
+}
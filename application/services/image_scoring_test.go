@@ -0,0 +1,80 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+func TestImageQualityScoreNormalize(t *testing.T) {
+	ctx := &scoringContext{Prompt: "p", Characters: []scoringCharacter{{Name: "小明", SheetURL: "/static/characters/ming.png"}}, Style: "水墨"}
+	character, style := 12.0, 9.0
+	score := &ImageQualityScore{PromptAdherence: 8, CharacterConsistency: &character, Anatomy: 6, StyleMatch: &style, Defects: []string{"左手六根手指"}}
+	score.normalize(ctx)
+	if *score.CharacterConsistency != 10 {
+		t.Fatalf("expected character score to be clamped, got %v", *score.CharacterConsistency)
+	}
+	// 8*0.35 + 10*0.25 + 6*0.25 + 9*0.15 = 8.15
+	if score.Overall != 8.15 || len(score.Defects) != 1 {
+		t.Fatalf("unexpected score %+v", score)
+	}
+
+	// 没有角色与风格时这两个维度不参与加权
+	character, style = 12.0, 9.0
+	score = &ImageQualityScore{PromptAdherence: 8, CharacterConsistency: &character, Anatomy: 6, StyleMatch: &style}
+	score.normalize(&scoringContext{Prompt: "p"})
+	if score.CharacterConsistency != nil || score.StyleMatch != nil || score.Overall != 7.17 {
+		t.Fatalf("expected prompt and anatomy only, got %+v", score)
+	}
+}
+
+func TestScoringPromptNumbersCharacterSheets(t *testing.T) {
+	ctx := &scoringContext{Prompt: "雨夜街头", Characters: []scoringCharacter{
+		{Name: "小明", SheetURL: "/static/characters/ming.png", Appearance: "短发"},
+		{Name: "小红", Appearance: "长发"},
+		{Name: "老王", SheetURL: "/static/characters/wang.png"},
+	}}
+	if urls := ctx.sheetURLs(); len(urls) != 2 || urls[1] != "/static/characters/wang.png" {
+		t.Fatalf("unexpected sheet urls %v", urls)
+	}
+	prompt := buildScoringPrompt(ctx)
+	for _, want := range []string{"第 1 张图片是待评分的画面", "小明：设定图为第 2 张图片；设定描述：短发", "小红；设定描述：长发", "老王：设定图为第 3 张图片"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("expected prompt to contain %q, got:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestCandidateGroupBecomesReadyWhenAllFinished(t *testing.T) {
+	db := newTestDB(t, &models.ImageGeneration{}, &models.ImageCandidateGroup{})
+
+	service := NewImageGenerationService(db, &config.Config{}, nil, nil, logger.NewLogger(true))
+	for _, tc := range []struct {
+		group    string
+		statuses []models.ImageGenerationStatus
+		expected string
+	}{
+		{"ready", []models.ImageGenerationStatus{models.ImageStatusCompleted, models.ImageStatusFailed}, models.CandidateGroupReady},
+		{"all-failed", []models.ImageGenerationStatus{models.ImageStatusFailed, models.ImageStatusFailed}, models.CandidateGroupFailed},
+		{"running", []models.ImageGenerationStatus{models.ImageStatusCompleted, models.ImageStatusProcessing}, models.CandidateGroupGenerating},
+	} {
+		group := tc.group
+		db.Create(&models.ImageCandidateGroup{ID: group, StoryboardID: 1, DramaID: 1, Candidates: len(tc.statuses), Status: models.CandidateGroupGenerating})
+		var last models.ImageGeneration
+		for _, status := range tc.statuses {
+			last = models.ImageGeneration{DramaID: 1, Provider: "openai", Prompt: "p", CandidateGroup: &group, Status: status}
+			db.Create(&last)
+		}
+
+		service.onCandidateFinished(&last)
+
+		var saved models.ImageCandidateGroup
+		db.First(&saved, "id = ?", group)
+		if saved.Status != tc.expected {
+			t.Fatalf("group %s: expected status %s, got %s", group, tc.expected, saved.Status)
+		}
+	}
+}
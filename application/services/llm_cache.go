@@ -0,0 +1,273 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultLLMCacheTTL = 72 * time.Hour
+
+	llmCacheMetaBypass = "llm_cache_bypass"
+
+	llmCacheKindText             = "text"
+	llmCacheKindImageDescription = "image_description"
+)
+
+// LLMCacheService 文本模型响应缓存，相同提示词、模型与参数的调用直接返回上次结果
+type LLMCacheService struct {
+	db  *gorm.DB
+	log *logger.Logger
+	ttl time.Duration
+}
+
+func NewLLMCacheService(db *gorm.DB, log *logger.Logger, cfg *config.Config) *LLMCacheService {
+	ttl := defaultLLMCacheTTL
+	if cfg != nil && cfg.AI.LLMCacheTTLHours != 0 {
+		ttl = time.Duration(cfg.AI.LLMCacheTTLHours) * time.Hour
+	}
+	return &LLMCacheService{db: db, log: log, ttl: ttl}
+}
+
+// Enabled TTL 小于等于 0 时关闭缓存
+func (s *LLMCacheService) Enabled() bool {
+	return s != nil && s.ttl > 0
+}
+
+// Get 查找未过期的缓存，命中时累加命中次数
+func (s *LLMCacheService) Get(key string) (string, bool) {
+	var entry models.LLMCacheEntry
+	if err := s.db.Where("cache_key = ? AND expires_at > ?", key, time.Now()).First(&entry).Error; err != nil {
+		return "", false
+	}
+	s.db.Model(&entry).UpdateColumn("hit_count", gorm.Expr("hit_count + 1"))
+	return entry.Response, true
+}
+
+// Put 写入或刷新缓存，刷新时保留累计命中次数
+func (s *LLMCacheService) Put(key, kind, provider, model, response string) {
+	entry := &models.LLMCacheEntry{
+		CacheKey:  key,
+		Kind:      kind,
+		Provider:  provider,
+		Model:     model,
+		Response:  response,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cache_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"response", "expires_at", "updated_at"}),
+	}).Create(entry).Error
+	if err != nil {
+		s.log.Warnw("Failed to write LLM cache", "error", err, "kind", kind, "model", model)
+	}
+}
+
+// PurgeExpired 删除已过期的缓存
+func (s *LLMCacheService) PurgeExpired() (int64, error) {
+	result := s.db.Where("expires_at <= ?", time.Now()).Delete(&models.LLMCacheEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// Clear 清空全部缓存
+func (s *LLMCacheService) Clear() (int64, error) {
+	result := s.db.Where("1 = 1").Delete(&models.LLMCacheEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// WithLLMCacheBypass 本次调用跳过缓存读取，结果仍会写入缓存以刷新旧值
+func WithLLMCacheBypass() func(*ai.ChatCompletionRequest) {
+	return ai.WithMetadata(llmCacheMetaBypass, "1")
+}
+
+// LLMCacheStatus 统计一个任务内文本调用的缓存命中情况，用于写入任务结果
+type LLMCacheStatus struct {
+	bypass bool
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+func NewLLMCacheStatus(bypass bool) *LLMCacheStatus {
+	return &LLMCacheStatus{bypass: bypass}
+}
+
+// Option 附加到文本调用上：需要时跳过缓存，并记录命中情况
+func (s *LLMCacheStatus) Option() func(*ai.ChatCompletionRequest) {
+	return func(req *ai.ChatCompletionRequest) {
+		if s.bypass {
+			WithLLMCacheBypass()(req)
+		}
+		ai.WithCacheCallback(func(hit bool) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if hit {
+				s.hits++
+			} else {
+				s.misses++
+			}
+		})(req)
+	}
+}
+
+// Hit 任务内所有文本调用都命中缓存时返回 true
+func (s *LLMCacheStatus) Hit() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits > 0 && s.misses == 0
+}
+
+// llmCacheKey 按调用内容计算缓存键；只包含影响输出的参数，回调与元数据不参与
+func llmCacheKey(kind string, config *models.AIServiceConfig, model, systemPrompt, prompt string, req *ai.ChatCompletionRequest) string {
+	payload := struct {
+		Kind                string             `json:"kind"`
+		Provider            string             `json:"provider"`
+		BaseURL             string             `json:"base_url"`
+		Model               string             `json:"model"`
+		SystemPrompt        string             `json:"system_prompt"`
+		Prompt              string             `json:"prompt"`
+		Temperature         *float64           `json:"temperature,omitempty"`
+		MaxTokens           *int               `json:"max_tokens,omitempty"`
+		MaxCompletionTokens *int               `json:"max_completion_tokens,omitempty"`
+		TopP                float64            `json:"top_p,omitempty"`
+		ResponseFormat      *ai.ResponseFormat `json:"response_format,omitempty"`
+		Images              []string           `json:"images,omitempty"`
+	}{
+		Kind:         kind,
+		Model:        model,
+		SystemPrompt: systemPrompt,
+		Prompt:       prompt,
+	}
+	if config != nil {
+		payload.Provider = config.Provider
+		payload.BaseURL = config.BaseURL
+	}
+	if req != nil {
+		payload.Temperature = req.Temperature
+		payload.MaxTokens = req.MaxTokens
+		payload.MaxCompletionTokens = req.MaxCompletionTokens
+		payload.TopP = req.TopP
+		payload.ResponseFormat = req.ResponseFormat
+		payload.Images = req.Images
+	}
+
+	data, _ := json.Marshal(payload)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedTextClient 包装文本客户端，在限流、预算与用量记录之前查询缓存，命中时不产生费用
+type cachedTextClient struct {
+	inner  ai.AIClient
+	cache  *LLMCacheService
+	config *models.AIServiceConfig
+	model  string
+}
+
+// lookup 计算缓存键并查询缓存；调用方要求跳过缓存时只计算缓存键
+func (c *cachedTextClient) lookup(kind, systemPrompt, prompt string, options []func(*ai.ChatCompletionRequest)) (string, *ai.ChatCompletionRequest, string, bool) {
+	probe := &ai.ChatCompletionRequest{}
+	for _, option := range options {
+		option(probe)
+	}
+	key := llmCacheKey(kind, c.config, c.model, systemPrompt, prompt, probe)
+	if probe.Metadata[llmCacheMetaBypass] != "" {
+		return key, probe, "", false
+	}
+	text, ok := c.cache.Get(key)
+	return key, probe, text, ok
+}
+
+// remember 调用服务商并缓存结果。输出因 token 上限被截断时不缓存；
+// 调用方通过 ai.WithCacheCommit 要求确认时，把写入交给调用方在结果校验通过后执行
+func (c *cachedTextClient) remember(key, kind string, probe *ai.ChatCompletionRequest, options []func(*ai.ChatCompletionRequest), call func(options ...func(*ai.ChatCompletionRequest)) (string, error)) (string, error) {
+	truncated := false
+	options = append(options[:len(options):len(options)], ai.WithFinishCallback(func(reason string) {
+		truncated = ai.IsTruncated(reason)
+	}))
+
+	text, err := call(options...)
+	if err != nil || truncated || text == "" {
+		return text, err
+	}
+	commit := func() { c.store(key, kind, text) }
+	if probe.OnStore != nil {
+		probe.OnStore(commit)
+	} else {
+		commit()
+	}
+	return text, nil
+}
+
+func (c *cachedTextClient) store(key, kind, text string) {
+	provider := ""
+	if c.config != nil {
+		provider = c.config.Provider
+	}
+	c.cache.Put(key, kind, provider, c.model, text)
+}
+
+func (c *cachedTextClient) GenerateText(prompt string, systemPrompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	key, probe, cached, hit := c.lookup(llmCacheKindText, systemPrompt, prompt, options)
+	if probe.OnCache != nil {
+		probe.OnCache(hit)
+	}
+	if hit {
+		return cached, nil
+	}
+
+	return c.remember(key, llmCacheKindText, probe, options, func(options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return c.inner.GenerateText(prompt, systemPrompt, options...)
+	})
+}
+
+func (c *cachedTextClient) GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	key, probe, cached, hit := c.lookup(llmCacheKindText, systemPrompt, prompt, options)
+	if probe.OnCache != nil {
+		probe.OnCache(hit)
+	}
+	if hit {
+		// 命中缓存时一次性输出完整内容
+		if onChunk != nil {
+			onChunk(cached)
+		}
+		return cached, nil
+	}
+
+	return c.remember(key, llmCacheKindText, probe, options, func(options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return c.inner.GenerateTextStream(prompt, systemPrompt, onChunk, options...)
+	})
+}
+
+func (c *cachedTextClient) GenerateImage(prompt string, size string, n int, options ...func(*ai.ChatCompletionRequest)) ([]string, error) {
+	return c.inner.GenerateImage(prompt, size, n, options...)
+}
+
+// GenerateImageDescription 以图片地址代替系统提示词参与缓存键计算
+func (c *cachedTextClient) GenerateImageDescription(imageURL string, prompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	key, probe, cached, hit := c.lookup(llmCacheKindImageDescription, imageURL, prompt, options)
+	if probe.OnCache != nil {
+		probe.OnCache(hit)
+	}
+	if hit {
+		return cached, nil
+	}
+
+	return c.remember(key, llmCacheKindImageDescription, probe, options, func(options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return c.inner.GenerateImageDescription(imageURL, prompt, options...)
+	})
+}
+
+func (c *cachedTextClient) TestConnection() error {
+	return c.inner.TestConnection()
+}
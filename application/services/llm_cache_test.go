@@ -0,0 +1,138 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+// countingTextClient 记录实际调用次数的文本客户端，finishReason 非空时回调给调用方
+type countingTextClient struct {
+	ai.AIClient
+	calls        int
+	finishReason string
+}
+
+func (c *countingTextClient) GenerateText(prompt string, systemPrompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	c.calls++
+	c.finish(options)
+	return "answer: " + prompt, nil
+}
+
+func (c *countingTextClient) GenerateImageDescription(imageURL string, prompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	c.calls++
+	c.finish(options)
+	return "image: " + prompt, nil
+}
+
+func (c *countingTextClient) finish(options []func(*ai.ChatCompletionRequest)) {
+	req := &ai.ChatCompletionRequest{}
+	for _, option := range options {
+		option(req)
+	}
+	if req.OnFinish != nil && c.finishReason != "" {
+		req.OnFinish(c.finishReason)
+	}
+}
+
+func newCountingCachedClient(t *testing.T) (*cachedTextClient, *countingTextClient, *LLMCacheService) {
+	t.Helper()
+	db := newTestDB(t, &models.LLMCacheEntry{})
+	inner := &countingTextClient{}
+	cache := NewLLMCacheService(db, logger.NewLogger(true), &config.Config{})
+	return &cachedTextClient{
+		inner:  inner,
+		cache:  cache,
+		config: &models.AIServiceConfig{Provider: "openai"},
+		model:  "gpt-4o",
+	}, inner, cache
+}
+
+func TestCachedTextClientHitAndBypass(t *testing.T) {
+	client, inner, _ := newCountingCachedClient(t)
+
+	first := NewLLMCacheStatus(false)
+	if _, err := client.GenerateText("hello", "", ai.WithTemperature(0.7), first.Option()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Hit() || inner.calls != 1 {
+		t.Fatalf("first call should miss, hit=%v calls=%d", first.Hit(), inner.calls)
+	}
+
+	second := NewLLMCacheStatus(false)
+	text, _ := client.GenerateText("hello", "", ai.WithTemperature(0.7), second.Option())
+	if !second.Hit() || inner.calls != 1 || text != "answer: hello" {
+		t.Fatalf("second call should hit, hit=%v calls=%d text=%q", second.Hit(), inner.calls, text)
+	}
+
+	// 参数不同视为不同请求
+	client.GenerateText("hello", "", ai.WithTemperature(0.2))
+	if inner.calls != 2 {
+		t.Fatalf("different temperature should miss, calls=%d", inner.calls)
+	}
+
+	bypass := NewLLMCacheStatus(true)
+	client.GenerateText("hello", "", ai.WithTemperature(0.7), bypass.Option())
+	if bypass.Hit() || inner.calls != 3 {
+		t.Fatalf("bypass should call provider, hit=%v calls=%d", bypass.Hit(), inner.calls)
+	}
+}
+
+func TestCachedTextClientStoresOnlyAcceptedOutput(t *testing.T) {
+	client, inner, _ := newCountingCachedClient(t)
+
+	// 调用方未确认的结果不缓存
+	var commit func()
+	client.GenerateText("hello", "", ai.WithCacheCommit(func(fn func()) { commit = fn }))
+	if commit == nil {
+		t.Fatalf("expected commit callback on cache miss")
+	}
+	client.GenerateText("hello", "", ai.WithCacheCommit(func(fn func()) { commit = fn }))
+	if inner.calls != 2 {
+		t.Fatalf("unconfirmed output should not be cached, calls=%d", inner.calls)
+	}
+
+	commit()
+	client.GenerateText("hello", "")
+	if inner.calls != 2 {
+		t.Fatalf("confirmed output should be cached, calls=%d", inner.calls)
+	}
+
+	// 被截断的输出不缓存
+	inner.finishReason = "length"
+	client.GenerateText("long", "")
+	client.GenerateText("long", "")
+	if inner.calls != 4 {
+		t.Fatalf("truncated output should not be cached, calls=%d", inner.calls)
+	}
+}
+
+func TestCachedTextClientImageDescriptionHonorsBypass(t *testing.T) {
+	client, inner, cache := newCountingCachedClient(t)
+
+	first := NewLLMCacheStatus(false)
+	client.GenerateImageDescription("data:image/png;base64,cmVm", "describe", first.Option())
+	second := NewLLMCacheStatus(false)
+	client.GenerateImageDescription("data:image/png;base64,cmVm", "describe", second.Option())
+	if first.Hit() || !second.Hit() || inner.calls != 1 {
+		t.Fatalf("expected miss then hit, first=%v second=%v calls=%d", first.Hit(), second.Hit(), inner.calls)
+	}
+
+	bypass := NewLLMCacheStatus(true)
+	client.GenerateImageDescription("data:image/png;base64,cmVm", "describe", bypass.Option())
+	if bypass.Hit() || inner.calls != 2 {
+		t.Fatalf("bypass should call provider, hit=%v calls=%d", bypass.Hit(), inner.calls)
+	}
+
+	// 刷新缓存不重置命中次数
+	var entry models.LLMCacheEntry
+	if err := cache.db.First(&entry).Error; err != nil {
+		t.Fatalf("expected cache entry: %v", err)
+	}
+	if entry.HitCount != 1 {
+		t.Fatalf("expected hit count to survive refresh, got %d", entry.HitCount)
+	}
+}
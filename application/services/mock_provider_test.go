@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	stdimage "image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/image"
+)
+
+// TestMockTextClientSatisfiesSchemas 模拟文本客户端的输出应直接通过结构化输出校验，无需重试
+func TestMockTextClientSatisfiesSchemas(t *testing.T) {
+	s := newStructuredTestService(nil)
+	client := ai.NewMockClient("mock-text")
+
+	calls := 0
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		calls++
+		return client.GenerateText(p, "", options...)
+	}
+
+	var storyboards []Storyboard
+	first, err := s.generateStructured(generate, "拆解分镜", "storyboards", &storyboards)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 || len(storyboards) == 0 {
+		t.Fatalf("expected a valid first answer, calls=%d storyboards=%d", calls, len(storyboards))
+	}
+	if storyboards[0].Duration <= 0 {
+		t.Fatalf("expected positive duration, got %+v", storyboards[0])
+	}
+
+	var backgrounds []BackgroundInfo
+	if _, err := s.generateStructured(generate, "提取场景", "backgrounds", &backgrounds); err != nil || len(backgrounds) == 0 {
+		t.Fatalf("unexpected background result: %v, %+v", err, backgrounds)
+	}
+
+	// 相同输入得到相同输出
+	second, _ := s.generateStructured(generate, "拆解分镜", "storyboards", &storyboards)
+	if first != second {
+		t.Fatalf("mock output should be deterministic")
+	}
+}
+
+func TestMockImageClientRendersPNG(t *testing.T) {
+	result, err := image.NewMockImageClient("mock-image").GenerateImage("a quiet street at night 夜晚", image.WithSize("640x360"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Completed || !strings.HasPrefix(result.ImageURL, "data:image/png;base64,") {
+		t.Fatalf("unexpected result: completed=%v url prefix=%q", result.Completed, result.ImageURL[:30])
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(result.ImageURL, "data:image/png;base64,"))
+	if err != nil {
+		t.Fatalf("invalid base64: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid png: %v", err)
+	}
+	if img.Bounds() != stdimage.Rect(0, 0, 640, 360) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+}
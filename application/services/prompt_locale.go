@@ -0,0 +1,133 @@
+package services
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// basePromptLanguage 基础语言，所有回退链最终落到这里
+const basePromptLanguage = "zh"
+
+//go:embed locales/*.yaml
+var promptLocaleFS embed.FS
+
+// promptLocale 单个语言的提示词包，新增语言只需在 locales 目录下增加一个 YAML 文件
+type promptLocale struct {
+	Language  string            `yaml:"language"`
+	Name      string            `yaml:"name"`
+	Fallback  string            `yaml:"fallback"`  // 缺失条目时优先回退的语言，为空时直接回退到基础语言
+	Templates map[string]string `yaml:"templates"` // 系统提示词，text/template 语法
+	Messages  map[string]string `yaml:"messages"`  // 用户提示词片段，fmt 占位符
+}
+
+// PromptLanguage 可选的提示词语言
+type PromptLanguage struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+var promptLocales = mustLoadPromptLocales()
+
+func mustLoadPromptLocales() map[string]*promptLocale {
+	locales, err := loadPromptLocales()
+	if err != nil {
+		panic(err)
+	}
+	return locales
+}
+
+func loadPromptLocales() (map[string]*promptLocale, error) {
+	files, err := promptLocaleFS.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+
+	locales := make(map[string]*promptLocale, len(files))
+	for _, file := range files {
+		data, err := promptLocaleFS.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var locale promptLocale
+		if err := yaml.Unmarshal(data, &locale); err != nil {
+			return nil, fmt.Errorf("parse prompt locale %s: %w", file.Name(), err)
+		}
+		if locale.Language == "" {
+			return nil, fmt.Errorf("prompt locale %s: missing language", file.Name())
+		}
+		locales[locale.Language] = &locale
+	}
+
+	if _, ok := locales[basePromptLanguage]; !ok {
+		return nil, fmt.Errorf("prompt locale %s not found", basePromptLanguage)
+	}
+	return locales, nil
+}
+
+// SupportedPromptLanguages 返回所有可用的提示词语言
+func SupportedPromptLanguages() []PromptLanguage {
+	languages := make([]PromptLanguage, 0, len(promptLocales))
+	for code, locale := range promptLocales {
+		languages = append(languages, PromptLanguage{Code: code, Name: locale.Name})
+	}
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Code < languages[j].Code })
+	return languages
+}
+
+// IsSupportedPromptLanguage 判断是否存在该语言的提示词包
+func IsSupportedPromptLanguage(language string) bool {
+	_, ok := promptLocales[language]
+	return ok
+}
+
+func promptLanguageCodes() []string {
+	languages := SupportedPromptLanguages()
+	codes := make([]string, len(languages))
+	for i, lang := range languages {
+		codes[i] = lang.Code
+	}
+	return codes
+}
+
+// promptLanguageChain 回退链：请求的语言 → 各级 fallback → 基础语言
+func promptLanguageChain(language string) []string {
+	var chain []string
+	seen := map[string]bool{}
+	for lang := language; lang != "" && !seen[lang]; {
+		seen[lang] = true
+		locale, ok := promptLocales[lang]
+		if !ok {
+			break
+		}
+		chain = append(chain, lang)
+		lang = locale.Fallback
+	}
+	if !seen[basePromptLanguage] {
+		chain = append(chain, basePromptLanguage)
+	}
+	return chain
+}
+
+// localeTemplate 沿回退链查找内置系统提示词
+func localeTemplate(key, language string) (string, bool) {
+	for _, lang := range promptLanguageChain(language) {
+		if content, ok := promptLocales[lang].Templates[key]; ok {
+			return content, true
+		}
+	}
+	return "", false
+}
+
+// localeMessage 沿回退链查找用户提示词片段
+func localeMessage(key, language string) (string, bool) {
+	for _, lang := range promptLanguageChain(language) {
+		if message, ok := promptLocales[lang].Messages[key]; ok {
+			return message, true
+		}
+	}
+	return "", false
+}
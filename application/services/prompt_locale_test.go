@@ -0,0 +1,116 @@
+package services
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+var (
+	printfVerbPattern    = regexp.MustCompile(`%[-+# 0]*\d*(?:\.\d+)?[a-zA-Z%]`)
+	templateFieldPattern = regexp.MustCompile(`\{\{\s*\.(\w+)\s*\}\}`)
+)
+
+func printfVerbs(message string) []string {
+	var verbs []string
+	for _, verb := range printfVerbPattern.FindAllString(message, -1) {
+		if verb != "%%" {
+			verbs = append(verbs, verb[len(verb)-1:])
+		}
+	}
+	return verbs
+}
+
+// TestPromptLocalesComplete 每个语言包都必须包含基础语言的全部条目，且占位符一致
+func TestPromptLocalesComplete(t *testing.T) {
+	base := promptLocales[basePromptLanguage]
+
+	for key := range promptTemplateVariables {
+		if _, ok := base.Templates[key]; !ok {
+			t.Errorf("base locale %s is missing template %q", basePromptLanguage, key)
+		}
+	}
+
+	for lang, locale := range promptLocales {
+		if locale.Name == "" {
+			t.Errorf("locale %s: missing display name", lang)
+		}
+		if locale.Fallback != "" && !IsSupportedPromptLanguage(locale.Fallback) {
+			t.Errorf("locale %s: unknown fallback %q", lang, locale.Fallback)
+		}
+
+		for key := range base.Templates {
+			content, ok := locale.Templates[key]
+			if !ok {
+				t.Errorf("locale %s: missing template %q", lang, key)
+				continue
+			}
+			if err := validatePromptTemplate(key, content); err != nil {
+				t.Errorf("locale %s: template %q is invalid: %v", lang, key, err)
+			}
+			allowed := map[string]bool{}
+			for _, name := range promptTemplateVariables[key] {
+				allowed[name] = true
+			}
+			for _, match := range templateFieldPattern.FindAllStringSubmatch(content, -1) {
+				if !allowed[match[1]] {
+					t.Errorf("locale %s: template %q uses unknown variable %q", lang, key, match[1])
+				}
+			}
+		}
+		for key := range locale.Templates {
+			if _, ok := base.Templates[key]; !ok {
+				t.Errorf("locale %s: template %q does not exist in base locale", lang, key)
+			}
+		}
+
+		for key, baseMessage := range base.Messages {
+			message, ok := locale.Messages[key]
+			if !ok {
+				t.Errorf("locale %s: missing message %q", lang, key)
+				continue
+			}
+			if got, want := printfVerbs(message), printfVerbs(baseMessage); !reflect.DeepEqual(got, want) {
+				t.Errorf("locale %s: message %q placeholders %v, want %v", lang, key, got, want)
+			}
+		}
+		for key := range locale.Messages {
+			if _, ok := base.Messages[key]; !ok {
+				t.Errorf("locale %s: message %q does not exist in base locale", lang, key)
+			}
+		}
+	}
+}
+
+func TestPromptLanguageChain(t *testing.T) {
+	if got := promptLanguageChain("ja"); !reflect.DeepEqual(got, []string{"ja", "en", "zh"}) {
+		t.Fatalf("unexpected chain for ja: %v", got)
+	}
+	if got := promptLanguageChain("fr"); !reflect.DeepEqual(got, []string{"zh"}) {
+		t.Fatalf("unknown language should fall back to base, got %v", got)
+	}
+}
+
+func TestFormatUserPromptUsesLocale(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.App.Language = "ja"
+	cfg.Style.DefaultStyle = "100% anime"
+	cfg.Style.DefaultImageRatio = "16:9"
+	i18n := NewPromptI18n(cfg, nil, logger.NewLogger(true))
+
+	if got := i18n.FormatUserPrompt("characters_label", "A, B"); got != "キャラクター: A, B" {
+		t.Fatalf("unexpected message: %q", got)
+	}
+
+	info := i18n.FormatUserPrompt("drama_info_template", "タイトル", "あらすじ", "ジャンル")
+	if !strings.Contains(info, "スタイル: 100% anime") || !strings.Contains(info, "画像比率: 16:9") {
+		t.Fatalf("expected config values in drama info, got %q", info)
+	}
+	if strings.Contains(info, "%!") {
+		t.Fatalf("unexpected formatting error in %q", info)
+	}
+}
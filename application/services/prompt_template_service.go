@@ -0,0 +1,324 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// 提示词模板键
+const (
+	PromptKeyStoryboardSystem    = "storyboard_system"
+	PromptKeySceneExtraction     = "scene_extraction"
+	PromptKeyFirstFrame          = "first_frame"
+	PromptKeyKeyFrame            = "key_frame"
+	PromptKeyLastFrame           = "last_frame"
+	PromptKeyOutlineGeneration   = "outline_generation"
+	PromptKeyCharacterExtraction = "character_extraction"
+	PromptKeyPropExtraction      = "prop_extraction"
+	PromptKeyEpisodeScript       = "episode_script"
+)
+
+// promptTemplateVariables 各模板可使用的变量，用于接口展示
+var promptTemplateVariables = map[string][]string{
+	PromptKeyStoryboardSystem:    {},
+	PromptKeySceneExtraction:     {"Style", "ImageRatio"},
+	PromptKeyFirstFrame:          {"Style", "ImageRatio"},
+	PromptKeyKeyFrame:            {"Style", "ImageRatio"},
+	PromptKeyLastFrame:           {"Style", "ImageRatio"},
+	PromptKeyOutlineGeneration:   {},
+	PromptKeyCharacterExtraction: {"Style", "ImageRatio"},
+	PromptKeyPropExtraction:      {"Script", "Style", "ImageRatio"},
+	PromptKeyEpisodeScript:       {},
+}
+
+type PromptTemplateService struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewPromptTemplateService(db *gorm.DB, log *logger.Logger) *PromptTemplateService {
+	return &PromptTemplateService{db: db, log: log}
+}
+
+// PromptTemplateKey 模板键说明
+type PromptTemplateKey struct {
+	Key       string   `json:"key"`
+	Variables []string `json:"variables"`
+	Languages []string `json:"languages"`
+}
+
+type CreatePromptTemplateRequest struct {
+	Key         string `json:"key" binding:"required"`
+	Language    string `json:"language" binding:"required"`
+	DramaID     *uint  `json:"drama_id"`
+	Content     string `json:"content" binding:"required"`
+	Description string `json:"description"`
+	Activate    bool   `json:"activate"` // 创建后立即启用
+}
+
+type UpdatePromptTemplateRequest struct {
+	Content     *string `json:"content"`
+	Description *string `json:"description"`
+}
+
+type PromptTemplateFilter struct {
+	Key      string
+	Language string
+	DramaID  *uint
+}
+
+// EnsureDefaults 为尚无任何版本的模板键写入各语言包中的内置模板作为启用的版本 1
+func (s *PromptTemplateService) EnsureDefaults() error {
+	for lang, locale := range promptLocales {
+		for key, content := range locale.Templates {
+			var count int64
+			if err := s.db.Unscoped().Model(&models.PromptTemplate{}).
+				Where("template_key = ? AND language = ? AND drama_id IS NULL", key, lang).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			tpl := &models.PromptTemplate{
+				Key:         key,
+				Language:    lang,
+				Version:     1,
+				Content:     content,
+				Description: "内置模板",
+				IsActive:    true,
+			}
+			if err := s.db.Create(tpl).Error; err != nil {
+				return err
+			}
+			s.log.Infow("Seeded prompt template", "key", key, "language", lang)
+		}
+	}
+	return nil
+}
+
+func (s *PromptTemplateService) ListKeys() []PromptTemplateKey {
+	languages := promptLanguageCodes()
+	keys := make([]PromptTemplateKey, 0, len(promptTemplateVariables))
+	for key := range promptTemplateVariables {
+		keys = append(keys, PromptTemplateKey{
+			Key:       key,
+			Variables: promptTemplateVariables[key],
+			Languages: languages,
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys
+}
+
+func (s *PromptTemplateService) ListTemplates(filter PromptTemplateFilter) ([]models.PromptTemplate, error) {
+	query := s.db.Model(&models.PromptTemplate{})
+	if filter.Key != "" {
+		query = query.Where("template_key = ?", filter.Key)
+	}
+	if filter.Language != "" {
+		query = query.Where("language = ?", filter.Language)
+	}
+	if filter.DramaID != nil {
+		query = query.Where("drama_id = ?", *filter.DramaID)
+	}
+
+	var templates []models.PromptTemplate
+	if err := query.Order("template_key ASC, language ASC, drama_id ASC, version DESC").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (s *PromptTemplateService) GetTemplate(id uint) (*models.PromptTemplate, error) {
+	var tpl models.PromptTemplate
+	if err := s.db.First(&tpl, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("template not found")
+		}
+		return nil, err
+	}
+	return &tpl, nil
+}
+
+// CreateTemplate 新建一个版本，版本号在同一模板键、语言、剧本下递增；没有启用版本时自动启用
+func (s *PromptTemplateService) CreateTemplate(req *CreatePromptTemplateRequest) (*models.PromptTemplate, error) {
+	if _, ok := promptTemplateVariables[req.Key]; !ok {
+		return nil, &ValidationError{Message: fmt.Sprintf("未知的模板键: %s", req.Key)}
+	}
+	if !IsSupportedPromptLanguage(req.Language) {
+		return nil, &ValidationError{Message: fmt.Sprintf("不支持的语言: %s", req.Language)}
+	}
+	if err := validatePromptTemplate(req.Key, req.Content); err != nil {
+		return nil, err
+	}
+	if req.DramaID != nil {
+		var count int64
+		if err := s.db.Model(&models.Drama{}).Where("id = ?", *req.DramaID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, &ValidationError{Message: "剧本不存在"}
+		}
+	}
+
+	tpl := &models.PromptTemplate{
+		Key:         req.Key,
+		Language:    req.Language,
+		DramaID:     req.DramaID,
+		Content:     req.Content,
+		Description: req.Description,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var maxVersion int
+		if err := scopePromptTemplate(tx.Unscoped().Model(&models.PromptTemplate{}), req.Key, req.Language, req.DramaID).
+			Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+			return err
+		}
+		tpl.Version = maxVersion + 1
+
+		var activeCount int64
+		if err := scopePromptTemplate(tx.Model(&models.PromptTemplate{}), req.Key, req.Language, req.DramaID).
+			Where("is_active = ?", true).Count(&activeCount).Error; err != nil {
+			return err
+		}
+		if req.Activate && activeCount > 0 {
+			if err := scopePromptTemplate(tx.Model(&models.PromptTemplate{}), req.Key, req.Language, req.DramaID).
+				Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		tpl.IsActive = req.Activate || activeCount == 0
+		return tx.Create(tpl).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// UpdateTemplate 修改模板；启用中的版本内容不可修改，需创建新版本后启用，以便回滚
+func (s *PromptTemplateService) UpdateTemplate(id uint, req *UpdatePromptTemplateRequest) (*models.PromptTemplate, error) {
+	tpl, err := s.GetTemplate(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Content != nil && *req.Content != tpl.Content {
+		if tpl.IsActive {
+			return nil, &ValidationError{Message: "启用中的版本不可修改内容，请创建新版本后启用"}
+		}
+		if err := validatePromptTemplate(tpl.Key, *req.Content); err != nil {
+			return nil, err
+		}
+		tpl.Content = *req.Content
+	}
+	if req.Description != nil {
+		tpl.Description = *req.Description
+	}
+
+	if err := s.db.Save(tpl).Error; err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// DeleteTemplate 删除版本；删除启用中的版本后回退到上一级（全局模板或内置模板）
+func (s *PromptTemplateService) DeleteTemplate(id uint) error {
+	result := s.db.Delete(&models.PromptTemplate{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("template not found")
+	}
+	return nil
+}
+
+// ActivateTemplate 启用指定版本，同一模板键、语言、剧本下的其他版本自动停用
+func (s *PromptTemplateService) ActivateTemplate(id uint) (*models.PromptTemplate, error) {
+	tpl, err := s.GetTemplate(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := scopePromptTemplate(tx.Model(&models.PromptTemplate{}), tpl.Key, tpl.Language, tpl.DramaID).
+			Where("id <> ?", tpl.ID).
+			Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(tpl).Update("is_active", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infow("Prompt template activated", "id", tpl.ID, "key", tpl.Key, "language", tpl.Language, "version", tpl.Version)
+	return tpl, nil
+}
+
+func scopePromptTemplate(query *gorm.DB, key, language string, dramaID *uint) *gorm.DB {
+	query = query.Where("template_key = ? AND language = ?", key, language)
+	if dramaID != nil {
+		return query.Where("drama_id = ?", *dramaID)
+	}
+	return query.Where("drama_id IS NULL")
+}
+
+// validatePromptTemplate 校验模板语法，并用示例变量试渲染一次
+func validatePromptTemplate(key, content string) error {
+	if strings.TrimSpace(content) == "" {
+		return &ValidationError{Message: "模板内容不能为空"}
+	}
+	vars := map[string]string{}
+	for _, name := range promptTemplateVariables[key] {
+		vars[name] = name
+	}
+	if _, err := renderPromptTemplate(key, content, vars); err != nil {
+		return &ValidationError{Message: fmt.Sprintf("模板语法错误: %v", err)}
+	}
+	return nil
+}
+
+func renderPromptTemplate(name, content string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(content)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// resolvePromptTemplate 按 剧本覆盖 → 全局启用版本 → 语言包（含回退链）的顺序查找模板内容
+func resolvePromptTemplate(db *gorm.DB, key, language string, dramaID uint) string {
+	if db != nil {
+		var tpl models.PromptTemplate
+		if dramaID > 0 {
+			err := db.Where("template_key = ? AND language = ? AND drama_id = ? AND is_active = ?", key, language, dramaID, true).
+				Order("version DESC").First(&tpl).Error
+			if err == nil {
+				return tpl.Content
+			}
+		}
+		err := db.Where("template_key = ? AND language = ? AND drama_id IS NULL AND is_active = ?", key, language, true).
+			Order("version DESC").First(&tpl).Error
+		if err == nil {
+			return tpl.Content
+		}
+	}
+
+	content, _ := localeTemplate(key, language)
+	return content
+}
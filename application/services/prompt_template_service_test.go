@@ -0,0 +1,122 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+func setupPromptTemplateService(t *testing.T) (*PromptTemplateService, *gorm.DB) {
+	t.Helper()
+
+	db := newTestDB(t, &models.Drama{}, &models.PromptTemplate{})
+	return NewPromptTemplateService(db, logger.NewLogger(true)), db
+}
+
+func TestPromptTemplateResolveOrder(t *testing.T) {
+	svc, db := setupPromptTemplateService(t)
+	if err := svc.EnsureDefaults(); err != nil {
+		t.Fatalf("failed to seed defaults: %v", err)
+	}
+
+	drama := models.Drama{Title: "测试剧本"}
+	if err := db.Create(&drama).Error; err != nil {
+		t.Fatalf("failed to create drama: %v", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.App.Language = "zh"
+	i18n := NewPromptI18n(cfg, db, logger.NewLogger(true))
+
+	if got := i18n.GetStoryboardSystemPrompt(); got == "" {
+		t.Fatalf("expected seeded default prompt")
+	}
+
+	if _, err := svc.CreateTemplate(&CreatePromptTemplateRequest{
+		Key:      PromptKeyStoryboardSystem,
+		Language: "zh",
+		Content:  "全局版本",
+		Activate: true,
+	}); err != nil {
+		t.Fatalf("failed to create global template: %v", err)
+	}
+	override, err := svc.CreateTemplate(&CreatePromptTemplateRequest{
+		Key:      PromptKeyStoryboardSystem,
+		Language: "zh",
+		DramaID:  &drama.ID,
+		Content:  "剧本覆盖 {{.Style}}",
+	})
+	if err != nil {
+		t.Fatalf("failed to create drama override: %v", err)
+	}
+	if !override.IsActive || override.Version != 1 {
+		t.Fatalf("expected first override to be active v1, got active=%v version=%d", override.IsActive, override.Version)
+	}
+
+	if got := i18n.GetStoryboardSystemPrompt(); got != "全局版本" {
+		t.Fatalf("expected global template without drama scope, got %q", got)
+	}
+	if got := i18n.ForDrama(drama.ID).GetStoryboardSystemPrompt(); got != "剧本覆盖 " {
+		t.Fatalf("expected drama override, got %q", got)
+	}
+}
+
+func TestPromptTemplateActivateRollsBack(t *testing.T) {
+	svc, db := setupPromptTemplateService(t)
+
+	v1, err := svc.CreateTemplate(&CreatePromptTemplateRequest{Key: PromptKeyPropExtraction, Language: "en", Content: "v1 {{.Script}}"})
+	if err != nil {
+		t.Fatalf("failed to create v1: %v", err)
+	}
+	v2, err := svc.CreateTemplate(&CreatePromptTemplateRequest{Key: PromptKeyPropExtraction, Language: "en", Content: "v2 {{.Script}}", Activate: true})
+	if err != nil {
+		t.Fatalf("failed to create v2: %v", err)
+	}
+	if v2.Version != 2 {
+		t.Fatalf("expected version 2, got %d", v2.Version)
+	}
+
+	content := "changed"
+	if _, err := svc.UpdateTemplate(v2.ID, &UpdatePromptTemplateRequest{Content: &content}); err == nil {
+		t.Fatalf("expected active version content to be immutable")
+	}
+
+	if _, err := svc.ActivateTemplate(v1.ID); err != nil {
+		t.Fatalf("failed to activate v1: %v", err)
+	}
+	if got := resolvePromptTemplate(db, PromptKeyPropExtraction, "en", 0); got != "v1 {{.Script}}" {
+		t.Fatalf("expected rollback to v1, got %q", got)
+	}
+
+	if _, err := svc.CreateTemplate(&CreatePromptTemplateRequest{Key: PromptKeyPropExtraction, Language: "en", Content: "{{.Script"}); err == nil {
+		t.Fatalf("expected syntax error to be rejected")
+	}
+}
+
+func TestPromptI18nUsesDramaLanguage(t *testing.T) {
+	_, db := setupPromptTemplateService(t)
+
+	english := models.Drama{Title: "English drama", Language: "en"}
+	chinese := models.Drama{Title: "中文剧本"}
+	db.Create(&english)
+	db.Create(&chinese)
+
+	cfg := &config.Config{}
+	cfg.App.Language = "zh"
+	i18n := NewPromptI18n(cfg, db, logger.NewLogger(true))
+
+	if !i18n.ForDrama(english.ID).IsEnglish() {
+		t.Fatalf("expected drama language to override system default")
+	}
+	if i18n.ForDrama(chinese.ID).IsEnglish() {
+		t.Fatalf("expected drama without language to follow system default")
+	}
+
+	cfg.App.Language = "en"
+	if !i18n.ForDrama(chinese.ID).IsEnglish() {
+		t.Fatalf("expected drama without language to follow updated system default")
+	}
+}
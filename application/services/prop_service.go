@@ -11,7 +11,6 @@ import (
 	"github.com/drama-generator/backend/pkg/ai"
 	"github.com/drama-generator/backend/pkg/config"
 	"github.com/drama-generator/backend/pkg/logger"
-	"github.com/drama-generator/backend/pkg/utils"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
@@ -48,7 +47,7 @@ func NewPropService(db *gorm.DB, aiService *AIService, taskService *TaskService,
 		imageGenerationService: imageGenerationService,
 		log:                    log,
 		config:                 cfg,
-		promptI18n:             NewPromptI18n(cfg),
+		promptI18n:             NewPromptI18n(cfg, db, log),
 		styleConsistencyService: NewStyleConsistencyService(cfg, log),
 	}
 }
@@ -245,8 +244,8 @@ func (s *PropService) RemovePropFromLibrary(id uint) error {
 	return s.db.Delete(&models.PropLibrary{}, id).Error
 }
 
-// ExtractPropsFromScript 从剧本提取道具（异步）
-func (s *PropService) ExtractPropsFromScript(episodeID uint) (string, error) {
+// ExtractPropsFromScript 从剧本提取道具（异步），bypassCache 为 true 时不使用缓存的模型响应
+func (s *PropService) ExtractPropsFromScript(episodeID uint, bypassCache bool) (string, error) {
 	var episode models.Episode
 	if err := s.db.First(&episode, episodeID).Error; err != nil {
 		return "", fmt.Errorf("episode not found: %w", err)
@@ -256,17 +255,17 @@ func (s *PropService) ExtractPropsFromScript(episodeID uint) (string, error) {
 		return "", fmt.Errorf("剧本内容为空")
 	}
 
-	task, err := s.taskService.CreateTask("prop_extraction", fmt.Sprintf("%d", episodeID))
+	task, err := s.taskService.CreateTaskWithParams("prop_extraction", fmt.Sprintf("%d", episodeID), episodeTaskParams{EpisodeID: episodeID})
 	if err != nil {
 		return "", err
 	}
 
-	go s.processPropExtraction(task.ID, episode)
+	go s.processPropExtraction(task.ID, episode, NewLLMCacheStatus(bypassCache))
 
 	return task.ID, nil
 }
 
-func (s *PropService) processPropExtraction(taskID string, episode models.Episode) {
+func (s *PropService) processPropExtraction(taskID string, episode models.Episode, cacheStatus *LLMCacheStatus) {
 	s.taskService.UpdateTaskStatus(taskID, "processing", 0, "正在分析剧本...")
 
 	script := ""
@@ -274,14 +273,7 @@ func (s *PropService) processPropExtraction(taskID string, episode models.Episod
 		script = *episode.ScriptContent
 	}
 
-	promptTemplate := s.promptI18n.GetPropExtractionPrompt()
-	prompt := fmt.Sprintf(promptTemplate, script)
-
-	response, err := s.aiService.GenerateText(prompt, "", ai.WithMaxTokens(2000))
-	if err != nil {
-		s.taskService.UpdateTaskError(taskID, err)
-		return
-	}
+	prompt := s.promptI18n.ForDrama(episode.DramaID).GetPropExtractionPrompt(script)
 
 	var extractedProps []struct {
 		Name        string `json:"name"`
@@ -290,7 +282,11 @@ func (s *PropService) processPropExtraction(taskID string, episode models.Episod
 		ImagePrompt string `json:"image_prompt"`
 	}
 
-	if err := utils.SafeParseAIJSON(response, &extractedProps); err != nil {
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return s.aiService.GenerateText(p, "", options...)
+	}
+	if _, err := s.aiService.generateStructured(generate, prompt, "props", &extractedProps, ai.WithMaxTokens(2000),
+		WithUsageScope(UsageScope{DramaID: episode.DramaID, EpisodeID: episode.ID, Operation: "prop_extraction"}), cacheStatus.Option()); err != nil {
 		s.taskService.UpdateTaskError(taskID, fmt.Errorf("解析AI结果失败: %w", err))
 		return
 	}
@@ -299,13 +295,10 @@ func (s *PropService) processPropExtraction(taskID string, episode models.Episod
 
 	var createdProps []models.Prop
 	for _, p := range extractedProps {
-		var count int64
-		s.db.Model(&models.Prop{}).Where("drama_id = ? AND name = ?", episode.DramaID, p.Name).Count(&count)
-		if count > 0 {
-			var existingProp models.Prop
-			if err := s.db.Where("drama_id = ? AND name = ?", episode.DramaID, p.Name).First(&existingProp).Error; err == nil {
-				_ = s.db.Model(&episode).Association("Props").Append(&existingProp)
-			}
+		// 同名或别名相同的道具只关联到本集
+		var existingProp models.Prop
+		if err := findEntityByName(s.db, episode.DramaID, models.EntityTypeProp, p.Name, &existingProp); err == nil {
+			_ = s.db.Model(&episode).Association("Props").Append(&existingProp)
 			continue
 		}
 
@@ -322,7 +315,11 @@ func (s *PropService) processPropExtraction(taskID string, episode models.Episod
 		}
 	}
 
-	s.taskService.UpdateTaskResult(taskID, createdProps)
+	s.taskService.UpdateTaskResult(taskID, map[string]interface{}{
+		"props":     createdProps,
+		"count":     len(createdProps),
+		"cache_hit": cacheStatus.Hit(),
+	})
 }
 
 func (s *PropService) GeneratePropImage(propID uint) (string, error) {
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	models "github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/httpadapter"
+	"github.com/drama-generator/backend/pkg/image"
+	"github.com/drama-generator/backend/pkg/video"
+	"gorm.io/gorm"
+)
+
+// callbackProviders 支持任务完成回调（callback_url）的视频服务商
+var callbackProviders = map[string]bool{
+	"doubao":     true,
+	"volcengine": true,
+	"volces":     true,
+	"minimax":    true,
+}
+
+// imageCallbackProviders 图片服务商中只有声明式适配器能在请求里携带回调地址，
+// 且仅当其提交模板引用了 {{callback_url}} 时才视为已注册回调
+var imageCallbackProviders = map[string]bool{
+	"http_adapter": true,
+	"custom":       true,
+}
+
+// 注册回调后轮询只作兜底：间隔拉长，总等待时长与普通轮询相同
+const (
+	callbackPollInterval    = 60 * time.Second
+	callbackPollMaxAttempts = 50
+
+	imageCallbackPollInterval    = 30 * time.Second
+	imageCallbackPollMaxAttempts = 10
+)
+
+// taskLocks 按生成记录串行化回调与轮询，避免同一任务被重复完成。
+// 记录持有者数量，最后一个持有者释放时删除条目，表不会随任务数增长
+type taskLocks struct {
+	mu      sync.Mutex
+	entries map[uint]*taskLock
+}
+
+type taskLock struct {
+	sync.Mutex
+	holders int
+}
+
+func (l *taskLocks) lock(id uint) func() {
+	l.mu.Lock()
+	if l.entries == nil {
+		l.entries = map[uint]*taskLock{}
+	}
+	entry, ok := l.entries[id]
+	if !ok {
+		entry = &taskLock{}
+		l.entries[id] = entry
+	}
+	entry.holders++
+	l.mu.Unlock()
+
+	entry.Lock()
+	return func() {
+		entry.Unlock()
+		l.mu.Lock()
+		entry.holders--
+		if entry.holders == 0 {
+			delete(l.entries, id)
+		}
+		l.mu.Unlock()
+	}
+}
+
+func (l *taskLocks) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+var (
+	videoTaskLocks taskLocks
+	imageTaskLocks taskLocks
+	mergeTaskLocks taskLocks
+)
+
+func newCallbackToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// callbackOption 服务商支持回调且配置了 server.public_url 时返回回调参数。
+// 令牌在首次需要时生成并立即落库，保证回调早于任务ID写入时也能找到记录
+func (s *VideoGenerationService) callbackOption(videoGenID uint, provider string, token *string) (video.VideoOption, bool) {
+	if !callbackProviders[provider] || !s.aiService.CallbacksEnabled() {
+		return nil, false
+	}
+	if *token == "" {
+		generated, err := newCallbackToken()
+		if err != nil {
+			s.log.Warnw("Failed to generate callback token, falling back to polling", "id", videoGenID, "error", err)
+			return nil, false
+		}
+		if err := s.db.Model(&models.VideoGeneration{}).Where("id = ?", videoGenID).Update("callback_token", generated).Error; err != nil {
+			s.log.Warnw("Failed to save callback token, falling back to polling", "id", videoGenID, "error", err)
+			return nil, false
+		}
+		*token = generated
+	}
+	return video.WithCallbackURL(s.aiService.CallbackURL(provider, *token)), true
+}
+
+// HandleProviderCallback 处理服务商回调：按令牌找到生成记录并核对服务商与任务ID，
+// 回调内容本身不作为结果依据，而是立即向服务商查询一次任务状态
+func (s *VideoGenerationService) HandleProviderCallback(provider, token string, body []byte) (map[string]interface{}, error) {
+	payload := map[string]interface{}{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, &ValidationError{Message: "回调内容不是有效的JSON"}
+		}
+	}
+
+	if !callbackProviders[provider] || token == "" {
+		return nil, errors.New("callback not found")
+	}
+
+	var videoGen models.VideoGeneration
+	if err := s.db.Where("callback_token = ?", token).First(&videoGen).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("callback not found")
+		}
+		return nil, err
+	}
+
+	if videoGen.Provider != provider {
+		s.log.Warnw("Provider callback rejected: provider mismatch", "id", videoGen.ID, "provider", provider, "expected", videoGen.Provider)
+		return nil, &ValidationError{Message: "服务商不匹配"}
+	}
+
+	// Minimax 在创建任务时先发送 challenge 校验回调地址，令牌核对通过后原样返回
+	if challenge, ok := payload["challenge"]; ok {
+		return map[string]interface{}{"challenge": challenge}, nil
+	}
+
+	taskID := callbackTaskID(payload)
+	if videoGen.TaskID == nil || *videoGen.TaskID == "" {
+		// 任务ID尚未写入，交给轮询处理
+		s.log.Infow("Provider callback arrived before task id was saved", "id", videoGen.ID, "task_id", taskID)
+		return map[string]interface{}{"status": "pending"}, nil
+	}
+	if taskID != "" && taskID != *videoGen.TaskID {
+		s.log.Warnw("Provider callback rejected: task id mismatch", "id", videoGen.ID, "task_id", taskID, "expected", *videoGen.TaskID)
+		return nil, &ValidationError{Message: "任务ID不匹配"}
+	}
+
+	if videoGen.Status != models.VideoStatusProcessing {
+		return map[string]interface{}{"status": string(videoGen.Status)}, nil
+	}
+
+	s.log.Infow("Provider callback received", "id", videoGen.ID, "provider", provider, "task_id", *videoGen.TaskID)
+	go s.refreshVideoTaskStatus(videoGen.ID)
+	return map[string]interface{}{"status": "accepted"}, nil
+}
+
+// callbackTaskID 兼容火山方舟（id）与 Minimax（task_id）的回调格式
+func callbackTaskID(payload map[string]interface{}) string {
+	for _, key := range []string{"task_id", "id"} {
+		switch v := payload[key].(type) {
+		case string:
+			return v
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+	}
+	return ""
+}
+
+// refreshVideoTaskStatus 回调触发的一次状态查询，失败时由兜底轮询继续处理
+func (s *VideoGenerationService) refreshVideoTaskStatus(videoGenID uint) {
+	var videoGen models.VideoGeneration
+	if err := s.db.First(&videoGen, videoGenID).Error; err != nil {
+		s.log.Errorw("Failed to load video generation", "error", err, "id", videoGenID)
+		return
+	}
+	if videoGen.TaskID == nil {
+		return
+	}
+
+	client, config, err := s.getVideoClientForGeneration(&videoGen)
+	if err != nil {
+		s.log.Warnw("Failed to get video client for callback", "error", err, "id", videoGenID)
+		return
+	}
+	result, err := client.GetTaskStatus(*videoGen.TaskID)
+	if err != nil {
+		s.log.Warnw("Failed to query task status after callback", "error", err, "id", videoGenID, "task_id", *videoGen.TaskID)
+		return
+	}
+	s.applyVideoTaskResult(videoGenID, result, config)
+}
+
+// applyVideoTaskResult 根据任务状态完成或标记失败，返回任务是否已结束
+func (s *VideoGenerationService) applyVideoTaskResult(videoGenID uint, result *video.VideoResult, config *models.AIServiceConfig) bool {
+	unlock := videoTaskLocks.lock(videoGenID)
+	defer unlock()
+
+	var videoGen models.VideoGeneration
+	if err := s.db.Select("id", "status").First(&videoGen, videoGenID).Error; err != nil {
+		s.log.Errorw("Failed to load video generation", "error", err, "id", videoGenID)
+		return true
+	}
+	if videoGen.Status != models.VideoStatusProcessing {
+		return true
+	}
+
+	if result.Completed {
+		if result.VideoURL != "" {
+			s.completeVideoGeneration(videoGenID, result.VideoURL, &result.Duration, &result.Width, &result.Height, nil)
+			s.recordVideoUsage(videoGenID, config)
+			return true
+		}
+		if result.Error != "" {
+			s.updateVideoGenError(videoGenID, result.Error)
+			return true
+		}
+		s.updateVideoGenError(videoGenID, "task completed but no video URL")
+		return true
+	}
+
+	if result.Error != "" {
+		s.updateVideoGenError(videoGenID, result.Error)
+		return true
+	}
+	return false
+}
+
+// callbackOption 图片配置的适配器模板使用回调且配置了 server.public_url 时返回回调参数，令牌处理与视频相同
+func (s *ImageGenerationService) callbackOption(imageGenID uint, config *models.AIServiceConfig, provider string, token *string) (image.ImageOption, bool) {
+	if !imageCallbackProviders[provider] || !s.aiService.CallbacksEnabled() {
+		return nil, false
+	}
+	spec, err := httpadapter.ParseSpec(config.Settings)
+	if err != nil || !spec.UsesCallback() {
+		return nil, false
+	}
+	if *token == "" {
+		generated, err := newCallbackToken()
+		if err != nil {
+			s.log.Warnw("Failed to generate callback token, falling back to polling", "id", imageGenID, "error", err)
+			return nil, false
+		}
+		if err := s.db.Model(&models.ImageGeneration{}).Where("id = ?", imageGenID).Update("callback_token", generated).Error; err != nil {
+			s.log.Warnw("Failed to save callback token, falling back to polling", "id", imageGenID, "error", err)
+			return nil, false
+		}
+		*token = generated
+	}
+	return image.WithCallbackURL(s.aiService.ImageCallbackURL(provider, *token)), true
+}
+
+// HandleProviderCallback 处理图片服务商回调，校验方式与视频回调相同，结果以立即查询的任务状态为准
+func (s *ImageGenerationService) HandleProviderCallback(provider, token string, body []byte) (map[string]interface{}, error) {
+	payload := map[string]interface{}{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, &ValidationError{Message: "回调内容不是有效的JSON"}
+		}
+	}
+
+	if !imageCallbackProviders[provider] || token == "" {
+		return nil, errors.New("callback not found")
+	}
+
+	var imageGen models.ImageGeneration
+	if err := s.db.Where("callback_token = ?", token).First(&imageGen).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("callback not found")
+		}
+		return nil, err
+	}
+
+	if imageGen.Provider != provider {
+		s.log.Warnw("Provider callback rejected: provider mismatch", "image_id", imageGen.ID, "provider", provider, "expected", imageGen.Provider)
+		return nil, &ValidationError{Message: "服务商不匹配"}
+	}
+
+	taskID := callbackTaskID(payload)
+	if imageGen.TaskID == nil || *imageGen.TaskID == "" {
+		s.log.Infow("Provider callback arrived before task id was saved", "image_id", imageGen.ID, "task_id", taskID)
+		return map[string]interface{}{"status": "pending"}, nil
+	}
+	if taskID != "" && taskID != *imageGen.TaskID {
+		s.log.Warnw("Provider callback rejected: task id mismatch", "image_id", imageGen.ID, "task_id", taskID, "expected", *imageGen.TaskID)
+		return nil, &ValidationError{Message: "任务ID不匹配"}
+	}
+
+	if imageGen.Status != models.ImageStatusProcessing {
+		return map[string]interface{}{"status": string(imageGen.Status)}, nil
+	}
+
+	s.log.Infow("Provider callback received", "image_id", imageGen.ID, "provider", provider, "task_id", *imageGen.TaskID)
+	go s.refreshImageTaskStatus(imageGen.ID)
+	return map[string]interface{}{"status": "accepted"}, nil
+}
+
+// refreshImageTaskStatus 回调触发的一次状态查询，失败时由兜底轮询继续处理
+func (s *ImageGenerationService) refreshImageTaskStatus(imageGenID uint) {
+	var imageGen models.ImageGeneration
+	if err := s.db.First(&imageGen, imageGenID).Error; err != nil {
+		s.log.Errorw("Failed to load image generation", "error", err, "id", imageGenID)
+		return
+	}
+	if imageGen.TaskID == nil {
+		return
+	}
+
+	client, config, model, err := s.getImageClientForGeneration(&imageGen)
+	if err != nil {
+		s.log.Warnw("Failed to get image client for callback", "error", err, "id", imageGenID)
+		return
+	}
+	result, err := client.GetTaskStatus(*imageGen.TaskID)
+	if err != nil {
+		s.log.Warnw("Failed to query task status after callback", "error", err, "id", imageGenID, "task_id", *imageGen.TaskID)
+		return
+	}
+	s.applyImageTaskResult(imageGenID, result, config, model)
+}
+
+// applyImageTaskResult 根据任务状态完成或标记失败，返回任务是否已结束
+func (s *ImageGenerationService) applyImageTaskResult(imageGenID uint, result *image.ImageResult, config *models.AIServiceConfig, model string) bool {
+	unlock := imageTaskLocks.lock(imageGenID)
+	defer unlock()
+
+	var imageGen models.ImageGeneration
+	if err := s.db.Select("id", "status").First(&imageGen, imageGenID).Error; err != nil {
+		s.log.Errorw("Failed to load image generation", "error", err, "id", imageGenID)
+		return true
+	}
+	if imageGen.Status != models.ImageStatusProcessing {
+		return true
+	}
+
+	if result.Completed {
+		s.completeImageGeneration(imageGenID, result)
+		s.recordImageUsage(imageGenID, config, model)
+		return true
+	}
+	if result.Error != "" {
+		s.updateImageGenError(imageGenID, result.Error)
+		return true
+	}
+	return false
+}
+
+// applyMergeTaskResult 合成任务与生成任务使用同样的串行化完成逻辑，返回任务是否已结束。
+// 合成在本地由 FFmpeg 完成，不向服务商提交任务，因此没有可注册的回调地址，
+// 这里只保证轮询与其他完成路径不会重复写入结果
+func (s *VideoMergeService) applyMergeTaskResult(mergeID uint, result *video.VideoResult) bool {
+	unlock := mergeTaskLocks.lock(mergeID)
+	defer unlock()
+
+	var videoMerge models.VideoMerge
+	if err := s.db.Select("id", "status").First(&videoMerge, mergeID).Error; err != nil {
+		s.log.Errorw("Failed to load video merge", "error", err, "id", mergeID)
+		return true
+	}
+	if videoMerge.Status != models.VideoMergeStatusProcessing {
+		return true
+	}
+
+	if result.Completed {
+		s.completeMerge(mergeID, result)
+		return true
+	}
+	if result.Error != "" {
+		s.updateMergeError(mergeID, result.Error)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/image"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/video"
+)
+
+func TestProviderCallbackVerifiesTokenAndTask(t *testing.T) {
+	db := newTestDB(t, &models.VideoGeneration{})
+
+	s := &VideoGenerationService{
+		db:        db,
+		log:       logger.NewLogger(true),
+		aiService: &AIService{publicURL: "https://drama.example.com"},
+	}
+
+	videoGen := models.VideoGeneration{DramaID: 1, Provider: "minimax", Prompt: "雨夜", Status: models.VideoStatusProcessing}
+	if err := db.Create(&videoGen).Error; err != nil {
+		t.Fatalf("failed to create video generation: %v", err)
+	}
+
+	var token string
+	opt, ok := s.callbackOption(videoGen.ID, "minimax", &token)
+	if !ok || token == "" {
+		t.Fatalf("expected callback option for minimax")
+	}
+	options := &video.VideoOptions{}
+	opt(options)
+	if !strings.HasPrefix(options.CallbackURL, "https://drama.example.com/api/v1/callbacks/minimax/") {
+		t.Fatalf("unexpected callback url %q", options.CallbackURL)
+	}
+	if _, ok := s.callbackOption(videoGen.ID, "runway", &token); ok {
+		t.Fatalf("runway does not support callbacks")
+	}
+
+	// 回调地址校验：令牌无效时不回显 challenge
+	if _, err := s.HandleProviderCallback("minimax", "anything", []byte(`{"challenge": "abc"}`)); err == nil || err.Error() != "callback not found" {
+		t.Fatalf("expected challenge with unknown token to be rejected, got %v", err)
+	}
+	result, err := s.HandleProviderCallback("minimax", token, []byte(`{"challenge": "abc"}`))
+	if err != nil || result["challenge"] != "abc" {
+		t.Fatalf("expected challenge echo, got %v, %v", result, err)
+	}
+
+	if _, err := s.HandleProviderCallback("minimax", "wrong-token", []byte(`{"task_id": "t-1"}`)); err == nil || err.Error() != "callback not found" {
+		t.Fatalf("expected unknown token to be rejected, got %v", err)
+	}
+
+	// 任务ID尚未写入时交给轮询
+	result, err = s.HandleProviderCallback("minimax", token, []byte(`{"task_id": "t-1", "status": "success"}`))
+	if err != nil || result["status"] != "pending" {
+		t.Fatalf("expected pending before task id is saved, got %v, %v", result, err)
+	}
+
+	db.Model(&videoGen).Update("task_id", "t-1")
+	if _, err := s.HandleProviderCallback("minimax", token, []byte(`{"task_id": "t-2"}`)); err == nil {
+		t.Fatalf("expected task id mismatch to be rejected")
+	} else if _, ok := IsValidationError(err); !ok {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if _, err := s.HandleProviderCallback("doubao", token, []byte(`{"id": "t-1"}`)); err == nil {
+		t.Fatalf("expected provider mismatch to be rejected")
+	}
+
+	// 失败结果只生效一次，之后的回调按已结束处理
+	if done := s.applyVideoTaskResult(videoGen.ID, &video.VideoResult{Status: "Failed", Error: "content blocked"}, nil); !done {
+		t.Fatalf("failed result should finish the task")
+	}
+	if size := videoTaskLocks.size(); size != 0 {
+		t.Fatalf("expected task lock to be released, %d entries left", size)
+	}
+	var saved models.VideoGeneration
+	db.First(&saved, videoGen.ID)
+	if saved.Status != models.VideoStatusFailed || saved.ErrorMsg == nil || *saved.ErrorMsg != "content blocked" {
+		t.Fatalf("unexpected saved generation %+v", saved)
+	}
+	result, err = s.HandleProviderCallback("minimax", token, []byte(`{"task_id": "t-1"}`))
+	if err != nil || result["status"] != string(models.VideoStatusFailed) {
+		t.Fatalf("expected finished task to be acknowledged, got %v, %v", result, err)
+	}
+}
+
+func TestImageProviderCallbackUsesAdapterTemplate(t *testing.T) {
+	db := newTestDB(t, &models.ImageGeneration{})
+
+	s := &ImageGenerationService{
+		db:        db,
+		log:       logger.NewLogger(true),
+		aiService: &AIService{publicURL: "https://drama.example.com"},
+	}
+
+	imageGen := models.ImageGeneration{DramaID: 1, Provider: "http_adapter", Prompt: "雨夜", Status: models.ImageStatusProcessing}
+	if err := db.Create(&imageGen).Error; err != nil {
+		t.Fatalf("failed to create image generation: %v", err)
+	}
+
+	const settings = `{"http_adapter": {"submit": {"method": "POST", "path": "/v1/images", "body": {"prompt": "{{prompt}}"%s}}, "query": {"path": "/v1/images/{{task_id}}"}, "task_id_path": "id", "status_path": "status", "result_url_path": "url"}}`
+	var token string
+	withoutCallback := &models.AIServiceConfig{Provider: "http_adapter", Settings: strings.Replace(settings, "%s", "", 1)}
+	if _, ok := s.callbackOption(imageGen.ID, withoutCallback, "http_adapter", &token); ok || token != "" {
+		t.Fatalf("adapter without {{callback_url}} should not register a callback")
+	}
+	withCallback := &models.AIServiceConfig{Provider: "http_adapter", Settings: strings.Replace(settings, "%s", `, "notify_url": "{{callback_url}}"`, 1)}
+	opt, ok := s.callbackOption(imageGen.ID, withCallback, "http_adapter", &token)
+	if !ok || token == "" {
+		t.Fatalf("expected callback option for adapter template")
+	}
+	options := &image.ImageOptions{}
+	opt(options)
+	if options.CallbackURL != "https://drama.example.com/api/v1/callbacks/images/http_adapter/"+token {
+		t.Fatalf("unexpected callback url %q", options.CallbackURL)
+	}
+
+	if _, err := s.HandleProviderCallback("http_adapter", "wrong-token", nil); err == nil || err.Error() != "callback not found" {
+		t.Fatalf("expected unknown token to be rejected, got %v", err)
+	}
+	result, err := s.HandleProviderCallback("http_adapter", token, []byte(`{"id": "img-1"}`))
+	if err != nil || result["status"] != "pending" {
+		t.Fatalf("expected pending before task id is saved, got %v, %v", result, err)
+	}
+	db.Model(&imageGen).Update("task_id", "img-1")
+	if _, err := s.HandleProviderCallback("http_adapter", token, []byte(`{"id": "img-2"}`)); err == nil {
+		t.Fatalf("expected task id mismatch to be rejected")
+	}
+
+	if done := s.applyImageTaskResult(imageGen.ID, &image.ImageResult{Error: "content blocked"}, nil, ""); !done {
+		t.Fatalf("failed result should finish the task")
+	}
+	if done := s.applyImageTaskResult(imageGen.ID, &image.ImageResult{Completed: true, ImageURL: "https://cdn.example.com/a.png"}, nil, ""); !done {
+		t.Fatalf("finished task should not be completed again")
+	}
+	if size := imageTaskLocks.size(); size != 0 {
+		t.Fatalf("expected task lock to be released, %d entries left", size)
+	}
+	var saved models.ImageGeneration
+	db.First(&saved, imageGen.ID)
+	if saved.Status != models.ImageStatusFailed || saved.ImageURL != nil {
+		t.Fatalf("unexpected saved generation %+v", saved)
+	}
+	result, err = s.HandleProviderCallback("http_adapter", token, []byte(`{"id": "img-1"}`))
+	if err != nil || result["status"] != string(models.ImageStatusFailed) {
+		t.Fatalf("expected finished task to be acknowledged, got %v, %v", result, err)
+	}
+}
@@ -0,0 +1,513 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// providerAliases 将配置中的 provider 别名映射到能力表中的规范名称
+var providerAliases = map[string]string{
+	"volcengine": "doubao",
+	"volces":     "doubao",
+	"ark":        "doubao",
+	"sora":       "openai",
+	"dalle":      "openai",
+	"google":     "gemini",
+}
+
+func canonicalProvider(provider string) string {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if alias, ok := providerAliases[provider]; ok {
+		return alias
+	}
+	return provider
+}
+
+// defaultProviderCapabilities 内置的服务商能力，启动时补全到 ai_service_providers，已有能力配置不会被覆盖
+var defaultProviderCapabilities = []struct {
+	Name        string
+	DisplayName string
+	ServiceType string
+	Provider    string
+	DefaultURL  string
+	Description string
+	Caps        models.ProviderCapabilities
+}{
+	{
+		Name: "doubao", DisplayName: "豆包(火山引擎)", ServiceType: "video", Provider: "doubao",
+		DefaultURL: "https://ark.cn-beijing.volces.com", Description: "火山引擎豆包视频生成",
+		Caps: models.ProviderCapabilities{
+			MinDuration: 2, MaxDuration: 12,
+			AspectRatios:       []string{"16:9", "4:3", "1:1", "3:4", "9:16", "21:9"},
+			Resolutions:        []string{"480p", "720p", "1080p"},
+			ReferenceModes:     []string{"none", "single", "first_last", "multiple"},
+			MaxReferenceImages: 4,
+		},
+	},
+	{
+		Name: "openai-sora", DisplayName: "OpenAI Sora", ServiceType: "video", Provider: "openai",
+		DefaultURL: "https://api.openai.com/v1", Description: "OpenAI Sora视频生成",
+		Caps: models.ProviderCapabilities{
+			Durations:          []int{4, 8, 12},
+			AspectRatios:       []string{"16:9", "9:16"},
+			Resolutions:        []string{"1280x720", "720x1280"},
+			ReferenceModes:     []string{"none", "single"},
+			MaxReferenceImages: 1,
+		},
+	},
+	{
+		Name: "runway", DisplayName: "Runway", ServiceType: "video", Provider: "runway",
+		Description: "Runway视频生成",
+		Caps: models.ProviderCapabilities{
+			Durations:          []int{5, 10},
+			AspectRatios:       []string{"16:9", "9:16"},
+			ReferenceModes:     []string{"single", "first_last"},
+			MaxReferenceImages: 1,
+		},
+	},
+	{
+		Name: "pika", DisplayName: "Pika Labs", ServiceType: "video", Provider: "pika",
+		Description: "Pika视频生成",
+		Caps: models.ProviderCapabilities{
+			MinDuration: 3, MaxDuration: 10,
+			AspectRatios:       []string{"16:9", "9:16", "1:1", "4:5", "5:4"},
+			ReferenceModes:     []string{"none", "single"},
+			MaxReferenceImages: 1,
+		},
+	},
+	{
+		Name: "minimax", DisplayName: "MiniMax", ServiceType: "video", Provider: "minimax",
+		Description: "MiniMax视频生成",
+		Caps: models.ProviderCapabilities{
+			Durations:          []int{6, 10},
+			Resolutions:        []string{"768P", "1080P"},
+			ReferenceModes:     []string{"none", "single", "first_last"},
+			MaxReferenceImages: 1,
+		},
+	},
+	{
+		Name: "chatfire-video", DisplayName: "Chatfire", ServiceType: "video", Provider: "chatfire",
+		Description: "Chatfire聚合视频生成",
+		Caps: models.ProviderCapabilities{
+			MinDuration: 1, MaxDuration: 20,
+			ReferenceModes:     []string{"none", "single", "first_last", "multiple"},
+			MaxReferenceImages: 4,
+		},
+	},
+	{
+		Name: "mock-video", DisplayName: "模拟视频(离线)", ServiceType: "video", Provider: "mock",
+		Description: "本地 ffmpeg 生成测试图案视频，用于离线开发与测试",
+		Caps: models.ProviderCapabilities{
+			MinDuration: 1, MaxDuration: 20,
+			ReferenceModes:     []string{"none", "single", "first_last", "multiple"},
+			MaxReferenceImages: 4,
+		},
+	},
+	{
+		Name: "openai-dalle", DisplayName: "OpenAI DALL-E", ServiceType: "image", Provider: "openai",
+		DefaultURL: "https://api.openai.com/v1", Description: "OpenAI DALL-E图片生成",
+		Caps: models.ProviderCapabilities{
+			ImageSizes: []string{"1024x1024", "1536x1024", "1024x1536", "1792x1024", "1024x1792"},
+		},
+	},
+	{
+		Name: "doubao-image", DisplayName: "豆包(火山引擎)", ServiceType: "image", Provider: "doubao",
+		DefaultURL: "https://ark.cn-beijing.volces.com", Description: "火山引擎豆包图片生成",
+		Caps: models.ProviderCapabilities{
+			ImageSizes:         []string{"2048x2048", "2560x1440", "1440x2560", "2304x1728", "1728x2304", "2496x1664", "1664x2496", "3024x1296"},
+			MaxReferenceImages: 10,
+		},
+	},
+	{
+		Name: "gemini-image", DisplayName: "Google Gemini", ServiceType: "image", Provider: "gemini",
+		DefaultURL: "https://generativelanguage.googleapis.com", Description: "Google Gemini原生图片生成(base64)",
+		Caps: models.ProviderCapabilities{
+			AspectRatios:       []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "21:9"},
+			MaxReferenceImages: 3,
+		},
+	},
+	{
+		Name: "sdwebui-image", DisplayName: "Stable Diffusion WebUI", ServiceType: "image", Provider: string(models.ProviderStableDiffusion),
+		DefaultURL: "http://127.0.0.1:7860", Description: "AUTOMATIC1111 txt2img/img2img，支持自定义 checkpoint 与 LoRA(base64)",
+		Caps: models.ProviderCapabilities{
+			MaxReferenceImages: 1,
+		},
+	},
+	{
+		Name: "comfyui-image", DisplayName: "ComfyUI", ServiceType: "image", Provider: string(models.ProviderComfyUI),
+		DefaultURL: "http://127.0.0.1:8188", Description: "提交 ComfyUI 工作流并轮询 /history 获取结果",
+		Caps: models.ProviderCapabilities{
+			MaxReferenceImages: 1,
+		},
+	},
+	{
+		Name: "mock-image", DisplayName: "模拟图片(离线)", ServiceType: "image", Provider: "mock",
+		Description: "纯 Go 绘制带提示词的占位图(base64)，用于离线开发与测试",
+		Caps: models.ProviderCapabilities{
+			MaxReferenceImages: 10,
+		},
+	},
+}
+
+// CapabilityAdjustment 记录一次自动调整
+type CapabilityAdjustment struct {
+	Field  string `json:"field"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// VideoParams 需要按服务商能力校验的视频参数
+type VideoParams struct {
+	Duration        *int
+	AspectRatio     *string
+	Resolution      *string
+	ReferenceMode   string
+	ReferenceImages []string
+}
+
+type ProviderCapabilityService struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewProviderCapabilityService(db *gorm.DB, log *logger.Logger) *ProviderCapabilityService {
+	return &ProviderCapabilityService{
+		db:  db,
+		log: log,
+	}
+}
+
+// EnsureDefaults 补全内置服务商及其能力配置，不覆盖用户已修改的能力
+func (s *ProviderCapabilityService) EnsureDefaults() error {
+	for _, def := range defaultProviderCapabilities {
+		capsJSON, err := json.Marshal(def.Caps)
+		if err != nil {
+			return err
+		}
+
+		var provider models.AIServiceProvider
+		err = s.db.Where("name = ?", def.Name).First(&provider).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			provider = models.AIServiceProvider{
+				Name:         def.Name,
+				DisplayName:  def.DisplayName,
+				ServiceType:  def.ServiceType,
+				Provider:     def.Provider,
+				DefaultURL:   def.DefaultURL,
+				Description:  def.Description,
+				Capabilities: datatypes.JSON(capsJSON),
+				IsActive:     true,
+			}
+			if err := s.db.Create(&provider).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		if provider.Provider == "" {
+			updates["provider"] = def.Provider
+		}
+		if len(provider.Capabilities) == 0 || string(provider.Capabilities) == "null" {
+			updates["capabilities"] = datatypes.JSON(capsJSON)
+		}
+		if len(updates) > 0 {
+			if err := s.db.Model(&provider).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ProviderCapabilityService) ListProviders(serviceType string) ([]models.AIServiceProvider, error) {
+	var providers []models.AIServiceProvider
+	query := s.db.Order("service_type ASC, name ASC")
+	if serviceType != "" {
+		query = query.Where("service_type = ?", serviceType)
+	}
+	if err := query.Find(&providers).Error; err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
+
+type UpdateProviderRequest struct {
+	DisplayName  *string                      `json:"display_name"`
+	Description  *string                      `json:"description"`
+	IsActive     *bool                        `json:"is_active"`
+	Capabilities *models.ProviderCapabilities `json:"capabilities"`
+}
+
+func (s *ProviderCapabilityService) UpdateProvider(id uint, req *UpdateProviderRequest) (*models.AIServiceProvider, error) {
+	var provider models.AIServiceProvider
+	if err := s.db.First(&provider, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("provider not found")
+		}
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.DisplayName != nil {
+		updates["display_name"] = *req.DisplayName
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.Capabilities != nil {
+		capsJSON, err := json.Marshal(req.Capabilities)
+		if err != nil {
+			return nil, err
+		}
+		updates["capabilities"] = datatypes.JSON(capsJSON)
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&provider).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := s.db.First(&provider, id).Error; err != nil {
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// GetCapabilities 返回服务商（及模型）合并后的能力，未登记的服务商返回 nil
+func (s *ProviderCapabilityService) GetCapabilities(serviceType, provider, model string) (*models.ProviderCapabilities, error) {
+	var row models.AIServiceProvider
+	err := s.db.Where("service_type = ? AND provider = ? AND is_active = ?", serviceType, canonicalProvider(provider), true).
+		Order("id ASC").First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(row.Capabilities) == 0 {
+		return nil, nil
+	}
+
+	var caps models.ProviderCapabilities
+	if err := json.Unmarshal(row.Capabilities, &caps); err != nil {
+		return nil, fmt.Errorf("invalid capabilities for provider %s: %w", row.Name, err)
+	}
+	return mergeModelCapabilities(&caps, model), nil
+}
+
+// mergeModelCapabilities 用模型级配置覆盖服务商默认值（非空字段覆盖）
+func mergeModelCapabilities(base *models.ProviderCapabilities, model string) *models.ProviderCapabilities {
+	merged := *base
+	merged.Models = nil
+
+	override, ok := base.Models[model]
+	if model == "" || !ok || override == nil {
+		return &merged
+	}
+	if len(override.Durations) > 0 {
+		merged.Durations = override.Durations
+	}
+	if override.MinDuration > 0 {
+		merged.MinDuration = override.MinDuration
+	}
+	if override.MaxDuration > 0 {
+		merged.MaxDuration = override.MaxDuration
+	}
+	if len(override.AspectRatios) > 0 {
+		merged.AspectRatios = override.AspectRatios
+	}
+	if len(override.Resolutions) > 0 {
+		merged.Resolutions = override.Resolutions
+	}
+	if len(override.ImageSizes) > 0 {
+		merged.ImageSizes = override.ImageSizes
+	}
+	if len(override.ReferenceModes) > 0 {
+		merged.ReferenceModes = override.ReferenceModes
+	}
+	if override.MaxReferenceImages > 0 {
+		merged.MaxReferenceImages = override.MaxReferenceImages
+	}
+	return &merged
+}
+
+// AdjustVideoParams 按能力校验视频参数，能自动调整的就地修改并返回调整记录，无法满足时返回 ValidationError
+func AdjustVideoParams(caps *models.ProviderCapabilities, params *VideoParams) ([]CapabilityAdjustment, error) {
+	if caps == nil {
+		return nil, nil
+	}
+	var adjustments []CapabilityAdjustment
+
+	if params.Duration != nil && *params.Duration > 0 {
+		adjusted := adjustDuration(caps, *params.Duration)
+		if adjusted != *params.Duration {
+			adjustments = append(adjustments, CapabilityAdjustment{
+				Field: "duration", From: strconv.Itoa(*params.Duration), To: strconv.Itoa(adjusted),
+				Reason: "unsupported duration",
+			})
+			value := adjusted
+			params.Duration = &value
+		}
+	}
+
+	if params.AspectRatio != nil && *params.AspectRatio != "" && len(caps.AspectRatios) > 0 {
+		if nearest := nearestAspectRatio(*params.AspectRatio, caps.AspectRatios); nearest != *params.AspectRatio {
+			adjustments = append(adjustments, CapabilityAdjustment{
+				Field: "aspect_ratio", From: *params.AspectRatio, To: nearest, Reason: "unsupported aspect ratio",
+			})
+			params.AspectRatio = &nearest
+		}
+	}
+
+	if params.Resolution != nil && *params.Resolution != "" && len(caps.Resolutions) > 0 && !containsFold(caps.Resolutions, *params.Resolution) {
+		fallback := caps.Resolutions[len(caps.Resolutions)-1]
+		adjustments = append(adjustments, CapabilityAdjustment{
+			Field: "resolution", From: *params.Resolution, To: fallback, Reason: "unsupported resolution",
+		})
+		params.Resolution = &fallback
+	}
+
+	mode := params.ReferenceMode
+	if mode != "" && len(caps.ReferenceModes) > 0 && !containsFold(caps.ReferenceModes, mode) {
+		downgraded := ""
+		switch mode {
+		case "first_last", "multiple":
+			// 退化为单图：首尾帧保留首帧，多图保留第一张
+			if containsFold(caps.ReferenceModes, "single") {
+				downgraded = "single"
+			}
+		}
+		if downgraded == "" {
+			return adjustments, &ValidationError{Message: fmt.Sprintf("当前服务商不支持参考图模式: %s（支持: %s）", mode, strings.Join(caps.ReferenceModes, ", "))}
+		}
+		adjustments = append(adjustments, CapabilityAdjustment{
+			Field: "reference_mode", From: mode, To: downgraded, Reason: "unsupported reference mode",
+		})
+		params.ReferenceMode = downgraded
+		if len(params.ReferenceImages) > 1 {
+			params.ReferenceImages = params.ReferenceImages[:1]
+		}
+	}
+
+	if params.ReferenceMode == "multiple" && caps.MaxReferenceImages > 0 && len(params.ReferenceImages) > caps.MaxReferenceImages {
+		adjustments = append(adjustments, CapabilityAdjustment{
+			Field:  "reference_images",
+			From:   strconv.Itoa(len(params.ReferenceImages)),
+			To:     strconv.Itoa(caps.MaxReferenceImages),
+			Reason: "too many reference images",
+		})
+		params.ReferenceImages = params.ReferenceImages[:caps.MaxReferenceImages]
+	}
+
+	return adjustments, nil
+}
+
+// AdjustImageSize 按能力将图片尺寸映射到最接近的受支持尺寸
+func AdjustImageSize(caps *models.ProviderCapabilities, size string) (string, *CapabilityAdjustment) {
+	if caps == nil || size == "" || len(caps.ImageSizes) == 0 || containsFold(caps.ImageSizes, size) {
+		return size, nil
+	}
+	nearest := nearestAspectRatio(size, caps.ImageSizes)
+	if nearest == size {
+		return size, nil
+	}
+	return nearest, &CapabilityAdjustment{Field: "size", From: size, To: nearest, Reason: "unsupported image size"}
+}
+
+func adjustDuration(caps *models.ProviderCapabilities, duration int) int {
+	if len(caps.Durations) > 0 {
+		best := caps.Durations[0]
+		for _, d := range caps.Durations {
+			if absInt(d-duration) < absInt(best-duration) || (absInt(d-duration) == absInt(best-duration) && d > best) {
+				best = d
+			}
+		}
+		return best
+	}
+	if caps.MinDuration > 0 && duration < caps.MinDuration {
+		return caps.MinDuration
+	}
+	if caps.MaxDuration > 0 && duration > caps.MaxDuration {
+		return caps.MaxDuration
+	}
+	return duration
+}
+
+// parseRatio 解析 "16:9" 或 "1280x720" 形式的比例
+func parseRatio(value string) (float64, bool) {
+	sep := ":"
+	if strings.Contains(strings.ToLower(value), "x") {
+		sep = "x"
+	}
+	parts := strings.Split(strings.ToLower(value), sep)
+	if len(parts) != 2 {
+		return 0, false
+	}
+	w, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	h, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, false
+	}
+	return w / h, true
+}
+
+// nearestAspectRatio 在受支持的取值中选出比例最接近的一项
+func nearestAspectRatio(value string, supported []string) string {
+	for _, candidate := range supported {
+		if strings.EqualFold(candidate, value) {
+			return candidate
+		}
+	}
+	target, ok := parseRatio(value)
+	if !ok {
+		return supported[0]
+	}
+
+	best := supported[0]
+	bestDiff := math.MaxFloat64
+	for _, candidate := range supported {
+		ratio, ok := parseRatio(candidate)
+		if !ok {
+			continue
+		}
+		diff := math.Abs(math.Log(ratio / target))
+		if diff < bestDiff {
+			best = candidate
+			bestDiff = diff
+		}
+	}
+	return best
+}
+
+func containsFold(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+)
+
+func TestAdjustVideoParamsClampsAndMaps(t *testing.T) {
+	caps := &models.ProviderCapabilities{
+		Durations:      []int{4, 8, 12},
+		AspectRatios:   []string{"16:9", "9:16"},
+		ReferenceModes: []string{"none", "single"},
+		Models: map[string]*models.ProviderCapabilities{
+			"sora-2-pro": {Durations: []int{10, 15}},
+		},
+	}
+
+	duration := 10
+	ratio := "3:4"
+	params := &VideoParams{
+		Duration:        &duration,
+		AspectRatio:     &ratio,
+		ReferenceMode:   "first_last",
+		ReferenceImages: []string{"first.png", "last.png"},
+	}
+	adjustments, err := AdjustVideoParams(mergeModelCapabilities(caps, ""), params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *params.Duration != 12 {
+		t.Fatalf("expected duration snapped to 12, got %d", *params.Duration)
+	}
+	if *params.AspectRatio != "9:16" {
+		t.Fatalf("expected portrait ratio mapped to 9:16, got %s", *params.AspectRatio)
+	}
+	if params.ReferenceMode != "single" || len(params.ReferenceImages) != 1 || params.ReferenceImages[0] != "first.png" {
+		t.Fatalf("expected first_last downgraded to single with first frame, got %+v", params)
+	}
+	if len(adjustments) != 3 {
+		t.Fatalf("expected 3 adjustments, got %+v", adjustments)
+	}
+
+	modelDuration := 12
+	modelParams := &VideoParams{Duration: &modelDuration}
+	if _, err := AdjustVideoParams(mergeModelCapabilities(caps, "sora-2-pro"), modelParams); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *modelParams.Duration != 10 {
+		t.Fatalf("expected model override durations to apply, got %d", *modelParams.Duration)
+	}
+
+	_, err = AdjustVideoParams(&models.ProviderCapabilities{ReferenceModes: []string{"single"}}, &VideoParams{ReferenceMode: "none"})
+	if _, ok := IsValidationError(err); !ok {
+		t.Fatalf("expected validation error for unsupported mode, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ratelimit"
+)
+
+const (
+	// breakerFailureThreshold 连续失败次数达到阈值后熔断
+	breakerFailureThreshold = 3
+	// breakerCooldown 熔断后跳过该配置的时长
+	breakerCooldown = 2 * time.Minute
+)
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// configBreaker 按服务配置ID记录连续失败，进程内所有服务共享
+type configBreaker struct {
+	mu     sync.Mutex
+	states map[uint]*breakerState
+}
+
+var providerBreaker = &configBreaker{states: make(map[uint]*breakerState)}
+
+func (b *configBreaker) RecordSuccess(configID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states, configID)
+}
+
+func (b *configBreaker) RecordFailure(configID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.states[configID]
+	if !ok {
+		state = &breakerState{}
+		b.states[configID] = state
+	}
+	state.failures++
+	if state.failures >= breakerFailureThreshold {
+		state.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
+
+// IsOpen 返回配置是否处于熔断状态；冷却期结束后放行，再次失败会立即重新熔断
+func (b *configBreaker) IsOpen(configID uint) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.states[configID]
+	return ok && state.failures >= breakerFailureThreshold && time.Now().Before(state.openUntil)
+}
+
+var serverErrorPattern = regexp.MustCompile(`status:? 5\d\d`)
+
+// isFailoverError 判断错误是否应切换到下一个配置：网络错误、限流、服务端 5xx
+func isFailoverError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if isRetryableAIError(err) || ratelimit.IsRateLimitError(err) {
+		return true
+	}
+	return serverErrorPattern.MatchString(err.Error())
+}
+
+// recordConfigResult 根据调用结果更新熔断器，只有服务商侧故障才计入失败
+func recordConfigResult(configID uint, err error) {
+	if err == nil {
+		providerBreaker.RecordSuccess(configID)
+		return
+	}
+	if isFailoverError(err) {
+		providerBreaker.RecordFailure(configID)
+	}
+}
+
+// candidateConfigsForModel 按优先级返回候选配置：指定模型时只保留包含该模型的配置（没有则使用全部），
+// 并跳过熔断中的配置；全部熔断时仍返回原列表，避免完全无法生成
+func candidateConfigsForModel(configs []models.AIServiceConfig, modelName string) []models.AIServiceConfig {
+	candidates := configs
+	if modelName != "" {
+		var matched []models.AIServiceConfig
+		for _, cfg := range configs {
+			if configHasModel(&cfg, modelName) {
+				matched = append(matched, cfg)
+			}
+		}
+		if len(matched) > 0 {
+			candidates = matched
+		}
+	}
+
+	var available []models.AIServiceConfig
+	for _, cfg := range candidates {
+		if !providerBreaker.IsOpen(cfg.ID) {
+			available = append(available, cfg)
+		}
+	}
+	if len(available) == 0 {
+		return candidates
+	}
+	return available
+}
+
+func configHasModel(config *models.AIServiceConfig, modelName string) bool {
+	for _, model := range config.Model {
+		if model == modelName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+)
+
+func TestCandidateConfigsSkipOpenBreaker(t *testing.T) {
+	configs := []models.AIServiceConfig{
+		{ID: 901, Provider: "doubao", Model: models.ModelField{"seedance"}},
+		{ID: 902, Provider: "chatfire", Model: models.ModelField{"seedance", "sora"}},
+		{ID: 903, Provider: "openai", Model: models.ModelField{"sora"}},
+	}
+	t.Cleanup(func() {
+		for _, cfg := range configs {
+			providerBreaker.RecordSuccess(cfg.ID)
+		}
+	})
+
+	candidates := candidateConfigsForModel(configs, "seedance")
+	if len(candidates) != 2 || candidates[0].ID != 901 {
+		t.Fatalf("expected configs with requested model in priority order, got %+v", candidates)
+	}
+
+	serverErr := errors.New("API error (status 503): upstream unavailable")
+	for i := 0; i < breakerFailureThreshold; i++ {
+		recordConfigResult(901, serverErr)
+	}
+	candidates = candidateConfigsForModel(configs, "seedance")
+	if len(candidates) != 1 || candidates[0].ID != 902 {
+		t.Fatalf("expected tripped config to be skipped, got %+v", candidates)
+	}
+
+	// 参数错误不计入熔断
+	for i := 0; i < breakerFailureThreshold; i++ {
+		recordConfigResult(902, errors.New("API error (status 400): invalid prompt"))
+	}
+	if providerBreaker.IsOpen(902) {
+		t.Fatalf("client errors should not open the breaker")
+	}
+
+	recordConfigResult(901, nil)
+	if providerBreaker.IsOpen(901) {
+		t.Fatalf("success should reset the breaker")
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/image"
+	"github.com/drama-generator/backend/pkg/ratelimit"
+	"github.com/drama-generator/backend/pkg/video"
+)
+
+// rateLimitPollPause 轮询任务状态遇到 429 时的暂停时长
+const rateLimitPollPause = 10 * time.Second
+
+// providerLimitSettings 从 AIServiceConfig.Settings 中读取的限流配置，例如：
+// {"rate_limit": {"max_concurrent": 2, "requests_per_minute": 30, "tokens_per_minute": 60000}}
+type providerLimitSettings struct {
+	RateLimit *ratelimit.Config `json:"rate_limit"`
+}
+
+// limiterForConfig 返回服务配置对应的共享限流器，未配置限流时仍返回限流器以提供 429 退避
+func limiterForConfig(config *models.AIServiceConfig) *ratelimit.Limiter {
+	if config == nil {
+		return nil
+	}
+
+	var cfg ratelimit.Config
+	if strings.TrimSpace(config.Settings) != "" {
+		var settings providerLimitSettings
+		if err := json.Unmarshal([]byte(config.Settings), &settings); err == nil && settings.RateLimit != nil {
+			cfg = *settings.RateLimit
+		}
+	}
+	return ratelimit.Get(fmt.Sprintf("config:%d", config.ID), cfg)
+}
+
+// estimateTokens 粗略估算输入 token 数（约 4 字符/token），加上请求的最大输出
+func estimateTokens(prompt, systemPrompt string, options []func(*ai.ChatCompletionRequest)) int {
+	req := &ai.ChatCompletionRequest{}
+	for _, opt := range options {
+		opt(req)
+	}
+	estimated := (len(prompt) + len(systemPrompt)) / 4
+	if req.MaxTokens != nil {
+		estimated += *req.MaxTokens
+	}
+	return estimated
+}
+
+// rateLimitedTextClient 为文本客户端加上并发、RPM、TPM 限制
+type rateLimitedTextClient struct {
+	inner   ai.AIClient
+	limiter *ratelimit.Limiter
+}
+
+func newRateLimitedTextClient(inner ai.AIClient, config *models.AIServiceConfig) ai.AIClient {
+	limiter := limiterForConfig(config)
+	if limiter == nil {
+		return inner
+	}
+	return &rateLimitedTextClient{inner: inner, limiter: limiter}
+}
+
+func (c *rateLimitedTextClient) GenerateText(prompt string, systemPrompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	var text string
+	estimated := estimateTokens(prompt, systemPrompt, options)
+	err := c.limiter.Do(estimated, func() (int, error) {
+		used := -1
+		opts := append(options[:len(options):len(options)], ai.WithUsageCallback(func(usage ai.Usage) {
+			used = usage.TotalTokens
+		}))
+		var callErr error
+		text, callErr = c.inner.GenerateText(prompt, systemPrompt, opts...)
+		return used, callErr
+	})
+	return text, err
+}
+
+func (c *rateLimitedTextClient) GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	var text string
+	estimated := estimateTokens(prompt, systemPrompt, options)
+	err := c.limiter.Do(estimated, func() (int, error) {
+		used := -1
+		opts := append(options[:len(options):len(options)], ai.WithUsageCallback(func(usage ai.Usage) {
+			used = usage.TotalTokens
+		}))
+		var callErr error
+		text, callErr = c.inner.GenerateTextStream(prompt, systemPrompt, onChunk, opts...)
+		return used, callErr
+	})
+	return text, err
+}
+
+func (c *rateLimitedTextClient) GenerateImage(prompt string, size string, n int, options ...func(*ai.ChatCompletionRequest)) ([]string, error) {
+	var urls []string
+	err := c.limiter.Do(0, func() (int, error) {
+		var callErr error
+		urls, callErr = c.inner.GenerateImage(prompt, size, n, options...)
+		return -1, callErr
+	})
+	return urls, err
+}
+
+func (c *rateLimitedTextClient) GenerateImageDescription(imageURL string, prompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	var text string
+	err := c.limiter.Do(len(prompt)/4, func() (int, error) {
+		var callErr error
+		text, callErr = c.inner.GenerateImageDescription(imageURL, prompt, options...)
+		return -1, callErr
+	})
+	return text, err
+}
+
+func (c *rateLimitedTextClient) TestConnection() error {
+	return c.inner.TestConnection()
+}
+
+// rateLimitedImageClient 限制图片生成提交；状态轮询只等待 429 暂停
+type rateLimitedImageClient struct {
+	inner   image.ImageClient
+	limiter *ratelimit.Limiter
+}
+
+func newRateLimitedImageClient(inner image.ImageClient, config *models.AIServiceConfig) image.ImageClient {
+	limiter := limiterForConfig(config)
+	if limiter == nil {
+		return inner
+	}
+	return &rateLimitedImageClient{inner: inner, limiter: limiter}
+}
+
+func (c *rateLimitedImageClient) GenerateImage(prompt string, opts ...image.ImageOption) (*image.ImageResult, error) {
+	var result *image.ImageResult
+	err := c.limiter.Do(0, func() (int, error) {
+		var callErr error
+		result, callErr = c.inner.GenerateImage(prompt, opts...)
+		return -1, callErr
+	})
+	return result, err
+}
+
+func (c *rateLimitedImageClient) GetTaskStatus(taskID string) (*image.ImageResult, error) {
+	c.limiter.WaitPaused()
+	result, err := c.inner.GetTaskStatus(taskID)
+	if ratelimit.IsRateLimitError(err) {
+		c.limiter.Pause(rateLimitPollPause)
+	}
+	return result, err
+}
+
+// rateLimitedVideoClient 限制视频生成提交；状态轮询只等待 429 暂停
+type rateLimitedVideoClient struct {
+	inner   video.VideoClient
+	limiter *ratelimit.Limiter
+}
+
+func newRateLimitedVideoClient(inner video.VideoClient, config *models.AIServiceConfig) video.VideoClient {
+	limiter := limiterForConfig(config)
+	if limiter == nil {
+		return inner
+	}
+	return &rateLimitedVideoClient{inner: inner, limiter: limiter}
+}
+
+func (c *rateLimitedVideoClient) GenerateVideo(imageURL, prompt string, opts ...video.VideoOption) (*video.VideoResult, error) {
+	var result *video.VideoResult
+	err := c.limiter.Do(0, func() (int, error) {
+		var callErr error
+		result, callErr = c.inner.GenerateVideo(imageURL, prompt, opts...)
+		return -1, callErr
+	})
+	return result, err
+}
+
+func (c *rateLimitedVideoClient) GetTaskStatus(taskID string) (*video.VideoResult, error) {
+	c.limiter.WaitPaused()
+	result, err := c.inner.GetTaskStatus(taskID)
+	if ratelimit.IsRateLimitError(err) {
+		c.limiter.Pause(rateLimitPollPause)
+	}
+	return result, err
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	models "github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/infrastructure/storage"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// sceneLibraryCategory 场景库图片在本地存储中的目录
+const sceneLibraryCategory = "scene_library"
+
+type SceneLibraryService struct {
+	db           *gorm.DB
+	config       *config.Config
+	localStorage *storage.LocalStorage
+	log          *logger.Logger
+}
+
+func NewSceneLibraryService(db *gorm.DB, cfg *config.Config, localStorage *storage.LocalStorage, log *logger.Logger) *SceneLibraryService {
+	return &SceneLibraryService{
+		db:           db,
+		config:       cfg,
+		localStorage: localStorage,
+		log:          log,
+	}
+}
+
+type CreateSceneLibraryItemRequest struct {
+	Name        string  `json:"name" binding:"required,min=1,max=200"`
+	Category    *string `json:"category"`
+	Location    *string `json:"location"`
+	Time        *string `json:"time"`
+	ImageURL    string  `json:"image_url" binding:"required"`
+	Prompt      *string `json:"prompt"`
+	Description *string `json:"description"`
+	Tags        *string `json:"tags"`
+	SourceType  string  `json:"source_type"`
+}
+
+type UpdateSceneLibraryItemRequest struct {
+	Name        *string `json:"name"`
+	Category    *string `json:"category"`
+	Description *string `json:"description"`
+	Tags        *string `json:"tags"`
+}
+
+type SceneLibraryQuery struct {
+	Page       int    `form:"page,default=1"`
+	PageSize   int    `form:"page_size,default=20"`
+	Category   string `form:"category"`
+	Tag        string `form:"tag"`
+	SourceType string `form:"source_type"`
+	Keyword    string `form:"keyword"`
+}
+
+// ListLibraryItems 获取场景库列表
+func (s *SceneLibraryService) ListLibraryItems(query *SceneLibraryQuery) ([]models.SceneLibrary, int64, error) {
+	var items []models.SceneLibrary
+	var total int64
+
+	db := s.db.Model(&models.SceneLibrary{})
+
+	// 筛选条件
+	if query.Category != "" {
+		db = db.Where("category = ?", query.Category)
+	}
+
+	if query.SourceType != "" {
+		db = db.Where("source_type = ?", query.SourceType)
+	}
+
+	if query.Tag != "" {
+		db = db.Where("tags LIKE ?", "%"+query.Tag+"%")
+	}
+
+	if query.Keyword != "" {
+		keyword := "%" + query.Keyword + "%"
+		db = db.Where("name LIKE ? OR location LIKE ? OR description LIKE ? OR tags LIKE ?", keyword, keyword, keyword, keyword)
+	}
+
+	// 获取总数
+	if err := db.Count(&total).Error; err != nil {
+		s.log.Errorw("Failed to count scene library", "error", err)
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (query.Page - 1) * query.PageSize
+	err := db.Order("created_at DESC").
+		Offset(offset).
+		Limit(query.PageSize).
+		Find(&items).Error
+
+	if err != nil {
+		s.log.Errorw("Failed to list scene library", "error", err)
+		return nil, 0, err
+	}
+
+	return items, total, nil
+}
+
+// ListCategories 获取场景库中已使用的分类
+func (s *SceneLibraryService) ListCategories() ([]string, error) {
+	var categories []string
+	err := s.db.Model(&models.SceneLibrary{}).
+		Where("category IS NOT NULL AND category <> ''").
+		Distinct().Order("category ASC").
+		Pluck("category", &categories).Error
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// CreateLibraryItem 添加到场景库，图片须已上传到本地存储
+func (s *SceneLibraryService) CreateLibraryItem(req *CreateSceneLibraryItemRequest) (*models.SceneLibrary, error) {
+	if localImagePath(s.config, s.localStorage, req.ImageURL) == "" {
+		return nil, &ValidationError{Message: "图片必须是本地存储中的文件，请先上传"}
+	}
+
+	sourceType := req.SourceType
+	if sourceType == "" {
+		sourceType = "generated"
+	}
+
+	item := &models.SceneLibrary{
+		Name:        req.Name,
+		Category:    req.Category,
+		Location:    req.Location,
+		Time:        req.Time,
+		ImageURL:    s.copyImage(req.ImageURL),
+		Prompt:      req.Prompt,
+		Description: req.Description,
+		Tags:        req.Tags,
+		SourceType:  sourceType,
+	}
+
+	if err := s.db.Create(item).Error; err != nil {
+		s.log.Errorw("Failed to create scene library item", "error", err)
+		return nil, err
+	}
+
+	s.log.Infow("Scene library item created", "item_id", item.ID)
+	return item, nil
+}
+
+// GetLibraryItem 获取场景库项
+func (s *SceneLibraryService) GetLibraryItem(itemID string) (*models.SceneLibrary, error) {
+	var item models.SceneLibrary
+	err := s.db.Where("id = ?", itemID).First(&item).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("library item not found")
+		}
+		s.log.Errorw("Failed to get scene library item", "error", err)
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// UpdateLibraryItem 更新场景库项的名称、分类、描述与标签
+func (s *SceneLibraryService) UpdateLibraryItem(itemID string, req *UpdateSceneLibraryItemRequest) (*models.SceneLibrary, error) {
+	item, err := s.GetLibraryItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Category != nil {
+		updates["category"] = req.Category
+	}
+	if req.Description != nil {
+		updates["description"] = req.Description
+	}
+	if req.Tags != nil {
+		updates["tags"] = req.Tags
+	}
+	if len(updates) > 0 {
+		if err := s.db.Model(item).Updates(updates).Error; err != nil {
+			s.log.Errorw("Failed to update scene library item", "error", err)
+			return nil, err
+		}
+	}
+
+	return s.GetLibraryItem(itemID)
+}
+
+// DeleteLibraryItem 删除场景库项
+func (s *SceneLibraryService) DeleteLibraryItem(itemID string) error {
+	result := s.db.Where("id = ?", itemID).Delete(&models.SceneLibrary{})
+
+	if result.Error != nil {
+		s.log.Errorw("Failed to delete scene library item", "error", result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("library item not found")
+	}
+
+	s.log.Infow("Scene library item deleted", "item_id", itemID)
+	return nil
+}
+
+// ApplyLibraryItemToScene 将场景库背景图应用到场景
+func (s *SceneLibraryService) ApplyLibraryItemToScene(sceneID string, libraryItemID string) error {
+	var libraryItem models.SceneLibrary
+	if err := s.db.Where("id = ?", libraryItemID).First(&libraryItem).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("library item not found")
+		}
+		return err
+	}
+
+	var scene models.Scene
+	if err := s.db.Where("id = ?", sceneID).First(&scene).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("scene not found")
+		}
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"image_url": libraryItem.ImageURL,
+		"status":    "generated",
+	}
+	// 场景还没有提示词时沿用库中的提示词，便于之后重新生成
+	if strings.TrimSpace(scene.Prompt) == "" && libraryItem.Prompt != nil {
+		updates["prompt"] = *libraryItem.Prompt
+	}
+	if err := s.db.Model(&scene).Updates(updates).Error; err != nil {
+		s.log.Errorw("Failed to update scene image", "error", err)
+		return err
+	}
+
+	s.log.Infow("Scene library item applied to scene", "scene_id", sceneID, "library_item_id", libraryItemID)
+	return nil
+}
+
+// AddSceneToLibrary 将场景添加到场景库
+func (s *SceneLibraryService) AddSceneToLibrary(sceneID string, category *string, tags *string) (*models.SceneLibrary, error) {
+	var scene models.Scene
+	if err := s.db.Where("id = ?", sceneID).First(&scene).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("scene not found")
+		}
+		return nil, err
+	}
+
+	// 检查是否有图片
+	if scene.ImageURL == nil || *scene.ImageURL == "" {
+		return nil, errors.New("scene has no image")
+	}
+
+	name := scene.Location
+	if scene.Time != "" {
+		name = scene.Location + " · " + scene.Time
+	}
+	location, timeOfDay, prompt := scene.Location, scene.Time, scene.Prompt
+	sceneLibrary := &models.SceneLibrary{
+		Name:          name,
+		Category:      category,
+		Location:      &location,
+		Time:          &timeOfDay,
+		ImageURL:      s.copySceneImage(&scene),
+		Prompt:        &prompt,
+		Tags:          tags,
+		SourceType:    "scene",
+		SourceSceneID: &scene.ID,
+	}
+
+	if err := s.db.Create(sceneLibrary).Error; err != nil {
+		s.log.Errorw("Failed to add scene to library", "error", err)
+		return nil, err
+	}
+
+	s.log.Infow("Scene added to library", "scene_id", sceneID, "library_item_id", sceneLibrary.ID)
+	return sceneLibrary, nil
+}
+
+// copySceneImage 将场景图片存入场景库目录。服务商托管的图片链接会过期，因此远程图片也下载到本地，
+// 但只下载该场景自身生成记录中由服务端写入的地址，不按客户端提供的地址发起请求
+func (s *SceneLibraryService) copySceneImage(scene *models.Scene) string {
+	imageURL := *scene.ImageURL
+	if s.localStorage == nil || localImagePath(s.config, s.localStorage, imageURL) != "" {
+		return s.copyImage(imageURL)
+	}
+	if !strings.HasPrefix(imageURL, "http://") && !strings.HasPrefix(imageURL, "https://") {
+		return imageURL
+	}
+
+	var count int64
+	if err := s.db.Model(&models.ImageGeneration{}).
+		Where("scene_id = ? AND image_url = ? AND status = ?", scene.ID, imageURL, models.ImageStatusCompleted).
+		Count(&count).Error; err != nil || count == 0 {
+		s.log.Warnw("Scene image has no generation record, keeping remote URL", "scene_id", scene.ID, "image_url", truncateImageURL(imageURL))
+		return imageURL
+	}
+	copied, err := s.localStorage.DownloadFromURL(imageURL, sceneLibraryCategory)
+	if err != nil {
+		s.log.Warnw("Failed to download scene image into scene library", "scene_id", scene.ID, "image_url", truncateImageURL(imageURL), "error", err)
+		return imageURL
+	}
+	return copied
+}
+
+// copyImage 将本地存储中的图片复制到场景库目录，使库中的图片不受原场景删除影响；
+// 不下载远程图片，以免服务端按用户提供的地址发起请求。非本地图片或复制失败时沿用原地址
+func (s *SceneLibraryService) copyImage(imageURL string) string {
+	if s.localStorage == nil || imageURL == "" {
+		return imageURL
+	}
+	localPath := localImagePath(s.config, s.localStorage, imageURL)
+	if localPath == "" {
+		return imageURL
+	}
+
+	var copied string
+	file, err := os.Open(localPath)
+	if err == nil {
+		defer file.Close()
+		copied, err = s.localStorage.Upload(file, filepath.Base(localPath), sceneLibraryCategory)
+	}
+	if err != nil {
+		s.log.Warnw("Failed to copy image into scene library", "image_url", truncateImageURL(imageURL), "error", err)
+		return imageURL
+	}
+	return copied
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/infrastructure/storage"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+func TestSceneLibraryAddAndApply(t *testing.T) {
+	db := newTestDB(t, &models.Scene{}, &models.SceneLibrary{}, &models.ImageGeneration{})
+
+	cfg := &config.Config{}
+	cfg.Storage.LocalPath = t.TempDir()
+	cfg.Storage.BaseURL = "http://localhost:5678/static"
+	localStorage, err := storage.NewLocalStorage(cfg.Storage.LocalPath, cfg.Storage.BaseURL)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	imageURL, err := localStorage.Upload(strings.NewReader("warehouse"), "warehouse.png", "images")
+	if err != nil {
+		t.Fatalf("failed to upload image: %v", err)
+	}
+
+	source := models.Scene{DramaID: 1, Location: "旧仓库", Time: "夜晚", Prompt: "abandoned warehouse at night", ImageURL: &imageURL, Status: "generated"}
+	target := models.Scene{DramaID: 2, Location: "仓库", Time: "夜", Prompt: "", Status: "pending"}
+	db.Create(&source)
+	db.Create(&target)
+
+	service := NewSceneLibraryService(db, cfg, localStorage, logger.NewLogger(true))
+	category, tags := "室内", "仓库,夜景"
+	item, err := service.AddSceneToLibrary(fmt.Sprint(source.ID), &category, &tags)
+	if err != nil {
+		t.Fatalf("AddSceneToLibrary failed: %v", err)
+	}
+	if item.Name != "旧仓库 · 夜晚" || item.SourceSceneID == nil || *item.SourceSceneID != source.ID {
+		t.Fatalf("unexpected library item: %+v", item)
+	}
+	// 图片复制到场景库目录，原场景删除后库中图片仍可用
+	if item.ImageURL == imageURL || !strings.Contains(item.ImageURL, "/"+sceneLibraryCategory+"/") {
+		t.Fatalf("expected image copied into scene library, got %s", item.ImageURL)
+	}
+	copied, err := os.ReadFile(filepath.Join(cfg.Storage.LocalPath, strings.TrimPrefix(item.ImageURL, cfg.Storage.BaseURL+"/")))
+	if err != nil || string(copied) != "warehouse" {
+		t.Fatalf("expected copied file content, got %q (%v)", copied, err)
+	}
+
+	empty := models.Scene{DramaID: 1, Location: "街道", Time: "白天", Prompt: "street"}
+	db.Create(&empty)
+	if _, err := service.AddSceneToLibrary(fmt.Sprint(empty.ID), nil, nil); err == nil || err.Error() != "scene has no image" {
+		t.Fatalf("expected scene has no image, got %v", err)
+	}
+
+	if err := service.ApplyLibraryItemToScene(fmt.Sprint(target.ID), fmt.Sprint(item.ID)); err != nil {
+		t.Fatalf("ApplyLibraryItemToScene failed: %v", err)
+	}
+	db.First(&target, target.ID)
+	if target.ImageURL == nil || *target.ImageURL != item.ImageURL || target.Status != "generated" || target.Prompt != source.Prompt {
+		t.Fatalf("unexpected scene after apply: %+v", target)
+	}
+
+	for _, query := range []SceneLibraryQuery{{Category: "室内"}, {Tag: "夜景"}, {Keyword: "仓库"}} {
+		query.Page, query.PageSize = 1, 20
+		items, total, err := service.ListLibraryItems(&query)
+		if err != nil || total != 1 || len(items) != 1 {
+			t.Fatalf("query %+v: expected 1 item, got %d (%v)", query, total, err)
+		}
+	}
+	if categories, _ := service.ListCategories(); len(categories) != 1 || categories[0] != "室内" {
+		t.Fatalf("unexpected categories: %v", categories)
+	}
+
+	if err := service.DeleteLibraryItem(fmt.Sprint(item.ID)); err != nil {
+		t.Fatalf("DeleteLibraryItem failed: %v", err)
+	}
+	if err := service.DeleteLibraryItem(fmt.Sprint(item.ID)); err == nil || err.Error() != "library item not found" {
+		t.Fatalf("expected library item not found, got %v", err)
+	}
+	// 手动添加只接受本地存储中的图片，远程地址与越出存储目录的路径都被拒绝
+	manual, err := service.CreateLibraryItem(&CreateSceneLibraryItemRequest{Name: "旧仓库", ImageURL: imageURL})
+	if err != nil || !strings.Contains(manual.ImageURL, "/"+sceneLibraryCategory+"/") {
+		t.Fatalf("expected local image copied, got %+v (%v)", manual, err)
+	}
+	for _, rejected := range []string{"http://169.254.169.254/latest/meta-data", "/static/../../etc/passwd", cfg.Storage.BaseURL + "/../secret.png"} {
+		if _, err := service.CreateLibraryItem(&CreateSceneLibraryItemRequest{Name: "x", ImageURL: rejected}); err == nil {
+			t.Fatalf("%s: expected validation error", rejected)
+		} else if _, ok := IsValidationError(err); !ok {
+			t.Fatalf("%s: expected validation error, got %v", rejected, err)
+		}
+	}
+
+	// 场景自身生成记录中的服务商图片下载到场景库，其他远程地址不发起请求
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("provider"))
+	}))
+	defer server.Close()
+	generatedURL, pastedURL := server.URL+"/generated.png", server.URL+"/pasted.png"
+	generated := models.Scene{DramaID: 1, Location: "码头", Time: "清晨", Prompt: "p", ImageURL: &generatedURL, Status: "generated"}
+	pasted := models.Scene{DramaID: 1, Location: "码头", Time: "黄昏", Prompt: "p", ImageURL: &pastedURL, Status: "generated"}
+	db.Create(&generated)
+	db.Create(&pasted)
+	db.Create(&models.ImageGeneration{SceneID: &generated.ID, DramaID: 1, ImageType: string(models.ImageTypeScene), Provider: "test", Prompt: "p", ImageURL: &generatedURL, Status: models.ImageStatusCompleted})
+
+	downloaded, err := service.AddSceneToLibrary(fmt.Sprint(generated.ID), nil, nil)
+	if err != nil || !strings.HasPrefix(downloaded.ImageURL, cfg.Storage.BaseURL+"/"+sceneLibraryCategory+"/") {
+		t.Fatalf("expected generated image downloaded into scene library, got %+v (%v)", downloaded, err)
+	}
+	kept, err := service.AddSceneToLibrary(fmt.Sprint(pasted.ID), nil, nil)
+	if err != nil || kept.ImageURL != pastedURL {
+		t.Fatalf("expected image without generation record kept as is, got %+v (%v)", kept, err)
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("expected only the generated image to be fetched, got %d requests", requests.Load())
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/drama-generator/backend/pkg/ai"
 	"github.com/drama-generator/backend/pkg/config"
 	"github.com/drama-generator/backend/pkg/logger"
-	"github.com/drama-generator/backend/pkg/utils"
 	"gorm.io/gorm"
 )
 
@@ -27,7 +26,7 @@ func NewScriptGenerationService(db *gorm.DB, cfg *config.Config, log *logger.Log
 		aiService:  NewAIService(db, log, cfg),
 		log:        log,
 		config:     cfg,
-		promptI18n: NewPromptI18n(cfg),
+		promptI18n: NewPromptI18n(cfg, db, log),
 		taskService: NewTaskService(db, log),
 	}
 }
@@ -38,7 +37,8 @@ type GenerateCharactersRequest struct {
 	Outline     string  `json:"outline"`
 	Count       int     `json:"count"`
 	Temperature float64 `json:"temperature"`
-	Model       string  `json:"model"` // 指定使用的文本模型
+	Model       string  `json:"model"`        // 指定使用的文本模型
+	BypassCache bool    `json:"bypass_cache"` // 不使用缓存的模型响应
 }
 
 func (s *ScriptGenerationService) GenerateCharacters(req *GenerateCharactersRequest) (string, error) {
@@ -71,8 +71,6 @@ func (s *ScriptGenerationService) processCharacterGeneration(taskID string, req
 		count = 5
 	}
 
-	systemPrompt := s.promptI18n.GetCharacterExtractionPrompt()
-
 	var drama models.Drama
 	if err := s.db.Where("id = ? ", req.DramaID).First(&drama).Error; err != nil {
 		s.log.Errorw("Drama not found during character generation", "error", err, "drama_id", req.DramaID)
@@ -80,12 +78,15 @@ func (s *ScriptGenerationService) processCharacterGeneration(taskID string, req
 		return
 	}
 
+	promptI18n := s.promptI18n.ForDrama(drama.ID)
+	systemPrompt := promptI18n.GetCharacterExtractionPrompt()
+
 	outlineText := req.Outline
 	if outlineText == "" {
-		outlineText = s.promptI18n.FormatUserPrompt("drama_info_template", drama.Title, drama.Description, drama.Genre)
+		outlineText = promptI18n.FormatUserPrompt("drama_info_template", drama.Title, drama.Description, drama.Genre)
 	}
 
-	userPrompt := s.promptI18n.FormatUserPrompt("character_request", outlineText, count)
+	userPrompt := promptI18n.FormatUserPrompt("character_request", outlineText, count)
 
 	// Apply StylePrompt if exists
 	if drama.StylePrompt != nil && *drama.StylePrompt != "" {
@@ -97,46 +98,43 @@ func (s *ScriptGenerationService) processCharacterGeneration(taskID string, req
 		temperature = 0.7
 	}
 
+	// AI直接返回数组格式
+	var result []struct {
+		Name        string `json:"name"`
+		Role        string `json:"role"`
+		Description string `json:"description"`
+		Personality string `json:"personality"`
+		Appearance  string `json:"appearance"`
+		VoiceStyle  string `json:"voice_style"`
+	}
+
 	// 如果指定了模型，使用指定的模型；否则使用默认配置
-	var text string
-	var err error
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		return s.aiService.GenerateText(p, systemPrompt, options...)
+	}
+	usageScope := WithUsageScope(UsageScope{DramaID: drama.ID, Operation: "character_generation"})
+	cacheStatus := NewLLMCacheStatus(req.BypassCache)
 	if req.Model != "" {
 		s.log.Infow("Using specified model for character generation", "model", req.Model, "task_id", taskID)
 		client, getErr := s.aiService.GetAIClientForModel("text", req.Model)
 		if getErr != nil {
 			s.log.Warnw("Failed to get client for specified model, using default", "model", req.Model, "error", getErr, "task_id", taskID)
-			text, err = s.aiService.GenerateText(userPrompt, systemPrompt, ai.WithTemperature(temperature), ai.WithMaxTokens(4000))
 		} else {
-			text, err = client.GenerateText(userPrompt, systemPrompt, ai.WithTemperature(temperature), ai.WithMaxTokens(4000))
+			generate = func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+				return client.GenerateText(p, systemPrompt, options...)
+			}
 		}
-	} else {
-		text, err = s.aiService.GenerateText(userPrompt, systemPrompt, ai.WithTemperature(temperature), ai.WithMaxTokens(4000))
 	}
 
+	text, err := s.aiService.generateStructured(generate, userPrompt, "characters", &result, ai.WithTemperature(temperature), ai.WithMaxTokens(4000), usageScope, cacheStatus.Option())
 	if err != nil {
-		s.log.Errorw("Failed to generate characters", "error", err, "task_id", taskID)
+		s.log.Errorw("Failed to generate characters", "error", err, "raw_response", text[:minInt(500, len(text))], "task_id", taskID)
 		s.taskService.UpdateTaskError(taskID, fmt.Errorf("AI生成失败: %w", err))
 		return
 	}
 
 	s.log.Infow("AI response received for character generation", "length", len(text), "preview", text[:minInt(200, len(text))], "task_id", taskID)
 
-	// AI直接返回数组格式
-	var result []struct {
-		Name        string `json:"name"`
-		Role        string `json:"role"`
-		Description string `json:"description"`
-		Personality string `json:"personality"`
-		Appearance  string `json:"appearance"`
-		VoiceStyle  string `json:"voice_style"`
-	}
-
-	if err := utils.SafeParseAIJSON(text, &result); err != nil {
-		s.log.Errorw("Failed to parse characters JSON", "error", err, "raw_response", text[:minInt(500, len(text))], "task_id", taskID)
-		s.taskService.UpdateTaskError(taskID, fmt.Errorf("解析AI返回结果失败"))
-		return
-	}
-
 	var characters []models.Character
 	for _, char := range result {
 		// 检查角色是否已存在
@@ -188,6 +186,7 @@ func (s *ScriptGenerationService) processCharacterGeneration(taskID string, req
 	resultData := map[string]interface{}{
 		"characters": characters,
 		"count":      len(characters),
+		"cache_hit":  cacheStatus.Hit(),
 	}
 	s.taskService.UpdateTaskResult(taskID, resultData)
 
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	models "github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/infrastructure/storage"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+	"github.com/drama-generator/backend/pkg/sheetsplit"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// sheetViewCategory 裁切出的视图在本地存储中的目录
+const sheetViewCategory = "sheet_views"
+
+// SheetSplitService 将角色、道具的三视图设定图裁切为单独的视图，供分镜与视频生成按镜头角度选用
+type SheetSplitService struct {
+	db           *gorm.DB
+	config       *config.Config
+	localStorage *storage.LocalStorage
+	log          *logger.Logger
+	httpClient   *http.Client
+}
+
+func NewSheetSplitService(db *gorm.DB, cfg *config.Config, localStorage *storage.LocalStorage, log *logger.Logger) *SheetSplitService {
+	return &SheetSplitService{
+		db:           db,
+		config:       cfg,
+		localStorage: localStorage,
+		log:          log,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// SplitCharacterSheet 裁切角色设定图并保存到 Character.ReferenceImages
+func (s *SheetSplitService) SplitCharacterSheet(characterID uint) ([]models.ReferenceView, error) {
+	var character models.Character
+	if err := s.db.First(&character, characterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("character not found")
+		}
+		return nil, err
+	}
+	if character.ImageURL == nil || *character.ImageURL == "" {
+		return nil, &ValidationError{Message: "角色尚未生成设定图"}
+	}
+
+	views, err := s.splitSheet(*character.ImageURL, fmt.Sprintf("character_%d", character.ID))
+	if err != nil {
+		return nil, err
+	}
+	merged := mergeReferenceViews(character.ReferenceImages, views)
+	if err := s.db.Model(&models.Character{}).Where("id = ?", character.ID).Update("reference_images", merged).Error; err != nil {
+		return nil, err
+	}
+	s.log.Infow("Character sheet split into views", "character_id", character.ID, "views", len(views))
+	return views, nil
+}
+
+// SplitPropSheet 裁切道具设定图并保存到 Prop.ReferenceImages
+func (s *SheetSplitService) SplitPropSheet(propID uint) ([]models.ReferenceView, error) {
+	var prop models.Prop
+	if err := s.db.First(&prop, propID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("prop not found")
+		}
+		return nil, err
+	}
+	if prop.ImageURL == nil || *prop.ImageURL == "" {
+		return nil, &ValidationError{Message: "道具尚未生成设定图"}
+	}
+
+	views, err := s.splitSheet(*prop.ImageURL, fmt.Sprintf("prop_%d", prop.ID))
+	if err != nil {
+		return nil, err
+	}
+	merged := mergeReferenceViews(prop.ReferenceImages, views)
+	if err := s.db.Model(&models.Prop{}).Where("id = ?", prop.ID).Update("reference_images", merged).Error; err != nil {
+		return nil, err
+	}
+	s.log.Infow("Prop sheet split into views", "prop_id", prop.ID, "views", len(views))
+	return views, nil
+}
+
+func (s *SheetSplitService) splitSheet(imageURL, name string) ([]models.ReferenceView, error) {
+	if s.localStorage == nil {
+		return nil, &ValidationError{Message: "未启用本地存储，无法保存裁切后的视图"}
+	}
+
+	data, err := s.loadImage(imageURL)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sheet image: %w", err)
+	}
+	panels, err := sheetsplit.Detect(img)
+	if err != nil {
+		if errors.Is(err, sheetsplit.ErrNoPanels) {
+			return nil, &ValidationError{Message: "未能在设定图中识别出三视图布局"}
+		}
+		return nil, err
+	}
+
+	views := make([]models.ReferenceView, 0, len(panels))
+	for _, panel := range panels {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, sheetsplit.Crop(img, panel.Bounds)); err != nil {
+			return nil, fmt.Errorf("failed to encode %s view: %w", panel.View, err)
+		}
+		url, err := s.localStorage.Upload(&buf, fmt.Sprintf("%s_%s.png", name, panel.View), sheetViewCategory)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, models.ReferenceView{View: string(panel.View), URL: url, SourceURL: imageURL})
+	}
+	return views, nil
+}
+
+// localImagePath 返回本地存储中图片对应的文件路径，非本地图片或路径越出存储目录（如 /static/../..）时返回空字符串
+func localImagePath(cfg *config.Config, localStorage *storage.LocalStorage, imageURL string) string {
+	if baseURL := strings.TrimSuffix(cfg.Storage.BaseURL, "/"); baseURL != "" && localStorage != nil && strings.HasPrefix(imageURL, baseURL+"/") {
+		return pathWithin(localStorage.GetPath(""), strings.TrimPrefix(imageURL, baseURL+"/"))
+	}
+	if strings.HasPrefix(imageURL, "/static/") {
+		return pathWithin(cfg.Storage.LocalPath, strings.TrimPrefix(imageURL, "/static/"))
+	}
+	return ""
+}
+
+// pathWithin 将相对路径拼接到 root 下，清理后仍位于 root 内才返回
+func pathWithin(root, relPath string) string {
+	root = filepath.Clean(root)
+	fullPath := filepath.Join(root, filepath.FromSlash(relPath))
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return fullPath
+}
+
+// loadImage 本地存储中的图片直接读取文件，其余通过 HTTP 下载
+func (s *SheetSplitService) loadImage(imageURL string) ([]byte, error) {
+	if localPath := localImagePath(s.config, s.localStorage, imageURL); localPath != "" {
+		if data, err := os.ReadFile(localPath); err == nil {
+			return data, nil
+		}
+	}
+
+	resp, err := s.httpClient.Get(imageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sheet image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch sheet image, status: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// SplitLookSheet 裁切造型设定图并保存到 CharacterLook.ReferenceImages
+func (s *SheetSplitService) SplitLookSheet(lookID uint) ([]models.ReferenceView, error) {
+	var look models.CharacterLook
+	if err := s.db.First(&look, lookID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("look not found")
+		}
+		return nil, err
+	}
+	if look.ImageURL == nil || *look.ImageURL == "" {
+		return nil, &ValidationError{Message: "造型尚未设置设定图"}
+	}
+
+	views, err := s.splitSheet(*look.ImageURL, fmt.Sprintf("character_%d_look_%d", look.CharacterID, look.ID))
+	if err != nil {
+		return nil, err
+	}
+	merged := mergeReferenceViews(look.ReferenceImages, views)
+	if err := s.db.Model(&models.CharacterLook{}).Where("id = ?", look.ID).Update("reference_images", merged).Error; err != nil {
+		return nil, err
+	}
+	s.log.Infow("Character look sheet split into views", "look_id", look.ID, "views", len(views))
+	return views, nil
+}
+
+// splitSheetAsync 角色与道具设定图生成完成后在后台裁切视图，无法识别布局时保持原样
+func (s *ImageGenerationService) splitSheetAsync(imageGen *models.ImageGeneration, imageURL string) {
+	if imageGen.CharacterID == nil && imageGen.PropID == nil {
+		return
+	}
+	splitter := NewSheetSplitService(s.db, s.config, s.localStorage, s.log)
+	go func() {
+		var err error
+		if imageGen.CharacterID != nil {
+			_, err = splitter.SplitCharacterSheet(*imageGen.CharacterID)
+		} else {
+			_, err = splitter.SplitPropSheet(*imageGen.PropID)
+		}
+		if err != nil {
+			s.log.Infow("Sheet not split into views", "image_gen_id", imageGen.ID, "image_url", truncateImageURL(imageURL), "reason", err)
+		}
+	}()
+}
+
+// parseReferenceViews 兼容纯 URL 数组与视图对象数组两种格式
+func parseReferenceViews(raw datatypes.JSON) []models.ReferenceView {
+	if len(raw) == 0 {
+		return nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil
+	}
+	views := make([]models.ReferenceView, 0, len(items))
+	for _, item := range items {
+		var url string
+		if json.Unmarshal(item, &url) == nil {
+			if url != "" {
+				views = append(views, models.ReferenceView{URL: url})
+			}
+			continue
+		}
+		var view models.ReferenceView
+		if json.Unmarshal(item, &view) == nil && view.URL != "" {
+			views = append(views, view)
+		}
+	}
+	return views
+}
+
+// mergeReferenceViews 用新裁切的视图替换旧视图，保留手动添加的参考图
+func mergeReferenceViews(raw datatypes.JSON, views []models.ReferenceView) datatypes.JSON {
+	merged := make([]models.ReferenceView, 0, len(views))
+	for _, existing := range parseReferenceViews(raw) {
+		if existing.View == "" {
+			merged = append(merged, existing)
+		}
+	}
+	merged = append(merged, views...)
+	data, _ := json.Marshal(merged)
+	return datatypes.JSON(data)
+}
+
+// viewForShot 根据镜头角度与景别选择视图：背面/侧面镜头使用对应视图，其余使用正面
+func viewForShot(angle, shotType string) sheetsplit.View {
+	text := strings.ToLower(angle + " " + shotType)
+	for _, keyword := range []string{"背面", "背影", "背後", "back", "behind", "rear"} {
+		if strings.Contains(text, keyword) {
+			return sheetsplit.ViewBack
+		}
+	}
+	for _, keyword := range []string{"侧面", "侧身", "横から", "side", "profile"} {
+		if strings.Contains(text, keyword) {
+			return sheetsplit.ViewSide
+		}
+	}
+	return sheetsplit.ViewFront
+}
+
+// pickReferenceView 从当前设定图裁切出的视图中选择目标视图，缺失时依次退回正面与主立绘
+func pickReferenceView(raw datatypes.JSON, sheetURL string, view sheetsplit.View) string {
+	byView := map[string]string{}
+	for _, v := range parseReferenceViews(raw) {
+		if v.View != "" && v.SourceURL == sheetURL {
+			byView[v.View] = v.URL
+		}
+	}
+	for _, candidate := range []sheetsplit.View{view, sheetsplit.ViewFront, sheetsplit.ViewMain} {
+		if url, ok := byView[string(candidate)]; ok {
+			return url
+		}
+	}
+	return ""
+}
+
+// resolveSheetViews 将参考图中分镜关联角色、道具的设定图替换为最适合该镜头的单个视图，
+// 角色使用当前生效的造型
+func resolveSheetViews(db *gorm.DB, storyboardID uint, refs []string) []string {
+	var storyboard models.Storyboard
+	if err := db.Preload("Characters").Preload("Props").First(&storyboard, storyboardID).Error; err != nil {
+		return refs
+	}
+	angle, shotType := "", ""
+	if storyboard.Angle != nil {
+		angle = *storyboard.Angle
+	}
+	if storyboard.ShotType != nil {
+		shotType = *storyboard.ShotType
+	}
+	view := viewForShot(angle, shotType)
+
+	// 角色有生效造型时，基础设定图替换为造型设定图（或其对应视图）
+	looks := resolveActiveLooks(db, &storyboard)
+	replacements := map[string]string{}
+	for _, base := range storyboard.Characters {
+		if base.ImageURL == nil {
+			continue
+		}
+		character := withLook(base, looks[base.ID])
+		if url := pickReferenceView(character.ReferenceImages, *character.ImageURL, view); url != "" {
+			replacements[*base.ImageURL] = url
+		} else if *character.ImageURL != *base.ImageURL {
+			replacements[*base.ImageURL] = *character.ImageURL
+		}
+	}
+	for _, prop := range storyboard.Props {
+		if prop.ImageURL != nil {
+			if url := pickReferenceView(prop.ReferenceImages, *prop.ImageURL, view); url != "" {
+				replacements[*prop.ImageURL] = url
+			}
+		}
+	}
+	if len(replacements) == 0 {
+		return refs
+	}
+
+	resolved := make([]string, len(refs))
+	for i, ref := range refs {
+		if url, ok := replacements[ref]; ok {
+			resolved[i] = url
+		} else {
+			resolved[i] = ref
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/infrastructure/storage"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+func TestSplitCharacterSheetAndResolveViews(t *testing.T) {
+	db := newTestDB(t, &models.Character{}, &models.Prop{}, &models.Storyboard{}, &models.CharacterLookAssignment{})
+
+	cfg := &config.Config{}
+	cfg.Storage.LocalPath = t.TempDir()
+	cfg.Storage.BaseURL = "http://localhost:5678/static"
+	localStorage, err := storage.NewLocalStorage(cfg.Storage.LocalPath, cfg.Storage.BaseURL)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	// 主立绘 + 正、侧、背三视图
+	sheet := image.NewRGBA(image.Rect(0, 0, 760, 400))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, r := range []image.Rectangle{image.Rect(20, 20, 320, 380), image.Rect(360, 60, 460, 380), image.Rect(500, 60, 580, 380), image.Rect(620, 60, 720, 380)} {
+		draw.Draw(sheet, r, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, sheet)
+	sheetURL, err := localStorage.Upload(&buf, "sheet.png", "characters")
+	if err != nil {
+		t.Fatalf("failed to upload sheet: %v", err)
+	}
+
+	manual, _ := json.Marshal([]string{"http://example.com/manual.png"})
+	character := models.Character{DramaID: 1, Name: "阿青", ImageURL: &sheetURL, ReferenceImages: manual}
+	db.Create(&character)
+
+	service := NewSheetSplitService(db, cfg, localStorage, logger.NewLogger(true))
+	views, err := service.SplitCharacterSheet(character.ID)
+	if err != nil {
+		t.Fatalf("split failed: %v", err)
+	}
+	if len(views) != 4 || views[0].View != "main" || views[3].View != "back" {
+		t.Fatalf("unexpected views %+v", views)
+	}
+
+	db.First(&character, character.ID)
+	stored := parseReferenceViews(character.ReferenceImages)
+	if len(stored) != 5 || stored[0].URL != "http://example.com/manual.png" || stored[0].View != "" {
+		t.Fatalf("expected manual reference to be kept alongside views, got %+v", stored)
+	}
+
+	angle := "背面"
+	storyboard := models.Storyboard{EpisodeID: 1, StoryboardNumber: 1, Angle: &angle}
+	db.Create(&storyboard)
+	db.Model(&storyboard).Association("Characters").Append(&character)
+
+	refs := resolveSheetViews(db, storyboard.ID, []string{"scene.png", sheetURL})
+	if refs[0] != "scene.png" || refs[1] != views[3].URL {
+		t.Fatalf("expected sheet to be replaced by the back view, got %v", refs)
+	}
+
+	// 设定图更换后旧视图不再使用
+	newSheet := "http://example.com/new_sheet.png"
+	db.Model(&character).Update("image_url", newSheet)
+	if refs := resolveSheetViews(db, storyboard.ID, []string{newSheet}); refs[0] != newSheet {
+		t.Fatalf("expected stale views to be ignored, got %v", refs)
+	}
+}
+
+func TestViewForShot(t *testing.T) {
+	for input, expected := range map[[2]string]string{
+		{"背面", "中景"}:         "back",
+		{"side", "close-up"}: "side",
+		{"仰视", "特写"}:         "front",
+		{"", ""}:             "front",
+	} {
+		if view := viewForShot(input[0], input[1]); string(view) != expected {
+			t.Fatalf("%v: expected %s, got %s", input, expected, view)
+		}
+	}
+}
+
+func TestLocalImagePathStaysInStorage(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Storage.LocalPath = t.TempDir()
+	cfg.Storage.BaseURL = "http://localhost:5678/static"
+	localStorage, err := storage.NewLocalStorage(cfg.Storage.LocalPath, cfg.Storage.BaseURL)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	expected := filepath.Join(cfg.Storage.LocalPath, "images", "a.png")
+	for _, imageURL := range []string{"/static/images/a.png", cfg.Storage.BaseURL + "/images/a.png", "/static/images/../images/a.png"} {
+		if path := localImagePath(cfg, localStorage, imageURL); path != expected {
+			t.Fatalf("%s: expected %s, got %q", imageURL, expected, path)
+		}
+	}
+	for _, imageURL := range []string{"/static/../../etc/passwd", cfg.Storage.BaseURL + "/../secret.png", "/static/images/../../x.png", "/static/", "http://example.com/a.png"} {
+		if path := localImagePath(cfg, localStorage, imageURL); path != "" {
+			t.Fatalf("%s: expected rejection, got %s", imageURL, path)
+		}
+	}
+}
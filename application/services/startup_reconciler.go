@@ -0,0 +1,281 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	models "github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/infrastructure/storage"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// interruptedByRestartMsg 无法续跑的任务统一使用的失败原因
+const interruptedByRestartMsg = "interrupted by restart"
+
+// maxTaskRestarts 同一任务因重启重新执行的次数上限，避免导致崩溃的任务反复执行
+const maxTaskRestarts = 2
+
+// episodeTaskParams 按章节执行的提取任务参数（道具、角色）
+type episodeTaskParams struct {
+	EpisodeID uint `json:"episode_id"`
+}
+
+// storyboardTaskParams 分镜生成任务参数，保存已构造好的提示词以便原样重跑
+type storyboardTaskParams struct {
+	EpisodeID     string `json:"episode_id"`
+	Model         string `json:"model,omitempty"`
+	Prompt        string `json:"prompt"`
+	ExpectedShots int    `json:"expected_shots"`
+}
+
+// backgroundTaskParams 场景提取任务参数
+type backgroundTaskParams struct {
+	EpisodeID string `json:"episode_id"`
+	Model     string `json:"model,omitempty"`
+	Style     string `json:"style,omitempty"`
+}
+
+// framePromptTaskParams 帧提示词生成任务参数
+type framePromptTaskParams struct {
+	Request GenerateFramePromptRequest `json:"request"`
+	Model   string                     `json:"model,omitempty"`
+}
+
+// entityMergeTaskParams 实体合并建议任务参数
+type entityMergeTaskParams struct {
+	DramaID    uint   `json:"drama_id"`
+	EntityType string `json:"entity_type,omitempty"`
+}
+
+// StartupReconciler 启动时收尾上次进程遗留的任务：能继续轮询的恢复轮询，
+// 幂等的文本模型任务重新执行，其余标记为失败
+type StartupReconciler struct {
+	db           *gorm.DB
+	log          *logger.Logger
+	config       *config.Config
+	localStorage *storage.LocalStorage
+	taskService  *TaskService
+}
+
+func NewStartupReconciler(db *gorm.DB, log *logger.Logger, cfg *config.Config, localStorage *storage.LocalStorage) *StartupReconciler {
+	return &StartupReconciler{
+		db:           db,
+		log:          log,
+		config:       cfg,
+		localStorage: localStorage,
+		taskService:  NewTaskService(db, log),
+	}
+}
+
+// Run 依次处理图片生成、视频生成、视频合成与异步任务
+func (r *StartupReconciler) Run() {
+	r.reconcileImageGenerations()
+	r.reconcileVideoGenerations()
+	r.reconcileVideoMerges()
+	r.reconcileAsyncTasks()
+}
+
+func (r *StartupReconciler) reconcileImageGenerations() {
+	var imageGens []models.ImageGeneration
+	if err := r.db.Where("status IN ?", []models.ImageGenerationStatus{models.ImageStatusPending, models.ImageStatusProcessing}).
+		Find(&imageGens).Error; err != nil {
+		r.log.Errorw("Failed to load unfinished image generations", "error", err)
+		return
+	}
+	if len(imageGens) == 0 {
+		return
+	}
+
+	imageService := NewImageGenerationService(r.db, r.config, NewResourceTransferService(r.db, r.log), r.localStorage, r.log)
+	resumed, failed := 0, 0
+	for i := range imageGens {
+		imageGen := &imageGens[i]
+		if imageGen.Status == models.ImageStatusProcessing && imageGen.TaskID != nil && *imageGen.TaskID != "" {
+			client, config, model, err := imageService.getImageClientForGeneration(imageGen)
+			if err == nil {
+				go imageService.pollTaskStatus(imageGen.ID, client, *imageGen.TaskID, config, model)
+				resumed++
+				continue
+			}
+			r.log.Warnw("Failed to resume image polling", "id", imageGen.ID, "error", err)
+		}
+		imageService.updateImageGenError(imageGen.ID, interruptedByRestartMsg)
+		failed++
+	}
+	r.log.Infow("Reconciled image generations", "resumed", resumed, "failed", failed)
+}
+
+// reconcileVideoGenerations 已拿到任务ID的视频由 VideoGenerationService.RecoverPendingTasks 恢复轮询，
+// 这里只处理提交前中断的记录
+func (r *StartupReconciler) reconcileVideoGenerations() {
+	result := r.db.Model(&models.VideoGeneration{}).
+		Where("status = ? OR (status = ? AND (task_id IS NULL OR task_id = ''))", models.VideoStatusPending, models.VideoStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":    models.VideoStatusFailed,
+			"error_msg": interruptedByRestartMsg,
+		})
+	if result.Error != nil {
+		r.log.Errorw("Failed to reconcile video generations", "error", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		r.log.Infow("Reconciled video generations", "failed", result.RowsAffected)
+	}
+}
+
+func (r *StartupReconciler) reconcileVideoMerges() {
+	var merges []models.VideoMerge
+	if err := r.db.Where("status IN ?", []models.VideoMergeStatus{models.VideoMergeStatusPending, models.VideoMergeStatusProcessing}).
+		Find(&merges).Error; err != nil {
+		r.log.Errorw("Failed to load unfinished video merges", "error", err)
+		return
+	}
+	if len(merges) == 0 {
+		return
+	}
+
+	mergeService := NewVideoMergeService(r.db, NewResourceTransferService(r.db, r.log), r.config.Storage.LocalPath, r.config.Storage.BaseURL, r.log, r.config)
+	resumed, failed := 0, 0
+	for _, merge := range merges {
+		if merge.Status == models.VideoMergeStatusProcessing && merge.TaskID != nil && *merge.TaskID != "" {
+			client, err := mergeService.getVideoClient(merge.Provider)
+			if err == nil {
+				go mergeService.pollMergeStatus(merge.ID, client, *merge.TaskID)
+				resumed++
+				continue
+			}
+			r.log.Warnw("Failed to resume merge polling", "id", merge.ID, "error", err)
+		}
+		// 本地 FFmpeg 合成进程已随重启丢失
+		mergeService.updateMergeError(merge.ID, interruptedByRestartMsg)
+		failed++
+	}
+	r.log.Infow("Reconciled video merges", "resumed", resumed, "failed", failed)
+}
+
+func (r *StartupReconciler) reconcileAsyncTasks() {
+	var tasks []models.AsyncTask
+	if err := r.db.Where("status IN ?", []string{"pending", "processing"}).Find(&tasks).Error; err != nil {
+		r.log.Errorw("Failed to load unfinished async tasks", "error", err)
+		return
+	}
+	if len(tasks) == 0 {
+		return
+	}
+
+	resumers := r.taskResumers()
+	requeued, failed := 0, 0
+	for i := range tasks {
+		task := &tasks[i]
+		resume, ok := resumers[task.Type]
+		if !ok || task.Params == "" || task.Restarts >= maxTaskRestarts {
+			r.taskService.UpdateTaskError(task.ID, errors.New(interruptedByRestartMsg))
+			failed++
+			continue
+		}
+
+		r.db.Model(task).Updates(map[string]interface{}{
+			"restarts": task.Restarts + 1,
+			"status":   "pending",
+			"progress": 0,
+			"message":  "服务重启，任务重新排队",
+		})
+		if err := resume(task); err != nil {
+			r.log.Warnw("Failed to requeue async task", "task_id", task.ID, "type", task.Type, "error", err)
+			r.taskService.UpdateTaskError(task.ID, fmt.Errorf("%s: %v", interruptedByRestartMsg, err))
+			failed++
+			continue
+		}
+		requeued++
+	}
+	r.log.Infow("Reconciled async tasks", "requeued", requeued, "failed", failed)
+}
+
+// taskResumers 可安全重跑的文本与视觉模型任务：结果按章节/分镜整体替换或按名称去重，重复执行不会产生重复数据
+func (r *StartupReconciler) taskResumers() map[string]func(task *models.AsyncTask) error {
+	return map[string]func(task *models.AsyncTask) error{
+		"storyboard_generation": func(task *models.AsyncTask) error {
+			var params storyboardTaskParams
+			if err := json.Unmarshal([]byte(task.Params), &params); err != nil {
+				return err
+			}
+			service := NewStoryboardService(r.db, r.config, r.log)
+			go service.processStoryboardGeneration(task.ID, params.EpisodeID, params.Model, params.Prompt, params.ExpectedShots, NewLLMCacheStatus(false))
+			return nil
+		},
+		"prop_extraction": func(task *models.AsyncTask) error {
+			episode, err := r.loadTaskEpisode(task)
+			if err != nil {
+				return err
+			}
+			aiService := NewAIService(r.db, r.log, r.config)
+			imageService := NewImageGenerationService(r.db, r.config, NewResourceTransferService(r.db, r.log), r.localStorage, r.log)
+			service := NewPropService(r.db, aiService, r.taskService, imageService, r.log, r.config)
+			go service.processPropExtraction(task.ID, *episode, NewLLMCacheStatus(false))
+			return nil
+		},
+		"character_extraction": func(task *models.AsyncTask) error {
+			episode, err := r.loadTaskEpisode(task)
+			if err != nil {
+				return err
+			}
+			service := NewCharacterLibraryService(r.db, r.log, r.config)
+			go service.processCharacterExtraction(task.ID, *episode, NewLLMCacheStatus(false))
+			return nil
+		},
+		"background_extraction": func(task *models.AsyncTask) error {
+			var params backgroundTaskParams
+			if err := json.Unmarshal([]byte(task.Params), &params); err != nil {
+				return err
+			}
+			service := NewImageGenerationService(r.db, r.config, NewResourceTransferService(r.db, r.log), r.localStorage, r.log)
+			go service.processBackgroundExtraction(task.ID, params.EpisodeID, params.Model, params.Style, false)
+			return nil
+		},
+		"frame_prompt_generation": func(task *models.AsyncTask) error {
+			var params framePromptTaskParams
+			if err := json.Unmarshal([]byte(task.Params), &params); err != nil {
+				return err
+			}
+			params.Request.BypassCache = false
+			service := NewFramePromptService(r.db, r.config, r.log)
+			go service.processFramePromptGeneration(task.ID, params.Request, params.Model)
+			return nil
+		},
+		"character_consistency_audit": func(task *models.AsyncTask) error {
+			episode, err := r.loadTaskEpisode(task)
+			if err != nil {
+				return err
+			}
+			aiService := NewAIService(r.db, r.log, r.config)
+			imageService := NewImageGenerationService(r.db, r.config, NewResourceTransferService(r.db, r.log), r.localStorage, r.log)
+			service := NewCharacterConsistencyService(r.db, aiService, r.taskService, imageService, r.log)
+			go service.processAudit(task.ID, *episode)
+			return nil
+		},
+		"entity_merge_proposal": func(task *models.AsyncTask) error {
+			var params entityMergeTaskParams
+			if err := json.Unmarshal([]byte(task.Params), &params); err != nil {
+				return err
+			}
+			service := NewEntityResolutionService(r.db, NewAIService(r.db, r.log, r.config), r.taskService, r.log)
+			go service.processProposals(task.ID, params.DramaID, params.EntityType)
+			return nil
+		},
+	}
+}
+
+func (r *StartupReconciler) loadTaskEpisode(task *models.AsyncTask) (*models.Episode, error) {
+	var params episodeTaskParams
+	if err := json.Unmarshal([]byte(task.Params), &params); err != nil {
+		return nil, err
+	}
+	var episode models.Episode
+	if err := r.db.First(&episode, params.EpisodeID).Error; err != nil {
+		return nil, fmt.Errorf("episode not found: %w", err)
+	}
+	return &episode, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+func TestStartupReconcilerFailsStrandedWork(t *testing.T) {
+	db := newTestDB(t, &models.AsyncTask{}, &models.Episode{}, &models.ImageGeneration{}, &models.VideoGeneration{}, &models.VideoMerge{})
+
+	taskID := "t-1"
+	db.Create(&models.ImageGeneration{DramaID: 1, Provider: "openai", Prompt: "p", Status: models.ImageStatusProcessing})
+	db.Create(&models.VideoGeneration{DramaID: 1, Provider: "doubao", Prompt: "p", Status: models.VideoStatusPending})
+	polling := models.VideoGeneration{DramaID: 1, Provider: "doubao", Prompt: "p", Status: models.VideoStatusProcessing, TaskID: &taskID}
+	db.Create(&polling)
+	db.Create(&models.VideoMerge{EpisodeID: 1, DramaID: 1, Provider: "doubao", Scenes: []byte("[]"), Status: models.VideoMergeStatusProcessing})
+
+	tasks := []models.AsyncTask{
+		{ID: "legacy", Type: "storyboard_generation", Status: "processing"},
+		{ID: "not-idempotent", Type: "prop_image_generation", Status: "processing", Params: `{}`},
+		{ID: "crash-loop", Type: "prop_extraction", Status: "processing", Params: `{"episode_id": 1}`, Restarts: maxTaskRestarts},
+		{ID: "missing-episode", Type: "character_extraction", Status: "pending", Params: `{"episode_id": 404}`},
+		{ID: "done", Type: "prop_extraction", Status: "completed"},
+	}
+	for i := range tasks {
+		db.Create(&tasks[i])
+	}
+
+	reconciler := NewStartupReconciler(db, logger.NewLogger(true), &config.Config{}, nil)
+	reconciler.Run()
+
+	var imageGen models.ImageGeneration
+	db.First(&imageGen)
+	if imageGen.Status != models.ImageStatusFailed || imageGen.ErrorMsg == nil || *imageGen.ErrorMsg != interruptedByRestartMsg {
+		t.Fatalf("expected image without task id to fail, got %+v", imageGen)
+	}
+
+	var videoGens []models.VideoGeneration
+	db.Order("id").Find(&videoGens)
+	if videoGens[0].Status != models.VideoStatusFailed || videoGens[1].Status != models.VideoStatusProcessing {
+		t.Fatalf("expected only the unsubmitted video to fail, got %s and %s", videoGens[0].Status, videoGens[1].Status)
+	}
+
+	var merge models.VideoMerge
+	db.First(&merge)
+	if merge.Status != models.VideoMergeStatusFailed {
+		t.Fatalf("expected interrupted merge to fail, got %s", merge.Status)
+	}
+
+	for _, id := range []string{"legacy", "not-idempotent", "crash-loop", "missing-episode"} {
+		var task models.AsyncTask
+		db.First(&task, "id = ?", id)
+		if task.Status != "failed" || task.Error == "" {
+			t.Fatalf("expected task %s to fail, got %+v", id, task)
+		}
+	}
+	var done models.AsyncTask
+	db.First(&done, "id = ?", "done")
+	if done.Status != "completed" {
+		t.Fatalf("completed task should be untouched, got %s", done.Status)
+	}
+}
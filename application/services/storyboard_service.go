@@ -5,6 +5,7 @@ import (
 
 	"fmt"
 	"strings"
+	"time"
 
 	models "github.com/drama-generator/backend/domain/models"
 	"github.com/drama-generator/backend/pkg/ai"
@@ -15,6 +16,10 @@ import (
 	"gorm.io/gorm"
 )
 
+// streamProgressInterval 流式生成分镜时写入任务进度的最小间隔。
+// 每次写入都带上全部已解析的镜头，逐镜头写入会让写入量随镜头数平方增长
+const streamProgressInterval = 2 * time.Second
+
 type StoryboardService struct {
 	db          *gorm.DB
 	aiService   *AIService
@@ -31,7 +36,7 @@ func NewStoryboardService(db *gorm.DB, cfg *config.Config, log *logger.Logger) *
 		taskService: NewTaskService(db, log),
 		log:         log,
 		config:      cfg,
-		promptI18n:  NewPromptI18n(cfg),
+		promptI18n:  NewPromptI18n(cfg, db, log),
 	}
 }
 
@@ -61,7 +66,7 @@ type GenerateStoryboardResult struct {
 	Total       int          `json:"total"`
 }
 
-func (s *StoryboardService) GenerateStoryboard(episodeID string, model string) (string, error) {
+func (s *StoryboardService) GenerateStoryboard(episodeID string, model string, bypassCache bool) (string, error) {
 	// 从数据库获取剧集信息
 	var episode struct {
 		ID            string
@@ -123,16 +128,18 @@ func (s *StoryboardService) GenerateStoryboard(episodeID string, model string) (
 		sceneList = fmt.Sprintf("[%s]", strings.Join(sceneInfoList, ", "))
 	}
 
-	// 使用国际化提示词
-	systemPrompt := s.promptI18n.GetStoryboardSystemPrompt()
+	// 使用国际化提示词（优先使用该剧本的模板覆盖）
+	dramaID, _ := strconv.ParseUint(episode.DramaID, 10, 32)
+	promptI18n := s.promptI18n.ForDrama(uint(dramaID))
+	systemPrompt := promptI18n.GetStoryboardSystemPrompt()
 
-	scriptLabel := s.promptI18n.FormatUserPrompt("script_content_label")
-	taskLabel := s.promptI18n.FormatUserPrompt("task_label")
-	taskInstruction := s.promptI18n.FormatUserPrompt("task_instruction")
-	charListLabel := s.promptI18n.FormatUserPrompt("character_list_label")
-	charConstraint := s.promptI18n.FormatUserPrompt("character_constraint")
-	sceneListLabel := s.promptI18n.FormatUserPrompt("scene_list_label")
-	sceneConstraint := s.promptI18n.FormatUserPrompt("scene_constraint")
+	scriptLabel := promptI18n.FormatUserPrompt("script_content_label")
+	taskLabel := promptI18n.FormatUserPrompt("task_label")
+	taskInstruction := promptI18n.FormatUserPrompt("task_instruction")
+	charListLabel := promptI18n.FormatUserPrompt("character_list_label")
+	charConstraint := promptI18n.FormatUserPrompt("character_constraint")
+	sceneListLabel := promptI18n.FormatUserPrompt("scene_list_label")
+	sceneConstraint := promptI18n.FormatUserPrompt("scene_constraint")
 
 	// 构建风格提示词部分
 	stylePromptPart := ""
@@ -329,7 +336,13 @@ func (s *StoryboardService) GenerateStoryboard(episodeID string, model string) (
 - 避免抽象词汇，使用具象的视觉化描述`, systemPrompt, scriptLabel, scriptContent, taskLabel, taskInstruction, charListLabel, characterList, charConstraint, sceneListLabel, sceneList, sceneConstraint, stylePromptPart, scriptContent)
 
 	// 创建异步任务
-	task, err := s.taskService.CreateTask("storyboard_generation", episodeID)
+	expectedShots := estimateShotCount(scriptContent)
+	task, err := s.taskService.CreateTaskWithParams("storyboard_generation", episodeID, storyboardTaskParams{
+		EpisodeID:     episodeID,
+		Model:         model,
+		Prompt:        prompt,
+		ExpectedShots: expectedShots,
+	})
 	if err != nil {
 		s.log.Errorw("Failed to create task", "error", err)
 		return "", fmt.Errorf("创建任务失败: %w", err)
@@ -346,14 +359,26 @@ func (s *StoryboardService) GenerateStoryboard(episodeID string, model string) (
 		"scenes", sceneList)
 
 	// 启动后台goroutine处理AI调用和后续逻辑
-	go s.processStoryboardGeneration(task.ID, episodeID, model, prompt)
+	go s.processStoryboardGeneration(task.ID, episodeID, model, prompt, expectedShots, NewLLMCacheStatus(bypassCache))
 
 	// 立即返回任务ID
 	return task.ID, nil
 }
 
+// estimateShotCount 根据剧本长度粗略估算分镜数量，用于流式生成时计算进度
+func estimateShotCount(scriptContent string) int {
+	count := len([]rune(scriptContent)) / 50
+	if count < 10 {
+		return 10
+	}
+	if count > 120 {
+		return 120
+	}
+	return count
+}
+
 // processStoryboardGeneration 后台处理故事板生成
-func (s *StoryboardService) processStoryboardGeneration(taskID, episodeID, model, prompt string) {
+func (s *StoryboardService) processStoryboardGeneration(taskID, episodeID, model, prompt string, expectedShots int, cacheStatus *LLMCacheStatus) {
 	// 更新任务状态为处理中
 	if err := s.taskService.UpdateTaskStatus(taskID, "processing", 10, "开始生成分镜头..."); err != nil {
 		s.log.Errorw("Failed to update task status", "error", err, "task_id", taskID)
@@ -362,63 +387,95 @@ func (s *StoryboardService) processStoryboardGeneration(taskID, episodeID, model
 
 	s.log.Infow("Processing storyboard generation", "task_id", taskID, "episode_id", episodeID)
 
+	// 流式接收AI输出，每解析出一个完整镜头就更新任务进度与部分结果
+	// 进度区间 10-70 按已解析镜头数与估算镜头数的比例推进
+	// 每次请求（包括校验失败后的重新请求）都从头接收；进度写入按 streamProgressInterval 节流
+	var streamed []Storyboard
+	var truncated bool
+	var lastProgressAt time.Time
+	var client ai.AIClient
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		stream := utils.NewJSONArrayStream()
+		streamed = nil
+		truncated = false
+		options = append(options[:len(options):len(options)], ai.WithFinishCallback(func(reason string) {
+			truncated = ai.IsTruncated(reason)
+		}))
+		onChunk := func(chunk string) {
+			added := false
+			for _, object := range stream.Write(chunk) {
+				var sb Storyboard
+				if err := utils.SafeParseAIJSON(object, &sb); err != nil {
+					s.log.Warnw("Failed to parse streamed storyboard", "error", err, "task_id", taskID)
+					continue
+				}
+				streamed = append(streamed, sb)
+				added = true
+			}
+			if !added || time.Since(lastProgressAt) < streamProgressInterval {
+				return
+			}
+			lastProgressAt = time.Now()
+			progress := 10 + 60*len(streamed)/expectedShots
+			if progress > 69 {
+				progress = 69
+			}
+			message := fmt.Sprintf("已生成 %d 个分镜头...", len(streamed))
+			if err := s.taskService.UpdateTaskProgress(taskID, progress, message, gin.H{
+				"storyboards": streamed,
+				"total":       len(streamed),
+			}); err != nil {
+				s.log.Warnw("Failed to update task progress", "error", err, "task_id", taskID)
+			}
+		}
+		if client != nil {
+			return client.GenerateTextStream(p, "", onChunk, options...)
+		}
+		return s.aiService.GenerateTextStream(p, "", onChunk, options...)
+	}
+
 	// 调用AI服务生成（如果指定了模型则使用指定的模型）
 	// 设置较大的max_tokens以确保完整返回所有分镜的JSON
-	var text string
-	var err error
 	if model != "" {
 		s.log.Infow("Using specified model for storyboard generation", "model", model, "task_id", taskID)
-		client, getErr := s.aiService.GetAIClientForModel("text", model)
+		modelClient, getErr := s.aiService.GetAIClientForModel("text", model)
 		if getErr != nil {
 			s.log.Warnw("Failed to get client for specified model, using default", "model", model, "error", getErr, "task_id", taskID)
-			text, err = s.aiService.GenerateText(prompt, "", ai.WithMaxTokens(16000))
 		} else {
-			text, err = client.GenerateText(prompt, "", ai.WithMaxTokens(16000))
+			client = modelClient
 		}
-	} else {
-		text, err = s.aiService.GenerateText(prompt, "", ai.WithMaxTokens(16000))
 	}
 
+	// 结构化输出：AI返回 {"storyboards": [...]}，也兼容直接返回数组
+	var result GenerateStoryboardResult
+	episodeIDUint, _ := strconv.ParseUint(episodeID, 10, 32)
+	usageScope := WithUsageScope(UsageScope{EpisodeID: uint(episodeIDUint), Operation: "storyboard_generation"})
+	text, err := s.aiService.generateStructured(generate, prompt, "storyboards", &result.Storyboards, ai.WithMaxTokens(16000), usageScope, cacheStatus.Option())
+	if err == nil && truncated {
+		// 达到 token 上限时，修复后的 JSON 即使通过校验也缺少后续镜头
+		err = &StructuredOutputError{Errors: []string{"输出达到 token 上限被截断"}}
+	}
+	if err != nil && IsStructuredOutputError(err) && len(streamed) > 0 {
+		// 输出被截断等导致整体校验失败时，流式过程中已完整解析的镜头只作为部分结果返回，不覆盖剧集现有分镜
+		s.log.Warnw("Storyboard output failed validation, returning streamed shots as partial result", "error", err, "count", len(streamed), "task_id", taskID)
+		s.completePartialStoryboards(taskID, streamed, expectedShots, cacheStatus)
+		return
+	}
 	if err != nil {
-		s.log.Errorw("Failed to generate storyboard", "error", err, "task_id", taskID)
+		s.log.Errorw("Failed to generate storyboard", "error", err, "response", text[:min(500, len(text))], "task_id", taskID)
 		if updateErr := s.taskService.UpdateTaskError(taskID, fmt.Errorf("生成分镜头失败: %w", err)); updateErr != nil {
 			s.log.Errorw("Failed to update task error", "error", updateErr, "task_id", taskID)
 		}
 		return
 	}
+	result.Total = len(result.Storyboards)
 
 	// 更新任务进度
-	if err := s.taskService.UpdateTaskStatus(taskID, "processing", 50, "分镜头生成完成，正在解析结果..."); err != nil {
+	if err := s.taskService.UpdateTaskStatus(taskID, "processing", 70, "分镜头生成完成，正在整理结果..."); err != nil {
 		s.log.Errorw("Failed to update task status", "error", err, "task_id", taskID)
 		return
 	}
 
-	// 解析JSON结果
-	// AI可能返回两种格式：
-	// 1. 数组格式: [{...}, {...}]
-	// 2. 对象格式: {"storyboards": [{...}, {...}]}
-	var result GenerateStoryboardResult
-
-	// 先尝试解析为数组格式
-	var storyboards []Storyboard
-	if err := utils.SafeParseAIJSON(text, &storyboards); err == nil {
-		// 成功解析为数组，包装为对象
-		result.Storyboards = storyboards
-		result.Total = len(storyboards)
-		s.log.Infow("Parsed storyboard as array format", "count", len(storyboards), "task_id", taskID)
-	} else {
-		// 尝试解析为对象格式
-		if err := utils.SafeParseAIJSON(text, &result); err != nil {
-			s.log.Errorw("Failed to parse storyboard JSON in both formats", "error", err, "response", text[:min(500, len(text))], "task_id", taskID)
-			if updateErr := s.taskService.UpdateTaskError(taskID, fmt.Errorf("解析分镜头结果失败: %w", err)); updateErr != nil {
-				s.log.Errorw("Failed to update task error", "error", updateErr, "task_id", taskID)
-			}
-			return
-		}
-		result.Total = len(result.Storyboards)
-		s.log.Infow("Parsed storyboard as object format", "count", len(result.Storyboards), "task_id", taskID)
-	}
-
 	// 计算总时长（所有分镜时长之和）
 	totalDuration := 0
 	for _, sb := range result.Storyboards {
@@ -432,7 +489,7 @@ func (s *StoryboardService) processStoryboardGeneration(taskID, episodeID, model
 		"total_duration_seconds", totalDuration)
 
 	// 更新任务进度
-	if err := s.taskService.UpdateTaskStatus(taskID, "processing", 70, "正在保存分镜头..."); err != nil {
+	if err := s.taskService.UpdateTaskStatus(taskID, "processing", 75, "正在保存分镜头..."); err != nil {
 		s.log.Errorw("Failed to update task status", "error", err, "task_id", taskID)
 		return
 	}
@@ -471,6 +528,7 @@ func (s *StoryboardService) processStoryboardGeneration(taskID, episodeID, model
 		"total":            result.Total,
 		"total_duration":   totalDuration,
 		"duration_minutes": durationMinutes,
+		"cache_hit":        cacheStatus.Hit(),
 	}
 
 	if err := s.taskService.UpdateTaskResult(taskID, resultData); err != nil {
@@ -481,6 +539,26 @@ func (s *StoryboardService) processStoryboardGeneration(taskID, episodeID, model
 	s.log.Infow("Storyboard generation completed", "task_id", taskID, "episode_id", episodeID)
 }
 
+// completePartialStoryboards 以部分结果结束任务：返回已解析的镜头并标记 partial，剧集现有分镜与时长保持不变
+func (s *StoryboardService) completePartialStoryboards(taskID string, storyboards []Storyboard, expectedShots int, cacheStatus *LLMCacheStatus) {
+	resultData := gin.H{
+		"storyboards": storyboards,
+		"total":       len(storyboards),
+		"partial":     true,
+		"parsed":      len(storyboards),
+		"expected":    expectedShots,
+		"cache_hit":   cacheStatus.Hit(),
+	}
+	if err := s.taskService.UpdateTaskResult(taskID, resultData); err != nil {
+		s.log.Errorw("Failed to update task result", "error", err, "task_id", taskID)
+		return
+	}
+	message := fmt.Sprintf("AI输出不完整，仅解析出 %d/%d 个分镜头，未覆盖现有分镜，请重新生成", len(storyboards), expectedShots)
+	if err := s.taskService.UpdateTaskStatus(taskID, "completed", 100, message); err != nil {
+		s.log.Errorw("Failed to update task status", "error", err, "task_id", taskID)
+	}
+}
+
 // generateImagePrompt 生成专门用于图片生成的提示词（首帧静态画面）
 func (s *StoryboardService) generateImagePrompt(sb Storyboard, stylePrompt *string) string {
 	var parts []string
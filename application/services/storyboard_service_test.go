@@ -0,0 +1,77 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+)
+
+// 输出被截断时只返回已完整解析的镜头作为部分结果，剧集现有分镜与时长保持不变
+func TestTruncatedStoryboardOutputKeepsExistingStoryboards(t *testing.T) {
+	db := newTestDB(t, &models.Drama{}, &models.Episode{}, &models.Storyboard{}, &models.AsyncTask{},
+		&models.AIServiceConfig{}, &models.UsageRecord{}, &models.ModelPrice{}, &models.Budget{}, &models.LLMCacheEntry{})
+
+	// 每次请求都在第二个镜头中途达到 token 上限
+	first, _ := json.Marshal(Storyboard{ShotNumber: 1, Title: "噩梦惊醒", Location: "仓库", Duration: 5, Characters: []uint{}})
+	truncated := `{"storyboards": [` + string(first) + `, {"shot_number": 2, "title": "对视`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunk, _ := json.Marshal(map[string]interface{}{
+			"choices": []map[string]interface{}{{"delta": map[string]string{"content": truncated}, "finish_reason": "length"}},
+		})
+		fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", chunk)
+	}))
+	defer server.Close()
+	db.Create(&models.AIServiceConfig{ServiceType: "text", Provider: "openai", Name: "stub", BaseURL: server.URL, APIKey: "k", Model: models.ModelField{"gpt-test"}, IsActive: true})
+
+	drama := models.Drama{Title: "d"}
+	db.Create(&drama)
+	episode := models.Episode{DramaID: drama.ID, EpisodeNum: 1, Title: "e", Duration: 3}
+	db.Create(&episode)
+	for i := 1; i <= 3; i++ {
+		db.Create(&models.Storyboard{EpisodeID: episode.ID, StoryboardNumber: i})
+	}
+
+	log := logger.NewLogger(true)
+	service := NewStoryboardService(db, &config.Config{}, log)
+	task, err := service.taskService.CreateTask("storyboard_generation", fmt.Sprint(episode.ID))
+	if err != nil {
+		t.Fatalf("create task failed: %v", err)
+	}
+	service.processStoryboardGeneration(task.ID, fmt.Sprint(episode.ID), "", "prompt", 6, NewLLMCacheStatus(true))
+
+	if task, err = service.taskService.GetTask(task.ID); err != nil || task.Status != "completed" {
+		t.Fatalf("expected completed task, got %+v (%v)", task, err)
+	}
+	var result struct {
+		Storyboards []Storyboard `json:"storyboards"`
+		Partial     bool         `json:"partial"`
+		Parsed      int          `json:"parsed"`
+		Expected    int          `json:"expected"`
+	}
+	if err := json.Unmarshal([]byte(task.Result), &result); err != nil {
+		t.Fatalf("invalid task result: %v", err)
+	}
+	if !result.Partial || result.Parsed != 1 || result.Expected != 6 || len(result.Storyboards) != 1 {
+		t.Fatalf("expected partial result with 1/6 shots, got %+v", result)
+	}
+	if task.Message != "AI输出不完整，仅解析出 1/6 个分镜头，未覆盖现有分镜，请重新生成" {
+		t.Fatalf("unexpected task message %q", task.Message)
+	}
+
+	var count int64
+	db.Model(&models.Storyboard{}).Where("episode_id = ?", episode.ID).Count(&count)
+	if count != 3 {
+		t.Fatalf("expected existing storyboards kept, got %d", count)
+	}
+	db.First(&episode, episode.ID)
+	if episode.Duration != 3 {
+		t.Fatalf("expected episode duration unchanged, got %d", episode.Duration)
+	}
+}
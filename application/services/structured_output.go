@@ -0,0 +1,170 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/utils"
+)
+
+// structuredOutputMaxRetries 输出不符合 Schema 时最多重新请求的次数
+const structuredOutputMaxRetries = 2
+
+// StructuredOutputError 模型多次重试后输出仍不符合 Schema
+type StructuredOutputError struct {
+	Errors []string
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("AI输出不符合JSON结构: %s", strings.Join(e.Errors, "; "))
+}
+
+// IsStructuredOutputError 检查错误是否为结构化输出校验失败
+func IsStructuredOutputError(err error) bool {
+	var target *StructuredOutputError
+	return errors.As(err, &target)
+}
+
+// textGenerateFunc 一次文本生成调用，可以是 AIService.GenerateText、指定模型的客户端或流式调用
+type textGenerateFunc func(prompt string, options ...func(*ai.ChatCompletionRequest)) (string, error)
+
+// generateStructured 以结构化输出模式生成 JSON 并解析到 target（指向切片或结构体的指针）。
+// 根类型为数组时请求 {wrapKey: [...]} 形式的对象，解析时同时兼容直接返回数组；
+// 响应不符合 Schema 时把校验错误附加到提示词中重新请求；服务商不支持结构化输出时退回普通模式；
+// 重试用尽后再按宽松模式解析一次，只有仍无法解析时才返回 StructuredOutputError。
+// 经过响应缓存时只缓存通过 Schema 校验的输出
+func (s *AIService) generateStructured(generate textGenerateFunc, prompt, wrapKey string, target interface{}, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	schema := ai.SchemaFor(target)
+	wrapped := schema["type"] == "array"
+	root := schema
+	if wrapped {
+		root = ai.JSONSchema{
+			"type":                 "object",
+			"properties":           ai.JSONSchema{wrapKey: schema},
+			"required":             []string{wrapKey},
+			"additionalProperties": false,
+		}
+	}
+
+	useSchema := true
+	currentPrompt := prompt
+	var text string
+	var lastErr error
+	for attempt := 0; attempt <= structuredOutputMaxRetries; attempt++ {
+		// 响应缓存只写入通过校验的结果
+		var commit func()
+		base := append(options[:len(options):len(options)], ai.WithCacheCommit(func(fn func()) { commit = fn }))
+		opts := base
+		if useSchema {
+			opts = append(base[:len(base):len(base)], ai.WithJSONSchema(wrapKey, root))
+		}
+
+		var err error
+		text, err = generate(currentPrompt, opts...)
+		if err != nil && useSchema && isStructuredOutputUnsupported(err) {
+			s.log.Warnw("Provider rejected structured output, retrying without schema", "schema", wrapKey, "error", err)
+			useSchema = false
+			text, err = generate(currentPrompt, base...)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		errs := decodeStructured(text, schema, wrapKey, wrapped, target)
+		if len(errs) == 0 {
+			if commit != nil {
+				commit()
+			}
+			return text, nil
+		}
+
+		if len(errs) > 10 {
+			errs = append(errs[:10], fmt.Sprintf("... %d more", len(errs)-10))
+		}
+		lastErr = &StructuredOutputError{Errors: errs}
+		s.log.Warnw("Structured output failed validation", "schema", wrapKey, "attempt", attempt+1, "errors", errs)
+
+		currentPrompt = s.structuredRetryPrompt(prompt, errs, options)
+	}
+
+	if err := decodeLenient(text, wrapKey, wrapped, target); err == nil {
+		s.log.Warnw("Structured output accepted by lenient parsing after retries", "schema", wrapKey, "errors", lastErr)
+		return text, nil
+	}
+	return text, lastErr
+}
+
+// structuredRetryPrompt 按调用所属剧本的语言，把校验错误附加到原提示词后作为重新请求的提示词
+func (s *AIService) structuredRetryPrompt(prompt string, errs []string, options []func(*ai.ChatCompletionRequest)) string {
+	i18n := s.promptI18n
+	if scope := usageScopeFromOptions(options); scope.DramaID != 0 {
+		i18n = i18n.ForDrama(scope.DramaID)
+	} else {
+		i18n = i18n.ForEpisode(scope.EpisodeID)
+	}
+	return i18n.FormatUserPrompt("structured_output_retry", prompt, strings.Join(errs, "\n- "))
+}
+
+// decodeStructured 解析并校验模型输出，校验通过后写入 target
+func decodeStructured(text string, schema ai.JSONSchema, wrapKey string, wrapped bool, target interface{}) []string {
+	var data interface{}
+	if err := utils.SafeParseAIJSON(text, &data); err != nil {
+		return []string{err.Error()}
+	}
+
+	if wrapped {
+		if obj, ok := data.(map[string]interface{}); ok {
+			inner, ok := obj[wrapKey]
+			if !ok {
+				return []string{fmt.Sprintf("$: missing required field %q", wrapKey)}
+			}
+			data = inner
+		}
+		// {"storyboards": null} 视为空数组
+		if data == nil {
+			data = []interface{}{}
+		}
+	}
+
+	if errs := ai.ValidateSchema(schema, data); len(errs) > 0 {
+		return errs
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// decodeLenient 不做 Schema 校验，按 SafeParseAIJSON 的宽松规则解析，数组结果同样兼容 {wrapKey: [...]} 形式
+func decodeLenient(text, wrapKey string, wrapped bool, target interface{}) error {
+	if wrapped {
+		var obj map[string]json.RawMessage
+		if err := utils.SafeParseAIJSON(text, &obj); err == nil {
+			if inner, ok := obj[wrapKey]; ok {
+				return json.Unmarshal(inner, target)
+			}
+		}
+	}
+	return utils.SafeParseAIJSON(text, target)
+}
+
+// isStructuredOutputUnsupported 判断服务商是否因不支持 response_format / responseSchema 而拒绝请求
+func isStructuredOutputUnsupported(err error) bool {
+	msg := strings.ToLower(err.Error())
+	if !strings.Contains(msg, "400") && !strings.Contains(msg, "invalid") && !strings.Contains(msg, "unsupported") && !strings.Contains(msg, "not support") {
+		return false
+	}
+	return strings.Contains(msg, "response_format") ||
+		strings.Contains(msg, "json_schema") ||
+		strings.Contains(msg, "responseschema") ||
+		strings.Contains(msg, "response_schema") ||
+		strings.Contains(msg, "responsemimetype")
+}
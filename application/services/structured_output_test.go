@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+func newStructuredTestService(db *gorm.DB) *AIService {
+	log := logger.NewLogger(true)
+	return &AIService{db: db, log: log, promptI18n: NewPromptI18n(&config.Config{}, db, log)}
+}
+
+func TestGenerateStructuredReasksWithValidationErrors(t *testing.T) {
+	s := newStructuredTestService(nil)
+
+	responses := []string{
+		`{"storyboards": [{"shot_number": "1"}]}`,
+		"```json\n[{\"shot_number\": 1, \"duration\": 5, \"scene_id\": null}]\n```",
+	}
+	var prompts []string
+	var schemaNames []string
+	var committed []int
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		req := &ai.ChatCompletionRequest{}
+		for _, opt := range options {
+			opt(req)
+		}
+		if req.ResponseFormat != nil {
+			schemaNames = append(schemaNames, req.ResponseFormat.JSONSchema.Name)
+		}
+		prompts = append(prompts, p)
+		call := len(prompts)
+		if req.OnStore != nil {
+			req.OnStore(func() { committed = append(committed, call) })
+		}
+		return responses[call-1], nil
+	}
+
+	var shots []struct {
+		ShotNumber int   `json:"shot_number"`
+		Duration   int   `json:"duration"`
+		SceneID    *uint `json:"scene_id"`
+	}
+	if _, err := s.generateStructured(generate, "拆解分镜", "storyboards", &shots); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(prompts) != 2 {
+		t.Fatalf("expected one re-ask, got %d calls", len(prompts))
+	}
+	if !strings.Contains(prompts[1], `$[0].shot_number: expected integer, got string`) {
+		t.Fatalf("re-ask prompt should include validation error, got: %s", prompts[1])
+	}
+	if !strings.Contains(prompts[1], `missing required field "duration"`) {
+		t.Fatalf("re-ask prompt should include missing field, got: %s", prompts[1])
+	}
+	if !strings.HasPrefix(prompts[1], "拆解分镜\n\n你上一次的输出不符合要求的 JSON Schema") {
+		t.Fatalf("re-ask prompt should default to the zh locale, got: %s", prompts[1])
+	}
+	if len(schemaNames) != 2 || schemaNames[0] != "storyboards" {
+		t.Fatalf("expected schema on every call, got %v", schemaNames)
+	}
+	if len(shots) != 1 || shots[0].ShotNumber != 1 || shots[0].SceneID != nil {
+		t.Fatalf("unexpected decoded result: %+v", shots)
+	}
+	// 只有通过校验的第二次输出允许写入响应缓存
+	if len(committed) != 1 || committed[0] != 2 {
+		t.Fatalf("expected only the valid response to be committed, got %v", committed)
+	}
+}
+
+func TestGenerateStructuredFallsBackWhenSchemaUnsupported(t *testing.T) {
+	s := newStructuredTestService(nil)
+
+	calls := 0
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		calls++
+		req := &ai.ChatCompletionRequest{}
+		for _, opt := range options {
+			opt(req)
+		}
+		if req.ResponseFormat != nil {
+			return "", errors.New("API error: Invalid parameter: 'response_format' of type 'json_schema' is not supported with this model.")
+		}
+		return `[{"name": "林默"}]`, nil
+	}
+
+	var characters []struct {
+		Name string `json:"name"`
+	}
+	if _, err := s.generateStructured(generate, "提取角色", "characters", &characters); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 || len(characters) != 1 || characters[0].Name != "林默" {
+		t.Fatalf("unexpected result: calls=%d characters=%+v", calls, characters)
+	}
+}
+
+func TestGenerateStructuredAcceptsOptionalAndNullFields(t *testing.T) {
+	s := newStructuredTestService(nil)
+
+	calls := 0
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		calls++
+		return `{"characters": [{"name": "林默", "aliases": null}]}`, nil
+	}
+
+	var characters []struct {
+		Name     string   `json:"name"`
+		Nickname *string  `json:"nickname"`
+		Aliases  []string `json:"aliases"`
+	}
+	if _, err := s.generateStructured(generate, "提取角色", "characters", &characters); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 || len(characters) != 1 || characters[0].Nickname != nil || characters[0].Aliases != nil {
+		t.Fatalf("unexpected result: calls=%d characters=%+v", calls, characters)
+	}
+}
+
+func TestGenerateStructuredFallsBackToLenientParse(t *testing.T) {
+	s := newStructuredTestService(nil)
+
+	calls := 0
+	generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		calls++
+		return `{"characters": [{"name": "林默"}]}`, nil
+	}
+
+	var characters []struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	if _, err := s.generateStructured(generate, "提取角色", "characters", &characters); err != nil {
+		t.Fatalf("expected lenient fallback, got %v", err)
+	}
+	if calls != structuredOutputMaxRetries+1 || len(characters) != 1 || characters[0].Name != "林默" {
+		t.Fatalf("unexpected result: calls=%d characters=%+v", calls, characters)
+	}
+
+	calls = 0
+	broken := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+		calls++
+		return "无法完成", nil
+	}
+	if _, err := s.generateStructured(broken, "提取角色", "characters", &characters); !IsStructuredOutputError(err) {
+		t.Fatalf("expected structured output error, got %v", err)
+	}
+}
+
+// 重新请求的提示词跟随调用所属剧本的语言
+func TestGenerateStructuredReaskFollowsDramaLanguage(t *testing.T) {
+	db := newTestDB(t, &models.Drama{}, &models.Episode{})
+	drama := models.Drama{Title: "d", Language: "ja"}
+	db.Create(&drama)
+	episode := models.Episode{DramaID: drama.ID, EpisodeNum: 1, Title: "e"}
+	db.Create(&episode)
+	s := newStructuredTestService(db)
+
+	for _, scope := range []UsageScope{{DramaID: drama.ID}, {EpisodeID: episode.ID}} {
+		var prompts []string
+		generate := func(p string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+			prompts = append(prompts, p)
+			if len(prompts) == 1 {
+				return `{"characters": [{}]}`, nil
+			}
+			return `{"characters": [{"name": "林默"}]}`, nil
+		}
+
+		var characters []struct {
+			Name string `json:"name"`
+		}
+		if _, err := s.generateStructured(generate, "提取角色", "characters", &characters, WithUsageScope(scope)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(prompts) != 2 || !strings.Contains(prompts[1], "前回の出力は指定された JSON スキーマに適合していませんでした") ||
+			!strings.Contains(prompts[1], `missing required field "name"`) {
+			t.Fatalf("expected ja re-ask for scope %+v, got %q", scope, prompts)
+		}
+	}
+}
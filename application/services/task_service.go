@@ -40,6 +40,29 @@ func (s *TaskService) CreateTask(taskType, resourceID string) (*models.AsyncTask
 	return task, nil
 }
 
+// CreateTaskWithParams 创建新任务并保存执行参数，服务重启后可据此重新执行
+func (s *TaskService) CreateTaskWithParams(taskType, resourceID string, params interface{}) (*models.AsyncTask, error) {
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task params: %w", err)
+	}
+
+	task := &models.AsyncTask{
+		ID:         uuid.New().String(),
+		Type:       taskType,
+		Status:     "pending",
+		Progress:   0,
+		ResourceID: resourceID,
+		Params:     string(paramsJSON),
+	}
+
+	if err := s.db.Create(task).Error; err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return task, nil
+}
+
 // UpdateTaskStatus 更新任务状态
 func (s *TaskService) UpdateTaskStatus(taskID, status string, progress int, message string) error {
 	updates := map[string]interface{}{
@@ -92,6 +115,28 @@ func (s *TaskService) UpdateTaskResult(taskID string, result interface{}) error
 		}).Error
 }
 
+// UpdateTaskProgress 更新处理中任务的进度，并写入目前已产出的部分结果供前端轮询展示
+func (s *TaskService) UpdateTaskProgress(taskID string, progress int, message string, partial interface{}) error {
+	updates := map[string]interface{}{
+		"status":     "processing",
+		"progress":   progress,
+		"message":    message,
+		"updated_at": time.Now(),
+	}
+
+	if partial != nil {
+		resultJSON, err := json.Marshal(partial)
+		if err != nil {
+			return fmt.Errorf("failed to marshal result: %w", err)
+		}
+		updates["result"] = string(resultJSON)
+	}
+
+	return s.db.Model(&models.AsyncTask{}).
+		Where("id = ?", taskID).
+		Updates(updates).Error
+}
+
 // GetTask 获取任务信息
 func (s *TaskService) GetTask(taskID string) (*models.AsyncTask, error) {
 	var task models.AsyncTask
@@ -0,0 +1,487 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// 用量上下文在 ChatCompletionRequest.Metadata 中使用的键
+const (
+	usageMetaDramaID   = "usage_drama_id"
+	usageMetaEpisodeID = "usage_episode_id"
+	usageMetaOperation = "usage_operation"
+)
+
+// UsageScope 用量归属：调用属于哪个剧本/章节以及哪个业务环节
+type UsageScope struct {
+	DramaID   uint
+	EpisodeID uint
+	Operation string
+}
+
+// WithUsageScope 为文本调用附加用量归属信息
+func WithUsageScope(scope UsageScope) func(*ai.ChatCompletionRequest) {
+	return func(req *ai.ChatCompletionRequest) {
+		if scope.DramaID != 0 {
+			ai.WithMetadata(usageMetaDramaID, strconv.FormatUint(uint64(scope.DramaID), 10))(req)
+		}
+		if scope.EpisodeID != 0 {
+			ai.WithMetadata(usageMetaEpisodeID, strconv.FormatUint(uint64(scope.EpisodeID), 10))(req)
+		}
+		if scope.Operation != "" {
+			ai.WithMetadata(usageMetaOperation, scope.Operation)(req)
+		}
+	}
+}
+
+// usageScopeFromOptions 从调用选项中还原用量归属
+func usageScopeFromOptions(options []func(*ai.ChatCompletionRequest)) UsageScope {
+	probe := &ai.ChatCompletionRequest{}
+	for _, option := range options {
+		option(probe)
+	}
+
+	var scope UsageScope
+	if v, err := strconv.ParseUint(probe.Metadata[usageMetaDramaID], 10, 32); err == nil {
+		scope.DramaID = uint(v)
+	}
+	if v, err := strconv.ParseUint(probe.Metadata[usageMetaEpisodeID], 10, 32); err == nil {
+		scope.EpisodeID = uint(v)
+	}
+	scope.Operation = probe.Metadata[usageMetaOperation]
+	return scope
+}
+
+type UsageService struct {
+	db  *gorm.DB
+	log *logger.Logger
+}
+
+func NewUsageService(db *gorm.DB, log *logger.Logger) *UsageService {
+	return &UsageService{
+		db:  db,
+		log: log,
+	}
+}
+
+// RecordText 记录一次文本调用
+func (s *UsageService) RecordText(scope UsageScope, config *models.AIServiceConfig, model string, usage ai.Usage) {
+	total := usage.TotalTokens
+	if total == 0 {
+		total = usage.PromptTokens + usage.CompletionTokens
+	}
+	s.record(scope, config, &models.UsageRecord{
+		ServiceType:      "text",
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      total,
+	})
+}
+
+// RecordImage 记录图片生成
+func (s *UsageService) RecordImage(scope UsageScope, config *models.AIServiceConfig, model string, count int, size string, imageGenID *uint) {
+	s.record(scope, config, &models.UsageRecord{
+		ServiceType: "image",
+		Model:       model,
+		ImageCount:  count,
+		ImageSize:   size,
+		ImageGenID:  imageGenID,
+	})
+}
+
+// RecordVideo 记录视频生成
+func (s *UsageService) RecordVideo(scope UsageScope, config *models.AIServiceConfig, model string, seconds int, resolution string, videoGenID *uint) {
+	s.record(scope, config, &models.UsageRecord{
+		ServiceType:  "video",
+		Model:        model,
+		VideoSeconds: seconds,
+		Resolution:   resolution,
+		VideoGenID:   videoGenID,
+	})
+}
+
+func (s *UsageService) record(scope UsageScope, config *models.AIServiceConfig, record *models.UsageRecord) {
+	if s == nil || s.db == nil {
+		return
+	}
+
+	if scope.DramaID == 0 && scope.EpisodeID != 0 {
+		var episode models.Episode
+		if err := s.db.Select("id", "drama_id").First(&episode, scope.EpisodeID).Error; err == nil {
+			scope.DramaID = episode.DramaID
+		}
+	}
+	if scope.DramaID != 0 {
+		dramaID := scope.DramaID
+		record.DramaID = &dramaID
+	}
+	if scope.EpisodeID != 0 {
+		episodeID := scope.EpisodeID
+		record.EpisodeID = &episodeID
+	}
+	record.Operation = scope.Operation
+
+	if config != nil {
+		configID := config.ID
+		record.ConfigID = &configID
+		record.Provider = config.Provider
+		if record.Model == "" && len(config.Model) > 0 {
+			record.Model = config.Model[0]
+		}
+	}
+
+	s.applyPrice(record)
+
+	now := time.Now()
+	record.UsageDate = now.Format("2006-01-02")
+	record.CreatedAt = now
+
+	if err := s.db.Create(record).Error; err != nil {
+		s.log.Warnw("Failed to record usage", "error", err, "service_type", record.ServiceType, "model", record.Model)
+	}
+}
+
+// applyPrice 根据价格表计算费用
+func (s *UsageService) applyPrice(record *models.UsageRecord) {
+	resolution := record.Resolution
+	if record.ServiceType == "image" {
+		resolution = record.ImageSize
+	}
+
+	price, err := s.findPrice(record.ServiceType, record.Provider, record.Model, resolution)
+	if err != nil {
+		record.Currency = "USD"
+		return
+	}
+
+	record.Currency = price.Currency
+	record.Priced = true
+	switch record.ServiceType {
+	case "text":
+		record.Cost = float64(record.PromptTokens)/1e6*price.InputPricePerMillion +
+			float64(record.CompletionTokens)/1e6*price.OutputPricePerMillion
+	case "image":
+		record.Cost = float64(record.ImageCount) * price.PricePerImage
+	case "video":
+		record.Cost = float64(record.VideoSeconds) * price.PricePerSecond
+	}
+}
+
+// findPrice 查找最匹配的价格：厂商与分辨率精确匹配优先，其次为通用价格
+func (s *UsageService) findPrice(serviceType, provider, model, resolution string) (*models.ModelPrice, error) {
+	var prices []models.ModelPrice
+	if err := s.db.Where("service_type = ? AND model = ?", serviceType, model).Find(&prices).Error; err != nil {
+		return nil, err
+	}
+
+	var best *models.ModelPrice
+	bestScore := -1
+	for i := range prices {
+		p := &prices[i]
+		if p.Provider != "" && p.Provider != provider {
+			continue
+		}
+		if p.Resolution != "" && p.Resolution != resolution {
+			continue
+		}
+		score := 0
+		if p.Provider != "" {
+			score++
+		}
+		if p.Resolution != "" {
+			score += 2
+		}
+		if score > bestScore {
+			best = p
+			bestScore = score
+		}
+	}
+
+	if best == nil {
+		return nil, errors.New("price not found")
+	}
+	return best, nil
+}
+
+// UsageQuery 用量查询条件
+type UsageQuery struct {
+	GroupBy     string `form:"group_by"` // drama, episode, provider, model, day
+	DramaID     *uint  `form:"drama_id"`
+	EpisodeID   *uint  `form:"episode_id"`
+	Provider    string `form:"provider"`
+	ServiceType string `form:"service_type"`
+	From        string `form:"from"` // YYYY-MM-DD
+	To          string `form:"to"`   // YYYY-MM-DD
+}
+
+// UsageSummary 汇总结果
+type UsageSummary struct {
+	GroupKey         *string `json:"group_key"`
+	ServiceType      string  `json:"service_type"`
+	Currency         string  `json:"currency"`
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	ImageCount       int64   `json:"image_count"`
+	VideoSeconds     int64   `json:"video_seconds"`
+	Cost             float64 `json:"cost"`
+	UnpricedCalls    int64   `json:"unpriced_calls"`
+}
+
+var usageGroupColumns = map[string]string{
+	"drama":    "drama_id",
+	"episode":  "episode_id",
+	"provider": "provider",
+	"model":    "model",
+	"day":      "usage_date",
+}
+
+func (s *UsageService) filteredQuery(query *UsageQuery) *gorm.DB {
+	db := s.db.Model(&models.UsageRecord{})
+	if query.DramaID != nil {
+		db = db.Where("drama_id = ?", *query.DramaID)
+	}
+	if query.EpisodeID != nil {
+		db = db.Where("episode_id = ?", *query.EpisodeID)
+	}
+	if query.Provider != "" {
+		db = db.Where("provider = ?", query.Provider)
+	}
+	if query.ServiceType != "" {
+		db = db.Where("service_type = ?", query.ServiceType)
+	}
+	if query.From != "" {
+		db = db.Where("usage_date >= ?", query.From)
+	}
+	if query.To != "" {
+		db = db.Where("usage_date <= ?", query.To)
+	}
+	return db
+}
+
+// Summarize 按维度汇总用量与费用
+func (s *UsageService) Summarize(query *UsageQuery) ([]UsageSummary, error) {
+	column, ok := usageGroupColumns[query.GroupBy]
+	if !ok && query.GroupBy != "" {
+		return nil, &ValidationError{Message: fmt.Sprintf("不支持的汇总维度: %s", query.GroupBy)}
+	}
+
+	selectCols := "service_type, currency, count(*) as calls, " +
+		"sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, sum(total_tokens) as total_tokens, " +
+		"sum(image_count) as image_count, sum(video_seconds) as video_seconds, sum(cost) as cost, " +
+		"sum(case when priced then 0 else 1 end) as unpriced_calls"
+	groupCols := "service_type, currency"
+	if column != "" {
+		selectCols = column + " as group_key, " + selectCols
+		groupCols = column + ", " + groupCols
+	}
+
+	var rows []UsageSummary
+	db := s.filteredQuery(query).Select(selectCols).Group(groupCols)
+	if column != "" {
+		db = db.Order(column)
+	}
+	if err := db.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ListRecords 分页查询用量流水
+func (s *UsageService) ListRecords(query *UsageQuery, page, pageSize int) ([]models.UsageRecord, int64, error) {
+	db := s.filteredQuery(query)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var records []models.UsageRecord
+	offset := (page - 1) * pageSize
+	if err := db.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// ListPrices 获取价格表
+func (s *UsageService) ListPrices(serviceType string) ([]models.ModelPrice, error) {
+	var prices []models.ModelPrice
+	db := s.db
+	if serviceType != "" {
+		db = db.Where("service_type = ?", serviceType)
+	}
+	if err := db.Order("service_type, model, provider").Find(&prices).Error; err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// ModelPriceRequest 价格表写入请求
+type ModelPriceRequest struct {
+	ServiceType           string  `json:"service_type" binding:"required,oneof=text image video"`
+	Provider              string  `json:"provider"`
+	Model                 string  `json:"model" binding:"required"`
+	Resolution            string  `json:"resolution"`
+	InputPricePerMillion  float64 `json:"input_price_per_million" binding:"min=0"`
+	OutputPricePerMillion float64 `json:"output_price_per_million" binding:"min=0"`
+	PricePerImage         float64 `json:"price_per_image" binding:"min=0"`
+	PricePerSecond        float64 `json:"price_per_second" binding:"min=0"`
+	Currency              string  `json:"currency"`
+}
+
+func (r *ModelPriceRequest) apply(price *models.ModelPrice) {
+	price.ServiceType = r.ServiceType
+	price.Provider = r.Provider
+	price.Model = r.Model
+	price.Resolution = r.Resolution
+	price.InputPricePerMillion = r.InputPricePerMillion
+	price.OutputPricePerMillion = r.OutputPricePerMillion
+	price.PricePerImage = r.PricePerImage
+	price.PricePerSecond = r.PricePerSecond
+	price.Currency = r.Currency
+	if price.Currency == "" {
+		price.Currency = "USD"
+	}
+}
+
+func (s *UsageService) CreatePrice(req *ModelPriceRequest) (*models.ModelPrice, error) {
+	price := &models.ModelPrice{}
+	req.apply(price)
+	if err := s.db.Create(price).Error; err != nil {
+		return nil, err
+	}
+	return price, nil
+}
+
+func (s *UsageService) UpdatePrice(id uint, req *ModelPriceRequest) (*models.ModelPrice, error) {
+	var price models.ModelPrice
+	if err := s.db.First(&price, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("price not found")
+		}
+		return nil, err
+	}
+	req.apply(&price)
+	if err := s.db.Save(&price).Error; err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+func (s *UsageService) DeletePrice(id uint) error {
+	result := s.db.Delete(&models.ModelPrice{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("price not found")
+	}
+	return nil
+}
+
+// meteredTextClient 包装文本客户端，调用前检查预算，调用后记录用量
+type meteredTextClient struct {
+	inner  ai.AIClient
+	usage  *UsageService
+	budget *BudgetService
+	config *models.AIServiceConfig
+	model  string
+}
+
+func (c *meteredTextClient) checkBudget(scope UsageScope, serviceType string) error {
+	if c.budget == nil {
+		return nil
+	}
+	check := BudgetCheck{DramaID: c.budget.resolveDramaID(scope), ServiceType: serviceType}
+	if c.config != nil {
+		check.ConfigID = c.config.ID
+	}
+	return c.budget.Check(check)
+}
+
+func (c *meteredTextClient) GenerateText(prompt string, systemPrompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	scope := usageScopeFromOptions(options)
+	if err := c.checkBudget(scope, "text"); err != nil {
+		return "", err
+	}
+	reported := false
+	options = append(options, ai.WithUsageCallback(func(usage ai.Usage) {
+		reported = true
+		c.usage.RecordText(scope, c.config, c.model, usage)
+	}))
+
+	text, err := c.inner.GenerateText(prompt, systemPrompt, options...)
+	if err == nil && !reported {
+		// 服务商未返回用量时仍记录调用次数
+		c.usage.RecordText(scope, c.config, c.model, ai.Usage{})
+	}
+	return text, err
+}
+
+func (c *meteredTextClient) GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	scope := usageScopeFromOptions(options)
+	if err := c.checkBudget(scope, "text"); err != nil {
+		return "", err
+	}
+	reported := false
+	options = append(options, ai.WithUsageCallback(func(usage ai.Usage) {
+		reported = true
+		c.usage.RecordText(scope, c.config, c.model, usage)
+	}))
+
+	text, err := c.inner.GenerateTextStream(prompt, systemPrompt, onChunk, options...)
+	if err == nil && !reported {
+		c.usage.RecordText(scope, c.config, c.model, ai.Usage{})
+	}
+	return text, err
+}
+
+func (c *meteredTextClient) GenerateImage(prompt string, size string, n int, options ...func(*ai.ChatCompletionRequest)) ([]string, error) {
+	scope := usageScopeFromOptions(options)
+	if scope.Operation == "" {
+		scope.Operation = "image_generation"
+	}
+	if err := c.checkBudget(scope, "image"); err != nil {
+		return nil, err
+	}
+	urls, err := c.inner.GenerateImage(prompt, size, n, options...)
+	if err == nil {
+		c.usage.RecordImage(scope, c.config, c.model, len(urls), size, nil)
+	}
+	return urls, err
+}
+
+func (c *meteredTextClient) GenerateImageDescription(imageURL string, prompt string, options ...func(*ai.ChatCompletionRequest)) (string, error) {
+	scope := usageScopeFromOptions(options)
+	if scope.Operation == "" {
+		scope.Operation = "image_description"
+	}
+	if err := c.checkBudget(scope, "text"); err != nil {
+		return "", err
+	}
+	reported := false
+	options = append(options, ai.WithUsageCallback(func(usage ai.Usage) {
+		reported = true
+		c.usage.RecordText(scope, c.config, c.model, usage)
+	}))
+
+	text, err := c.inner.GenerateImageDescription(imageURL, prompt, options...)
+	if err == nil && !reported {
+		c.usage.RecordText(scope, c.config, c.model, ai.Usage{})
+	}
+	return text, err
+}
+
+func (c *meteredTextClient) TestConnection() error {
+	return c.inner.TestConnection()
+}
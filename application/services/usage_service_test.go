@@ -0,0 +1,97 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/drama-generator/backend/domain/models"
+	"github.com/drama-generator/backend/pkg/ai"
+	"github.com/drama-generator/backend/pkg/logger"
+	"gorm.io/gorm"
+)
+
+func setupUsageService(t *testing.T) (*UsageService, *gorm.DB) {
+	t.Helper()
+
+	db := newTestDB(t, &models.Episode{}, &models.UsageRecord{}, &models.ModelPrice{})
+	return NewUsageService(db, logger.NewLogger(true)), db
+}
+
+func TestUsageServiceRecordTextAppliesPrice(t *testing.T) {
+	svc, db := setupUsageService(t)
+
+	db.Create(&models.ModelPrice{ServiceType: "text", Model: "gpt-4o", InputPricePerMillion: 2, OutputPricePerMillion: 8, Currency: "USD"})
+	db.Create(&models.ModelPrice{ServiceType: "text", Provider: "openai", Model: "gpt-4o", InputPricePerMillion: 1, OutputPricePerMillion: 4, Currency: "USD"})
+
+	config := &models.AIServiceConfig{ID: 3, Provider: "openai"}
+	svc.RecordText(UsageScope{DramaID: 7, Operation: "storyboard_generation"}, config, "gpt-4o", ai.Usage{PromptTokens: 1000000, CompletionTokens: 500000})
+
+	var record models.UsageRecord
+	if err := db.First(&record).Error; err != nil {
+		t.Fatalf("expected usage record: %v", err)
+	}
+	if !record.Priced {
+		t.Fatalf("expected record to be priced")
+	}
+	if math.Abs(record.Cost-3) > 1e-9 {
+		t.Fatalf("expected provider-specific price to win (cost 3), got %v", record.Cost)
+	}
+	if record.DramaID == nil || *record.DramaID != 7 {
+		t.Fatalf("expected drama id 7, got %v", record.DramaID)
+	}
+}
+
+func TestUsageServiceSummarizeByDrama(t *testing.T) {
+	svc, db := setupUsageService(t)
+
+	db.Create(&models.ModelPrice{ServiceType: "image", Model: "img-1", PricePerImage: 0.04, Currency: "USD"})
+
+	config := &models.AIServiceConfig{ID: 1, Provider: "openai"}
+	svc.RecordImage(UsageScope{DramaID: 1}, config, "img-1", 1, "1024x1024", nil)
+	svc.RecordImage(UsageScope{DramaID: 1}, config, "img-1", 2, "1024x1024", nil)
+	svc.RecordImage(UsageScope{DramaID: 2}, config, "unknown-model", 1, "1024x1024", nil)
+
+	dramaID := uint(1)
+	rows, err := svc.Summarize(&UsageQuery{GroupBy: "drama", DramaID: &dramaID})
+	if err != nil {
+		t.Fatalf("summarize failed: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 summary row, got %d", len(rows))
+	}
+	if rows[0].ImageCount != 3 || math.Abs(rows[0].Cost-0.12) > 1e-9 {
+		t.Fatalf("unexpected summary: %+v", rows[0])
+	}
+
+	all, err := svc.Summarize(&UsageQuery{})
+	if err != nil {
+		t.Fatalf("summarize failed: %v", err)
+	}
+	if len(all) != 1 || all[0].UnpricedCalls != 1 {
+		t.Fatalf("expected one unpriced call in totals, got %+v", all)
+	}
+
+	if _, err := svc.Summarize(&UsageQuery{GroupBy: "bogus"}); err == nil {
+		t.Fatalf("expected validation error for unknown group_by")
+	}
+}
+
+func TestMeteredClientAttributesImageDescription(t *testing.T) {
+	svc, db := setupUsageService(t)
+
+	client := &meteredTextClient{inner: ai.NewMockClient("mock-vision"), usage: svc, config: &models.AIServiceConfig{ID: 2, Provider: "mock"}, model: "mock-vision"}
+	if _, err := client.GenerateImageDescription("data:image/png;base64,cmVm", "描述这张图", WithUsageScope(UsageScope{DramaID: 4, EpisodeID: 9, Operation: "image_scoring"})); err != nil {
+		t.Fatalf("describe failed: %v", err)
+	}
+
+	var record models.UsageRecord
+	if err := db.First(&record).Error; err != nil {
+		t.Fatalf("expected usage record: %v", err)
+	}
+	if record.DramaID == nil || *record.DramaID != 4 || record.EpisodeID == nil || *record.EpisodeID != 9 {
+		t.Fatalf("expected scope drama 4 / episode 9, got %v / %v", record.DramaID, record.EpisodeID)
+	}
+	if record.Operation != "image_scoring" || record.TotalTokens == 0 {
+		t.Fatalf("expected reported tokens for image_scoring, got %+v", record)
+	}
+}
@@ -10,17 +10,22 @@ import (
 	models "github.com/drama-generator/backend/domain/models"
 	"github.com/drama-generator/backend/infrastructure/external/ffmpeg"
 	"github.com/drama-generator/backend/infrastructure/storage"
+	"github.com/drama-generator/backend/pkg/httpadapter"
 	"github.com/drama-generator/backend/pkg/logger"
 	"github.com/drama-generator/backend/pkg/video"
 	"gorm.io/gorm"
 )
 
+// mockVideoCategory 模拟视频服务商输出文件所在的存储目录
+const mockVideoCategory = "videos/mock"
+
 type VideoGenerationService struct {
 	db              *gorm.DB
 	transferService *ResourceTransferService
 	log             *logger.Logger
 	localStorage    *storage.LocalStorage
 	aiService       *AIService
+	capabilities    *ProviderCapabilityService
 	ffmpeg          *ffmpeg.FFmpeg
 }
 
@@ -30,6 +35,7 @@ func NewVideoGenerationService(db *gorm.DB, transferService *ResourceTransferSer
 		localStorage:    localStorage,
 		transferService: transferService,
 		aiService:       aiService,
+		capabilities:    NewProviderCapabilityService(db, log),
 		log:             log,
 		ffmpeg:          ffmpeg.NewFFmpeg(log),
 	}
@@ -86,6 +92,11 @@ func (s *VideoGenerationService) GenerateVideo(request *GenerateVideoRequest) (*
 		return nil, fmt.Errorf("drama not found: %w", err)
 	}
 
+	// 剧本或工作区预算用尽时拒绝新任务
+	if err := s.aiService.GetBudgetService().Check(BudgetCheck{DramaID: drama.ID, ServiceType: "video"}); err != nil {
+		return nil, err
+	}
+
 	// Apply StylePrompt
 	if drama.StylePrompt != nil && *drama.StylePrompt != "" {
 		if !strings.Contains(request.Prompt, *drama.StylePrompt) {
@@ -151,7 +162,10 @@ func (s *VideoGenerationService) GenerateVideo(request *GenerateVideoRequest) (*
 			videoGen.LastFrameURL = request.LastFrameURL
 		}
 	case "multiple":
-		// 多图模式
+		// 多图模式，设定图替换为最适合镜头角度的单个视图
+		if request.StoryboardID != nil {
+			request.ReferenceImageURLs = resolveSheetViews(s.db, *request.StoryboardID, request.ReferenceImageURLs)
+		}
 		if len(request.ReferenceImageURLs) > 0 {
 			referenceImagesJSON, err := json.Marshal(request.ReferenceImageURLs)
 			if err == nil {
@@ -183,6 +197,19 @@ func (s *VideoGenerationService) GenerateVideo(request *GenerateVideoRequest) (*
 		}
 	}
 
+	// 按将要使用的服务商能力预先校验参数，避免轮询后才发现不支持
+	capabilityProvider := provider
+	if candidates, err := s.getVideoCandidateConfigs(request.Model); err == nil && len(candidates) > 0 {
+		capabilityProvider = candidates[0].Provider
+	}
+	adjustments, err := s.applyVideoCapabilities(videoGen, capabilityProvider)
+	if err != nil {
+		return nil, err
+	}
+	if len(adjustments) > 0 {
+		s.log.Infow("Video request adjusted to provider capabilities", "provider", capabilityProvider, "adjustments", adjustments)
+	}
+
 	if err := s.db.Create(videoGen).Error; err != nil {
 		return nil, fmt.Errorf("failed to create record: %w", err)
 	}
@@ -201,15 +228,121 @@ func (s *VideoGenerationService) ProcessVideoGeneration(videoGenID uint) {
 
 	s.db.Model(&videoGen).Update("status", models.VideoStatusProcessing)
 
-	client, err := s.getVideoClient(videoGen.Provider, videoGen.Model)
+	candidateConfigs, err := s.getVideoCandidateConfigs(videoGen.Model)
 	if err != nil {
-		s.log.Errorw("Failed to get video client", "error", err, "provider", videoGen.Provider, "model", videoGen.Model)
+		s.log.Errorw("Failed to load video configs", "error", err, "provider", videoGen.Provider, "model", videoGen.Model)
 		s.updateVideoGenError(videoGenID, err.Error())
 		return
 	}
 
 	s.log.Infow("Starting video generation", "id", videoGenID, "prompt", videoGen.Prompt, "provider", videoGen.Provider)
 
+	var result *video.VideoResult
+	var config *models.AIServiceConfig
+	var lastErr error
+	var callbackToken string
+	usedCallback := false
+	for index := range candidateConfigs {
+		candidate := &candidateConfigs[index]
+		if budgetErr := s.aiService.GetBudgetService().CheckConfig(candidate.ID, "video"); budgetErr != nil {
+			lastErr = budgetErr
+			s.log.Warnw("Video config budget exhausted, skipping", "id", videoGenID, "config_id", candidate.ID)
+			continue
+		}
+		client, _, buildErr := s.buildVideoClientFromConfig(candidate, videoGen.Provider, videoGen.Model)
+		if buildErr != nil {
+			lastErr = buildErr
+			continue
+		}
+
+		// 候选配置不支持请求的模型时改用其默认模型，并按该服务商能力调整参数
+		attempt := videoGen
+		if attempt.Model != "" && !configHasModel(candidate, attempt.Model) && len(candidate.Model) > 0 {
+			attempt.Model = candidate.Model[0]
+		}
+		adjustments, capErr := s.applyVideoCapabilities(&attempt, candidate.Provider)
+		if capErr != nil {
+			lastErr = capErr
+			s.log.Warnw("Video request not supported by config, skipping", "id", videoGenID, "config_id", candidate.ID, "error", capErr)
+			continue
+		}
+		if len(adjustments) > 0 {
+			s.log.Infow("Video parameters adjusted to provider capabilities", "id", videoGenID, "config_id", candidate.ID, "adjustments", adjustments)
+		}
+
+		opts, imageURL := buildVideoOptions(&attempt)
+		callbackOpt, withCallback := s.callbackOption(videoGenID, candidate.Provider, &callbackToken)
+		if withCallback {
+			opts = append(opts, callbackOpt)
+		}
+		callResult, callErr := client.GenerateVideo(imageURL, attempt.Prompt, opts...)
+		recordConfigResult(candidate.ID, callErr)
+		if callErr == nil {
+			result = callResult
+			config = candidate
+			videoGen = attempt
+			usedCallback = withCallback
+			break
+		}
+
+		lastErr = callErr
+		s.log.Errorw("Video generation API call failed", "error", callErr, "id", videoGenID, "config_id", candidate.ID, "provider", candidate.Provider)
+		if !isFailoverError(callErr) {
+			break
+		}
+		if index < len(candidateConfigs)-1 {
+			s.log.Warnw("Retrying video generation with next config", "id", videoGenID, "current_config", candidate.ID)
+		}
+	}
+
+	if result == nil {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("video generation failed: no available config")
+		}
+		s.updateVideoGenError(videoGenID, lastErr.Error())
+		return
+	}
+
+	// 记录实际完成生成的配置及调整后的参数
+	s.db.Model(&videoGen).Updates(map[string]interface{}{
+		"provider":             config.Provider,
+		"model":                videoGen.Model,
+		"config_id":            config.ID,
+		"duration":             videoGen.Duration,
+		"aspect_ratio":         videoGen.AspectRatio,
+		"resolution":           videoGen.Resolution,
+		"reference_mode":       videoGen.ReferenceMode,
+		"image_url":            videoGen.ImageURL,
+		"first_frame_url":      videoGen.FirstFrameURL,
+		"last_frame_url":       videoGen.LastFrameURL,
+		"reference_image_urls": videoGen.ReferenceImageURLs,
+	})
+
+	if result.TaskID != "" {
+		updates := map[string]interface{}{
+			"task_id": result.TaskID,
+			"status":  models.VideoStatusProcessing,
+		}
+		if !usedCallback {
+			// 最终使用的服务商未注册回调，清除令牌以恢复正常轮询频率
+			updates["callback_token"] = nil
+		}
+		s.db.Model(&videoGen).Updates(updates)
+		go s.pollTaskStatus(videoGenID, result.TaskID, videoGen.Provider, videoGen.Model)
+		return
+	}
+
+	if result.VideoURL != "" {
+		s.completeVideoGeneration(videoGenID, result.VideoURL, &result.Duration, &result.Width, &result.Height, nil)
+		s.recordVideoUsage(videoGenID, config)
+		return
+	}
+
+	s.updateVideoGenError(videoGenID, "no task ID or video URL returned")
+}
+
+// buildVideoOptions 根据生成记录构造调用参数，返回单图模式使用的 imageURL
+func buildVideoOptions(videoGen *models.VideoGeneration) ([]video.VideoOption, string) {
 	var opts []video.VideoOption
 	if videoGen.Model != "" {
 		opts = append(opts, video.WithModel(videoGen.Model))
@@ -220,6 +353,9 @@ func (s *VideoGenerationService) ProcessVideoGeneration(videoGenID uint) {
 	if videoGen.FPS != nil {
 		opts = append(opts, video.WithFPS(*videoGen.FPS))
 	}
+	if videoGen.Resolution != nil {
+		opts = append(opts, video.WithResolution(*videoGen.Resolution))
+	}
 	if videoGen.AspectRatio != nil {
 		opts = append(opts, video.WithAspectRatio(*videoGen.AspectRatio))
 	}
@@ -263,33 +399,85 @@ func (s *VideoGenerationService) ProcessVideoGeneration(videoGenID uint) {
 	if videoGen.ImageURL != nil {
 		imageURL = *videoGen.ImageURL
 	}
+	return opts, imageURL
+}
 
-	result, err := client.GenerateVideo(imageURL, videoGen.Prompt, opts...)
+// applyVideoCapabilities 按服务商能力校验并就地调整生成参数，未登记能力的服务商不做处理
+func (s *VideoGenerationService) applyVideoCapabilities(videoGen *models.VideoGeneration, provider string) ([]CapabilityAdjustment, error) {
+	caps, err := s.capabilities.GetCapabilities("video", provider, videoGen.Model)
 	if err != nil {
-		s.log.Errorw("Video generation API call failed", "error", err, "id", videoGenID)
-		s.updateVideoGenError(videoGenID, err.Error())
-		return
+		s.log.Warnw("Failed to load provider capabilities", "provider", provider, "error", err)
+		return nil, nil
+	}
+	if caps == nil {
+		return nil, nil
 	}
 
-	if result.TaskID != "" {
-		s.db.Model(&videoGen).Updates(map[string]interface{}{
-			"task_id": result.TaskID,
-			"status":  models.VideoStatusProcessing,
-		})
-		go s.pollTaskStatus(videoGenID, result.TaskID, videoGen.Provider, videoGen.Model)
-		return
+	mode := ""
+	if videoGen.ReferenceMode != nil {
+		mode = *videoGen.ReferenceMode
+	}
+	var refs []string
+	switch mode {
+	case "single":
+		if videoGen.ImageURL != nil {
+			refs = []string{*videoGen.ImageURL}
+		}
+	case "first_last":
+		if videoGen.FirstFrameURL != nil {
+			refs = append(refs, *videoGen.FirstFrameURL)
+		}
+		if videoGen.LastFrameURL != nil {
+			refs = append(refs, *videoGen.LastFrameURL)
+		}
+	case "multiple":
+		if videoGen.ReferenceImageURLs != nil {
+			_ = json.Unmarshal([]byte(*videoGen.ReferenceImageURLs), &refs)
+		}
 	}
 
-	if result.VideoURL != "" {
-		s.completeVideoGeneration(videoGenID, result.VideoURL, &result.Duration, &result.Width, &result.Height, nil)
-		return
+	params := VideoParams{
+		Duration:        videoGen.Duration,
+		AspectRatio:     videoGen.AspectRatio,
+		Resolution:      videoGen.Resolution,
+		ReferenceMode:   mode,
+		ReferenceImages: refs,
+	}
+	adjustments, err := AdjustVideoParams(caps, &params)
+	if err != nil {
+		return nil, err
 	}
 
-	s.updateVideoGenError(videoGenID, "no task ID or video URL returned")
+	videoGen.Duration = params.Duration
+	videoGen.AspectRatio = params.AspectRatio
+	videoGen.Resolution = params.Resolution
+	if params.ReferenceMode != mode {
+		// 首尾帧/多图退化为单图
+		downgraded := params.ReferenceMode
+		videoGen.ReferenceMode = &downgraded
+		if len(params.ReferenceImages) > 0 {
+			first := params.ReferenceImages[0]
+			videoGen.ImageURL = &first
+		}
+		videoGen.FirstFrameURL = nil
+		videoGen.LastFrameURL = nil
+		videoGen.ReferenceImageURLs = nil
+	} else if mode == "multiple" && len(params.ReferenceImages) != len(refs) {
+		if data, err := json.Marshal(params.ReferenceImages); err == nil {
+			value := string(data)
+			videoGen.ReferenceImageURLs = &value
+		}
+	}
+	return adjustments, nil
 }
 
 func (s *VideoGenerationService) pollTaskStatus(videoGenID uint, taskID string, provider string, model string) {
-	client, err := s.getVideoClient(provider, model)
+	var current models.VideoGeneration
+	if err := s.db.First(&current, videoGenID).Error; err != nil {
+		s.log.Errorw("Failed to load video generation", "error", err, "id", videoGenID)
+		return
+	}
+	client, config, err := s.getVideoClientForGeneration(&current)
 	if err != nil {
 		s.log.Errorw("Failed to get video client for polling", "error", err)
 		s.updateVideoGenError(videoGenID, "failed to get video client")
@@ -298,6 +486,11 @@ func (s *VideoGenerationService) pollTaskStatus(videoGenID uint, taskID string,
 
 	maxAttempts := 300
 	interval := 10 * time.Second
+	if current.CallbackToken != nil {
+		// 已注册回调，轮询仅作兜底
+		maxAttempts = callbackPollMaxAttempts
+		interval = callbackPollInterval
+	}
 
 	for attempt := 0; attempt < maxAttempts; attempt++ {
 		time.Sleep(interval)
@@ -319,17 +512,7 @@ func (s *VideoGenerationService) pollTaskStatus(videoGenID uint, taskID string,
 			continue
 		}
 
-		if result.Completed {
-			if result.VideoURL != "" {
-				s.completeVideoGeneration(videoGenID, result.VideoURL, &result.Duration, &result.Width, &result.Height, nil)
-				return
-			}
-			s.updateVideoGenError(videoGenID, "task completed but no video URL")
-			return
-		}
-
-		if result.Error != "" {
-			s.updateVideoGenError(videoGenID, result.Error)
+		if s.applyVideoTaskResult(videoGenID, result, config) {
 			return
 		}
 
@@ -436,6 +619,36 @@ func (s *VideoGenerationService) completeVideoGeneration(videoGenID uint, videoU
 	s.log.Infow("Video generation completed", "id", videoGenID, "url", videoURL, "duration", duration)
 }
 
+// recordVideoUsage 记录视频生成用量（时长以探测后的实际时长为准）
+func (s *VideoGenerationService) recordVideoUsage(videoGenID uint, config *models.AIServiceConfig) {
+	var videoGen models.VideoGeneration
+	if err := s.db.First(&videoGen, videoGenID).Error; err != nil {
+		s.log.Warnw("Failed to load video generation for usage", "error", err, "id", videoGenID)
+		return
+	}
+
+	scope := UsageScope{DramaID: videoGen.DramaID, Operation: "video_generation"}
+	if videoGen.StoryboardID != nil {
+		var storyboard models.Storyboard
+		if err := s.db.Select("id", "episode_id").First(&storyboard, *videoGen.StoryboardID).Error; err == nil {
+			scope.EpisodeID = storyboard.EpisodeID
+		}
+	}
+
+	seconds := 0
+	if videoGen.Duration != nil {
+		seconds = *videoGen.Duration
+	}
+	resolution := ""
+	if videoGen.Resolution != nil {
+		resolution = *videoGen.Resolution
+	} else if videoGen.Width != nil && videoGen.Height != nil && *videoGen.Width > 0 {
+		resolution = fmt.Sprintf("%dx%d", *videoGen.Width, *videoGen.Height)
+	}
+
+	s.aiService.GetUsageService().RecordVideo(scope, config, videoGen.Model, seconds, resolution, &videoGen.ID)
+}
+
 func (s *VideoGenerationService) updateVideoGenError(videoGenID uint, errorMsg string) {
 	if err := s.db.Model(&models.VideoGeneration{}).Where("id = ?", videoGenID).Updates(map[string]interface{}{
 		"status":    models.VideoStatusFailed,
@@ -445,44 +658,55 @@ func (s *VideoGenerationService) updateVideoGenError(videoGenID uint, errorMsg s
 	}
 }
 
-func (s *VideoGenerationService) getVideoClient(provider string, modelName string) (video.VideoClient, error) {
-	// 根据模型名称获取AI配置
-	var config *models.AIServiceConfig
-	var err error
+// getVideoCandidateConfigs 按优先级返回可用的视频配置（跳过熔断中的配置）
+func (s *VideoGenerationService) getVideoCandidateConfigs(modelName string) ([]models.AIServiceConfig, error) {
+	configs, err := s.aiService.getActiveConfigs("video")
+	if err != nil {
+		return nil, fmt.Errorf("no video AI config found: %w", err)
+	}
+	return candidateConfigsForModel(configs, modelName), nil
+}
 
-	if modelName != "" {
-		config, err = s.aiService.GetConfigForModel("video", modelName)
-		if err != nil {
-			s.log.Warnw("Failed to get config for model, using default", "model", modelName, "error", err)
-			config, err = s.aiService.GetDefaultConfig("video")
-			if err != nil {
-				return nil, fmt.Errorf("no video AI config found: %w", err)
-			}
-		}
-	} else {
-		config, err = s.aiService.GetDefaultConfig("video")
-		if err != nil {
-			return nil, fmt.Errorf("no video AI config found: %w", err)
+// getVideoClient 返回优先级最高且未熔断的配置对应的客户端
+func (s *VideoGenerationService) getVideoClient(provider string, modelName string) (video.VideoClient, *models.AIServiceConfig, error) {
+	configs, err := s.getVideoCandidateConfigs(modelName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.buildVideoClientFromConfig(&configs[0], provider, modelName)
+}
+
+// getVideoClientForGeneration 轮询时优先使用实际提交任务的配置
+func (s *VideoGenerationService) getVideoClientForGeneration(videoGen *models.VideoGeneration) (video.VideoClient, *models.AIServiceConfig, error) {
+	if videoGen.ConfigID != nil {
+		config, err := s.aiService.GetConfig(*videoGen.ConfigID)
+		if err == nil {
+			return s.buildVideoClientFromConfig(config, videoGen.Provider, videoGen.Model)
 		}
+		s.log.Warnw("Serving video config not found, using current candidates", "config_id", *videoGen.ConfigID, "error", err)
 	}
+	return s.getVideoClient(videoGen.Provider, videoGen.Model)
+}
 
+func (s *VideoGenerationService) buildVideoClientFromConfig(config *models.AIServiceConfig, provider string, modelName string) (video.VideoClient, *models.AIServiceConfig, error) {
 	// 使用配置中的信息创建客户端
 	baseURL := config.BaseURL
 	apiKey := config.APIKey
 	model := modelName
-	if model == "" && len(config.Model) > 0 {
+	if (model == "" || !configHasModel(config, model)) && len(config.Model) > 0 {
 		model = config.Model[0]
 	}
 
 	// 根据配置中的 provider 创建对应的客户端
 	var endpoint string
 	var queryEndpoint string
+	var client video.VideoClient
 
 	switch config.Provider {
 	case "chatfire":
 		endpoint = "/video/generations"
 		queryEndpoint = "/video/task/{taskId}"
-		return video.NewChatfireClient(baseURL, apiKey, model, endpoint, queryEndpoint), nil
+		client = video.NewChatfireClient(baseURL, apiKey, model, endpoint, queryEndpoint)
 	case "doubao", "volcengine", "volces":
 		if config.Endpoint != "" {
 			endpoint = config.Endpoint
@@ -494,19 +718,32 @@ func (s *VideoGenerationService) getVideoClient(provider string, modelName strin
 		} else {
 			queryEndpoint = "/api/v3/contents/generations/tasks/{taskId}"
 		}
-		return video.NewVolcesArkClient(baseURL, apiKey, model, endpoint, queryEndpoint), nil
+		client = video.NewVolcesArkClient(baseURL, apiKey, model, endpoint, queryEndpoint)
 	case "openai":
 		// OpenAI Sora 使用 /v1/videos 端点
-		return video.NewOpenAISoraClient(baseURL, apiKey, model), nil
+		client = video.NewOpenAISoraClient(baseURL, apiKey, model)
 	case "runway":
-		return video.NewRunwayClient(baseURL, apiKey, model), nil
+		client = video.NewRunwayClient(baseURL, apiKey, model)
 	case "pika":
-		return video.NewPikaClient(baseURL, apiKey, model), nil
+		client = video.NewPikaClient(baseURL, apiKey, model)
 	case "minimax":
-		return video.NewMinimaxClient(baseURL, apiKey, model), nil
+		client = video.NewMinimaxClient(baseURL, apiKey, model)
+	case "mock":
+		if s.localStorage == nil {
+			return nil, nil, fmt.Errorf("mock video provider requires local storage")
+		}
+		client = video.NewMockVideoClient(s.localStorage.GetPath(mockVideoCategory), s.localStorage.GetURL(mockVideoCategory), model)
+	case "http_adapter", "custom":
+		spec, err := httpadapter.ParseSpec(config.Settings)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid http adapter config: %w", err)
+		}
+		client = video.NewHTTPAdapterClient(baseURL, apiKey, model, config.Endpoint, config.QueryEndpoint, spec)
 	default:
-		return nil, fmt.Errorf("unsupported video provider: %s", provider)
+		return nil, nil, fmt.Errorf("unsupported video provider: %s", provider)
 	}
+
+	return newRateLimitedVideoClient(client, config), config, nil
 }
 
 func (s *VideoGenerationService) RecoverPendingTasks() {
@@ -615,6 +852,13 @@ func (s *VideoGenerationService) BatchGenerateVideosForEpisode(episodeID string)
 		videoGen, err := s.GenerateVideoFromImage(imageGen.ID)
 		if err != nil {
 			s.log.Errorw("Failed to generate video", "storyboard_id", storyboard.ID, "error", err)
+			// 预算用尽时停止整批任务，避免逐个失败
+			if _, ok := IsBudgetExceededError(err); ok {
+				if len(results) == 0 {
+					return nil, err
+				}
+				break
+			}
 			continue
 		}
 
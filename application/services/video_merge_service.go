@@ -12,6 +12,7 @@ import (
 	models "github.com/drama-generator/backend/domain/models"
 	"github.com/drama-generator/backend/infrastructure/external/ffmpeg"
 	"github.com/drama-generator/backend/pkg/config"
+	"github.com/drama-generator/backend/pkg/httpadapter"
 	"github.com/drama-generator/backend/pkg/logger"
 	"github.com/drama-generator/backend/pkg/video"
 	"gorm.io/gorm"
@@ -221,19 +222,23 @@ func (s *VideoMergeService) pollMergeStatus(mergeID uint, client video.VideoClie
 	for i := 0; i < maxAttempts; i++ {
 		time.Sleep(pollInterval)
 
+		var videoMerge models.VideoMerge
+		if err := s.db.Select("id", "status").First(&videoMerge, mergeID).Error; err != nil {
+			s.log.Errorw("Failed to load video merge", "error", err, "id", mergeID)
+			return
+		}
+		if videoMerge.Status != models.VideoMergeStatusProcessing {
+			s.log.Infow("Video merge status changed, stopping poll", "id", mergeID, "status", videoMerge.Status)
+			return
+		}
+
 		result, err := client.GetTaskStatus(taskID)
 		if err != nil {
 			s.log.Errorw("Failed to get merge task status", "error", err, "task_id", taskID)
 			continue
 		}
 
-		if result.Completed {
-			s.completeMerge(mergeID, result)
-			return
-		}
-
-		if result.Error != "" {
-			s.updateMergeError(mergeID, result.Error)
+		if s.applyMergeTaskResult(mergeID, result) {
 			return
 		}
 	}
@@ -303,29 +308,40 @@ func (s *VideoMergeService) getVideoClient(provider string) (video.VideoClient,
 	// 根据配置中的 provider 创建对应的客户端
 	var endpoint string
 	var queryEndpoint string
+	var client video.VideoClient
 
 	switch config.Provider {
 	case "runway":
-		return video.NewRunwayClient(config.BaseURL, config.APIKey, model), nil
+		client = video.NewRunwayClient(config.BaseURL, config.APIKey, model)
 	case "pika":
-		return video.NewPikaClient(config.BaseURL, config.APIKey, model), nil
+		client = video.NewPikaClient(config.BaseURL, config.APIKey, model)
 	case "openai", "sora":
-		return video.NewOpenAISoraClient(config.BaseURL, config.APIKey, model), nil
+		client = video.NewOpenAISoraClient(config.BaseURL, config.APIKey, model)
 	case "minimax":
-		return video.NewMinimaxClient(config.BaseURL, config.APIKey, model), nil
+		client = video.NewMinimaxClient(config.BaseURL, config.APIKey, model)
+	case "mock":
+		client = video.NewMockVideoClient(filepath.Join(s.storagePath, mockVideoCategory), s.baseURL+"/"+mockVideoCategory, model)
+	case "http_adapter", "custom":
+		spec, err := httpadapter.ParseSpec(config.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http adapter config: %w", err)
+		}
+		client = video.NewHTTPAdapterClient(config.BaseURL, config.APIKey, model, config.Endpoint, config.QueryEndpoint, spec)
 	case "chatfire":
 		endpoint = "/video/generations"
 		queryEndpoint = "/video/task/{taskId}"
-		return video.NewChatfireClient(config.BaseURL, config.APIKey, model, endpoint, queryEndpoint), nil
+		client = video.NewChatfireClient(config.BaseURL, config.APIKey, model, endpoint, queryEndpoint)
 	case "doubao", "volces", "ark":
 		endpoint = "/api/v3/contents/generations/tasks"
 		queryEndpoint = "/api/v3/contents/generations/tasks/{taskId}"
-		return video.NewVolcesArkClient(config.BaseURL, config.APIKey, model, endpoint, queryEndpoint), nil
+		client = video.NewVolcesArkClient(config.BaseURL, config.APIKey, model, endpoint, queryEndpoint)
 	default:
 		endpoint = "/contents/generations/tasks"
 		queryEndpoint = "/generations/tasks/{taskId}"
-		return video.NewVolcesArkClient(config.BaseURL, config.APIKey, model, endpoint, queryEndpoint), nil
+		client = video.NewVolcesArkClient(config.BaseURL, config.APIKey, model, endpoint, queryEndpoint)
 	}
+
+	return newRateLimitedVideoClient(client, config), nil
 }
 
 func (s *VideoMergeService) GetMerge(mergeID uint) (*models.VideoMerge, error) {
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"time"
+
+	"gorm.io/datatypes"
 )
 
 type AIServiceConfig struct {
@@ -30,21 +32,37 @@ func (c *AIServiceConfig) TableName() string {
 }
 
 type AIServiceProvider struct {
-	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name        string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"name"`
-	DisplayName string    `gorm:"type:varchar(100);not null" json:"display_name"`
-	ServiceType string    `gorm:"type:varchar(50);not null" json:"service_type"`
-	DefaultURL  string    `gorm:"type:varchar(255)" json:"default_url"`
-	Description string    `gorm:"type:text" json:"description"`
-	IsActive    bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt   time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"not null;autoUpdateTime" json:"updated_at"`
+	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string         `gorm:"type:varchar(100);not null;uniqueIndex" json:"name"`
+	DisplayName  string         `gorm:"type:varchar(100);not null" json:"display_name"`
+	ServiceType  string         `gorm:"type:varchar(50);not null" json:"service_type"`
+	Provider     string         `gorm:"type:varchar(50);index" json:"provider"` // 对应 AIServiceConfig.Provider
+	DefaultURL   string         `gorm:"type:varchar(255)" json:"default_url"`
+	Description  string         `gorm:"type:text" json:"description"`
+	Capabilities datatypes.JSON `gorm:"type:json" json:"capabilities,omitempty"` // ProviderCapabilities
+	IsActive     bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt    time.Time      `gorm:"not null;autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time      `gorm:"not null;autoUpdateTime" json:"updated_at"`
 }
 
 func (p *AIServiceProvider) TableName() string {
 	return "ai_service_providers"
 }
 
+// ProviderCapabilities 服务商支持的生成参数，Models 中的模型级配置覆盖服务商默认值
+type ProviderCapabilities struct {
+	Durations          []int    `json:"durations,omitempty"`    // 支持的离散时长（秒），为空时使用 min/max
+	MinDuration        int      `json:"min_duration,omitempty"` // 秒
+	MaxDuration        int      `json:"max_duration,omitempty"` // 秒
+	AspectRatios       []string `json:"aspect_ratios,omitempty"`
+	Resolutions        []string `json:"resolutions,omitempty"`
+	ImageSizes         []string `json:"image_sizes,omitempty"`
+	ReferenceModes     []string `json:"reference_modes,omitempty"` // none, single, first_last, multiple
+	MaxReferenceImages int      `json:"max_reference_images,omitempty"`
+
+	Models map[string]*ProviderCapabilities `json:"models,omitempty"`
+}
+
 // ModelField 自定义类型，支持字符串或字符串数组
 type ModelField []string
 
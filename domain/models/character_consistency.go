@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// CharacterConsistencyCheck 分镜图片中角色与设定图一致性的审查结果，每次审查整集替换
+type CharacterConsistencyCheck struct {
+	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	EpisodeID    uint           `gorm:"not null;index" json:"episode_id"`
+	StoryboardID uint           `gorm:"not null;index" json:"storyboard_id"`
+	CharacterID  uint           `gorm:"not null;index" json:"character_id"`
+	TaskID       string         `gorm:"type:varchar(36);index" json:"task_id"`
+	ImageURL     string         `gorm:"type:text" json:"image_url"` // 审查时的分镜图片
+	Consistent   bool           `gorm:"not null" json:"consistent"`
+	Score        float64        `json:"score"`                   // 0-10，越高越一致
+	Issues       datatypes.JSON `gorm:"type:json" json:"issues"` // 发型、服装、面部等具体差异
+	CreatedAt    time.Time      `gorm:"not null;autoCreateTime" json:"created_at"`
+}
+
+func (CharacterConsistencyCheck) TableName() string {
+	return "character_consistency_checks"
+}
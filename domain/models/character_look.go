@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// CharacterLook 角色的造型变体（换装、受伤、年龄变化等），生效时覆盖角色的基础外貌与设定图
+type CharacterLook struct {
+	ID              uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	CharacterID     uint           `gorm:"not null;index" json:"character_id"`
+	Name            string         `gorm:"type:varchar(100);not null" json:"name"`
+	Appearance      *string        `gorm:"type:text" json:"appearance"`
+	ImageURL        *string        `gorm:"type:varchar(500)" json:"image_url"` // 造型设定图
+	ReferenceImages datatypes.JSON `gorm:"type:json" json:"reference_images"`
+	SeedValue       *string        `gorm:"type:varchar(100)" json:"seed_value"`
+	CreatedAt       time.Time      `gorm:"not null;autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time      `gorm:"not null;autoUpdateTime" json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (CharacterLook) TableName() string {
+	return "character_looks"
+}
+
+// CharacterLookAssignment 造型的生效范围，EpisodeID 与 StoryboardID 二选一；分镜级指定优先于章节级
+type CharacterLookAssignment struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CharacterID  uint      `gorm:"not null;index" json:"character_id"`
+	LookID       uint      `gorm:"not null;index" json:"look_id"`
+	EpisodeID    *uint     `gorm:"index" json:"episode_id"`
+	StoryboardID *uint     `gorm:"index" json:"storyboard_id"`
+	CreatedAt    time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+}
+
+func (CharacterLookAssignment) TableName() string {
+	return "character_look_assignments"
+}
@@ -16,6 +16,7 @@ type Drama struct {
 	StylePrompt   *string        `gorm:"type:text" json:"style_prompt"` // AI反推或用户自定义的风格提示词
 	ReferenceWork *string        `gorm:"type:varchar(200)" json:"reference_work"` // 风格参考作品（如《七龙珠》）
 	AspectRatio   string         `gorm:"type:varchar(20);default:'16:9'" json:"aspect_ratio"` // 16:9 (横屏) 或 9:16 (竖屏)
+	Language      string         `gorm:"type:varchar(10)" json:"language"`                   // 生成语言（zh/en/ja 等），为空时使用系统默认语言
 	ReferenceImage *string       `gorm:"type:varchar(500)" json:"reference_image"`
 	TotalEpisodes int            `gorm:"default:1" json:"total_episodes"`
 	TotalDuration int            `gorm:"default:0" json:"total_duration"`
@@ -116,6 +117,7 @@ type Storyboard struct {
 	Description      *string        `gorm:"type:text" json:"description"`
 	Duration         int            `gorm:"default:5" json:"duration"`
 	ComposedImage    *string        `gorm:"type:text" json:"composed_image"`
+	SelectedImageID  *uint          `gorm:"column:selected_image_id" json:"selected_image_id"` // 候选模式下选中的图片
 	VideoURL         *string        `gorm:"type:text" json:"video_url"`
 	Status           string         `gorm:"type:varchar(20);default:'pending'" json:"status"`
 	CreatedAt        time.Time      `gorm:"autoCreateTime" json:"created_at"`
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// 可做实体消歧的类型
+const (
+	EntityTypeCharacter = "character"
+	EntityTypeProp      = "prop"
+	EntityTypeScene     = "scene"
+)
+
+// EntityAlias 角色、道具、场景在剧本中的别名（如“李医生”“李伟”），提取时按别名归并到同一实体。
+// 场景以地点作为名称
+type EntityAlias struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	DramaID    uint      `gorm:"not null;uniqueIndex:idx_entity_alias" json:"drama_id"`
+	EntityType string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_entity_alias;index:idx_entity_alias_target" json:"entity_type"`
+	EntityID   uint      `gorm:"not null;index:idx_entity_alias_target" json:"entity_id"`
+	Alias      string    `gorm:"type:varchar(200);not null" json:"alias"`
+	Normalized string    `gorm:"type:varchar(200);not null;uniqueIndex:idx_entity_alias" json:"-"` // 去空白、小写后的别名，用于匹配
+	CreatedAt  time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+}
+
+func (EntityAlias) TableName() string {
+	return "entity_aliases"
+}
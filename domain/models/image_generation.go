@@ -16,6 +16,7 @@ type ImageGeneration struct {
 	ImageType       string                `gorm:"size:20;index;default:'storyboard'" json:"image_type"`
 	FrameType       *string               `gorm:"size:20" json:"frame_type,omitempty"`
 	Provider        string                `gorm:"size:50;not null" json:"provider"`
+	ConfigID        *uint                 `gorm:"index" json:"config_id,omitempty"` // 实际完成生成的服务配置
 	Prompt          string                `gorm:"type:text;not null" json:"prompt"`
 	NegPrompt       *string               `gorm:"column:negative_prompt;type:text" json:"negative_prompt,omitempty"`
 	Model           string                `gorm:"size:100" json:"model"`
@@ -34,6 +35,11 @@ type ImageGeneration struct {
 	Width           *int                  `json:"width,omitempty"`
 	Height          *int                  `json:"height,omitempty"`
 	ReferenceImages datatypes.JSON        `gorm:"type:json" json:"reference_images,omitempty"`
+	CandidateGroup  *string               `gorm:"size:36;index" json:"candidate_group,omitempty"` // 同一次候选生成的兄弟图片共享的分组ID
+	DiscardedAt     *time.Time            `gorm:"index" json:"discarded_at,omitempty"`            // 未被选中进入回收区的时间，清理任务据此删除
+	QualityScore    *float64              `json:"quality_score,omitempty"`                        // 视觉模型评估的综合分（0-10）
+	QualityDetail   datatypes.JSON        `gorm:"type:json" json:"quality_detail,omitempty"`      // 各维度评分明细
+	CallbackToken   *string               `gorm:"type:varchar(64);index" json:"-"`                // 服务商回调地址中的令牌，未注册回调时为空
 	CreatedAt       time.Time             `json:"created_at"`
 	UpdatedAt       time.Time             `json:"updated_at"`
 	CompletedAt     *time.Time            `json:"completed_at,omitempty"`
@@ -64,6 +70,7 @@ const (
 	ProviderOpenAI          ImageProvider = "openai"
 	ProviderMidjourney      ImageProvider = "midjourney"
 	ProviderStableDiffusion ImageProvider = "stable_diffusion"
+	ProviderComfyUI         ImageProvider = "comfyui"
 	ProviderDALLE           ImageProvider = "dalle"
 )
 
@@ -76,3 +83,34 @@ const (
 	ImageTypeProp       ImageType = "prop"       // 道具图片
 	ImageTypeStoryboard ImageType = "storyboard" // 分镜图片
 )
+
+// ImageCandidateGroup 一次候选生成的分组，记录自动选图的设置与进度
+type ImageCandidateGroup struct {
+	ID               string     `gorm:"primaryKey;size:36" json:"id"`
+	StoryboardID     uint       `gorm:"not null;index" json:"storyboard_id"`
+	DramaID          uint       `gorm:"not null;index" json:"drama_id"`
+	Candidates       int        `gorm:"not null" json:"candidates"`
+	AutoSelect       bool       `gorm:"default:false" json:"auto_select"`
+	MinScore         float64    `gorm:"default:0" json:"min_score"`         // 最佳候选低于该分数时重新生成
+	MaxRegenerations int        `gorm:"default:0" json:"max_regenerations"` // 重新生成的轮数上限
+	Regenerations    int        `gorm:"default:0" json:"regenerations"`
+	Status           string     `gorm:"size:20;not null;default:'generating'" json:"status"`
+	SelectedImageID  *uint      `json:"selected_image_id,omitempty"`
+	ErrorMsg         *string    `gorm:"type:text" json:"error_msg,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+}
+
+func (ImageCandidateGroup) TableName() string {
+	return "image_candidate_groups"
+}
+
+const (
+	CandidateGroupGenerating  = "generating"
+	CandidateGroupScoring     = "scoring"
+	CandidateGroupReady       = "ready" // 生成完成，等待手动选择
+	CandidateGroupSelected    = "selected"
+	CandidateGroupNeedsReview = "needs_review" // 重新生成后仍低于阈值，已选出最高分但需人工确认
+	CandidateGroupFailed      = "failed"
+)
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// LLMCacheEntry 文本模型响应缓存，按提示词、模型与调用参数的哈希寻址
+type LLMCacheEntry struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CacheKey  string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"cache_key"`
+	Kind      string    `gorm:"type:varchar(30);not null" json:"kind"` // text, image_description
+	Provider  string    `gorm:"type:varchar(50)" json:"provider"`
+	Model     string    `gorm:"type:varchar(100)" json:"model"`
+	Response  string    `gorm:"type:text;not null" json:"response"`
+	HitCount  int       `gorm:"default:0" json:"hit_count"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime" json:"updated_at"`
+}
+
+func (LLMCacheEntry) TableName() string {
+	return "llm_cache_entries"
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PromptTemplate 提示词模板，按模板键、语言、版本存储；DramaID 不为空时为该剧本的专属覆盖。
+// 同一模板键、语言、剧本下同时只有一个启用版本
+type PromptTemplate struct {
+	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key         string         `gorm:"column:template_key;type:varchar(100);not null;index:idx_prompt_template_lookup" json:"key"`
+	Language    string         `gorm:"type:varchar(10);not null;index:idx_prompt_template_lookup" json:"language"`
+	DramaID     *uint          `gorm:"index:idx_prompt_template_lookup" json:"drama_id,omitempty"`
+	Version     int            `gorm:"not null;default:1" json:"version"`
+	Content     string         `gorm:"type:text;not null" json:"content"`
+	Description string         `gorm:"type:varchar(500)" json:"description,omitempty"`
+	IsActive    bool           `gorm:"default:false;index" json:"is_active"`
+	CreatedAt   time.Time      `gorm:"not null;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time      `gorm:"not null;autoUpdateTime" json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (PromptTemplate) TableName() string {
+	return "prompt_templates"
+}
@@ -0,0 +1,8 @@
+package models
+
+// ReferenceView 从三视图设定图裁切出的单个视图，存放在 Character.ReferenceImages 与 Prop.ReferenceImages 中
+type ReferenceView struct {
+	View      string `json:"view"` // main, front, side, back；手动添加的参考图为空
+	URL       string `json:"url"`
+	SourceURL string `json:"source_url,omitempty"` // 裁切来源的设定图，设定图更换后旧视图不再使用
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SceneLibrary 场景库模型，保存可跨剧本复用的背景图
+type SceneLibrary struct {
+	ID            uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name          string         `gorm:"type:varchar(200);not null" json:"name"`
+	Category      *string        `gorm:"type:varchar(50);index" json:"category"`
+	Location      *string        `gorm:"type:varchar(200)" json:"location"`
+	Time          *string        `gorm:"type:varchar(100)" json:"time"`
+	ImageURL      string         `gorm:"type:varchar(500);not null" json:"image_url"`
+	Prompt        *string        `gorm:"type:text" json:"prompt"`
+	Description   *string        `gorm:"type:text" json:"description"`
+	Tags          *string        `gorm:"type:varchar(500)" json:"tags"`                           // 逗号分隔
+	SourceType    string         `gorm:"type:varchar(20);default:'generated'" json:"source_type"` // generated, uploaded, scene
+	SourceSceneID *uint          `gorm:"index" json:"source_scene_id"`
+	CreatedAt     time.Time      `gorm:"not null;autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"not null;autoUpdateTime" json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (s *SceneLibrary) TableName() string {
+	return "scene_libraries"
+}
@@ -16,6 +16,8 @@ type AsyncTask struct {
 	Error       string         `gorm:"type:text" json:"error,omitempty"`     // 错误信息
 	Result      string         `gorm:"type:text" json:"result,omitempty"`    // JSON格式的结果数据
 	ResourceID  string         `gorm:"size:36;index" json:"resource_id"`     // 关联资源ID（如episode_id）
+	Params      string         `gorm:"type:text" json:"-"`                   // JSON格式的任务参数，重启后重新执行时使用
+	Restarts    int            `gorm:"default:0" json:"restarts,omitempty"`  // 因服务重启而重新执行的次数
 	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 	CompletedAt *time.Time     `json:"completed_at,omitempty"`
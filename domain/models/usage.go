@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+// UsageRecord 服务商调用用量流水（每次调用一条）
+type UsageRecord struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	DramaID     *uint  `gorm:"index" json:"drama_id,omitempty"`
+	EpisodeID   *uint  `gorm:"index" json:"episode_id,omitempty"`
+	ConfigID    *uint  `gorm:"index" json:"config_id,omitempty"`
+	ServiceType string `gorm:"type:varchar(20);not null;index" json:"service_type"` // text, image, video
+	Provider    string `gorm:"type:varchar(50);index" json:"provider"`
+	Model       string `gorm:"type:varchar(100);index" json:"model"`
+	Operation   string `gorm:"type:varchar(50)" json:"operation"` // 业务场景，如 storyboard_generation
+
+	// 文本
+	PromptTokens     int `gorm:"default:0" json:"prompt_tokens"`
+	CompletionTokens int `gorm:"default:0" json:"completion_tokens"`
+	TotalTokens      int `gorm:"default:0" json:"total_tokens"`
+
+	// 图片
+	ImageCount int    `gorm:"default:0" json:"image_count"`
+	ImageSize  string `gorm:"type:varchar(20)" json:"image_size,omitempty"`
+
+	// 视频
+	VideoSeconds int    `gorm:"default:0" json:"video_seconds"`
+	Resolution   string `gorm:"type:varchar(50)" json:"resolution,omitempty"`
+
+	Cost     float64 `gorm:"default:0" json:"cost"`
+	Currency string  `gorm:"type:varchar(10);default:'USD'" json:"currency"`
+	Priced   bool    `gorm:"default:false" json:"priced"` // 是否命中价格表，未命中时 cost 为 0
+
+	ImageGenID *uint `gorm:"index" json:"image_gen_id,omitempty"`
+	VideoGenID *uint `gorm:"index" json:"video_gen_id,omitempty"`
+
+	UsageDate string    `gorm:"type:varchar(10);index" json:"usage_date"` // YYYY-MM-DD，按天汇总使用
+	CreatedAt time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+}
+
+func (UsageRecord) TableName() string {
+	return "usage_records"
+}
+
+// ModelPrice 模型价格表
+type ModelPrice struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ServiceType string `gorm:"type:varchar(20);not null;index" json:"service_type"` // text, image, video
+	Provider    string `gorm:"type:varchar(50)" json:"provider"`                    // 为空表示匹配任意厂商
+	Model       string `gorm:"type:varchar(100);not null;index" json:"model"`
+	Resolution  string `gorm:"type:varchar(50)" json:"resolution"` // 图片尺寸或视频分辨率，为空表示通用价格
+
+	InputPricePerMillion  float64 `gorm:"default:0" json:"input_price_per_million"`  // 每百万输入 token
+	OutputPricePerMillion float64 `gorm:"default:0" json:"output_price_per_million"` // 每百万输出 token
+	PricePerImage         float64 `gorm:"default:0" json:"price_per_image"`
+	PricePerSecond        float64 `gorm:"default:0" json:"price_per_second"` // 视频每秒价格
+
+	Currency  string    `gorm:"type:varchar(10);default:'USD'" json:"currency"`
+	CreatedAt time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime" json:"updated_at"`
+}
+
+func (ModelPrice) TableName() string {
+	return "model_prices"
+}
+
+// Budget 花费预算/配额，软限额触发告警，硬限额阻止新的生成任务
+type Budget struct {
+	ID          uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string  `gorm:"type:varchar(100)" json:"name"`
+	Scope       string  `gorm:"type:varchar(20);not null;index" json:"scope"`   // workspace, drama, config
+	ScopeID     *uint   `gorm:"index" json:"scope_id,omitempty"`                // drama_id 或 config_id，workspace 为空
+	ServiceType string  `gorm:"type:varchar(20)" json:"service_type"`           // 为空表示全部服务类型
+	Metric      string  `gorm:"type:varchar(20);default:'cost'" json:"metric"`  // cost, tokens, images, video_seconds, calls
+	Period      string  `gorm:"type:varchar(20);default:'total'" json:"period"` // total, daily, monthly
+	Currency    string  `gorm:"type:varchar(10);default:'USD'" json:"currency"`
+	SoftLimit   float64 `gorm:"default:0" json:"soft_limit"` // 0 表示不告警
+	HardLimit   float64 `gorm:"default:0" json:"hard_limit"` // 0 表示不阻止
+	IsActive    bool    `gorm:"not null" json:"is_active"`   // 无默认值，避免创建时显式的 false 被数据库默认值覆盖
+
+	LastAlertLevel  string     `gorm:"type:varchar(20)" json:"last_alert_level,omitempty"` // warning, exceeded
+	LastAlertPeriod string     `gorm:"type:varchar(10)" json:"last_alert_period,omitempty"`
+	LastAlertAt     *time.Time `json:"last_alert_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime" json:"updated_at"`
+}
+
+func (Budget) TableName() string {
+	return "budgets"
+}
@@ -21,6 +21,7 @@ type VideoGeneration struct {
 	Provider string `gorm:"type:varchar(50);not null;index" json:"provider"`
 	Prompt   string `gorm:"type:text;not null" json:"prompt"`
 	Model    string `gorm:"type:varchar(100)" json:"model,omitempty"`
+	ConfigID *uint  `gorm:"index" json:"config_id,omitempty"` // 实际完成生成的服务配置
 
 	ImageGenID *uint           `gorm:"index" json:"image_gen_id,omitempty"`
 	ImageGen   ImageGeneration `gorm:"foreignKey:ImageGenID" json:"image_gen,omitempty"`
@@ -48,6 +49,8 @@ type VideoGeneration struct {
 
 	Status VideoStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
 	TaskID *string     `gorm:"type:varchar(200);index" json:"task_id,omitempty"`
+	// 服务商回调校验令牌，仅在注册了回调地址时生成
+	CallbackToken *string `gorm:"type:varchar(64);index" json:"-"`
 
 	ErrorMsg    *string    `gorm:"type:text" json:"error_msg,omitempty"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
@@ -75,6 +75,8 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.Drama{},
 		&models.Episode{},
 		&models.Character{},
+		&models.CharacterLook{},
+		&models.CharacterLookAssignment{},
 		&models.Scene{},
 		&models.Storyboard{},
 		&models.FramePrompt{},
@@ -83,9 +85,12 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.SceneProp{},
 		&models.EpisodeProp{},
 		&models.PropLibrary{},
+		&models.EntityAlias{},
 
 		// 生成相关
 		&models.ImageGeneration{},
+		&models.ImageCandidateGroup{},
+		&models.CharacterConsistencyCheck{},
 		&models.VideoGeneration{},
 		&models.VideoMerge{},
 
@@ -96,8 +101,20 @@ func AutoMigrate(db *gorm.DB) error {
 		// 资源管理
 		&models.Asset{},
 		&models.CharacterLibrary{},
+		&models.SceneLibrary{},
 
 		// 任务管理
 		&models.AsyncTask{},
+
+		// 用量与计费
+		&models.UsageRecord{},
+		&models.ModelPrice{},
+		&models.Budget{},
+
+		// 提示词模板
+		&models.PromptTemplate{},
+
+		// 文本响应缓存
+		&models.LLMCacheEntry{},
 	)
 }
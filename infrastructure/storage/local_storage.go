@@ -58,6 +58,11 @@ func (s *LocalStorage) GetURL(path string) string {
 	return fmt.Sprintf("%s/%s", s.baseURL, path)
 }
 
+// GetPath 返回相对路径对应的本地文件路径
+func (s *LocalStorage) GetPath(path string) string {
+	return filepath.Join(s.basePath, path)
+}
+
 // DownloadFromURL 从远程URL下载文件到本地存储
 func (s *LocalStorage) DownloadFromURL(url, category string) (string, error) {
 	// 发送HTTP请求下载文件
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/drama-generator/backend/api/routes"
+	"github.com/drama-generator/backend/application/services"
 	"github.com/drama-generator/backend/infrastructure/database"
 	"github.com/drama-generator/backend/infrastructure/storage"
 	"github.com/drama-generator/backend/pkg/config"
@@ -41,6 +42,23 @@ func main() {
 	}
 	logr.Info("Database tables migrated successfully")
 
+	// 补全内置服务商能力配置
+	if err := services.NewProviderCapabilityService(db, logr).EnsureDefaults(); err != nil {
+		logr.Warnw("Failed to seed provider capabilities", "error", err)
+	}
+
+	// 写入内置提示词模板
+	if err := services.NewPromptTemplateService(db, logr).EnsureDefaults(); err != nil {
+		logr.Warnw("Failed to seed prompt templates", "error", err)
+	}
+
+	// 清理过期的文本响应缓存
+	if purged, err := services.NewLLMCacheService(db, logr, cfg).PurgeExpired(); err != nil {
+		logr.Warnw("Failed to purge expired LLM cache", "error", err)
+	} else if purged > 0 {
+		logr.Infow("Purged expired LLM cache", "count", purged)
+	}
+
 	// 初始化本地存储
 	var localStorage *storage.LocalStorage
 	if cfg.Storage.Type == "local" {
@@ -51,6 +69,17 @@ func main() {
 		logr.Info("Local storage initialized successfully", "path", cfg.Storage.LocalPath)
 	}
 
+	// 清理回收区中超过保留期的落选候选图
+	imageService := services.NewImageGenerationService(db, cfg, services.NewResourceTransferService(db, logr), localStorage, logr)
+	if purged, err := imageService.PurgeDiscardedImages(services.DiscardedImageRetention); err != nil {
+		logr.Warnw("Failed to purge discarded images", "error", err)
+	} else if purged > 0 {
+		logr.Infow("Purged discarded images", "count", purged)
+	}
+
+	// 收尾上次运行中断的任务
+	services.NewStartupReconciler(db, logr, cfg, localStorage).Run()
+
 	if cfg.App.Debug {
 		gin.SetMode(gin.DebugMode)
 	} else {
@@ -0,0 +1,402 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 8192
+	// 服务端过载（HTTP 529 / overloaded_error）时的重试次数与初始退避
+	anthropicOverloadRetries = 3
+	anthropicOverloadBackoff = 2 * time.Second
+)
+
+// AnthropicClient Anthropic Messages API 客户端
+type AnthropicClient struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	Endpoint   string
+	HTTPClient *http.Client
+	// 过载重试的初始退避，每次翻倍
+	OverloadBackoff time.Duration
+}
+
+type AnthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *AnthropicImageSource `json:"source,omitempty"`
+}
+
+type AnthropicImageSource struct {
+	Type      string `json:"type"` // base64 或 url
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type AnthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []AnthropicContentBlock `json:"content"`
+}
+
+type AnthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []AnthropicMessage `json:"messages"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	TopP        *float64           `json:"top_p,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type AnthropicResponse struct {
+	ID         string                  `json:"id"`
+	Type       string                  `json:"type"`
+	Content    []AnthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      AnthropicUsage          `json:"usage"`
+}
+
+type AnthropicErrorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// AnthropicStreamEvent 流式事件，按 type 区分 message_start / content_block_delta / message_delta / error 等
+type AnthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message *struct {
+		Usage AnthropicUsage `json:"usage"`
+	} `json:"message,omitempty"`
+	Delta *struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta,omitempty"`
+	Usage *AnthropicUsage `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func NewAnthropicClient(baseURL, apiKey, model, endpoint string) *AnthropicClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	if endpoint == "" {
+		endpoint = "/v1/messages"
+	}
+	// 兼容 BaseURL 已包含 /v1 的写法
+	if strings.HasSuffix(baseURL, "/v1") && strings.HasPrefix(endpoint, "/v1/") {
+		endpoint = strings.TrimPrefix(endpoint, "/v1")
+	}
+
+	return &AnthropicClient{
+		BaseURL:  baseURL,
+		APIKey:   apiKey,
+		Model:    model,
+		Endpoint: endpoint,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Minute,
+		},
+		OverloadBackoff: anthropicOverloadBackoff,
+	}
+}
+
+func (c *AnthropicClient) GenerateText(prompt string, systemPrompt string, options ...func(*ChatCompletionRequest)) (string, error) {
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
+	}
+
+	reqBody := c.buildRequest(prompt, systemPrompt, reqOptions)
+	resp, err := c.send(reqBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read response: %w", err)
+	}
+
+	var result AnthropicResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("parse response: %w", err)
+	}
+
+	text := anthropicText(result.Content)
+	if text == "" {
+		return "", fmt.Errorf("no text content in response (stop_reason: %s)", result.StopReason)
+	}
+
+	reportFinish(reqOptions, result.StopReason)
+	if reqOptions.OnUsage != nil {
+		reqOptions.OnUsage(anthropicUsage(result.Usage))
+	}
+	return text, nil
+}
+
+// GenerateTextStream 流式生成，增量内容来自 content_block_delta 事件，用量分别在 message_start 与 message_delta 中给出
+func (c *AnthropicClient) GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ChatCompletionRequest)) (string, error) {
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
+	}
+
+	reqBody := c.buildRequest(prompt, systemPrompt, reqOptions)
+	reqBody.Stream = true
+	resp, err := c.send(reqBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage AnthropicUsage
+	stopReason := ""
+	err = readSSE(resp.Body, func(data string) error {
+		var event AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("parse stream event: %w, data: %s", err, data)
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage.InputTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_delta":
+			if event.Delta != nil && event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				content.WriteString(event.Delta.Text)
+				if onChunk != nil {
+					onChunk(event.Delta.Text)
+				}
+			}
+		case "message_delta":
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				stopReason = event.Delta.StopReason
+			}
+		case "message_stop":
+			return io.EOF
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("anthropic stream error (%s): %s", event.Error.Type, event.Error.Message)
+			}
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("read stream: %w", err)
+	}
+
+	if content.Len() == 0 {
+		return "", fmt.Errorf("no text content in response")
+	}
+	reportFinish(reqOptions, stopReason)
+	if reqOptions.OnUsage != nil {
+		reqOptions.OnUsage(anthropicUsage(usage))
+	}
+	return content.String(), nil
+}
+
+func (c *AnthropicClient) GenerateImage(prompt string, size string, n int, options ...func(*ChatCompletionRequest)) ([]string, error) {
+	return nil, fmt.Errorf("GenerateImage not implemented for Anthropic client")
+}
+
+// GenerateImageDescription data URI 以 base64 图片块发送，http(s) 地址以 url 图片块发送
+func (c *AnthropicClient) GenerateImageDescription(imageURL string, prompt string, options ...func(*ChatCompletionRequest)) (string, error) {
+	if prompt == "" {
+		prompt = "Describe this image in detail, focusing on style, artistic direction, colors, and key elements. The description should be suitable for use as an image generation prompt."
+	}
+
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
+	}
+
+	var content []AnthropicContentBlock
+	for _, url := range append([]string{imageURL}, reqOptions.Images...) {
+		source, err := anthropicImageSource(url)
+		if err != nil {
+			return "", err
+		}
+		content = append(content, AnthropicContentBlock{Type: "image", Source: source})
+	}
+	content = append(content, AnthropicContentBlock{Type: "text", Text: prompt})
+
+	reqBody := &AnthropicRequest{
+		Model:     c.Model,
+		MaxTokens: 1024,
+		Messages: []AnthropicMessage{
+			{
+				Role:    "user",
+				Content: content,
+			},
+		},
+	}
+
+	resp, err := c.send(reqBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result AnthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("parse response: %w", err)
+	}
+	reportFinish(reqOptions, result.StopReason)
+	if reqOptions.OnUsage != nil {
+		reqOptions.OnUsage(anthropicUsage(result.Usage))
+	}
+	return anthropicText(result.Content), nil
+}
+
+func anthropicImageSource(imageURL string) (*AnthropicImageSource, error) {
+	if !strings.HasPrefix(imageURL, "data:") {
+		return &AnthropicImageSource{Type: "url", URL: imageURL}, nil
+	}
+	parts := strings.SplitN(imageURL, ",", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid data URI")
+	}
+	return &AnthropicImageSource{
+		Type:      "base64",
+		MediaType: strings.TrimSuffix(strings.TrimPrefix(parts[0], "data:"), ";base64"),
+		Data:      parts[1],
+	}, nil
+}
+
+func (c *AnthropicClient) TestConnection() error {
+	_, err := c.GenerateText("Hello", "", WithMaxTokens(16))
+	return err
+}
+
+// buildRequest 映射通用选项；max_tokens 为必填，未指定时使用默认值。
+// Messages API 没有 response_format，结构化输出时把 Schema 写进系统提示，由调用方校验结果
+func (c *AnthropicClient) buildRequest(prompt, systemPrompt string, reqOptions *ChatCompletionRequest) *AnthropicRequest {
+	maxTokens := anthropicDefaultMaxTokens
+	if reqOptions.MaxTokens != nil {
+		maxTokens = *reqOptions.MaxTokens
+	} else if reqOptions.MaxCompletionTokens != nil {
+		maxTokens = *reqOptions.MaxCompletionTokens
+	}
+
+	if format := reqOptions.ResponseFormat; format != nil && format.JSONSchema != nil {
+		schema, _ := json.Marshal(format.JSONSchema.Schema)
+		instruction := fmt.Sprintf("Respond with a single JSON value that strictly matches this JSON Schema, without markdown fences or any explanation:\n%s", string(schema))
+		if systemPrompt != "" {
+			systemPrompt += "\n\n" + instruction
+		} else {
+			systemPrompt = instruction
+		}
+	}
+
+	reqBody := &AnthropicRequest{
+		Model:     c.Model,
+		MaxTokens: maxTokens,
+		System:    systemPrompt,
+		Messages: []AnthropicMessage{
+			{Role: "user", Content: []AnthropicContentBlock{{Type: "text", Text: prompt}}},
+		},
+	}
+	if reqOptions.Temperature != nil {
+		// Anthropic 温度范围为 0-1
+		temperature := *reqOptions.Temperature
+		if temperature > 1 {
+			temperature = 1
+		}
+		reqBody.Temperature = &temperature
+	} else if reqOptions.TopP > 0 {
+		// 新模型不允许同时指定 temperature 与 top_p
+		topP := reqOptions.TopP
+		reqBody.TopP = &topP
+	}
+	return reqBody
+}
+
+// send 发送请求，服务端过载时按指数退避重试；返回的响应状态码一定是 200
+func (c *AnthropicClient) send(reqBody *AnthropicRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	backoff := c.OverloadBackoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("POST", c.BaseURL+c.Endpoint, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", c.APIKey)
+		req.Header.Set("anthropic-version", anthropicVersion)
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("send request: %w", err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		apiErr := fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		var errResp AnthropicErrorResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+			apiErr = fmt.Errorf("API error (status %d): %s: %s", resp.StatusCode, errResp.Error.Type, errResp.Error.Message)
+		}
+
+		overloaded := resp.StatusCode == 529 || errResp.Error.Type == "overloaded_error"
+		if !overloaded {
+			return nil, apiErr
+		}
+		if attempt >= anthropicOverloadRetries {
+			return nil, fmt.Errorf("%w (still overloaded after %d retries)", apiErr, attempt)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func anthropicText(blocks []AnthropicContentBlock) string {
+	var text strings.Builder
+	for _, block := range blocks {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String()
+}
+
+func anthropicUsage(usage AnthropicUsage) Usage {
+	return Usage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.InputTokens + usage.OutputTokens,
+	}
+}
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicClientRetriesOverloaded(t *testing.T) {
+	var requests []AnthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "sk-test" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing auth headers: %v", r.Header)
+		}
+		var req AnthropicRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+
+		if len(requests) == 1 {
+			w.WriteHeader(529)
+			w.Write([]byte(`{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`))
+			return
+		}
+		w.Write([]byte(`{"type": "message", "content": [{"type": "text", "text": "第一幕"}], "stop_reason": "end_turn", "usage": {"input_tokens": 12, "output_tokens": 3}}`))
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient(server.URL, "sk-test", "claude-test", "")
+	client.OverloadBackoff = 0
+
+	var usage Usage
+	text, err := client.GenerateText("写剧本", "你是编剧", WithTemperature(0.7),
+		WithJSONSchema("script", JSONSchema{"type": "object"}),
+		WithUsageCallback(func(u Usage) { usage = u }))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "第一幕" || usage.TotalTokens != 15 || len(requests) != 2 {
+		t.Fatalf("unexpected result %q usage %+v requests %d", text, usage, len(requests))
+	}
+
+	req := requests[1]
+	if req.MaxTokens != anthropicDefaultMaxTokens || req.Temperature == nil || *req.Temperature != 0.7 {
+		t.Fatalf("options not mapped: %+v", req)
+	}
+	if !strings.HasPrefix(req.System, "你是编剧") || !strings.Contains(req.System, "JSON Schema") {
+		t.Fatalf("expected schema instruction appended to system prompt, got %q", req.System)
+	}
+}
+
+func TestAnthropicClientReportsExhaustedOverloadRetries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(529)
+		w.Write([]byte(`{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`))
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient(server.URL, "sk-test", "claude-test", "")
+	client.OverloadBackoff = 0
+	_, err := client.GenerateText("写剧本", "")
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") || !strings.Contains(err.Error(), "retries") {
+		t.Fatalf("expected overload error with retry count, got %v", err)
+	}
+	if requests != anthropicOverloadRetries+1 {
+		t.Fatalf("expected %d requests, got %d", anthropicOverloadRetries+1, requests)
+	}
+}
+
+func TestAnthropicClientStreamAndVision(t *testing.T) {
+	var last AnthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = AnthropicRequest{}
+		json.NewDecoder(r.Body).Decode(&last)
+		if last.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: message_start\ndata: {\"type\": \"message_start\", \"message\": {\"usage\": {\"input_tokens\": 5}}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\": \"content_block_delta\", \"delta\": {\"type\": \"text_delta\", \"text\": \"Hello\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\": \"content_block_delta\", \"delta\": {\"type\": \"text_delta\", \"text\": \" world\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\": \"message_delta\", \"delta\": {\"stop_reason\": \"end_turn\"}, \"usage\": {\"output_tokens\": 2}}\n\n" +
+				"event: message_stop\ndata: {\"type\": \"message_stop\"}\n\n"))
+			return
+		}
+		w.Write([]byte(`{"type": "message", "content": [{"type": "text", "text": "a red door"}], "usage": {"input_tokens": 1, "output_tokens": 1}}`))
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient(server.URL+"/v1", "sk-test", "claude-test", "/v1/messages")
+
+	var chunks []string
+	var usage Usage
+	text, err := client.GenerateTextStream("hi", "", func(chunk string) { chunks = append(chunks, chunk) },
+		WithMaxTokens(100), WithUsageCallback(func(u Usage) { usage = u }))
+	if err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if text != "Hello world" || len(chunks) != 2 || usage.PromptTokens != 5 || usage.CompletionTokens != 2 {
+		t.Fatalf("unexpected stream result %q chunks %v usage %+v", text, chunks, usage)
+	}
+	if last.MaxTokens != 100 {
+		t.Fatalf("expected max_tokens 100, got %d", last.MaxTokens)
+	}
+
+	description, err := client.GenerateImageDescription("data:image/jpeg;base64,aGVsbG8=", "")
+	if err != nil || description != "a red door" {
+		t.Fatalf("unexpected description %q, %v", description, err)
+	}
+	source := last.Messages[0].Content[0].Source
+	if source == nil || source.Type != "base64" || source.MediaType != "image/jpeg" || source.Data != "aGVsbG8=" {
+		t.Fatalf("unexpected image block %+v", source)
+	}
+
+	// 附带的图片按顺序排在主图之后
+	if _, err := client.GenerateImageDescription("data:image/jpeg;base64,aGVsbG8=", "对比两张图", WithImages("https://cdn.example.com/sheet.png")); err != nil {
+		t.Fatalf("unexpected description error: %v", err)
+	}
+	content := last.Messages[0].Content
+	if len(content) != 3 || content[1].Source == nil || content[1].Source.Type != "url" || content[1].Source.URL != "https://cdn.example.com/sheet.png" || content[2].Text != "对比两张图" {
+		t.Fatalf("unexpected content blocks %+v", content)
+	}
+}
@@ -1,9 +1,37 @@
 package ai
 
+import "strings"
+
 // AIClient 定义文本生成客户端接口
 type AIClient interface {
 	GenerateText(prompt string, systemPrompt string, options ...func(*ChatCompletionRequest)) (string, error)
-	GenerateImage(prompt string, size string, n int) ([]string, error)
-	GenerateImageDescription(imageURL string, prompt string) (string, error)
+	// GenerateTextStream 流式生成文本，onChunk 按到达顺序接收增量内容，返回值为完整文本
+	GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ChatCompletionRequest)) (string, error)
+	GenerateImage(prompt string, size string, n int, options ...func(*ChatCompletionRequest)) ([]string, error)
+	GenerateImageDescription(imageURL string, prompt string, options ...func(*ChatCompletionRequest)) (string, error)
 	TestConnection() error
 }
+
+// Usage 单次调用的 token 用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// IsTruncated 判断结束原因是否表示输出因达到 token 上限被截断：
+// OpenAI、Ollama 为 length，Anthropic 为 max_tokens，Gemini 为 MAX_TOKENS
+func IsTruncated(finishReason string) bool {
+	switch strings.ToLower(finishReason) {
+	case "length", "max_tokens":
+		return true
+	}
+	return false
+}
+
+// reportFinish 回调服务商返回的结束原因，未返回时不回调
+func reportFinish(req *ChatCompletionRequest, finishReason string) {
+	if req.OnFinish != nil && finishReason != "" {
+		req.OnFinish(finishReason)
+	}
+}
@@ -19,8 +19,14 @@ type GeminiClient struct {
 }
 
 type GeminiTextRequest struct {
-	Contents          []GeminiContent    `json:"contents"`
-	SystemInstruction *GeminiInstruction `json:"systemInstruction,omitempty"`
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiInstruction      `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type GeminiGenerationConfig struct {
+	ResponseMimeType string                 `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]interface{} `json:"responseSchema,omitempty"`
 }
 
 type GeminiContent struct {
@@ -85,40 +91,46 @@ func NewGeminiClient(baseURL, apiKey, model, endpoint string) *GeminiClient {
 	}
 }
 
-func (c *GeminiClient) GenerateImageDescription(imageURL string, prompt string) (string, error) {
-	// Gemini requires parsing the data URI if provided
-	var inlineData *InlineData
-	if strings.HasPrefix(imageURL, "data:") {
-		// Format: data:image/png;base64,......
-		parts := strings.SplitN(imageURL, ",", 2)
-		if len(parts) == 2 {
-			mimeType := strings.TrimSuffix(strings.TrimPrefix(parts[0], "data:"), ";base64")
-			inlineData = &InlineData{
-				MimeType: mimeType,
-				Data:     parts[1],
-			}
-		}
+// geminiInlineData 解析 data:image/png;base64,... 形式的图片，其他格式返回 nil
+func geminiInlineData(imageURL string) *InlineData {
+	if !strings.HasPrefix(imageURL, "data:") {
+		return nil
+	}
+	parts := strings.SplitN(imageURL, ",", 2)
+	if len(parts) != 2 {
+		return nil
 	}
+	return &InlineData{
+		MimeType: strings.TrimSuffix(strings.TrimPrefix(parts[0], "data:"), ";base64"),
+		Data:     parts[1],
+	}
+}
 
-	if inlineData == nil {
-		// If not a data URI, Gemini API (REST) doesn't support direct image URLs easily without File API.
-		// For now, we assume the input is always a data URI for local files.
-		// If it's a remote URL, we might fail or need to download it first.
-		return "", fmt.Errorf("gemini client requires base64 data URI for images")
+func (c *GeminiClient) GenerateImageDescription(imageURL string, prompt string, options ...func(*ChatCompletionRequest)) (string, error) {
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
 	}
 
 	if prompt == "" {
 		prompt = "Describe this image in detail, focusing on style, artistic direction, colors, and key elements."
 	}
 
+	parts := []GeminiPart{{Text: prompt}}
+	for _, url := range append([]string{imageURL}, reqOptions.Images...) {
+		inlineData := geminiInlineData(url)
+		if inlineData == nil {
+			// Gemini REST API 不能直接读取图片地址，调用方需先转换为 data URI
+			return "", fmt.Errorf("gemini client requires base64 data URI for images")
+		}
+		parts = append(parts, GeminiPart{InlineData: inlineData})
+	}
+
 	reqBody := GeminiTextRequest{
 		Contents: []GeminiContent{
 			{
-				Role: "user",
-				Parts: []GeminiPart{
-					{Text: prompt},
-					{InlineData: inlineData},
-				},
+				Role:  "user",
+				Parts: parts,
 			},
 		},
 	}
@@ -156,16 +168,22 @@ func (c *GeminiClient) GenerateImageDescription(imageURL string, prompt string)
 	}
 
 	if len(geminiResp.Candidates) > 0 && len(geminiResp.Candidates[0].Content.Parts) > 0 {
+		reportFinish(reqOptions, geminiResp.Candidates[0].FinishReason)
+		if reqOptions.OnUsage != nil {
+			reqOptions.OnUsage(Usage{
+				PromptTokens:     geminiResp.UsageMetadata.PromptTokenCount,
+				CompletionTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
+				TotalTokens:      geminiResp.UsageMetadata.TotalTokenCount,
+			})
+		}
 		return geminiResp.Candidates[0].Content.Parts[0].Text, nil
 	}
 
 	return "", fmt.Errorf("no response content from gemini")
 }
 
-func (c *GeminiClient) GenerateText(prompt string, systemPrompt string, options ...func(*ChatCompletionRequest)) (string, error) {
-	model := c.Model
-
-	// 构建请求体
+// buildGeminiTextRequest 构建文本请求体，系统提示使用 systemInstruction 字段，结构化输出转换为 responseSchema
+func buildGeminiTextRequest(prompt string, systemPrompt string, options *ChatCompletionRequest) GeminiTextRequest {
 	reqBody := GeminiTextRequest{
 		Contents: []GeminiContent{
 			{
@@ -175,13 +193,33 @@ func (c *GeminiClient) GenerateText(prompt string, systemPrompt string, options
 		},
 	}
 
-	// 使用 systemInstruction 字段处理系统提示
 	if systemPrompt != "" {
 		reqBody.SystemInstruction = &GeminiInstruction{
 			Parts: []GeminiPart{{Text: systemPrompt}},
 		}
 	}
 
+	if options.ResponseFormat != nil && options.ResponseFormat.JSONSchema != nil {
+		reqBody.GenerationConfig = &GeminiGenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   toGeminiSchema(options.ResponseFormat.JSONSchema.Schema),
+		}
+	}
+
+	return reqBody
+}
+
+func (c *GeminiClient) GenerateText(prompt string, systemPrompt string, options ...func(*ChatCompletionRequest)) (string, error) {
+	model := c.Model
+
+	// Gemini 请求格式不同，这里只读取通用选项中的回调、上下文与结构化输出
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
+	}
+
+	reqBody := buildGeminiTextRequest(prompt, systemPrompt, reqOptions)
+
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		fmt.Printf("Gemini: Failed to marshal request: %v\n", err)
@@ -263,10 +301,118 @@ func (c *GeminiClient) GenerateText(prompt string, systemPrompt string, options
 	responseText := result.Candidates[0].Content.Parts[0].Text
 	fmt.Printf("Gemini: Generated text: %s\n", responseText)
 
+	reportFinish(reqOptions, result.Candidates[0].FinishReason)
+	if reqOptions.OnUsage != nil {
+		reqOptions.OnUsage(Usage{
+			PromptTokens:     result.UsageMetadata.PromptTokenCount,
+			CompletionTokens: result.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      result.UsageMetadata.TotalTokenCount,
+		})
+	}
+
 	return responseText, nil
 }
 
-func (c *GeminiClient) GenerateImage(prompt string, size string, n int) ([]string, error) {
+// GenerateTextStream 通过 streamGenerateContent 流式生成文本，每收到一段增量内容调用 onChunk
+func (c *GeminiClient) GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ChatCompletionRequest)) (string, error) {
+	// 自定义端点不是 generateContent 时无法推导流式端点，退回一次性生成
+	if !strings.Contains(c.Endpoint, ":generateContent") {
+		text, err := c.GenerateText(prompt, systemPrompt, options...)
+		if err == nil && onChunk != nil {
+			onChunk(text)
+		}
+		return text, err
+	}
+
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
+	}
+
+	reqBody := buildGeminiTextRequest(prompt, systemPrompt, reqOptions)
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	endpoint := strings.Replace(c.Endpoint, ":generateContent", ":streamGenerateContent", 1)
+	endpoint = strings.ReplaceAll(c.BaseURL+endpoint, "{model}", c.Model)
+	url := fmt.Sprintf("%s?alt=sse&key=%s", endpoint, c.APIKey)
+
+	safeURL := strings.Replace(url, c.APIKey, "***", 1)
+	fmt.Printf("Gemini: Sending stream request to: %s\n", safeURL)
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		fmt.Printf("Gemini: HTTP stream request failed: %v\n", err)
+		return "", fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Gemini: API error (status %d): %s\n", resp.StatusCode, string(body))
+		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var content strings.Builder
+	var usage *Usage
+	finishReason := ""
+	err = readSSE(resp.Body, func(data string) error {
+		var chunk GeminiTextResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("parse stream chunk: %w, data: %s", err, data)
+		}
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			usage = &Usage{
+				PromptTokens:     chunk.UsageMetadata.PromptTokenCount,
+				CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount,
+				TotalTokens:      chunk.UsageMetadata.TotalTokenCount,
+			}
+		}
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+		if chunk.Candidates[0].FinishReason != "" {
+			finishReason = chunk.Candidates[0].FinishReason
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text == "" {
+				continue
+			}
+			content.WriteString(part.Text)
+			if onChunk != nil {
+				onChunk(part.Text)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("read stream: %w", err)
+	}
+
+	fmt.Printf("Gemini: stream finished, content_length=%d\n", content.Len())
+
+	if content.Len() == 0 {
+		return "", fmt.Errorf("no parts in response")
+	}
+
+	reportFinish(reqOptions, finishReason)
+	if reqOptions.OnUsage != nil && usage != nil {
+		reqOptions.OnUsage(*usage)
+	}
+
+	return content.String(), nil
+}
+
+func (c *GeminiClient) GenerateImage(prompt string, size string, n int, options ...func(*ChatCompletionRequest)) ([]string, error) {
 	return nil, fmt.Errorf("GenerateImage not implemented for Gemini client")
 }
 
@@ -0,0 +1,191 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// mockArrayLength 模拟输出中数组的元素个数
+const mockArrayLength = 3
+
+// MockClient 离线文本客户端，不发起网络请求，输出固定且可复现。
+// 带 JSON Schema 的结构化请求按 Schema 生成合法数据；要求 JSON 的普通请求返回帧提示词格式；其余返回纯文本
+type MockClient struct {
+	Model string
+}
+
+func NewMockClient(model string) *MockClient {
+	return &MockClient{Model: model}
+}
+
+func (c *MockClient) GenerateText(prompt string, systemPrompt string, options ...func(*ChatCompletionRequest)) (string, error) {
+	req := c.buildRequest(options)
+	text := c.respond(req, prompt, systemPrompt)
+	c.reportUsage(req, prompt+systemPrompt, text)
+	return text, nil
+}
+
+func (c *MockClient) GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ChatCompletionRequest)) (string, error) {
+	req := c.buildRequest(options)
+	text := c.respond(req, prompt, systemPrompt)
+
+	// 按固定长度切分输出，模拟增量到达
+	if onChunk != nil {
+		runes := []rune(text)
+		for start := 0; start < len(runes); start += 64 {
+			end := start + 64
+			if end > len(runes) {
+				end = len(runes)
+			}
+			onChunk(string(runes[start:end]))
+		}
+	}
+
+	c.reportUsage(req, prompt+systemPrompt, text)
+	return text, nil
+}
+
+func (c *MockClient) GenerateImage(prompt string, size string, n int, options ...func(*ChatCompletionRequest)) ([]string, error) {
+	return nil, fmt.Errorf("GenerateImage not implemented for mock text client")
+}
+
+func (c *MockClient) GenerateImageDescription(imageURL string, prompt string, options ...func(*ChatCompletionRequest)) (string, error) {
+	req := c.buildRequest(options)
+	text := "A placeholder image generated by the mock provider, plain background with centered caption text."
+	if req.ResponseFormat != nil && req.ResponseFormat.JSONSchema != nil {
+		text = c.respond(req, prompt, "")
+	}
+	c.reportUsage(req, prompt, text)
+	return text, nil
+}
+
+func (c *MockClient) TestConnection() error {
+	return nil
+}
+
+func (c *MockClient) buildRequest(options []func(*ChatCompletionRequest)) *ChatCompletionRequest {
+	req := &ChatCompletionRequest{Model: c.Model}
+	for _, option := range options {
+		option(req)
+	}
+	return req
+}
+
+func (c *MockClient) respond(req *ChatCompletionRequest, prompt, systemPrompt string) string {
+	if req.ResponseFormat != nil && req.ResponseFormat.JSONSchema != nil {
+		data, _ := json.Marshal(MockValueForSchema(req.ResponseFormat.JSONSchema.Schema))
+		return string(data)
+	}
+
+	if strings.Contains(strings.ToLower(systemPrompt+prompt), "json") {
+		data, _ := json.Marshal(map[string]string{
+			"prompt":      "mock scene, cinematic lighting, detailed background, " + mockExcerpt(prompt, 40),
+			"description": "Mock frame description",
+		})
+		return string(data)
+	}
+
+	return "Mock response: " + mockExcerpt(prompt, 80)
+}
+
+// reportUsage 按字符数粗略估算 token 用量，保证用量统计链路可被测试覆盖
+func (c *MockClient) reportUsage(req *ChatCompletionRequest, input, output string) {
+	if req.OnUsage == nil {
+		return
+	}
+	promptTokens := len([]rune(input))/4 + 1
+	completionTokens := len([]rune(output))/4 + 1
+	req.OnUsage(Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	})
+}
+
+func mockExcerpt(text string, limit int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) > limit {
+		runes = runes[:limit]
+	}
+	return string(runes)
+}
+
+// MockValueForSchema 按 Schema 生成一份确定的示例数据，结果总能通过 ValidateSchema
+func MockValueForSchema(schema JSONSchema) interface{} {
+	return mockValue(schema, "", 0)
+}
+
+func mockValue(schema JSONSchema, field string, index int) interface{} {
+	typ := ""
+	nullable := false
+	for _, t := range schemaTypes(schema) {
+		if t == "null" {
+			nullable = true
+		} else if typ == "" {
+			typ = t
+		}
+	}
+	// 可空的关联ID无法凭空给出有效值，留空交给调用方处理
+	if nullable && strings.HasSuffix(field, "_id") {
+		return nil
+	}
+
+	switch typ {
+	case "object":
+		obj := map[string]interface{}{}
+		properties, _ := schema["properties"].(JSONSchema)
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if propSchema, ok := properties[name].(JSONSchema); ok {
+				obj[name] = mockValue(propSchema, name, index)
+			}
+		}
+		return obj
+	case "array":
+		items, _ := schema["items"].(JSONSchema)
+		list := make([]interface{}, 0, mockArrayLength)
+		for i := 0; i < mockArrayLength; i++ {
+			list = append(list, mockValue(items, field, i))
+		}
+		return list
+	case "integer":
+		return mockNumber(field, index)
+	case "number":
+		return mockNumber(field, index)
+	case "boolean":
+		return index%2 == 0
+	case "string":
+		return mockString(field, index)
+	}
+	return nil
+}
+
+func mockNumber(field string, index int) int {
+	name := strings.ToLower(field)
+	switch {
+	case strings.Contains(name, "duration"):
+		return 5
+	case strings.Contains(name, "age"):
+		return 20 + index
+	}
+	return index + 1
+}
+
+func mockString(field string, index int) string {
+	name := strings.ToLower(field)
+	switch {
+	case name == "":
+		return fmt.Sprintf("mock %d", index+1)
+	case strings.Contains(name, "time"):
+		return []string{"day", "night", "dusk"}[index%3]
+	case strings.Contains(name, "prompt"):
+		return fmt.Sprintf("mock %s %d, cinematic lighting, detailed, high quality", strings.ReplaceAll(name, "_", " "), index+1)
+	}
+	return fmt.Sprintf("Mock %s %d", strings.ReplaceAll(name, "_", " "), index+1)
+}
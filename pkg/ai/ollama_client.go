@@ -0,0 +1,383 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ModelLister 可以列出服务端已有模型的客户端
+type ModelLister interface {
+	ListModels() ([]string, error)
+}
+
+// OllamaClient Ollama 原生接口客户端（/api/chat、/api/tags），用于本地部署的模型
+type OllamaClient struct {
+	BaseURL    string
+	APIKey     string // 可选，经反向代理鉴权时使用
+	Model      string
+	NumCtx     int // 上下文长度，0 表示使用模型默认值（Ollama 默认较小，长剧本需要调大）
+	HTTPClient *http.Client
+}
+
+type OllamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"` // base64，不带 data URI 前缀
+}
+
+type OllamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"` // 指针区分未设置与显式的 0
+	TopP        float64  `json:"top_p,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	NumCtx      int      `json:"num_ctx,omitempty"`
+}
+
+type OllamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   interface{}     `json:"format,omitempty"` // "json" 或 JSON Schema
+	Options  *OllamaOptions  `json:"options,omitempty"`
+}
+
+type OllamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         OllamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+type OllamaTagsResponse struct {
+	Models []struct {
+		Name       string    `json:"name"`
+		Model      string    `json:"model"`
+		Size       int64     `json:"size"`
+		ModifiedAt time.Time `json:"modified_at"`
+	} `json:"models"`
+}
+
+func NewOllamaClient(baseURL, apiKey, model string) *OllamaClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	// 兼容按 OpenAI 格式填写的 .../v1 地址
+	baseURL = strings.TrimSuffix(baseURL, "/v1")
+
+	return &OllamaClient{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		Model:   model,
+		HTTPClient: &http.Client{
+			// 本地模型首次加载和长文本生成都较慢
+			Timeout: 30 * time.Minute,
+		},
+	}
+}
+
+func (c *OllamaClient) GenerateText(prompt string, systemPrompt string, options ...func(*ChatCompletionRequest)) (string, error) {
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
+	}
+
+	chatReq := c.buildChatRequest(c.textMessages(prompt, systemPrompt), reqOptions, false)
+	resp, err := c.chat(chatReq)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.Message.Content == "" {
+		return "", fmt.Errorf("no content in response (done_reason: %s)", resp.DoneReason)
+	}
+
+	reportFinish(reqOptions, resp.DoneReason)
+	if reqOptions.OnUsage != nil {
+		reqOptions.OnUsage(ollamaUsage(resp))
+	}
+	return resp.Message.Content, nil
+}
+
+// GenerateTextStream Ollama 流式输出为逐行 JSON，最后一行 done=true 时附带用量
+func (c *OllamaClient) GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ChatCompletionRequest)) (string, error) {
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
+	}
+
+	chatReq := c.buildChatRequest(c.textMessages(prompt, systemPrompt), reqOptions, true)
+	resp, err := c.post("/api/chat", chatReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage *Usage
+	doneReason := ""
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk OllamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", fmt.Errorf("parse stream chunk: %w, data: %s", err, string(line))
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if onChunk != nil {
+				onChunk(chunk.Message.Content)
+			}
+		}
+		if chunk.Done {
+			u := ollamaUsage(&chunk)
+			usage = &u
+			doneReason = chunk.DoneReason
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read stream: %w", err)
+	}
+
+	if content.Len() == 0 {
+		return "", fmt.Errorf("no content in response")
+	}
+	reportFinish(reqOptions, doneReason)
+	if reqOptions.OnUsage != nil && usage != nil {
+		reqOptions.OnUsage(*usage)
+	}
+	return content.String(), nil
+}
+
+func (c *OllamaClient) GenerateImage(prompt string, size string, n int, options ...func(*ChatCompletionRequest)) ([]string, error) {
+	return nil, fmt.Errorf("GenerateImage not implemented for Ollama client")
+}
+
+// GenerateImageDescription 使用多模态模型（如 llava、qwen2.5vl）描述图片，图片放在消息的 images 字段
+func (c *OllamaClient) GenerateImageDescription(imageURL string, prompt string, options ...func(*ChatCompletionRequest)) (string, error) {
+	if prompt == "" {
+		prompt = "Describe this image in detail, focusing on style, artistic direction, colors, and key elements. The description should be suitable for use as an image generation prompt."
+	}
+
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
+	}
+
+	var images []string
+	for _, url := range append([]string{imageURL}, reqOptions.Images...) {
+		imageData, err := c.loadImage(url)
+		if err != nil {
+			return "", err
+		}
+		images = append(images, imageData)
+	}
+
+	chatReq := c.buildChatRequest([]OllamaMessage{
+		{Role: "user", Content: prompt, Images: images},
+	}, reqOptions, false)
+	resp, err := c.chat(chatReq)
+	if err != nil {
+		return "", err
+	}
+	reportFinish(reqOptions, resp.DoneReason)
+	if reqOptions.OnUsage != nil {
+		reqOptions.OnUsage(ollamaUsage(resp))
+	}
+	return resp.Message.Content, nil
+}
+
+func (c *OllamaClient) TestConnection() error {
+	models, err := c.ListModels()
+	if err != nil {
+		return err
+	}
+	if c.Model == "" {
+		return nil
+	}
+	for _, name := range models {
+		if name == c.Model || strings.TrimSuffix(name, ":latest") == c.Model {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %s not found on ollama server, run `ollama pull %s` first", c.Model, c.Model)
+}
+
+// ListModels 列出服务端已下载的模型
+func (c *OllamaClient) ListModels() ([]string, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var tags OllamaTagsResponse
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+
+	names := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+func (c *OllamaClient) textMessages(prompt, systemPrompt string) []OllamaMessage {
+	var messages []OllamaMessage
+	if systemPrompt != "" {
+		messages = append(messages, OllamaMessage{Role: "system", Content: systemPrompt})
+	}
+	return append(messages, OllamaMessage{Role: "user", Content: prompt})
+}
+
+// buildChatRequest 把通用选项映射到 Ollama 的 options；max_tokens 与 max_completion_tokens 都对应 num_predict
+func (c *OllamaClient) buildChatRequest(messages []OllamaMessage, reqOptions *ChatCompletionRequest, stream bool) *OllamaChatRequest {
+	opts := &OllamaOptions{
+		Temperature: reqOptions.Temperature,
+		TopP:        reqOptions.TopP,
+		NumCtx:      c.NumCtx,
+	}
+	if reqOptions.MaxTokens != nil {
+		opts.NumPredict = *reqOptions.MaxTokens
+	} else if reqOptions.MaxCompletionTokens != nil {
+		opts.NumPredict = *reqOptions.MaxCompletionTokens
+	}
+
+	chatReq := &OllamaChatRequest{
+		Model:    c.Model,
+		Messages: messages,
+		Stream:   stream,
+		Options:  opts,
+	}
+	if format := reqOptions.ResponseFormat; format != nil {
+		if format.JSONSchema != nil {
+			chatReq.Format = format.JSONSchema.Schema
+		} else if format.Type == "json_object" {
+			chatReq.Format = "json"
+		}
+	}
+	return chatReq
+}
+
+func (c *OllamaClient) chat(chatReq *OllamaChatRequest) (*OllamaChatResponse, error) {
+	resp, err := c.post("/api/chat", chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	var result OllamaChatResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("ollama error: %s", result.Error)
+	}
+	return &result, nil
+}
+
+// post 发送请求，非 200 响应直接转为错误，保持与其他客户端一致的 "API error (status N)" 格式
+func (c *OllamaClient) post(path string, payload interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", c.BaseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+func (c *OllamaClient) setHeaders(req *http.Request) {
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+}
+
+// loadImage 返回图片的 base64 内容，支持 data URI 与 http(s) 地址
+func (c *OllamaClient) loadImage(imageURL string) (string, error) {
+	if strings.HasPrefix(imageURL, "data:") {
+		parts := strings.SplitN(imageURL, ",", 2)
+		if len(parts) != 2 {
+			return "", fmt.Errorf("invalid data URI")
+		}
+		return parts[1], nil
+	}
+
+	if !strings.HasPrefix(imageURL, "http://") && !strings.HasPrefix(imageURL, "https://") {
+		return "", fmt.Errorf("ollama client requires a data URI or http(s) image URL")
+	}
+
+	resp, err := c.HTTPClient.Get(imageURL)
+	if err != nil {
+		return "", fmt.Errorf("download image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download image: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("download image: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func ollamaUsage(resp *OllamaChatResponse) Usage {
+	return Usage{
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+	}
+}
@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOllamaClientChatAndStream(t *testing.T) {
+	var requests []OllamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models": [{"name": "qwen2.5:14b"}, {"name": "llava:latest"}]}`))
+		case "/api/chat":
+			var req OllamaChatRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Fatalf("invalid request body: %v", err)
+			}
+			requests = append(requests, req)
+			if req.Stream {
+				w.Write([]byte("{\"message\": {\"role\": \"assistant\", \"content\": \"你好\"}, \"done\": false}\n"))
+				w.Write([]byte("{\"message\": {\"role\": \"assistant\", \"content\": \"世界\"}, \"done\": false}\n"))
+				w.Write([]byte("{\"message\": {\"role\": \"assistant\", \"content\": \"\"}, \"done\": true, \"prompt_eval_count\": 7, \"eval_count\": 2}\n"))
+				return
+			}
+			w.Write([]byte(`{"message": {"role": "assistant", "content": "{\"ok\": true}"}, "done": true, "prompt_eval_count": 10, "eval_count": 4}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// 按 OpenAI 格式填写的 /v1 地址也能使用
+	client := NewOllamaClient(server.URL+"/v1", "", "qwen2.5:14b")
+	client.NumCtx = 16384
+
+	var usage Usage
+	text, err := client.GenerateText("提取角色", "system",
+		WithMaxTokens(2000), WithTemperature(0.5),
+		WithJSONSchema("characters", JSONSchema{"type": "object"}),
+		WithUsageCallback(func(u Usage) { usage = u }))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != `{"ok": true}` || usage.TotalTokens != 14 {
+		t.Fatalf("unexpected result %q usage %+v", text, usage)
+	}
+
+	req := requests[0]
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+		t.Fatalf("expected system and user messages, got %+v", req.Messages)
+	}
+	if req.Options.NumPredict != 2000 || req.Options.NumCtx != 16384 || req.Options.Temperature == nil || *req.Options.Temperature != 0.5 {
+		t.Fatalf("options not mapped: %+v", req.Options)
+	}
+	if schema, ok := req.Format.(map[string]interface{}); !ok || schema["type"] != "object" {
+		t.Fatalf("expected schema as format, got %#v", req.Format)
+	}
+
+	var chunks []string
+	text, err = client.GenerateTextStream("hi", "", func(chunk string) { chunks = append(chunks, chunk) },
+		WithUsageCallback(func(u Usage) { usage = u }))
+	if err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if text != "你好世界" || len(chunks) != 2 || usage.PromptTokens != 7 {
+		t.Fatalf("unexpected stream result %q chunks %v usage %+v", text, chunks, usage)
+	}
+	if temp := requests[1].Options.Temperature; temp != nil {
+		t.Fatalf("unset temperature should be omitted, got %v", *temp)
+	}
+
+	// 显式的 0 需要发送，否则服务端会使用模型默认温度
+	if _, err := client.GenerateText("固定输出", "", WithTemperature(0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if temp := requests[2].Options.Temperature; temp == nil || *temp != 0 {
+		t.Fatalf("explicit zero temperature should be sent, got %v", temp)
+	}
+
+	if _, err := client.GenerateImageDescription("data:image/png;base64,aGVsbG8=", ""); err != nil {
+		t.Fatalf("unexpected vision error: %v", err)
+	}
+	if images := requests[len(requests)-1].Messages[0].Images; len(images) != 1 || images[0] != "aGVsbG8=" {
+		t.Fatalf("expected raw base64 image in message, got %v", images)
+	}
+
+	models, err := client.ListModels()
+	if err != nil || strings.Join(models, ",") != "qwen2.5:14b,llava:latest" {
+		t.Fatalf("unexpected models %v, %v", models, err)
+	}
+	if err := NewOllamaClient(server.URL, "", "llava").TestConnection(); err != nil {
+		t.Fatalf("model with :latest tag should be found: %v", err)
+	}
+}
@@ -36,13 +36,23 @@ type ImageURL struct {
 }
 
 type ChatCompletionRequest struct {
-	Model               string        `json:"model"`
-	Messages            []ChatMessage `json:"messages"`
-	Temperature         float64       `json:"temperature,omitempty"`
-	MaxTokens           *int          `json:"max_tokens,omitempty"`
-	MaxCompletionTokens *int          `json:"max_completion_tokens,omitempty"`
-	TopP                float64       `json:"top_p,omitempty"`
-	Stream              bool          `json:"stream,omitempty"`
+	Model               string          `json:"model"`
+	Messages            []ChatMessage   `json:"messages"`
+	Temperature         *float64        `json:"temperature,omitempty"` // 指针区分未设置与显式的 0
+	MaxTokens           *int            `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens,omitempty"`
+	TopP                float64         `json:"top_p,omitempty"`
+	Stream              bool            `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat      *ResponseFormat `json:"response_format,omitempty"`
+
+	// 以下字段仅在本地使用，不会发送给服务商
+	Images   []string            `json:"-"` // 图片分析时在主图之后附带的其他图片（data URI 或地址），按顺序发送
+	OnUsage  func(Usage)         `json:"-"` // 调用成功后回调实际 token 用量
+	OnCache  func(hit bool)      `json:"-"` // 经过响应缓存时回调是否命中
+	OnFinish func(reason string) `json:"-"` // 调用成功后回调服务商返回的结束原因，用于识别被截断的输出
+	OnStore  func(commit func()) `json:"-"` // 设置后响应缓存不立即写入，而是把写入函数交给调用方在确认结果可用后执行
+	Metadata map[string]string   `json:"-"` // 调用方附带的上下文信息（如所属剧本、章节）
 }
 
 type ChatCompletionResponse struct {
@@ -104,30 +114,35 @@ func NewOpenAIClient(baseURL, apiKey, model, endpoint string) *OpenAIClient {
 	}
 }
 
-func (c *OpenAIClient) GenerateImageDescription(imageURL string, prompt string) (string, error) {
+func (c *OpenAIClient) GenerateImageDescription(imageURL string, prompt string, options ...func(*ChatCompletionRequest)) (string, error) {
 	if prompt == "" {
 		prompt = "Describe this image in detail, focusing on style, artistic direction, colors, and key elements. The description should be suitable for use as an image generation prompt."
 	}
 
+	reqOptions := &ChatCompletionRequest{}
+	for _, option := range options {
+		option(reqOptions)
+	}
+	content := []ContentPart{
+		{
+			Type: "text",
+			Text: prompt,
+		},
+	}
+	for _, url := range append([]string{imageURL}, reqOptions.Images...) {
+		content = append(content, ContentPart{
+			Type:     "image_url",
+			ImageURL: &ImageURL{URL: url},
+		})
+	}
 	messages := []ChatMessage{
 		{
-			Role: "user",
-			Content: []ContentPart{
-				{
-					Type: "text",
-					Text: prompt,
-				},
-				{
-					Type: "image_url",
-					ImageURL: &ImageURL{
-						URL: imageURL,
-					},
-				},
-			},
+			Role:    "user",
+			Content: content,
 		},
 	}
 
-	resp, err := c.ChatCompletion(messages)
+	resp, err := c.ChatCompletion(messages, options...)
 	if err != nil {
 		return "", err
 	}
@@ -270,12 +285,21 @@ func (c *OpenAIClient) doChatRequest(req *ChatCompletionRequest) (*ChatCompletio
 		}
 	}
 
+	reportFinish(req, chatResp.Choices[0].FinishReason)
+	if req.OnUsage != nil {
+		req.OnUsage(Usage{
+			PromptTokens:     chatResp.Usage.PromptTokens,
+			CompletionTokens: chatResp.Usage.CompletionTokens,
+			TotalTokens:      chatResp.Usage.TotalTokens,
+		})
+	}
+
 	return &chatResp, nil
 }
 
 func WithTemperature(temp float64) func(*ChatCompletionRequest) {
 	return func(req *ChatCompletionRequest) {
-		req.Temperature = temp
+		req.Temperature = &temp
 	}
 }
 
@@ -291,6 +315,70 @@ func WithTopP(topP float64) func(*ChatCompletionRequest) {
 	}
 }
 
+// WithUsageCallback 注册用量回调，多次注册时按顺序依次调用
+func WithUsageCallback(fn func(Usage)) func(*ChatCompletionRequest) {
+	return func(req *ChatCompletionRequest) {
+		prev := req.OnUsage
+		req.OnUsage = func(usage Usage) {
+			if prev != nil {
+				prev(usage)
+			}
+			fn(usage)
+		}
+	}
+}
+
+// WithCacheCallback 注册缓存命中回调，多次注册时按顺序依次调用
+func WithCacheCallback(fn func(hit bool)) func(*ChatCompletionRequest) {
+	return func(req *ChatCompletionRequest) {
+		prev := req.OnCache
+		req.OnCache = func(hit bool) {
+			if prev != nil {
+				prev(hit)
+			}
+			fn(hit)
+		}
+	}
+}
+
+// WithFinishCallback 注册结束原因回调，多次注册时按顺序依次调用
+func WithFinishCallback(fn func(reason string)) func(*ChatCompletionRequest) {
+	return func(req *ChatCompletionRequest) {
+		prev := req.OnFinish
+		req.OnFinish = func(reason string) {
+			if prev != nil {
+				prev(reason)
+			}
+			fn(reason)
+		}
+	}
+}
+
+// WithCacheCommit 延迟写入响应缓存：未命中缓存时 fn 收到写入函数，调用方校验结果通过后再执行；
+// 不执行则本次结果不会被缓存
+func WithCacheCommit(fn func(commit func())) func(*ChatCompletionRequest) {
+	return func(req *ChatCompletionRequest) {
+		req.OnStore = fn
+	}
+}
+
+// WithImages 图片分析时附带更多图片，例如把角色设定图与待评估的画面一起发送
+func WithImages(images ...string) func(*ChatCompletionRequest) {
+	return func(req *ChatCompletionRequest) {
+		req.Images = append(req.Images, images...)
+	}
+}
+
+// WithMetadata 附加调用上下文信息，不会发送给服务商
+func WithMetadata(key, value string) func(*ChatCompletionRequest) {
+	return func(req *ChatCompletionRequest) {
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]string)
+		}
+		req.Metadata[key] = value
+	}
+}
+
 func (c *OpenAIClient) GenerateText(prompt string, systemPrompt string, options ...func(*ChatCompletionRequest)) (string, error) {
 	messages := []ChatMessage{}
 
@@ -318,7 +406,158 @@ func (c *OpenAIClient) GenerateText(prompt string, systemPrompt string, options
 	return resp.Choices[0].Message.Content, nil
 }
 
-func (c *OpenAIClient) GenerateImage(prompt string, size string, n int) ([]string, error) {
+// GenerateTextStream 以流式方式生成文本，每收到一段增量内容调用 onChunk，返回完整文本
+func (c *OpenAIClient) GenerateTextStream(prompt string, systemPrompt string, onChunk func(string), options ...func(*ChatCompletionRequest)) (string, error) {
+	messages := []ChatMessage{}
+
+	if systemPrompt != "" {
+		messages = append(messages, ChatMessage{
+			Role:    "system",
+			Content: systemPrompt,
+		})
+	}
+
+	messages = append(messages, ChatMessage{
+		Role:    "user",
+		Content: prompt,
+	})
+
+	req := &ChatCompletionRequest{
+		Model:    c.Model,
+		Messages: messages,
+	}
+	for _, option := range options {
+		option(req)
+	}
+	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
+
+	text, err := c.doChatStreamRequest(req, onChunk)
+	if err != nil && shouldRetryWithMaxCompletionTokens(err, req) {
+		tokens := *req.MaxTokens
+		retryReq := *req
+		retryReq.MaxTokens = nil
+		retryReq.MaxCompletionTokens = &tokens
+		fmt.Printf("OpenAI: retrying stream with max_completion_tokens=%d\n", tokens)
+		return c.doChatStreamRequest(&retryReq, onChunk)
+	}
+	return text, err
+}
+
+func (c *OpenAIClient) doChatStreamRequest(req *ChatCompletionRequest, onChunk func(string)) (string, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := c.BaseURL + c.Endpoint
+	fmt.Printf("OpenAI: Sending stream request to: %s, Model=%s\n", url, c.Model)
+
+	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		fmt.Printf("OpenAI: HTTP stream request failed: %v\n", err)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) || strings.Contains(strings.ToLower(err.Error()), "no such host") {
+			return "", fmt.Errorf("无法解析域名，当前AI配置的BaseURL可能不可用：%s，请检查网络或在AI配置中更换可用的BaseURL和API Key: %w", c.BaseURL, err)
+		}
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Printf("OpenAI: API error (status %d): %s\n", resp.StatusCode, string(body))
+		var errResp ErrorResponse
+		if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error.Message == "" {
+			return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		}
+		return "", fmt.Errorf("API error: %s", errResp.Error.Message)
+	}
+
+	// 部分兼容服务商忽略 stream 参数直接返回完整 JSON
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to read response: %w", err)
+		}
+		var chatResp ChatCompletionResponse
+		if err := json.Unmarshal(body, &chatResp); err == nil && len(chatResp.Choices) > 0 {
+			content := chatResp.Choices[0].Message.Content
+			if onChunk != nil && content != "" {
+				onChunk(content)
+			}
+			if req.OnUsage != nil {
+				req.OnUsage(Usage{
+					PromptTokens:     chatResp.Usage.PromptTokens,
+					CompletionTokens: chatResp.Usage.CompletionTokens,
+					TotalTokens:      chatResp.Usage.TotalTokens,
+				})
+			}
+			return content, nil
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	var content strings.Builder
+	var usage *Usage
+	finishReason := ""
+	err = readSSE(resp.Body, func(data string) error {
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to unmarshal stream chunk: %w, data: %s", err, data)
+		}
+		if chunk.Usage != nil {
+			usage = &Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finishReason = *choice.FinishReason
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if onChunk != nil {
+				onChunk(choice.Delta.Content)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	fmt.Printf("OpenAI: stream finished, finish_reason=%s, content_length=%d\n", finishReason, content.Len())
+
+	if finishReason == "content_filter" {
+		return "", fmt.Errorf("AI内容被安全过滤器拦截，可能因为：\n1. 请求内容触发了安全策略\n2. 生成的内容包含敏感信息\n3. 建议：调整输入内容或联系API提供商调整过滤策略")
+	}
+	if content.Len() == 0 {
+		return "", fmt.Errorf("AI返回内容为空 (finish_reason: %s)，可能的原因：\n1. 内容被过滤\n2. Token限制\n3. API异常", finishReason)
+	}
+
+	reportFinish(req, finishReason)
+	if req.OnUsage != nil && usage != nil {
+		req.OnUsage(*usage)
+	}
+
+	return content.String(), nil
+}
+
+func (c *OpenAIClient) GenerateImage(prompt string, size string, n int, options ...func(*ChatCompletionRequest)) ([]string, error) {
 	// 图片生成端点通常是 /v1/images/generations
 	// 如果 c.Endpoint 是 chat 端点，我们需要将其替换
 	// 这是一个简单的处理逻辑，实际可能需要更复杂的配置
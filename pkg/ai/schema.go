@@ -0,0 +1,264 @@
+package ai
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JSONSchema 结构化输出使用的 JSON Schema（OpenAI 格式，Gemini 请求时再转换）
+type JSONSchema map[string]interface{}
+
+// ResponseFormat OpenAI response_format 参数
+type ResponseFormat struct {
+	Type       string              `json:"type"`
+	JSONSchema *ResponseJSONSchema `json:"json_schema,omitempty"`
+}
+
+type ResponseJSONSchema struct {
+	Name   string     `json:"name"`
+	Schema JSONSchema `json:"schema"`
+	Strict bool       `json:"strict,omitempty"`
+}
+
+// WithJSONSchema 要求模型按给定 Schema 输出 JSON
+func WithJSONSchema(name string, schema JSONSchema) func(*ChatCompletionRequest) {
+	return func(req *ChatCompletionRequest) {
+		req.ResponseFormat = &ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &ResponseJSONSchema{Name: name, Schema: schema},
+		}
+	}
+}
+
+// SchemaFor 根据 Go 类型生成 JSON Schema。
+// 字段名取 json 标签；带 omitempty 的字段与指针字段为可选，其余为必填；
+// 指针、切片与 map 字段允许 null（与 encoding/json 对 nil 值的处理一致）；
+// 标签 schema:"-" 的字段只在本地使用，不出现在 Schema 中
+func SchemaFor(v interface{}) JSONSchema {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return JSONSchema{}
+	}
+	return schemaForType(t)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaForType(t reflect.Type) JSONSchema {
+	if t.Kind() == reflect.Ptr {
+		schema := schemaForType(t.Elem())
+		if typ, ok := schema["type"].(string); ok {
+			schema["type"] = []string{typ, "null"}
+		}
+		return schema
+	}
+	if t == timeType {
+		return JSONSchema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Bool:
+		return JSONSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return JSONSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string"}
+		}
+		return JSONSchema{"type": "array", "items": schemaForType(t.Elem())}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": schemaForType(t.Elem())}
+	case reflect.Struct:
+		properties := JSONSchema{}
+		required := []string{}
+		collectStructFields(t, properties, &required)
+		return JSONSchema{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return JSONSchema{}
+}
+
+func collectStructFields(t reflect.Type, properties JSONSchema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("schema") == "-" {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// 匿名嵌入的结构体字段展开到外层
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				collectStructFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := schemaForType(field.Type)
+		if kind := field.Type.Kind(); kind == reflect.Slice || kind == reflect.Map {
+			if typ, ok := prop["type"].(string); ok && typ != "string" {
+				prop["type"] = []string{typ, "null"}
+			}
+		}
+		properties[name] = prop
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// ValidateSchema 校验 json.Unmarshal 到 interface{} 后的数据是否符合 Schema，返回全部错误
+func ValidateSchema(schema JSONSchema, data interface{}) []string {
+	var errs []string
+	validateValue(schema, data, "$", &errs)
+	return errs
+}
+
+func validateValue(schema JSONSchema, data interface{}, path string, errs *[]string) {
+	types := schemaTypes(schema)
+	if len(types) == 0 {
+		return
+	}
+
+	actual := jsonTypeOf(data)
+	matched := ""
+	for _, typ := range types {
+		if typ == actual || (typ == "number" && actual == "integer") {
+			matched = typ
+			break
+		}
+	}
+	if matched == "" {
+		*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), actual))
+		return
+	}
+
+	switch matched {
+	case "object":
+		obj := data.(map[string]interface{})
+		properties, _ := schema["properties"].(JSONSchema)
+		for _, name := range schemaRequired(schema) {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s: missing required field %q", path, name))
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if propSchema, ok := properties[key].(JSONSchema); ok {
+				validateValue(propSchema, obj[key], path+"."+key, errs)
+			} else if additional, ok := schema["additionalProperties"].(JSONSchema); ok {
+				validateValue(additional, obj[key], path+"."+key, errs)
+			}
+		}
+	case "array":
+		items, ok := schema["items"].(JSONSchema)
+		if !ok {
+			return
+		}
+		for i, item := range data.([]interface{}) {
+			validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func schemaTypes(schema JSONSchema) []string {
+	switch typ := schema["type"].(type) {
+	case string:
+		return []string{typ}
+	case []string:
+		return typ
+	}
+	return nil
+}
+
+func schemaRequired(schema JSONSchema) []string {
+	required, _ := schema["required"].([]string)
+	return required
+}
+
+func jsonTypeOf(data interface{}) string {
+	switch v := data.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", data)
+}
+
+// toGeminiSchema 转换为 Gemini responseSchema 使用的 OpenAPI 子集：
+// 类型名大写，可空类型改用 nullable，去掉不支持的 additionalProperties
+func toGeminiSchema(schema JSONSchema) map[string]interface{} {
+	result := map[string]interface{}{}
+	types := schemaTypes(schema)
+	for _, typ := range types {
+		if typ == "null" {
+			result["nullable"] = true
+			continue
+		}
+		if _, ok := result["type"]; !ok {
+			result["type"] = strings.ToUpper(typ)
+		}
+	}
+	if properties, ok := schema["properties"].(JSONSchema); ok {
+		converted := map[string]interface{}{}
+		for name, prop := range properties {
+			if propSchema, ok := prop.(JSONSchema); ok {
+				converted[name] = toGeminiSchema(propSchema)
+			}
+		}
+		result["properties"] = converted
+	}
+	if required := schemaRequired(schema); len(required) > 0 {
+		result["required"] = required
+	}
+	if items, ok := schema["items"].(JSONSchema); ok {
+		result["items"] = toGeminiSchema(items)
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// StreamOptions OpenAI 流式请求选项
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatCompletionChunk OpenAI 流式响应中的单个分片
+type ChatCompletionChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// readSSE 逐条读取 Server-Sent Events 的 data 字段，遇到 [DONE] 结束
+func readSSE(body io.Reader, onData func(data string) error) error {
+	reader := bufio.NewReader(body)
+	var data strings.Builder
+
+	flush := func() error {
+		if data.Len() == 0 {
+			return nil
+		}
+		payload := data.String()
+		data.Reset()
+		if payload == "[DONE]" {
+			return io.EOF
+		}
+		return onData(payload)
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case line == "":
+				if flushErr := flush(); flushErr != nil {
+					if flushErr == io.EOF {
+						return nil
+					}
+					return flushErr
+				}
+			case strings.HasPrefix(line, "data:"):
+				if data.Len() > 0 {
+					data.WriteString("\n")
+				}
+				data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				return err
+			}
+			// 最后一个事件后可能没有空行
+			if flushErr := flush(); flushErr != nil && flushErr != io.EOF {
+				return flushErr
+			}
+			return nil
+		}
+	}
+}
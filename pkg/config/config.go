@@ -28,6 +28,8 @@ type ServerConfig struct {
 	CORSOrigins  []string `mapstructure:"cors_origins"`
 	ReadTimeout  int      `mapstructure:"read_timeout"`
 	WriteTimeout int      `mapstructure:"write_timeout"`
+	// 服务商回调使用的外部可访问地址，例如 https://drama.example.com，为空时不注册回调，仅轮询
+	PublicURL string `mapstructure:"public_url"`
 }
 
 type DatabaseConfig struct {
@@ -53,6 +55,8 @@ type AIConfig struct {
 	DefaultTextProvider  string `mapstructure:"default_text_provider"`
 	DefaultImageProvider string `mapstructure:"default_image_provider"`
 	DefaultVideoProvider string `mapstructure:"default_video_provider"`
+	// 文本响应缓存有效期（小时），0 使用默认值，小于 0 关闭缓存
+	LLMCacheTTLHours int `mapstructure:"llm_cache_ttl_hours"`
 }
 
 type StyleConfig struct {
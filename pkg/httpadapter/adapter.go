@@ -0,0 +1,485 @@
+// Package httpadapter 声明式 HTTP 服务商适配器：请求体模板、任务ID/状态/结果/错误的取值路径
+// 全部来自配置，新接入的异步生成服务只需填写 AIServiceConfig.Settings 即可使用
+package httpadapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 统一的任务状态，status_map 的取值必须是其中之一
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+)
+
+// Request 一次 HTTP 调用的描述。Path 与 Headers 中可使用 {{变量}} 占位符，
+// 查询路径同时兼容现有配置的 {taskId} 写法
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// Spec 适配器配置，对应 Settings 中的 "http_adapter" 字段，例如：
+//
+//	{"http_adapter": {
+//	  "submit": {"method": "POST", "path": "/v1/videos", "body": {"model": "{{model}}", "prompt": "{{prompt}}", "image": "{{image_url}}", "duration": "{{duration}}"}},
+//	  "query": {"method": "GET", "path": "/v1/videos/{{task_id}}"},
+//	  "task_id_path": "data.task_id",
+//	  "status_path": "data.status",
+//	  "status_map": {"SUCCEED": "completed", "FAILED": "failed", "QUEUED": "pending"},
+//	  "result_url_path": "data.result.videos.0.url",
+//	  "error_path": "data.fail_reason"
+//	}}
+//
+// 路径使用点号分隔，数字段表示数组下标。模板中整个字符串恰好为一个占位符时保留变量的原始类型，
+// 变量为空时该字段会被省略；其余占位符按文本替换
+type Spec struct {
+	Submit        Request           `json:"submit"`
+	Query         Request           `json:"query"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	AuthHeader    string            `json:"auth_header,omitempty"` // 默认 Authorization，设为 "-" 表示不发送
+	AuthScheme    *string           `json:"auth_scheme,omitempty"` // 默认 Bearer
+	TaskIDPath    string            `json:"task_id_path"`
+	StatusPath    string            `json:"status_path"`
+	StatusMap     map[string]string `json:"status_map,omitempty"`
+	ResultURLPath string            `json:"result_url_path"`
+	ErrorPath     string            `json:"error_path,omitempty"`
+}
+
+// TaskResult 按 Spec 从响应中提取的结果
+type TaskResult struct {
+	TaskID    string
+	Status    string
+	ResultURL string
+	Error     string
+	Completed bool
+}
+
+// ParseSpec 从 Settings JSON 中读取 "http_adapter" 配置并校验必填项
+func ParseSpec(settings string) (*Spec, error) {
+	if strings.TrimSpace(settings) == "" {
+		return nil, fmt.Errorf("http_adapter settings are required")
+	}
+	var wrapper struct {
+		HTTPAdapter *Spec `json:"http_adapter"`
+	}
+	if err := json.Unmarshal([]byte(settings), &wrapper); err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	if wrapper.HTTPAdapter == nil {
+		return nil, fmt.Errorf("http_adapter settings are required")
+	}
+	spec := wrapper.HTTPAdapter
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// UsesCallback 提交请求是否引用了 {{callback_url}}，即服务商会在任务结束时回调
+func (s *Spec) UsesCallback() bool {
+	const placeholder = "{{callback_url}}"
+	return strings.Contains(s.Submit.Path, placeholder) || strings.Contains(string(s.Submit.Body), placeholder)
+}
+
+// Validate 检查配置完整性：必须能拿到结果地址，异步任务还需要任务ID与查询地址
+func (s *Spec) Validate() error {
+	if s.ResultURLPath == "" {
+		return fmt.Errorf("http_adapter.result_url_path is required")
+	}
+	if len(s.Submit.Body) > 0 && !json.Valid(s.Submit.Body) {
+		return fmt.Errorf("http_adapter.submit.body is not valid JSON")
+	}
+	if s.TaskIDPath != "" && s.StatusPath == "" {
+		return fmt.Errorf("http_adapter.status_path is required for async tasks")
+	}
+	for raw, mapped := range s.StatusMap {
+		switch mapped {
+		case StatusPending, StatusProcessing, StatusCompleted, StatusFailed:
+		default:
+			return fmt.Errorf("http_adapter.status_map[%q]: unknown status %q", raw, mapped)
+		}
+	}
+	return nil
+}
+
+// Client 按 Spec 发送请求并解析响应
+type Client struct {
+	BaseURL       string
+	APIKey        string
+	Endpoint      string // submit.path 为空时使用
+	QueryEndpoint string // query.path 为空时使用
+	Spec          *Spec
+	HTTPClient    *http.Client
+}
+
+func NewClient(baseURL, apiKey, endpoint, queryEndpoint string, spec *Spec) *Client {
+	return &Client{
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		APIKey:        apiKey,
+		Endpoint:      endpoint,
+		QueryEndpoint: queryEndpoint,
+		Spec:          spec,
+		HTTPClient: &http.Client{
+			Timeout: 180 * time.Second,
+		},
+	}
+}
+
+// Submit 渲染提交模板并发送；同步返回结果地址的服务直接得到已完成的结果
+func (c *Client) Submit(vars map[string]interface{}) (*TaskResult, error) {
+	path := c.Spec.Submit.Path
+	if path == "" {
+		path = c.Endpoint
+	}
+	if path == "" {
+		return nil, fmt.Errorf("http_adapter.submit.path is required")
+	}
+
+	data, err := c.do(c.Spec.Submit, "POST", path, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	result := c.extract(data)
+	if result.Completed || result.Status == StatusFailed {
+		if result.Status == StatusFailed {
+			return nil, fmt.Errorf("provider error: %s", result.Error)
+		}
+		return result, nil
+	}
+	if result.TaskID == "" {
+		return nil, fmt.Errorf("no task id at %q in response", c.Spec.TaskIDPath)
+	}
+	return result, nil
+}
+
+// Query 查询异步任务状态
+func (c *Client) Query(taskID string, vars map[string]interface{}) (*TaskResult, error) {
+	path := c.Spec.Query.Path
+	if path == "" {
+		path = c.QueryEndpoint
+	}
+	if path == "" {
+		return nil, fmt.Errorf("http_adapter.query.path is required")
+	}
+	path = strings.ReplaceAll(path, "{taskId}", taskID)
+
+	queryVars := make(map[string]interface{}, len(vars)+1)
+	for k, v := range vars {
+		queryVars[k] = v
+	}
+	queryVars["task_id"] = taskID
+
+	data, err := c.do(c.Spec.Query, "GET", path, queryVars)
+	if err != nil {
+		return nil, err
+	}
+
+	result := c.extract(data)
+	if result.TaskID == "" {
+		result.TaskID = taskID
+	}
+	return result, nil
+}
+
+func (c *Client) do(spec Request, defaultMethod, path string, vars map[string]interface{}) (interface{}, error) {
+	vars = withAPIKey(vars, c.APIKey)
+
+	method := strings.ToUpper(spec.Method)
+	if method == "" {
+		method = defaultMethod
+	}
+
+	var payload io.Reader
+	if len(spec.Body) > 0 {
+		var body interface{}
+		if err := json.Unmarshal(spec.Body, &body); err != nil {
+			return nil, fmt.Errorf("invalid body template: %w", err)
+		}
+		jsonData, err := json.Marshal(Render(body, vars))
+		if err != nil {
+			return nil, fmt.Errorf("marshal request: %w", err)
+		}
+		payload = bytes.NewBuffer(jsonData)
+	}
+
+	endpoint := c.BaseURL + renderURLPath(path, vars)
+	req, err := http.NewRequest(method, endpoint, payload)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.setAuth(req)
+	for key, value := range c.Spec.Headers {
+		req.Header.Set(key, renderString(value, vars))
+	}
+	for key, value := range spec.Headers {
+		req.Header.Set(key, renderString(value, vars))
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return data, nil
+}
+
+func (c *Client) setAuth(req *http.Request) {
+	header := c.Spec.AuthHeader
+	if header == "-" || c.APIKey == "" {
+		return
+	}
+	if header == "" {
+		header = "Authorization"
+	}
+	scheme := "Bearer"
+	if c.Spec.AuthScheme != nil {
+		scheme = *c.Spec.AuthScheme
+	}
+	if scheme == "" {
+		req.Header.Set(header, c.APIKey)
+		return
+	}
+	req.Header.Set(header, scheme+" "+c.APIKey)
+}
+
+func (c *Client) extract(data interface{}) *TaskResult {
+	result := &TaskResult{
+		TaskID:    stringAt(data, c.Spec.TaskIDPath),
+		ResultURL: stringAt(data, c.Spec.ResultURLPath),
+		Error:     stringAt(data, c.Spec.ErrorPath),
+	}
+
+	rawStatus := stringAt(data, c.Spec.StatusPath)
+	result.Status = c.mapStatus(rawStatus)
+	if result.Status == StatusCompleted && result.ResultURL == "" {
+		// 状态为完成但没有结果地址，视为失败，避免无限轮询
+		result.Status = StatusFailed
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("no result url at %q in response", c.Spec.ResultURLPath)
+		}
+	}
+	// 未配置状态路径的同步接口：拿到结果地址即完成
+	if c.Spec.StatusPath == "" && result.ResultURL != "" {
+		result.Status = StatusCompleted
+	}
+	if result.Status == StatusFailed && result.Error == "" {
+		result.Error = "task failed with status " + rawStatus
+	}
+	result.Completed = result.Status == StatusCompleted
+	return result
+}
+
+func (c *Client) mapStatus(raw string) string {
+	if raw == "" {
+		return StatusProcessing
+	}
+	if mapped, ok := c.Spec.StatusMap[raw]; ok {
+		return mapped
+	}
+	switch strings.ToLower(raw) {
+	case "completed", "complete", "succeeded", "success", "succeed", "done", "finished":
+		return StatusCompleted
+	case "failed", "failure", "fail", "error", "cancelled", "canceled", "expired":
+		return StatusFailed
+	case "pending", "queued", "queueing", "submitted", "waiting":
+		return StatusPending
+	}
+	return StatusProcessing
+}
+
+func withAPIKey(vars map[string]interface{}, apiKey string) map[string]interface{} {
+	if _, ok := vars["api_key"]; ok {
+		return vars
+	}
+	merged := make(map[string]interface{}, len(vars)+1)
+	for k, v := range vars {
+		merged[k] = v
+	}
+	merged["api_key"] = apiKey
+	return merged
+}
+
+// Render 递归替换模板中的 {{变量}}；整个字符串恰好为一个占位符时保留原始类型，变量为空时从对象中省略该字段
+func Render(node interface{}, vars map[string]interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			value := Render(child, vars)
+			if value == nil && isPlaceholder(child) {
+				continue
+			}
+			rendered[key] = value
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			value := Render(child, vars)
+			if value == nil && isPlaceholder(child) {
+				continue
+			}
+			rendered = append(rendered, value)
+		}
+		return rendered
+	case string:
+		if name, ok := placeholderName(v); ok {
+			value := vars[name]
+			if isEmpty(value) {
+				return nil
+			}
+			return value
+		}
+		return renderString(v, vars)
+	}
+	return node
+}
+
+func renderString(s string, vars map[string]interface{}) string {
+	return renderEscaped(s, vars, nil)
+}
+
+// renderURLPath 渲染请求路径：路径段中的变量（如任务 ID）按 url.PathEscape 转义，查询参数按 url.QueryEscape 转义，
+// 避免变量中的 / ? # 改变请求地址
+func renderURLPath(s string, vars map[string]interface{}) string {
+	path, query, hasQuery := strings.Cut(s, "?")
+	path = renderEscaped(path, vars, url.PathEscape)
+	if !hasQuery {
+		return path
+	}
+	return path + "?" + renderEscaped(query, vars, url.QueryEscape)
+}
+
+func renderEscaped(s string, vars map[string]interface{}, escape func(string) string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	for name, value := range vars {
+		placeholder := "{{" + name + "}}"
+		if !strings.Contains(s, placeholder) {
+			continue
+		}
+		text := ""
+		if !isEmpty(value) {
+			text = fmt.Sprint(value)
+		}
+		if escape != nil {
+			text = escape(text)
+		}
+		s = strings.ReplaceAll(s, placeholder, text)
+	}
+	return s
+}
+
+func placeholderName(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{{") || !strings.HasSuffix(s, "}}") {
+		return "", false
+	}
+	name := strings.TrimSpace(s[2 : len(s)-2])
+	if name == "" || strings.ContainsAny(name, "{}") {
+		return "", false
+	}
+	return name, true
+}
+
+func isPlaceholder(node interface{}) bool {
+	s, ok := node.(string)
+	if !ok {
+		return false
+	}
+	_, ok = placeholderName(s)
+	return ok
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case int:
+		return v == 0
+	case int64:
+		return v == 0
+	case float64:
+		return v == 0
+	case []string:
+		return len(v) == 0
+	}
+	return false
+}
+
+// Lookup 按点号路径取值，数字段作为数组下标，例如 "data.0.url"
+func Lookup(data interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return nil, false
+	}
+	current := data
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
+func stringAt(data interface{}, path string) string {
+	value, ok := Lookup(data, path)
+	if !ok {
+		return ""
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
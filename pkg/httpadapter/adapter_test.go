@@ -0,0 +1,121 @@
+package httpadapter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientSubmitAndQueryAsyncTask(t *testing.T) {
+	var submitted map[string]interface{}
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "sk-test" || r.Header.Get("X-Client") != "drama" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/videos":
+			json.NewDecoder(r.Body).Decode(&submitted)
+			w.Write([]byte(`{"code": 0, "data": {"task_id": "t-1", "task_status": "submitted"}}`))
+		case r.Method == "GET" && r.URL.Path == "/v1/videos/t-1":
+			polls++
+			if polls == 1 {
+				w.Write([]byte(`{"data": {"task_status": "RUNNING"}}`))
+				return
+			}
+			w.Write([]byte(`{"data": {"task_status": "SUCCEED", "task_result": {"videos": [{"url": "https://cdn.example.com/v.mp4"}]}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	spec, err := ParseSpec(`{"rate_limit": {"rpm": 10}, "http_adapter": {
+		"submit": {"path": "/v1/videos", "body": {"model_name": "{{model}}", "prompt": "{{prompt}}", "image": "{{image_url}}", "duration": "{{duration}}", "tail_image": "{{last_frame_url}}", "mode": "std", "note": "{{model}}-{{duration}}s"}},
+		"query": {"path": "/v1/videos/{{task_id}}"},
+		"headers": {"X-Client": "drama"},
+		"auth_header": "X-Api-Key", "auth_scheme": "",
+		"task_id_path": "data.task_id",
+		"status_path": "data.task_status",
+		"status_map": {"RUNNING": "processing"},
+		"result_url_path": "data.task_result.videos.0.url"
+	}}`)
+	if err != nil {
+		t.Fatalf("unexpected spec error: %v", err)
+	}
+
+	client := NewClient(server.URL, "sk-test", "", "", spec)
+	result, err := client.Submit(map[string]interface{}{
+		"model":          "kling-v1",
+		"prompt":         "雨夜街头",
+		"image_url":      "https://img.example.com/a.png",
+		"duration":       5,
+		"last_frame_url": "",
+	})
+	if err != nil {
+		t.Fatalf("unexpected submit error: %v", err)
+	}
+	if result.TaskID != "t-1" || result.Status != StatusPending || result.Completed {
+		t.Fatalf("unexpected submit result %+v", result)
+	}
+	if submitted["duration"] != float64(5) || submitted["prompt"] != "雨夜街头" || submitted["note"] != "kling-v1-5s" || submitted["mode"] != "std" {
+		t.Fatalf("template not rendered: %+v", submitted)
+	}
+	if _, ok := submitted["tail_image"]; ok {
+		t.Fatalf("empty placeholder should be omitted: %+v", submitted)
+	}
+
+	result, err = client.Query("t-1", nil)
+	if err != nil || result.Status != StatusProcessing || result.Completed {
+		t.Fatalf("expected processing, got %+v, %v", result, err)
+	}
+	result, err = client.Query("t-1", nil)
+	if err != nil || !result.Completed || result.ResultURL != "https://cdn.example.com/v.mp4" {
+		t.Fatalf("expected completed, got %+v, %v", result, err)
+	}
+}
+
+func TestClientSyncResponseAndFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("expected bearer auth, got %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/images":
+			w.Write([]byte(`{"images": [{"url": "https://cdn.example.com/a.png"}]}`))
+		case "/tasks/bad":
+			w.Write([]byte(`{"state": "error", "error": {"message": "content blocked"}}`))
+		}
+	}))
+	defer server.Close()
+
+	spec, err := ParseSpec(`{"http_adapter": {"result_url_path": "images.0.url"}}`)
+	if err != nil {
+		t.Fatalf("unexpected spec error: %v", err)
+	}
+	result, err := NewClient(server.URL, "sk-test", "/images", "", spec).Submit(map[string]interface{}{"prompt": "a cat"})
+	if err != nil || !result.Completed || result.ResultURL != "https://cdn.example.com/a.png" {
+		t.Fatalf("expected sync completion, got %+v, %v", result, err)
+	}
+
+	spec = &Spec{TaskIDPath: "id", StatusPath: "state", ResultURLPath: "url", ErrorPath: "error.message"}
+	result, err = NewClient(server.URL, "sk-test", "", "/tasks/{taskId}", spec).Query("bad", nil)
+	if err != nil || result.Status != StatusFailed || result.Error != "content blocked" || result.TaskID != "bad" {
+		t.Fatalf("expected failure with message, got %+v, %v", result, err)
+	}
+
+	if _, err := ParseSpec(`{"http_adapter": {"result_url_path": "url", "task_id_path": "id", "status_path": "s", "status_map": {"OK": "done"}}}`); err == nil {
+		t.Fatalf("expected invalid status_map to be rejected")
+	}
+}
+
+func TestRenderURLPathEscapesVariables(t *testing.T) {
+	path := renderURLPath("/v1/tasks/{{task_id}}?model={{model}}", map[string]interface{}{
+		"task_id": "a/../b?x#y",
+		"model":   "kling v1&debug=1",
+	})
+	if want := "/v1/tasks/a%2F..%2Fb%3Fx%23y?model=kling+v1%26debug%3D1"; path != want {
+		t.Fatalf("expected %s, got %s", want, path)
+	}
+}
@@ -0,0 +1,331 @@
+package image
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// comfyUIDefaultWorkflow 内置的文生图工作流（API 格式），自定义工作流使用相同的占位符：
+// {{prompt}} {{negative_prompt}} {{model}} {{seed}} {{steps}} {{cfg}} {{width}} {{height}} {{reference_image}}。
+// 整个字符串恰好是数值占位符时替换为数字，其余占位符按文本替换
+const comfyUIDefaultWorkflow = `{
+  "3": {"class_type": "KSampler", "inputs": {"seed": "{{seed}}", "steps": "{{steps}}", "cfg": "{{cfg}}", "sampler_name": "euler", "scheduler": "normal", "denoise": 1, "model": ["4", 0], "positive": ["6", 0], "negative": ["7", 0], "latent_image": ["5", 0]}},
+  "4": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "{{model}}"}},
+  "5": {"class_type": "EmptyLatentImage", "inputs": {"width": "{{width}}", "height": "{{height}}", "batch_size": 1}},
+  "6": {"class_type": "CLIPTextEncode", "inputs": {"text": "{{prompt}}", "clip": ["4", 1]}},
+  "7": {"class_type": "CLIPTextEncode", "inputs": {"text": "{{negative_prompt}}", "clip": ["4", 1]}},
+  "8": {"class_type": "VAEDecode", "inputs": {"samples": ["3", 0], "vae": ["4", 2]}},
+  "9": {"class_type": "SaveImage", "inputs": {"filename_prefix": "drama", "images": ["8", 0]}}
+}`
+
+// ComfyUIImageClient ComfyUI 客户端：提交工作流到 /prompt，再通过 /history 轮询结果
+type ComfyUIImageClient struct {
+	BaseURL    string
+	APIKey     string // 可选，经反向代理鉴权时使用
+	Model      string // checkpoint 文件名
+	Workflow   string // API 格式的工作流 JSON，为空时使用内置文生图工作流
+	ClientID   string
+	HTTPClient *http.Client
+}
+
+type ComfyUIPromptResponse struct {
+	PromptID   string                 `json:"prompt_id"`
+	Number     int                    `json:"number"`
+	Error      interface{}            `json:"error,omitempty"`
+	NodeErrors map[string]interface{} `json:"node_errors,omitempty"`
+}
+
+type ComfyUIHistoryEntry struct {
+	Status struct {
+		StatusStr string          `json:"status_str"`
+		Completed bool            `json:"completed"`
+		Messages  [][]interface{} `json:"messages"`
+	} `json:"status"`
+	Outputs map[string]struct {
+		Images []ComfyUIImageRef `json:"images"`
+	} `json:"outputs"`
+}
+
+type ComfyUIImageRef struct {
+	Filename  string `json:"filename"`
+	Subfolder string `json:"subfolder"`
+	Type      string `json:"type"`
+}
+
+func NewComfyUIImageClient(baseURL, apiKey, model, workflow string) *ComfyUIImageClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL == "" {
+		baseURL = "http://127.0.0.1:8188"
+	}
+	return &ComfyUIImageClient{
+		BaseURL:  baseURL,
+		APIKey:   apiKey,
+		Model:    model,
+		Workflow: workflow,
+		ClientID: fmt.Sprintf("drama-generator-%d", time.Now().UnixNano()),
+		HTTPClient: &http.Client{
+			Timeout: 2 * time.Minute,
+		},
+	}
+}
+
+func (c *ComfyUIImageClient) GenerateImage(prompt string, opts ...ImageOption) (*ImageResult, error) {
+	options := &ImageOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	model := c.Model
+	if options.Model != "" {
+		model = options.Model
+	}
+	width, height := optionDimensions(options, sdDefaultSize)
+	steps := sdDefaultSteps
+	if options.Steps > 0 {
+		steps = options.Steps
+	}
+	cfg := sdDefaultCfgScale
+	if options.CfgScale > 0 {
+		cfg = options.CfgScale
+	}
+	seed := options.Seed
+	if seed <= 0 {
+		seed = rand.Int63n(1 << 48)
+	}
+
+	workflowJSON := c.Workflow
+	if strings.TrimSpace(workflowJSON) == "" {
+		workflowJSON = comfyUIDefaultWorkflow
+	}
+	// 内置工作流的 ckpt_name 为 {{model}}，未配置模型时 ComfyUI 只会返回难以理解的节点校验错误
+	if model == "" && strings.Contains(workflowJSON, "{{model}}") {
+		return nil, fmt.Errorf("workflow requires a checkpoint model, set the model name in the ComfyUI config")
+	}
+
+	values := map[string]interface{}{
+		"prompt":          prompt,
+		"negative_prompt": options.NegativePrompt,
+		"model":           model,
+		"seed":            seed,
+		"steps":           steps,
+		"cfg":             cfg,
+		"width":           width,
+		"height":          height,
+	}
+	if strings.Contains(workflowJSON, "{{reference_image}}") {
+		if len(options.ReferenceImages) == 0 {
+			return nil, fmt.Errorf("workflow requires a reference image")
+		}
+		name, err := c.uploadImage(options.ReferenceImages[0])
+		if err != nil {
+			return nil, fmt.Errorf("upload reference image: %w", err)
+		}
+		values["reference_image"] = name
+	}
+
+	var workflow interface{}
+	if err := json.Unmarshal([]byte(workflowJSON), &workflow); err != nil {
+		return nil, fmt.Errorf("invalid ComfyUI workflow: %w", err)
+	}
+	workflow = fillComfyUIPlaceholders(workflow, values)
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"prompt":    workflow,
+		"client_id": c.ClientID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	body, err := c.do("POST", "/prompt", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	var result ComfyUIPromptResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	if result.PromptID == "" {
+		return nil, fmt.Errorf("ComfyUI rejected workflow: %s", string(body))
+	}
+
+	return &ImageResult{
+		TaskID:    result.PromptID,
+		Status:    "processing",
+		Width:     width,
+		Height:    height,
+		Completed: false,
+	}, nil
+}
+
+// GetTaskStatus 查询 /history/{prompt_id}；任务仍在队列中时返回空对象
+func (c *ComfyUIImageClient) GetTaskStatus(taskID string) (*ImageResult, error) {
+	body, err := c.do("GET", "/history/"+url.PathEscape(taskID), "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var history map[string]ComfyUIHistoryEntry
+	if err := json.Unmarshal(body, &history); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+
+	entry, ok := history[taskID]
+	if !ok {
+		return &ImageResult{TaskID: taskID, Status: "processing"}, nil
+	}
+
+	if entry.Status.StatusStr == "error" {
+		return &ImageResult{TaskID: taskID, Status: "failed", Error: comfyUIErrorMessage(entry)}, nil
+	}
+
+	// 按节点ID排序，取第一张输出图片
+	nodeIDs := make([]string, 0, len(entry.Outputs))
+	for id := range entry.Outputs {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+	for _, id := range nodeIDs {
+		for _, img := range entry.Outputs[id].Images {
+			if img.Type != "" && img.Type != "output" {
+				continue
+			}
+			query := url.Values{}
+			query.Set("filename", img.Filename)
+			query.Set("subfolder", img.Subfolder)
+			query.Set("type", "output")
+			return &ImageResult{
+				TaskID:    taskID,
+				Status:    "completed",
+				ImageURL:  c.BaseURL + "/view?" + query.Encode(),
+				Completed: true,
+			}, nil
+		}
+	}
+
+	if entry.Status.Completed {
+		return &ImageResult{TaskID: taskID, Status: "failed", Error: "workflow finished without output image"}, nil
+	}
+	return &ImageResult{TaskID: taskID, Status: "processing"}, nil
+}
+
+// uploadImage 上传参考图到 ComfyUI 的 input 目录，返回可在 LoadImage 节点中使用的文件名
+func (c *ComfyUIImageClient) uploadImage(ref string) (string, error) {
+	encoded, err := loadReferenceImage(ref)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("image", fmt.Sprintf("reference_%d.png", time.Now().UnixNano()))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	writer.WriteField("overwrite", "true")
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	body, err := c.do("POST", "/upload/image", writer.FormDataContentType(), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	var uploaded struct {
+		Name      string `json:"name"`
+		Subfolder string `json:"subfolder"`
+	}
+	if err := json.Unmarshal(body, &uploaded); err != nil {
+		return "", fmt.Errorf("parse upload response: %w", err)
+	}
+	if uploaded.Subfolder != "" {
+		return uploaded.Subfolder + "/" + uploaded.Name, nil
+	}
+	return uploaded.Name, nil
+}
+
+func (c *ComfyUIImageClient) do(method, path, contentType string, payload io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, c.BaseURL+path, payload)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// fillComfyUIPlaceholders 递归替换工作流中的占位符
+func fillComfyUIPlaceholders(node interface{}, values map[string]interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = fillComfyUIPlaceholders(child, values)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = fillComfyUIPlaceholders(child, values)
+		}
+		return v
+	case string:
+		for name, value := range values {
+			placeholder := "{{" + name + "}}"
+			if v == placeholder {
+				return value
+			}
+			if strings.Contains(v, placeholder) {
+				v = strings.ReplaceAll(v, placeholder, fmt.Sprint(value))
+			}
+		}
+		return v
+	}
+	return node
+}
+
+func comfyUIErrorMessage(entry ComfyUIHistoryEntry) string {
+	for _, message := range entry.Status.Messages {
+		if len(message) == 2 && message[0] == "execution_error" {
+			if detail, ok := message[1].(map[string]interface{}); ok {
+				return fmt.Sprintf("ComfyUI execution error in %v: %v", detail["node_type"], detail["exception_message"])
+			}
+		}
+	}
+	return "ComfyUI workflow failed"
+}
@@ -0,0 +1,86 @@
+package image
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestComfyUIImageClientSubmitAndPoll(t *testing.T) {
+	var submitted map[string]map[string]interface{}
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload/image":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Fatalf("invalid upload: %v", err)
+			}
+			w.Write([]byte(`{"name": "ref.png", "subfolder": "", "type": "input"}`))
+		case "/prompt":
+			var body struct {
+				Prompt map[string]map[string]interface{} `json:"prompt"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			submitted = body.Prompt
+			w.Write([]byte(`{"prompt_id": "abc-123", "number": 1}`))
+		case "/history/abc-123":
+			polls++
+			if polls == 1 {
+				w.Write([]byte(`{}`))
+				return
+			}
+			w.Write([]byte(`{"abc-123": {"status": {"status_str": "success", "completed": true}, "outputs": {"9": {"images": [{"filename": "drama_0001.png", "subfolder": "", "type": "output"}]}}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewComfyUIImageClient(server.URL, "", "hero_v2.safetensors", "")
+	result, err := client.GenerateImage("a girl in red", WithSize("768x512"), WithSeed(7), WithSteps(20))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Completed || result.TaskID != "abc-123" {
+		t.Fatalf("expected async task, got %+v", result)
+	}
+
+	sampler := submitted["3"]["inputs"].(map[string]interface{})
+	if sampler["seed"] != float64(7) || sampler["steps"] != float64(20) {
+		t.Fatalf("numeric placeholders not filled: %+v", sampler)
+	}
+	latent := submitted["5"]["inputs"].(map[string]interface{})
+	if latent["width"] != float64(768) || latent["height"] != float64(512) {
+		t.Fatalf("size not mapped: %+v", latent)
+	}
+	if text := submitted["6"]["inputs"].(map[string]interface{})["text"]; text != "a girl in red" {
+		t.Fatalf("prompt not filled: %v", text)
+	}
+
+	status, err := client.GetTaskStatus("abc-123")
+	if err != nil || status.Completed {
+		t.Fatalf("expected pending status, got %+v, %v", status, err)
+	}
+	status, err = client.GetTaskStatus("abc-123")
+	if err != nil || !status.Completed || !strings.HasPrefix(status.ImageURL, server.URL+"/view?") || !strings.Contains(status.ImageURL, "filename=drama_0001.png") {
+		t.Fatalf("unexpected completed status %+v, %v", status, err)
+	}
+
+	if _, err := NewComfyUIImageClient(server.URL, "", "", "").GenerateImage("x"); err == nil {
+		t.Fatalf("expected error when the built-in workflow has no model")
+	}
+
+	workflow := `{"1": {"class_type": "LoadImage", "inputs": {"image": "{{reference_image}}"}}, "2": {"class_type": "CLIPTextEncode", "inputs": {"text": "portrait, {{prompt}}"}}}`
+	custom := NewComfyUIImageClient(server.URL, "", "", workflow)
+	if _, err := custom.GenerateImage("x"); err == nil {
+		t.Fatalf("expected error when reference image is missing")
+	}
+	if _, err := custom.GenerateImage("hero", WithReferenceImages([]string{"data:image/png;base64,cmVm"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if submitted["1"]["inputs"].(map[string]interface{})["image"] != "ref.png" || submitted["2"]["inputs"].(map[string]interface{})["text"] != "portrait, hero" {
+		t.Fatalf("custom workflow not filled: %+v", submitted)
+	}
+}
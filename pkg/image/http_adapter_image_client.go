@@ -0,0 +1,75 @@
+package image
+
+import (
+	"github.com/drama-generator/backend/pkg/httpadapter"
+)
+
+// HTTPAdapterImageClient 声明式图片服务商客户端，请求与响应格式由 httpadapter.Spec 描述。
+// 模板可用变量：prompt, negative_prompt, model, size, width, height, quality, style, steps, cfg_scale,
+// seed, reference_images, callback_url, api_key；查询时另有 task_id
+type HTTPAdapterImageClient struct {
+	Model   string
+	Adapter *httpadapter.Client
+}
+
+func NewHTTPAdapterImageClient(baseURL, apiKey, model, endpoint, queryEndpoint string, spec *httpadapter.Spec) *HTTPAdapterImageClient {
+	return &HTTPAdapterImageClient{
+		Model:   model,
+		Adapter: httpadapter.NewClient(baseURL, apiKey, endpoint, queryEndpoint, spec),
+	}
+}
+
+func (c *HTTPAdapterImageClient) GenerateImage(prompt string, opts ...ImageOption) (*ImageResult, error) {
+	options := &ImageOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	model := c.Model
+	if options.Model != "" {
+		model = options.Model
+	}
+
+	result, err := c.Adapter.Submit(map[string]interface{}{
+		"prompt":           prompt,
+		"negative_prompt":  options.NegativePrompt,
+		"model":            model,
+		"size":             options.Size,
+		"width":            options.Width,
+		"height":           options.Height,
+		"quality":          options.Quality,
+		"style":            options.Style,
+		"steps":            options.Steps,
+		"cfg_scale":        options.CfgScale,
+		"seed":             options.Seed,
+		"reference_images": options.ReferenceImages,
+		"callback_url":     options.CallbackURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toImageResult(result, options), nil
+}
+
+func (c *HTTPAdapterImageClient) GetTaskStatus(taskID string) (*ImageResult, error) {
+	result, err := c.Adapter.Query(taskID, map[string]interface{}{"model": c.Model})
+	if err != nil {
+		return nil, err
+	}
+	return toImageResult(result, &ImageOptions{}), nil
+}
+
+func toImageResult(result *httpadapter.TaskResult, options *ImageOptions) *ImageResult {
+	imageResult := &ImageResult{
+		TaskID:    result.TaskID,
+		Status:    result.Status,
+		ImageURL:  result.ResultURL,
+		Width:     options.Width,
+		Height:    options.Height,
+		Completed: result.Completed,
+	}
+	if result.Status == httpadapter.StatusFailed {
+		imageResult.Error = result.Error
+	}
+	return imageResult
+}
@@ -27,6 +27,7 @@ type ImageOptions struct {
 	Width           int
 	Height          int
 	ReferenceImages []string // 参考图片URL列表
+	CallbackURL     string   // 支持回调的服务商在任务结束时通知该地址
 }
 
 type ImageOption func(*ImageOptions)
@@ -91,3 +92,9 @@ func WithReferenceImages(images []string) ImageOption {
 		o.ReferenceImages = images
 	}
 }
+
+func WithCallbackURL(url string) ImageOption {
+	return func(o *ImageOptions) {
+		o.CallbackURL = url
+	}
+}
@@ -0,0 +1,152 @@
+package image
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"unicode"
+)
+
+const (
+	mockDefaultSize = 1024
+	mockMaxSize     = 2048
+	mockGlyphScale  = 4
+)
+
+// MockImageClient 离线图片客户端，用纯 Go 绘制带提示词文字的占位 PNG，以 data URI 返回。
+// 背景色由提示词决定，同一提示词总得到同一张图
+type MockImageClient struct {
+	Model string
+}
+
+func NewMockImageClient(model string) *MockImageClient {
+	return &MockImageClient{Model: model}
+}
+
+func (c *MockImageClient) GenerateImage(prompt string, opts ...ImageOption) (*ImageResult, error) {
+	options := &ImageOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	width, height := mockImageDimensions(options)
+	data, err := renderMockImage(prompt, width, height)
+	if err != nil {
+		return nil, fmt.Errorf("render mock image: %w", err)
+	}
+
+	return &ImageResult{
+		Status:    "completed",
+		ImageURL:  "data:image/png;base64," + base64.StdEncoding.EncodeToString(data),
+		Width:     width,
+		Height:    height,
+		Completed: true,
+	}, nil
+}
+
+func (c *MockImageClient) GetTaskStatus(taskID string) (*ImageResult, error) {
+	return nil, fmt.Errorf("mock image provider is synchronous, no task %s", taskID)
+}
+
+func mockImageDimensions(options *ImageOptions) (int, int) {
+	width, height := options.Width, options.Height
+	if width <= 0 || height <= 0 {
+		width, height = mockDefaultSize, mockDefaultSize
+		fmt.Sscanf(options.Size, "%dx%d", &width, &height)
+	}
+	// 只是占位图，限制尺寸避免编码过大的 data URI
+	for width > mockMaxSize || height > mockMaxSize {
+		width, height = width/2, height/2
+	}
+	if width <= 0 || height <= 0 {
+		width, height = mockDefaultSize, mockDefaultSize
+	}
+	return width, height
+}
+
+func renderMockImage(prompt string, width, height int) ([]byte, error) {
+	hash := fnv.New32a()
+	hash.Write([]byte(prompt))
+	sum := hash.Sum32()
+	background := color.RGBA{R: uint8(sum>>16)/2 + 64, G: uint8(sum>>8)/2 + 64, B: uint8(sum)/2 + 64, A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, background)
+		}
+	}
+
+	// 按字符宽度自动换行，超出画面的行直接截断
+	cellW := (mockGlyphWidth + 1) * mockGlyphScale
+	cellH := (mockGlyphHeight + 3) * mockGlyphScale
+	margin := 2 * cellW
+	cols := (width - 2*margin) / cellW
+	rows := (height - 2*margin) / cellH
+	if cols <= 0 || rows <= 0 {
+		return encodePNG(img)
+	}
+
+	lines := wrapMockText("MOCK IMAGE  "+prompt, cols)
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	for row, line := range lines {
+		for col, r := range []rune(line) {
+			drawMockGlyph(img, r, margin+col*cellW, margin+row*cellH, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+	return encodePNG(img)
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func wrapMockText(text string, cols int) []string {
+	var lines []string
+	var line []rune
+	for _, r := range []rune(strings.Join(strings.Fields(text), " ")) {
+		if len(line) >= cols {
+			lines = append(lines, string(line))
+			line = line[:0]
+		}
+		if len(line) == 0 && r == ' ' {
+			continue
+		}
+		line = append(line, r)
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+func drawMockGlyph(img *image.RGBA, r rune, x, y int, c color.RGBA) {
+	rows, ok := mockFont[unicode.ToUpper(r)]
+	if !ok {
+		// 字库之外的字符（如中文）画成方框
+		rows = mockFontUnknown
+	}
+	for gy, bits := range rows {
+		for gx := 0; gx < mockGlyphWidth; gx++ {
+			if bits&(1<<(mockGlyphWidth-1-gx)) == 0 {
+				continue
+			}
+			for dy := 0; dy < mockGlyphScale; dy++ {
+				for dx := 0; dx < mockGlyphScale; dx++ {
+					img.SetRGBA(x+gx*mockGlyphScale+dx, y+gy*mockGlyphScale+dy, c)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	sdDefaultSize              = 1024
+	sdDefaultSteps             = 28
+	sdDefaultCfgScale          = 7.0
+	sdDefaultDenoisingStrength = 0.6
+)
+
+// SDLora 追加到提示词中的 LoRA，按 A1111 语法写成 <lora:name:weight>
+type SDLora struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+}
+
+// SDWebUIImageClient AUTOMATIC1111 Stable Diffusion WebUI 客户端（需以 --api 启动）。
+// 有参考图时走 img2img，以第一张参考图为初始图；否则走 txt2img。接口为同步返回，结果以 data URI 给出
+type SDWebUIImageClient struct {
+	BaseURL           string
+	APIKey            string // 可选，--api-auth 的 "用户名:密码"
+	Model             string // checkpoint 名称，为空时使用 WebUI 当前加载的模型
+	Sampler           string
+	LoRAs             []SDLora
+	DenoisingStrength float64
+	HTTPClient        *http.Client
+}
+
+type SDWebUIRequest struct {
+	Prompt                            string                 `json:"prompt"`
+	NegativePrompt                    string                 `json:"negative_prompt,omitempty"`
+	Steps                             int                    `json:"steps"`
+	CfgScale                          float64                `json:"cfg_scale"`
+	Seed                              int64                  `json:"seed"`
+	Width                             int                    `json:"width"`
+	Height                            int                    `json:"height"`
+	SamplerName                       string                 `json:"sampler_name,omitempty"`
+	InitImages                        []string               `json:"init_images,omitempty"`
+	DenoisingStrength                 float64                `json:"denoising_strength,omitempty"`
+	OverrideSettings                  map[string]interface{} `json:"override_settings,omitempty"`
+	OverrideSettingsRestoreAfterwards bool                   `json:"override_settings_restore_afterwards,omitempty"`
+}
+
+type SDWebUIResponse struct {
+	Images []string `json:"images"`
+	Info   string   `json:"info"`
+	Error  string   `json:"error,omitempty"`
+	Detail string   `json:"detail,omitempty"`
+}
+
+func NewSDWebUIImageClient(baseURL, apiKey, model string) *SDWebUIImageClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL == "" {
+		baseURL = "http://127.0.0.1:7860"
+	}
+	return &SDWebUIImageClient{
+		BaseURL:           baseURL,
+		APIKey:            apiKey,
+		Model:             model,
+		DenoisingStrength: sdDefaultDenoisingStrength,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Minute,
+		},
+	}
+}
+
+func (c *SDWebUIImageClient) GenerateImage(prompt string, opts ...ImageOption) (*ImageResult, error) {
+	options := &ImageOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	width, height := optionDimensions(options, sdDefaultSize)
+	reqBody := SDWebUIRequest{
+		Prompt:         c.promptWithLoRAs(prompt),
+		NegativePrompt: options.NegativePrompt,
+		Steps:          sdDefaultSteps,
+		CfgScale:       sdDefaultCfgScale,
+		Seed:           -1,
+		Width:          width,
+		Height:         height,
+		SamplerName:    c.Sampler,
+	}
+	if options.Steps > 0 {
+		reqBody.Steps = options.Steps
+	}
+	if options.CfgScale > 0 {
+		reqBody.CfgScale = options.CfgScale
+	}
+	if options.Seed != 0 {
+		reqBody.Seed = options.Seed
+	}
+
+	model := c.Model
+	if options.Model != "" {
+		model = options.Model
+	}
+	if model != "" {
+		reqBody.OverrideSettings = map[string]interface{}{"sd_model_checkpoint": model}
+		reqBody.OverrideSettingsRestoreAfterwards = true
+	}
+
+	endpoint := "/sdapi/v1/txt2img"
+	if len(options.ReferenceImages) > 0 {
+		initImage, err := loadReferenceImage(options.ReferenceImages[0])
+		if err != nil {
+			return nil, fmt.Errorf("load init image: %w", err)
+		}
+		endpoint = "/sdapi/v1/img2img"
+		reqBody.InitImages = []string{initImage}
+		reqBody.DenoisingStrength = c.DenoisingStrength
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", c.BaseURL+endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if user, pass, ok := strings.Cut(c.APIKey, ":"); ok {
+		req.SetBasicAuth(user, pass)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result SDWebUIResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	if len(result.Images) == 0 {
+		return nil, fmt.Errorf("no image generated: %s%s", result.Error, result.Detail)
+	}
+
+	return &ImageResult{
+		Status:    "completed",
+		ImageURL:  "data:image/png;base64," + result.Images[0],
+		Width:     width,
+		Height:    height,
+		Completed: true,
+	}, nil
+}
+
+func (c *SDWebUIImageClient) GetTaskStatus(taskID string) (*ImageResult, error) {
+	return nil, fmt.Errorf("not supported for Stable Diffusion WebUI")
+}
+
+func (c *SDWebUIImageClient) promptWithLoRAs(prompt string) string {
+	if len(c.LoRAs) == 0 {
+		return prompt
+	}
+	var builder strings.Builder
+	builder.WriteString(prompt)
+	for _, lora := range c.LoRAs {
+		weight := lora.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		fmt.Fprintf(&builder, " <lora:%s:%g>", lora.Name, weight)
+	}
+	return builder.String()
+}
+
+// optionDimensions 优先使用显式宽高，其次解析 "宽x高" 格式的尺寸，宽高向下取整到 8 的倍数
+func optionDimensions(options *ImageOptions, fallback int) (int, int) {
+	width, height := options.Width, options.Height
+	if width <= 0 || height <= 0 {
+		width, height = fallback, fallback
+		fmt.Sscanf(strings.ToLower(options.Size), "%dx%d", &width, &height)
+	}
+	if width < 64 || height < 64 {
+		width, height = fallback, fallback
+	}
+	return width / 8 * 8, height / 8 * 8
+}
+
+// loadReferenceImage 返回参考图的 base64 内容，支持 http(s) 地址、data URI 与原始 base64
+func loadReferenceImage(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://"):
+		data, _, err := downloadImageToBase64(ref)
+		return data, err
+	case strings.HasPrefix(ref, "data:"):
+		_, data, ok := strings.Cut(ref, ",")
+		if !ok {
+			return "", fmt.Errorf("invalid data URI")
+		}
+		return data, nil
+	}
+	return ref, nil
+}
@@ -0,0 +1,50 @@
+package image
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSDWebUIImageClientTxt2ImgAndImg2Img(t *testing.T) {
+	var paths []string
+	var last SDWebUIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			t.Errorf("expected basic auth, got %v", r.Header)
+		}
+		last = SDWebUIRequest{}
+		json.NewDecoder(r.Body).Decode(&last)
+		w.Write([]byte(`{"images": ["aW1hZ2U="], "info": "{}"}`))
+	}))
+	defer server.Close()
+
+	client := NewSDWebUIImageClient(server.URL, "admin:secret", "hero_v2.safetensors")
+	client.LoRAs = []SDLora{{Name: "hero_face", Weight: 0.8}}
+
+	result, err := client.GenerateImage("a girl in red", WithSize("1000x600"), WithNegativePrompt("blurry"), WithSeed(42))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Completed || result.ImageURL != "data:image/png;base64,aW1hZ2U=" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if paths[0] != "/sdapi/v1/txt2img" || last.Prompt != "a girl in red <lora:hero_face:0.8>" {
+		t.Fatalf("unexpected request %s %+v", paths[0], last)
+	}
+	if last.Width != 1000 || last.Height != 600 || last.Seed != 42 || last.NegativePrompt != "blurry" {
+		t.Fatalf("options not mapped: %+v", last)
+	}
+	if last.OverrideSettings["sd_model_checkpoint"] != "hero_v2.safetensors" || !last.OverrideSettingsRestoreAfterwards {
+		t.Fatalf("expected checkpoint override, got %+v", last.OverrideSettings)
+	}
+
+	if _, err := client.GenerateImage("same girl", WithReferenceImages([]string{"data:image/png;base64,cmVm"})); err != nil {
+		t.Fatalf("unexpected img2img error: %v", err)
+	}
+	if paths[1] != "/sdapi/v1/img2img" || len(last.InitImages) != 1 || last.InitImages[0] != "cmVm" || last.DenoisingStrength != sdDefaultDenoisingStrength {
+		t.Fatalf("unexpected img2img request %s %+v", paths[1], last)
+	}
+}
@@ -0,0 +1,222 @@
+package ratelimit
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config 单个服务配置的出站限流参数，0 表示不限制
+type Config struct {
+	MaxConcurrent     int `json:"max_concurrent"`
+	RequestsPerMinute int `json:"requests_per_minute"`
+	TokensPerMinute   int `json:"tokens_per_minute"`
+	MaxRetries        int `json:"max_retries"` // 遇到 429 时的重试次数，默认 3
+	BackoffMs         int `json:"backoff_ms"`  // 429 初始退避时间，默认 2000ms，每次翻倍
+}
+
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = 2 * time.Second
+	maxBackoff        = 2 * time.Minute
+	window            = time.Minute
+)
+
+type tokenEntry struct {
+	at     time.Time
+	tokens int
+}
+
+// Limiter 并发数、每分钟请求数与每分钟 token 预算限流器，调用方在额度不足时排队等待
+type Limiter struct {
+	cfg Config
+	sem chan struct{}
+
+	mu          sync.Mutex
+	requests    []time.Time
+	tokens      []*tokenEntry
+	pausedUntil time.Time
+}
+
+func NewLimiter(cfg Config) *Limiter {
+	l := &Limiter{cfg: cfg}
+	if cfg.MaxConcurrent > 0 {
+		l.sem = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return l
+}
+
+func (l *Limiter) Config() Config {
+	return l.cfg
+}
+
+// Acquire 占用一个并发槽位并按 RPM/TPM 排队，返回的 release 需传入实际消耗的 token 数（未知时传 -1）
+func (l *Limiter) Acquire(estimatedTokens int) (release func(actualTokens int)) {
+	if l.sem != nil {
+		l.sem <- struct{}{}
+	}
+
+	var entry *tokenEntry
+	for {
+		wait := l.reserve(estimatedTokens, &entry)
+		if wait <= 0 {
+			break
+		}
+		time.Sleep(wait)
+	}
+
+	var once sync.Once
+	return func(actualTokens int) {
+		once.Do(func() {
+			if entry != nil && actualTokens >= 0 {
+				l.mu.Lock()
+				entry.tokens = actualTokens
+				l.mu.Unlock()
+			}
+			if l.sem != nil {
+				<-l.sem
+			}
+		})
+	}
+}
+
+// reserve 尝试登记一次请求，额度不足时返回需要等待的时间
+func (l *Limiter) reserve(estimatedTokens int, entry **tokenEntry) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	l.prune(now)
+
+	if l.cfg.RequestsPerMinute > 0 && len(l.requests) >= l.cfg.RequestsPerMinute {
+		return l.requests[0].Add(window).Sub(now)
+	}
+
+	if l.cfg.TokensPerMinute > 0 && estimatedTokens > 0 && len(l.tokens) > 0 {
+		used := 0
+		for _, t := range l.tokens {
+			used += t.tokens
+		}
+		// 窗口为空时总是放行，避免单个超大请求永远无法执行
+		if used+estimatedTokens > l.cfg.TokensPerMinute {
+			return l.tokens[0].at.Add(window).Sub(now)
+		}
+	}
+
+	if l.cfg.RequestsPerMinute > 0 {
+		l.requests = append(l.requests, now)
+	}
+	if l.cfg.TokensPerMinute > 0 {
+		*entry = &tokenEntry{at: now, tokens: estimatedTokens}
+		l.tokens = append(l.tokens, *entry)
+	}
+	return 0
+}
+
+func (l *Limiter) prune(now time.Time) {
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(l.requests) && !l.requests[i].After(cutoff) {
+		i++
+	}
+	l.requests = l.requests[i:]
+
+	j := 0
+	for j < len(l.tokens) && !l.tokens[j].at.After(cutoff) {
+		j++
+	}
+	l.tokens = l.tokens[j:]
+}
+
+// Pause 收到 429 后暂停该配置的所有新请求
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// WaitPaused 只等待 429 暂停结束，不占用并发与 RPM 额度（用于任务状态轮询）
+func (l *Limiter) WaitPaused() {
+	l.mu.Lock()
+	until := l.pausedUntil
+	l.mu.Unlock()
+	if wait := time.Until(until); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Do 在限流下执行 fn，遇到 429 按指数退避重试；fn 返回实际消耗 token 数（未知时返回 -1）
+func (l *Limiter) Do(estimatedTokens int, fn func() (int, error)) error {
+	retries := l.cfg.MaxRetries
+	if retries <= 0 {
+		retries = defaultMaxRetries
+	}
+	backoff := defaultBackoff
+	if l.cfg.BackoffMs > 0 {
+		backoff = time.Duration(l.cfg.BackoffMs) * time.Millisecond
+	}
+
+	for attempt := 0; ; attempt++ {
+		release := l.Acquire(estimatedTokens)
+		used, err := fn()
+		release(used)
+
+		if err == nil || !IsRateLimitError(err) || attempt >= retries {
+			return err
+		}
+
+		l.Pause(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// IsRateLimitError 判断服务商是否返回了限流错误
+func IsRateLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "status 429") ||
+		strings.Contains(msg, "status: 429") ||
+		strings.Contains(msg, "too many requests") ||
+		strings.Contains(msg, "rate limit") ||
+		strings.Contains(msg, "rate_limit")
+}
+
+// Registry 按 key（通常为服务配置ID）缓存限流器，配置变化时重建
+type Registry struct {
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+func NewRegistry() *Registry {
+	return &Registry{limiters: make(map[string]*Limiter)}
+}
+
+func (r *Registry) Get(key string, cfg Config) *Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.limiters[key]; ok && l.cfg == cfg {
+		return l
+	}
+	l := NewLimiter(cfg)
+	r.limiters[key] = l
+	return l
+}
+
+var defaultRegistry = NewRegistry()
+
+// Get 从全局注册表获取限流器，同一进程内所有服务共享
+func Get(key string, cfg Config) *Limiter {
+	return defaultRegistry.Get(key, cfg)
+}
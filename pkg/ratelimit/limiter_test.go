@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiterMaxConcurrent(t *testing.T) {
+	l := NewLimiter(Config{MaxConcurrent: 2})
+
+	var running, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = l.Do(0, func() (int, error) {
+				current := atomic.AddInt32(&running, 1)
+				for {
+					old := atomic.LoadInt32(&peak)
+					if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return -1, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent calls, got %d", peak)
+	}
+}
+
+func TestLimiterRetriesOnRateLimit(t *testing.T) {
+	l := NewLimiter(Config{MaxRetries: 2, BackoffMs: 1})
+
+	attempts := 0
+	err := l.Do(0, func() (int, error) {
+		attempts++
+		if attempts < 3 {
+			return -1, errors.New("API error (status 429): Too Many Requests")
+		}
+		return -1, nil
+	})
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+
+	attempts = 0
+	err = l.Do(0, func() (int, error) {
+		attempts++
+		return -1, errors.New("API error (status 400): bad request")
+	})
+	if err == nil || attempts != 1 {
+		t.Fatalf("expected non-429 error without retry, attempts=%d err=%v", attempts, err)
+	}
+}
+
+func TestLimiterTokenBudgetAdmitsFirstRequest(t *testing.T) {
+	l := NewLimiter(Config{TokensPerMinute: 100})
+
+	done := make(chan struct{})
+	go func() {
+		release := l.Acquire(500)
+		release(500)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("oversized request should be admitted when window is empty")
+	}
+
+	if wait := l.reserve(10, new(*tokenEntry)); wait <= 0 {
+		t.Fatalf("expected token budget to be exhausted after oversized request")
+	}
+}
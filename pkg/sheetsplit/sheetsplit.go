@@ -0,0 +1,187 @@
+// Package sheetsplit 将白底设定图（主图 + 正/侧/背三视图）按空白间隔切分为单独的视图
+package sheetsplit
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+)
+
+// View 设定图中的视图类型
+type View string
+
+const (
+	ViewMain  View = "main"  // 主立绘
+	ViewFront View = "front" // 正面
+	ViewSide  View = "side"  // 侧面
+	ViewBack  View = "back"  // 背面
+)
+
+// ErrNoPanels 画布上找不到可识别的三视图布局
+var ErrNoPanels = errors.New("no three-view panels found on sheet")
+
+const (
+	// backgroundLevel 三个通道都不低于该值（16 位）的像素视为白色背景
+	backgroundLevel = 235 << 8
+	// transparentLevel 透明度低于该值的像素视为背景
+	transparentLevel = 16 << 8
+	// minInkRatio 一行/一列中前景像素占比超过该值才算有内容，过滤噪点和淡阴影
+	minInkRatio = 0.004
+	// minGapRatio 窄于该比例的空白不作为面板分隔，避免把同一人物的手臂与身体切开
+	minGapRatio = 0.01
+	// minPanelRatio 宽或高小于画布该比例的区域视为文字标注等杂项
+	minPanelRatio = 0.04
+	// paddingRatio 裁切时在人物四周保留的边距
+	paddingRatio = 0.02
+)
+
+// Panel 一个视图在原图中的区域
+type Panel struct {
+	View   View
+	Bounds image.Rectangle
+}
+
+type span struct{ start, end int }
+
+// Detect 识别设定图中的面板。三个面板按从左到右依次为正、侧、背；
+// 四个面板时面积最大的为主立绘，其余依次为正、侧、背。其他数量返回 ErrNoPanels
+func Detect(img image.Image) ([]Panel, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil, ErrNoPanels
+	}
+
+	ink := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			ink[y*w+x] = a >= transparentLevel && (r < backgroundLevel || g < backgroundLevel || b < backgroundLevel)
+		}
+	}
+
+	// 先按行切出横向条带（主图在上、三视图在下的布局），再在每个条带内按列切分
+	var rects []image.Rectangle
+	rowProfile := make([]int, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if ink[y*w+x] {
+				rowProfile[y]++
+			}
+		}
+	}
+	for _, band := range segments(rowProfile, int(float64(w)*minInkRatio), int(float64(h)*minGapRatio)) {
+		colProfile := make([]int, w)
+		for y := band.start; y < band.end; y++ {
+			for x := 0; x < w; x++ {
+				if ink[y*w+x] {
+					colProfile[x]++
+				}
+			}
+		}
+		for _, col := range segments(colProfile, int(float64(band.end-band.start)*minInkRatio), int(float64(w)*minGapRatio)) {
+			top, bottom := -1, -1
+			for y := band.start; y < band.end; y++ {
+				for x := col.start; x < col.end; x++ {
+					if ink[y*w+x] {
+						if top < 0 {
+							top = y
+						}
+						bottom = y + 1
+						break
+					}
+				}
+			}
+			if top < 0 {
+				continue
+			}
+			rect := image.Rect(col.start, top, col.end, bottom)
+			if float64(rect.Dx()) < float64(w)*minPanelRatio || float64(rect.Dy()) < float64(h)*minPanelRatio {
+				continue
+			}
+			rects = append(rects, rect)
+		}
+	}
+
+	panels, err := label(rects)
+	if err != nil {
+		return nil, err
+	}
+	padding := int(float64(max(w, h)) * paddingRatio)
+	canvas := image.Rect(0, 0, w, h)
+	for i := range panels {
+		panels[i].Bounds = panels[i].Bounds.Inset(-padding).Intersect(canvas).Add(bounds.Min)
+	}
+	return panels, nil
+}
+
+// label 按面板数量与阅读顺序（条带内从左到右）分配视图
+func label(rects []image.Rectangle) ([]Panel, error) {
+	order := []View{ViewFront, ViewSide, ViewBack}
+	switch len(rects) {
+	case 3:
+		panels := make([]Panel, 3)
+		for i, rect := range rects {
+			panels[i] = Panel{View: order[i], Bounds: rect}
+		}
+		return panels, nil
+	case 4:
+		mainIndex := 0
+		for i, rect := range rects {
+			if area(rect) > area(rects[mainIndex]) {
+				mainIndex = i
+			}
+		}
+		panels := []Panel{{View: ViewMain, Bounds: rects[mainIndex]}}
+		for i, rect := range rects {
+			if i != mainIndex {
+				panels = append(panels, Panel{View: order[len(panels)-1], Bounds: rect})
+			}
+		}
+		return panels, nil
+	default:
+		return nil, ErrNoPanels
+	}
+}
+
+// segments 返回 profile 中超过阈值的连续区间，间隔小于 minGap 的区间合并
+func segments(profile []int, threshold, minGap int) []span {
+	var spans []span
+	start := -1
+	for i, v := range profile {
+		if v > threshold {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(profile)})
+	}
+
+	merged := spans[:0]
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start-merged[n-1].end < minGap {
+			merged[n-1].end = s.end
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
+
+// Crop 将区域复制为独立的图片
+func Crop(img image.Image, rect image.Rectangle) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
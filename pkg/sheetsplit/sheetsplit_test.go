@@ -0,0 +1,70 @@
+package sheetsplit
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func newSheet(w, h int, figures ...image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, r := range figures {
+		draw.Draw(img, r, image.NewUniform(color.RGBA{R: 40, G: 60, B: 90, A: 255}), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func TestDetectMainAndThreeViews(t *testing.T) {
+	main := image.Rect(20, 20, 320, 380)
+	front := image.Rect(360, 60, 460, 380)
+	side := image.Rect(500, 60, 580, 380)
+	back := image.Rect(620, 60, 720, 380)
+	// 同一人物内部的窄缝不应被切开
+	img := newSheet(760, 400, main, front, image.Rect(585, 100, 588, 200), side, back)
+	draw.Draw(img, image.Rect(400, 200, 403, 260), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	panels, err := Detect(img)
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	expected := []struct {
+		view View
+		rect image.Rectangle
+	}{{ViewMain, main}, {ViewFront, front}, {ViewSide, image.Rect(500, 60, 588, 380)}, {ViewBack, back}}
+	if len(panels) != len(expected) {
+		t.Fatalf("expected %d panels, got %+v", len(expected), panels)
+	}
+	for i, want := range expected {
+		if panels[i].View != want.view || !panels[i].Bounds.In(want.rect.Inset(-16)) || !want.rect.In(panels[i].Bounds) {
+			t.Fatalf("panel %d: expected %s around %v, got %s %v", i, want.view, want.rect, panels[i].View, panels[i].Bounds)
+		}
+	}
+
+	crop := Crop(img, panels[1].Bounds)
+	if crop.Bounds().Dx() != panels[1].Bounds.Dx() || crop.Bounds().Min != (image.Point{}) {
+		t.Fatalf("unexpected crop bounds %v", crop.Bounds())
+	}
+}
+
+func TestDetectStackedLayout(t *testing.T) {
+	// 主图在上、三视图在下
+	img := newSheet(600, 800,
+		image.Rect(150, 20, 450, 420),
+		image.Rect(40, 480, 160, 780), image.Rect(240, 480, 360, 780), image.Rect(440, 480, 560, 780))
+
+	panels, err := Detect(img)
+	if err != nil {
+		t.Fatalf("detect failed: %v", err)
+	}
+	if panels[0].View != ViewMain || panels[0].Bounds.Min.Y > 20 || panels[3].View != ViewBack || panels[3].Bounds.Min.X < 400 {
+		t.Fatalf("unexpected panels %+v", panels)
+	}
+}
+
+func TestDetectRejectsSingleIllustration(t *testing.T) {
+	if _, err := Detect(newSheet(400, 400, image.Rect(100, 50, 300, 350))); err != ErrNoPanels {
+		t.Fatalf("expected ErrNoPanels, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import "strings"
+
+// JSONArrayStream 增量扫描流式输出的 JSON，按顺序返回数组中已经完整的对象元素。
+// 同时支持顶层数组 [{...}] 与包裹在对象字段中的数组 {"storyboards": [{...}]}，
+// 对象内部嵌套的对象不会单独返回；数组出现前的 Markdown 代码块标记等内容会被忽略。
+type JSONArrayStream struct {
+	buf      strings.Builder
+	stack    []byte
+	inString bool
+	escaped  bool
+	start    int // 当前正在收集的对象在 buf 中的起始位置，-1 表示未收集
+	depth    int // 当前正在收集的对象所在的栈深度
+	emitted  int
+}
+
+func NewJSONArrayStream() *JSONArrayStream {
+	return &JSONArrayStream{start: -1}
+}
+
+// Write 追加一段增量文本，返回本次新完成的对象 JSON 字符串
+func (s *JSONArrayStream) Write(chunk string) []string {
+	var objects []string
+	offset := s.buf.Len()
+	s.buf.WriteString(chunk)
+	data := s.buf.String()
+
+	for i := offset; i < len(data); i++ {
+		c := data[i]
+		if s.inString {
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case c == '\\':
+				s.escaped = true
+			case c == '"':
+				s.inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			if len(s.stack) > 0 {
+				s.inString = true
+			}
+		case '[':
+			s.stack = append(s.stack, c)
+		case '{':
+			if s.start < 0 && len(s.stack) > 0 && s.stack[len(s.stack)-1] == '[' {
+				s.start = i
+				s.depth = len(s.stack) + 1
+			}
+			s.stack = append(s.stack, c)
+		case '}', ']':
+			if len(s.stack) == 0 {
+				continue
+			}
+			if c == '}' && s.start >= 0 && len(s.stack) == s.depth {
+				objects = append(objects, data[s.start:i+1])
+				s.start = -1
+			}
+			s.stack = s.stack[:len(s.stack)-1]
+		}
+	}
+
+	s.emitted += len(objects)
+	return objects
+}
+
+// Count 返回已经完成的对象数量
+func (s *JSONArrayStream) Count() int {
+	return s.emitted
+}
+
+// String 返回目前累积的完整文本
+func (s *JSONArrayStream) String() string {
+	return s.buf.String()
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONArrayStreamEmitsObjectsAcrossChunks(t *testing.T) {
+	text := "```json\n{\"storyboards\": [{\"shot_number\": 1, \"action\": \"推门 {进入}\", \"characters\": [1, 2]}," +
+		"{\"shot_number\": 2, \"dialogue\": \"\\\"住手！\\\"\", \"meta\": {\"k\": 1}}]}\n```"
+
+	stream := NewJSONArrayStream()
+	var objects []string
+	// 逐字节写入，模拟最细粒度的流式分片
+	for i := 0; i < len(text); i++ {
+		objects = append(objects, stream.Write(text[i:i+1])...)
+	}
+
+	if len(objects) != 2 || stream.Count() != 2 {
+		t.Fatalf("expected 2 objects, got %d: %v", len(objects), objects)
+	}
+	for i, obj := range objects {
+		var shot struct {
+			ShotNumber int `json:"shot_number"`
+		}
+		if err := json.Unmarshal([]byte(obj), &shot); err != nil {
+			t.Fatalf("object %d is not valid JSON: %v (%s)", i, err, obj)
+		}
+		if shot.ShotNumber != i+1 {
+			t.Fatalf("expected shot %d, got %d", i+1, shot.ShotNumber)
+		}
+	}
+	if stream.String() != text {
+		t.Fatalf("accumulated text mismatch")
+	}
+}
+
+func TestJSONArrayStreamTopLevelArray(t *testing.T) {
+	stream := NewJSONArrayStream()
+	first := stream.Write(`[{"name":"林`)
+	if len(first) != 0 {
+		t.Fatalf("expected no complete object yet, got %v", first)
+	}
+	rest := stream.Write(`默"},{"name":"陈峥"}`)
+	if len(rest) != 2 {
+		t.Fatalf("expected 2 objects, got %v", rest)
+	}
+}
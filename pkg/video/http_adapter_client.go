@@ -0,0 +1,74 @@
+package video
+
+import (
+	"github.com/drama-generator/backend/pkg/httpadapter"
+)
+
+// HTTPAdapterClient 声明式视频服务商客户端，请求与响应格式由 httpadapter.Spec 描述。
+// 模板可用变量：prompt, image_url, first_frame_url, last_frame_url, reference_image_urls, model,
+// duration, fps, resolution, aspect_ratio, style, motion_level, camera_motion, seed, api_key；查询时另有 task_id
+type HTTPAdapterClient struct {
+	Model   string
+	Adapter *httpadapter.Client
+}
+
+func NewHTTPAdapterClient(baseURL, apiKey, model, endpoint, queryEndpoint string, spec *httpadapter.Spec) *HTTPAdapterClient {
+	return &HTTPAdapterClient{
+		Model:   model,
+		Adapter: httpadapter.NewClient(baseURL, apiKey, endpoint, queryEndpoint, spec),
+	}
+}
+
+func (c *HTTPAdapterClient) GenerateVideo(imageURL, prompt string, opts ...VideoOption) (*VideoResult, error) {
+	options := &VideoOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	model := c.Model
+	if options.Model != "" {
+		model = options.Model
+	}
+
+	result, err := c.Adapter.Submit(map[string]interface{}{
+		"prompt":               prompt,
+		"image_url":            imageURL,
+		"first_frame_url":      options.FirstFrameURL,
+		"last_frame_url":       options.LastFrameURL,
+		"reference_image_urls": options.ReferenceImageURLs,
+		"model":                model,
+		"duration":             options.Duration,
+		"fps":                  options.FPS,
+		"resolution":           options.Resolution,
+		"aspect_ratio":         options.AspectRatio,
+		"style":                options.Style,
+		"motion_level":         options.MotionLevel,
+		"camera_motion":        options.CameraMotion,
+		"seed":                 options.Seed,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toVideoResult(result), nil
+}
+
+func (c *HTTPAdapterClient) GetTaskStatus(taskID string) (*VideoResult, error) {
+	result, err := c.Adapter.Query(taskID, map[string]interface{}{"model": c.Model})
+	if err != nil {
+		return nil, err
+	}
+	return toVideoResult(result), nil
+}
+
+func toVideoResult(result *httpadapter.TaskResult) *VideoResult {
+	videoResult := &VideoResult{
+		TaskID:    result.TaskID,
+		Status:    result.Status,
+		VideoURL:  result.ResultURL,
+		Completed: result.Completed,
+	}
+	if result.Status == httpadapter.StatusFailed {
+		videoResult.Error = result.Error
+	}
+	return videoResult
+}
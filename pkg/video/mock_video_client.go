@@ -0,0 +1,130 @@
+package video
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MockVideoClient 离线视频客户端，用 ffmpeg 生成测试图案短片，并模拟异步任务的排队、处理、完成过程。
+// 任务参数编码在任务ID中，服务重启后仍能继续查询
+type MockVideoClient struct {
+	OutputDir   string // 生成文件的本地目录
+	BaseURL     string // OutputDir 对应的访问URL前缀
+	Model       string
+	QueueDelay  time.Duration // 提交后保持 pending 的时长
+	RenderDelay time.Duration // 之后保持 processing 的时长
+}
+
+func NewMockVideoClient(outputDir, baseURL, model string) *MockVideoClient {
+	return &MockVideoClient{
+		OutputDir:   outputDir,
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		Model:       model,
+		QueueDelay:  2 * time.Second,
+		RenderDelay: 5 * time.Second,
+	}
+}
+
+func (c *MockVideoClient) GenerateVideo(imageURL, prompt string, opts ...VideoOption) (*VideoResult, error) {
+	options := &VideoOptions{
+		Duration:    5,
+		AspectRatio: "16:9",
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.Duration <= 0 {
+		options.Duration = 5
+	}
+
+	width, height := mockVideoDimensions(options.AspectRatio)
+	taskID := fmt.Sprintf("mock-%d-%d-%dx%d", time.Now().UnixMilli(), options.Duration, width, height)
+
+	return &VideoResult{
+		TaskID: taskID,
+		Status: "pending",
+	}, nil
+}
+
+func (c *MockVideoClient) GetTaskStatus(taskID string) (*VideoResult, error) {
+	var createdMs int64
+	var duration, width, height int
+	if _, err := fmt.Sscanf(taskID, "mock-%d-%d-%dx%d", &createdMs, &duration, &width, &height); err != nil {
+		return nil, fmt.Errorf("invalid mock task id %q: %w", taskID, err)
+	}
+
+	elapsed := time.Since(time.UnixMilli(createdMs))
+	switch {
+	case elapsed < c.QueueDelay:
+		return &VideoResult{TaskID: taskID, Status: "pending"}, nil
+	case elapsed < c.QueueDelay+c.RenderDelay:
+		return &VideoResult{TaskID: taskID, Status: "processing"}, nil
+	}
+
+	filename := taskID + ".mp4"
+	if err := c.render(filepath.Join(c.OutputDir, filename), duration, width, height); err != nil {
+		return &VideoResult{TaskID: taskID, Status: "failed", Error: err.Error()}, nil
+	}
+
+	return &VideoResult{
+		TaskID:    taskID,
+		Status:    "completed",
+		VideoURL:  c.BaseURL + "/" + filename,
+		Duration:  duration,
+		Width:     width,
+		Height:    height,
+		Completed: true,
+	}, nil
+}
+
+// render 生成带正弦音轨的测试图案视频，已存在时直接复用
+func (c *MockVideoClient) render(path string, duration, width, height int) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+
+	// 先写临时文件再改名，避免并发查询读到未写完的文件
+	tmpPath := path + ".tmp.mp4"
+	cmd := exec.Command("ffmpeg",
+		"-y",
+		"-f", "lavfi", "-i", fmt.Sprintf("testsrc2=size=%dx%d:rate=24:duration=%d", width, height, duration),
+		"-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=440:duration=%d", duration),
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-shortest",
+		tmpPath,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg render failed: %w, output: %s", err, string(output))
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// mockVideoDimensions 按画面比例给出较小的分辨率，保证渲染足够快
+func mockVideoDimensions(aspectRatio string) (int, int) {
+	var w, h int
+	if _, err := fmt.Sscanf(aspectRatio, "%d:%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		w, h = 16, 9
+	}
+	if w >= h {
+		return 640, evenDimension(640 * h / w)
+	}
+	return evenDimension(640 * w / h), 640
+}
+
+// evenDimension libx264 要求宽高为偶数
+func evenDimension(v int) int {
+	if v%2 != 0 {
+		v++
+	}
+	return v
+}
@@ -39,6 +39,7 @@ type VideoOptions struct {
 	FirstFrameURL      string
 	LastFrameURL       string
 	ReferenceImageURLs []string
+	CallbackURL        string // 支持回调的服务商在任务结束时通知该地址
 }
 
 type VideoOption func(*VideoOptions)
@@ -115,6 +116,12 @@ func WithReferenceImages(urls []string) VideoOption {
 	}
 }
 
+func WithCallbackURL(url string) VideoOption {
+	return func(o *VideoOptions) {
+		o.CallbackURL = url
+	}
+}
+
 type RunwayClient struct {
 	BaseURL    string
 	APIKey     string
@@ -31,6 +31,7 @@ type VolcesArkRequest struct {
 	Model         string             `json:"model"`
 	Content       []VolcesArkContent `json:"content"`
 	GenerateAudio bool               `json:"generate_audio,omitempty"`
+	CallbackURL   string             `json:"callback_url,omitempty"`
 }
 
 type VolcesArkResponse struct {
@@ -166,6 +167,7 @@ func (c *VolcesArkClient) GenerateVideo(imageURL, prompt string, opts ...VideoOp
 		Model:         model,
 		Content:       content,
 		GenerateAudio: generateAudio,
+		CallbackURL:   options.CallbackURL,
 	}
 
 	jsonData, err := json.Marshal(reqBody)